@@ -0,0 +1,146 @@
+package vapi
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// OtherLabel replaces label values above the cardinality limit
+const OtherLabel = "other"
+
+// AccessLogEntry is a logged request
+type AccessLogEntry struct {
+	Time     time.Time
+	Verb     string
+	Path     string // path label, OtherLabel once the cardinality limit is reached
+	Status   int
+	Duration time.Duration
+	BytesIn  int
+	BytesOut int
+	// SampleRate is the share of similar requests which are logged, 1 for every request
+	SampleRate float64
+}
+
+// AccessLog is the access log middleware with sampling and label cardinality protection.
+//
+// Failed requests (status >= 400) and requests slower than SlowThreshold are
+// always logged, successful ones are sampled with SuccessSampleRate. Paths are
+// reported through LabelLimiter, so scanners hitting random urls can't blow up
+// log indexes or metrics built from entries.
+type AccessLog struct {
+	// SuccessSampleRate is the share of successful requests logged, e.g. 0.01; 0 logs none
+	SuccessSampleRate float64
+	// SlowThreshold makes slower successful requests always logged, 0 disables it
+	SlowThreshold time.Duration
+	// Paths limits distinct path labels
+	Paths *LabelLimiter
+
+	sink    func(entry AccessLogEntry)
+	counter uint64
+}
+
+// NewAccessLog returns access log passing entries to sink, nil sink writes them with the standard logger
+func NewAccessLog(successSampleRate float64, maxPaths int, sink func(entry AccessLogEntry)) *AccessLog {
+	if sink == nil {
+		sink = func(entry AccessLogEntry) {
+			log.Printf("%s %s %d %s in=%d out=%d", entry.Verb, entry.Path, entry.Status, entry.Duration, entry.BytesIn, entry.BytesOut)
+		}
+	}
+	return &AccessLog{
+		SuccessSampleRate: successSampleRate,
+		Paths:             NewLabelLimiter(maxPaths),
+		sink:              sink,
+	}
+}
+
+// Handler wraps next with access logging
+func (al *AccessLog) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		started := time.Now()
+		next(ctx)
+		duration := time.Since(started)
+
+		status := ctx.Response.StatusCode()
+		rate := 1.0
+		if status < 400 && (al.SlowThreshold == 0 || duration < al.SlowThreshold) {
+			rate = al.SuccessSampleRate
+			if !al.sample(rate) {
+				return
+			}
+		}
+
+		al.sink(AccessLogEntry{
+			Time:       started,
+			Verb:       string(ctx.Method()),
+			Path:       al.Paths.Label(string(ctx.Path())),
+			Status:     status,
+			Duration:   duration,
+			BytesIn:    len(ctx.Request.Body()),
+			BytesOut:   responseSize(ctx),
+			SampleRate: rate,
+		})
+	}
+}
+
+// sample reports whether the request falls into the rate share, every n-th request is taken
+func (al *AccessLog) sample(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	every := uint64(1/rate + 0.5)
+	return atomic.AddUint64(&al.counter, 1)%every == 0
+}
+
+// LabelLimiter caps the number of distinct values of a metrics or log label
+type LabelLimiter struct {
+	max    int
+	mutex  sync.RWMutex
+	values map[string]struct{}
+}
+
+// NewLabelLimiter returns limiter passing the first max distinct values, 0 means no limit
+func NewLabelLimiter(max int) *LabelLimiter {
+	return &LabelLimiter{max: max, values: make(map[string]struct{})}
+}
+
+// Label returns value while the limit isn't reached or value was seen before, OtherLabel otherwise
+func (ll *LabelLimiter) Label(value string) string {
+	if ll == nil || ll.max <= 0 {
+		return value
+	}
+
+	ll.mutex.RLock()
+	_, known := ll.values[value]
+	full := len(ll.values) >= ll.max
+	ll.mutex.RUnlock()
+	if known {
+		return value
+	}
+	if full {
+		return OtherLabel
+	}
+
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+	if _, known = ll.values[value]; !known {
+		if len(ll.values) >= ll.max {
+			return OtherLabel
+		}
+		ll.values[value] = struct{}{}
+	}
+	return value
+}
+
+// Len returns the number of tracked values
+func (ll *LabelLimiter) Len() int {
+	ll.mutex.RLock()
+	defer ll.mutex.RUnlock()
+	return len(ll.values)
+}
@@ -0,0 +1,51 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestAccessLog(t *testing.T) {
+	var entries []AccessLogEntry
+	al := NewAccessLog(0.1, 2, func(entry AccessLogEntry) {
+		entries = append(entries, entry)
+	})
+	handler := al.Handler(func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) == "/fail" {
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+		}
+	})
+
+	call := func(path string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		handler(ctx)
+	}
+
+	for i := 0; i < 100; i++ {
+		call("/ok")
+	}
+	if len(entries) != 10 || entries[0].SampleRate != 0.1 {
+		t.Error(fmt.Sprintf("wrong number of sampled entries: %d", len(entries)))
+	}
+
+	entries = nil
+	for i := 0; i < 3; i++ {
+		call("/fail")
+	}
+	if len(entries) != 3 || entries[0].Status != fasthttp.StatusInternalServerError {
+		t.Error(fmt.Sprintf("errors must always be logged, got %d entries", len(entries)))
+	}
+
+	entries = nil
+	al.SuccessSampleRate = 1
+	call("/unknown")
+	if len(entries) != 1 || entries[0].Path != OtherLabel {
+		t.Error(fmt.Sprintf("wrong entries: %+v", entries))
+	}
+	if al.Paths.Label("/fail") != "/fail" || al.Paths.Len() != 2 {
+		t.Error("path labels must be capped")
+	}
+}
@@ -0,0 +1,133 @@
+package vapi
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter is a ConcurrencyLimiter which tunes the limit of the wrapped
+// PriorityLimiter from observed latencies (AIMD driven by latency gradient).
+//
+// Latencies are averaged over windows of WindowSize calls. When the window
+// average exceeds the best observed average by more than Tolerance, the limit
+// is decreased multiplicatively by Backoff; otherwise it grows by one when the
+// limit was actually reached during the window. This way the limit follows
+// the capacity of the downstream resources instead of a hand-tuned constant.
+type AdaptiveLimiter struct {
+	*PriorityLimiter
+
+	// MinLimit and MaxLimit bound the adjusted limit, the limit never drops below 1
+	MinLimit int
+	MaxLimit int
+	// WindowSize is the number of calls in one sampling window
+	WindowSize int
+	// Tolerance is the allowed ratio of window average to the best average latency, e.g. 1.5
+	Tolerance float64
+	// Backoff is the multiplier applied to the limit on latency growth, e.g. 0.9
+	Backoff float64
+	// ResetWindows is the number of windows after which the best latency is forgotten,
+	// so the limiter adapts to permanent latency changes; 0 never resets it
+	ResetWindows int
+
+	mutex      sync.Mutex
+	samples    int
+	total      time.Duration
+	saturated  bool
+	best       time.Duration
+	windows    int
+	onAdjusted func(limit int)
+}
+
+// NewAdaptiveLimiter returns limiter starting from initial limit bounded by minLimit and maxLimit
+func NewAdaptiveLimiter(initial, minLimit, maxLimit int) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		PriorityLimiter: NewPriorityLimiter(initial),
+		MinLimit:        minLimit,
+		MaxLimit:        maxLimit,
+		WindowSize:      100,
+		Tolerance:       1.5,
+		Backoff:         0.9,
+		ResetWindows:    600,
+	}
+}
+
+// OnAdjusted registers callback receiving every new limit, e.g. to export it as a metric
+func (al *AdaptiveLimiter) OnAdjusted(fn func(limit int)) {
+	al.mutex.Lock()
+	al.onAdjusted = fn
+	al.mutex.Unlock()
+}
+
+// Acquire implements ConcurrencyLimiter
+func (al *AdaptiveLimiter) Acquire(priority Priority) bool {
+	if al.PriorityLimiter.Acquire(priority) {
+		return true
+	}
+	al.mutex.Lock()
+	al.saturated = true
+	al.mutex.Unlock()
+	return false
+}
+
+// Release implements ConcurrencyLimiter
+func (al *AdaptiveLimiter) Release(priority Priority, elapsed time.Duration) {
+	if al.PriorityLimiter.InFlight() >= al.PriorityLimiter.Limit() {
+		al.mutex.Lock()
+		al.saturated = true
+		al.mutex.Unlock()
+	}
+	al.PriorityLimiter.Release(priority, elapsed)
+	al.observe(elapsed)
+}
+
+// observe adds latency sample and adjusts the limit at the end of a window
+func (al *AdaptiveLimiter) observe(elapsed time.Duration) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	al.samples++
+	al.total += elapsed
+	if al.samples < al.WindowSize {
+		return
+	}
+
+	average := al.total / time.Duration(al.samples)
+	saturated := al.saturated
+	al.samples, al.total, al.saturated = 0, 0, false
+
+	al.windows++
+	if al.ResetWindows > 0 && al.windows >= al.ResetWindows {
+		al.windows, al.best = 0, 0
+	}
+	if al.best == 0 || average < al.best {
+		al.best = average
+	}
+
+	limit := al.PriorityLimiter.Limit()
+	newLimit := limit
+	if float64(average) > float64(al.best)*al.Tolerance {
+		newLimit = int(float64(limit) * al.Backoff)
+	} else if saturated {
+		newLimit = limit + 1
+	}
+
+	// a zero limit would reject every call forever: no call completes to raise it again
+	minLimit := al.MinLimit
+	if minLimit < 1 {
+		minLimit = 1
+	}
+	if newLimit < minLimit {
+		newLimit = minLimit
+	}
+	if al.MaxLimit > 0 && newLimit > al.MaxLimit {
+		newLimit = al.MaxLimit
+	}
+	if newLimit == limit {
+		return
+	}
+
+	al.PriorityLimiter.SetLimit(newLimit)
+	if al.onAdjusted != nil {
+		al.onAdjusted(newLimit)
+	}
+}
@@ -0,0 +1,59 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	al := NewAdaptiveLimiter(2, 1, 3)
+	al.WindowSize = 2
+
+	// saturated window with normal latency grows the limit
+	al.Acquire(PriorityNormal)
+	al.Acquire(PriorityNormal)
+	if al.Acquire(PriorityNormal) {
+		t.Fatal("limit must be reached")
+	}
+	al.Release(PriorityNormal, 10*time.Millisecond)
+	al.Release(PriorityNormal, 10*time.Millisecond)
+	if al.Limit() != 3 {
+		t.Error(fmt.Sprintf("limit must grow on saturation, got %d", al.Limit()))
+	}
+
+	// unsaturated window keeps the limit
+	al.Acquire(PriorityNormal)
+	al.Release(PriorityNormal, 10*time.Millisecond)
+	al.Acquire(PriorityNormal)
+	al.Release(PriorityNormal, 10*time.Millisecond)
+	if al.Limit() != 3 {
+		t.Error(fmt.Sprintf("limit must not change without saturation, got %d", al.Limit()))
+	}
+
+	// latency growth decreases the limit
+	al.Acquire(PriorityNormal)
+	al.Release(PriorityNormal, 50*time.Millisecond)
+	al.Acquire(PriorityNormal)
+	al.Release(PriorityNormal, 50*time.Millisecond)
+	if al.Limit() != 2 {
+		t.Error(fmt.Sprintf("limit must decrease on latency growth, got %d", al.Limit()))
+	}
+}
+
+func TestAdaptiveLimiter_MinLimit(t *testing.T) {
+	al := NewAdaptiveLimiter(1, 0, 0)
+	al.WindowSize = 1
+
+	al.Acquire(PriorityNormal)
+	al.Release(PriorityNormal, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if !al.Acquire(PriorityNormal) {
+			t.Fatal("limit must never drop to zero")
+		}
+		al.Release(PriorityNormal, time.Second)
+	}
+	if al.Limit() != 1 {
+		t.Error(fmt.Sprintf("limit must stay at 1, got %d", al.Limit()))
+	}
+}
@@ -0,0 +1,123 @@
+package vapi
+
+import (
+	"context"
+	"sync"
+)
+
+// AMQPMethodHeader is the message header naming the method when the message type property is empty
+const AMQPMethodHeader = "vapi-method"
+
+// AMQPDelivery is a consumed message, Ack and Reject settle it with the broker
+type AMQPDelivery struct {
+	Type          string
+	CorrelationID string
+	ReplyTo       string
+	Headers       map[string]interface{}
+	Body          []byte
+	Redelivered   bool
+
+	Ack    func() error
+	Reject func(requeue bool) error
+}
+
+// AMQPPublishing is a reply message
+type AMQPPublishing struct {
+	CorrelationID string
+	ContentType   string
+	Headers       map[string]interface{}
+	Body          []byte
+}
+
+// AMQPChannel is a thin adapter over an AMQP client channel, e.g. amqp091-go.
+// Consume must set channel QoS to prefetch before consuming with manual acks.
+type AMQPChannel interface {
+	Consume(queue string, prefetch int) (<-chan AMQPDelivery, error)
+	Publish(ctx context.Context, exchange, routingKey string, msg AMQPPublishing) error
+}
+
+// AMQPServer serves registered methods with the RPC request/reply-queue pattern.
+//
+// The method is taken from the message type property (or AMQPMethodHeader),
+// args from the body, and the response envelope is published to the ReplyTo
+// queue with the request correlation id. Prefetch messages are processed
+// concurrently. Poison messages (no method, unknown method, or server errors of
+// redelivered messages) are rejected without requeue, so the broker moves them
+// to the dead letter exchange configured for the queue; server errors of first
+// deliveries are requeued once.
+type AMQPServer struct {
+	as       *VAPI
+	channel  AMQPChannel
+	queue    string
+	prefetch int
+}
+
+// NewAMQPServer returns server consuming queue with prefetch concurrency
+func (as *VAPI) NewAMQPServer(channel AMQPChannel, queue string, prefetch int) *AMQPServer {
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	return &AMQPServer{as: as, channel: channel, queue: queue, prefetch: prefetch}
+}
+
+// Run consumes the queue until ctx is done or the delivery channel is closed
+func (s *AMQPServer) Run(ctx context.Context) error {
+	deliveries, err := s.channel.Consume(s.queue, s.prefetch)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.prefetch; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery, ok := <-deliveries:
+					if !ok {
+						return
+					}
+					s.handle(ctx, delivery)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// handle invokes the method of delivery, replies and settles it
+func (s *AMQPServer) handle(ctx context.Context, delivery AMQPDelivery) {
+	method := delivery.Type
+	if method == "" {
+		method, _ = delivery.Headers[AMQPMethodHeader].(string)
+	}
+	if _, err := s.as.get(method); err != nil {
+		delivery.Reject(false)
+		return
+	}
+
+	status, body, _ := s.as.callLocal(method, delivery.Body)
+	if status >= 500 {
+		// retry once, then dead-letter
+		delivery.Reject(!delivery.Redelivered)
+		return
+	}
+
+	if delivery.ReplyTo != "" {
+		err := s.channel.Publish(ctx, "", delivery.ReplyTo, AMQPPublishing{
+			CorrelationID: delivery.CorrelationID,
+			ContentType:   "application/json",
+			Headers:       map[string]interface{}{"vapi-status": int32(status)},
+			Body:          body,
+		})
+		if err != nil {
+			delivery.Reject(true)
+			return
+		}
+	}
+	delivery.Ack()
+}
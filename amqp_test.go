@@ -0,0 +1,156 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FlakyAPI fails every call with a server error
+type FlakyAPI struct{}
+
+func (f *FlakyAPI) Fail(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return &Error{ErrorHTTPCode: fasthttp.StatusServiceUnavailable, ErrorMessage: "backend is down"}
+}
+
+type fakeAMQP struct {
+	mutex     sync.Mutex
+	pending   []AMQPDelivery
+	published map[string]AMQPPublishing
+	settled   map[string]string
+	// publishErr (if any) is returned by Publish
+	publishErr error
+}
+
+// delivery returns delivery settling into fa.settled by correlation id
+func (fa *fakeAMQP) delivery(d AMQPDelivery) AMQPDelivery {
+	settle := func(outcome string) {
+		fa.mutex.Lock()
+		fa.settled[d.CorrelationID] = outcome
+		fa.mutex.Unlock()
+	}
+	d.Ack = func() error {
+		settle("ack")
+		return nil
+	}
+	d.Reject = func(requeue bool) error {
+		if requeue {
+			settle("requeue")
+		} else {
+			settle("dead-letter")
+		}
+		return nil
+	}
+	return d
+}
+
+func (fa *fakeAMQP) Consume(queue string, prefetch int) (<-chan AMQPDelivery, error) {
+	deliveries := make(chan AMQPDelivery, len(fa.pending))
+	for _, d := range fa.pending {
+		deliveries <- fa.delivery(d)
+	}
+	close(deliveries)
+	return deliveries, nil
+}
+
+func (fa *fakeAMQP) Publish(ctx context.Context, exchange, routingKey string, msg AMQPPublishing) error {
+	if fa.publishErr != nil {
+		return fa.publishErr
+	}
+	fa.mutex.Lock()
+	fa.published[routingKey+"/"+msg.CorrelationID] = msg
+	fa.mutex.Unlock()
+	return nil
+}
+
+func newFakeAMQP(deliveries ...AMQPDelivery) *fakeAMQP {
+	return &fakeAMQP{pending: deliveries, published: map[string]AMQPPublishing{}, settled: map[string]string{}}
+}
+
+func TestAMQPServer(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RegisterService(new(FlakyAPI), "flaky"); err != nil {
+		t.Fatal(err)
+	}
+
+	fa := newFakeAMQP(
+		AMQPDelivery{Type: "demo.Test", CorrelationID: "ok", ReplyTo: "replies", Body: []byte(`{"id":"1"}`)},
+		AMQPDelivery{Headers: map[string]interface{}{AMQPMethodHeader: "demo.ErrorTest"}, CorrelationID: "method-error", ReplyTo: "replies", Body: []byte(`{}`)},
+		AMQPDelivery{Type: "demo.Test", CorrelationID: "no-reply", Body: []byte(`{}`)},
+		AMQPDelivery{CorrelationID: "no-method", ReplyTo: "replies"},
+		AMQPDelivery{Type: "demo.Missing", CorrelationID: "unknown", ReplyTo: "replies"},
+		AMQPDelivery{Type: "flaky.Fail", CorrelationID: "first-failure", ReplyTo: "replies", Body: []byte(`{}`)},
+		AMQPDelivery{Type: "flaky.Fail", CorrelationID: "redelivered-failure", ReplyTo: "replies", Body: []byte(`{}`), Redelivered: true},
+	)
+	if err := as.NewAMQPServer(fa, "rpc", 3).Run(context.Background()); err != nil {
+		t.Error(fmt.Sprintf("server must stop cleanly when deliveries end: %v", err))
+	}
+
+	expected := map[string]string{
+		"ok":                  "ack",
+		"method-error":        "ack",
+		"no-reply":            "ack",
+		"no-method":           "dead-letter",
+		"unknown":             "dead-letter",
+		"first-failure":       "requeue",
+		"redelivered-failure": "dead-letter",
+	}
+	for id, outcome := range expected {
+		if fa.settled[id] != outcome {
+			t.Error(fmt.Sprintf("delivery %s: expected %s, got %q", id, outcome, fa.settled[id]))
+		}
+	}
+
+	if len(fa.published) != 2 {
+		t.Error(fmt.Sprintf("only replies of settled calls must be published: %+v", fa.published))
+	}
+	reply := fa.published["replies/ok"]
+	if string(reply.Body) != `{"response":{"id":"1"}}` || reply.Headers["vapi-status"] != int32(200) || reply.ContentType != "application/json" {
+		t.Error(fmt.Sprintf("wrong reply: %+v", reply))
+	}
+	if status := fa.published["replies/method-error"].Headers["vapi-status"]; status != int32(fasthttp.StatusFailedDependency) {
+		t.Error(fmt.Sprintf("method errors must be replied with their status: %v", status))
+	}
+}
+
+func TestAMQPServer_PublishFailure(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	fa := newFakeAMQP(AMQPDelivery{Type: "demo.Test", CorrelationID: "c1", ReplyTo: "replies", Body: []byte(`{}`)})
+	fa.publishErr = errors.New("channel closed")
+	as.NewAMQPServer(fa, "rpc", 1).Run(context.Background())
+
+	if fa.settled["c1"] != "requeue" {
+		t.Error(fmt.Sprintf("delivery must be requeued when the reply can't be published, got %q", fa.settled["c1"]))
+	}
+}
+
+func TestAMQPServer_Cancel(t *testing.T) {
+	as := NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	blocking := &blockingAMQP{fakeAMQP: newFakeAMQP()}
+	if err := as.NewAMQPServer(blocking, "rpc", 2).Run(ctx); err != context.Canceled {
+		t.Error(fmt.Sprintf("server must stop with context error, got %v", err))
+	}
+}
+
+// blockingAMQP never delivers and never closes the delivery channel
+type blockingAMQP struct {
+	*fakeAMQP
+}
+
+func (ba *blockingAMQP) Consume(queue string, prefetch int) (<-chan AMQPDelivery, error) {
+	return make(chan AMQPDelivery), nil
+}
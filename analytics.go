@@ -0,0 +1,186 @@
+package vapi
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// deprecatedUserValue is the RequestCtx user value key of deprecated features used by the call
+const deprecatedUserValue = "vapi.deprecated"
+
+// clientVariantsKept caps distinct user agents and fingerprints kept per client
+const clientVariantsKept = 16
+
+// ClientUsage is the usage of the api by a client
+type ClientUsage struct {
+	// Client is the principal, or "fingerprint:" and RequestFingerprint for anonymous calls
+	Client    string    `json:"client"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Calls     uint64    `json:"calls"`
+	Errors    uint64    `json:"errors"`
+	BytesIn   uint64    `json:"bytes_in"`
+	BytesOut  uint64    `json:"bytes_out"`
+	// Methods counts calls per method
+	Methods map[string]uint64 `json:"methods"`
+	// Deprecated counts calls per deprecated feature used
+	Deprecated map[string]uint64 `json:"deprecated,omitempty"`
+	// UserAgents and Fingerprints count calls per client software, up to 16 of each
+	UserAgents   map[string]uint64 `json:"user_agents,omitempty"`
+	Fingerprints map[string]uint64 `json:"fingerprints,omitempty"`
+}
+
+// clientAnalytics aggregates usage per client
+type clientAnalytics struct {
+	principal PrincipalFunc
+	clients   *LabelLimiter
+
+	mutex sync.Mutex
+	usage map[string]*ClientUsage
+}
+
+// SetClientAnalytics aggregates usage of every client identified by principal
+// (e.g. the api key), up to maxClients of them (0 for no limit), the rest are
+// accounted as OtherLabel. nil principal disables analytics.
+func (as *VAPI) SetClientAnalytics(principal PrincipalFunc, maxClients int) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if principal == nil {
+		as.analytics = nil
+		return
+	}
+	as.analytics = &clientAnalytics{principal: principal, clients: NewLabelLimiter(maxClients), usage: map[string]*ClientUsage{}}
+}
+
+// MarkDeprecated records that the call uses a deprecated feature, e.g. an old
+// args field, so clients can be notified before it is removed. The framework
+// marks reply versions and args transforms itself.
+func MarkDeprecated(ctx *fasthttp.RequestCtx, feature string) {
+	features, _ := ctx.UserValue(deprecatedUserValue).([]string)
+	ctx.SetUserValue(deprecatedUserValue, append(features, feature))
+}
+
+// ClientUsages returns usage of clients matching filter (nil for all), the most active first
+func (as *VAPI) ClientUsages(filter func(usage ClientUsage) bool) []ClientUsage {
+	as.mutex.RLock()
+	analytics := as.analytics
+	as.mutex.RUnlock()
+	if analytics == nil {
+		return nil
+	}
+
+	analytics.mutex.Lock()
+	usages := make([]ClientUsage, 0, len(analytics.usage))
+	for _, usage := range analytics.usage {
+		copied := *usage
+		copied.Methods, copied.Deprecated = copyCounts(usage.Methods), copyCounts(usage.Deprecated)
+		copied.UserAgents, copied.Fingerprints = copyCounts(usage.UserAgents), copyCounts(usage.Fingerprints)
+		if filter == nil || filter(copied) {
+			usages = append(usages, copied)
+		}
+	}
+	analytics.mutex.Unlock()
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Calls != usages[j].Calls {
+			return usages[i].Calls > usages[j].Calls
+		}
+		return usages[i].Client < usages[j].Client
+	})
+	return usages
+}
+
+// ClientsHandler serves ClientUsages as json, mount it behind admin authentication.
+// "?client=ID" selects a client, "?method=Service.Method" clients calling the
+// method and "?deprecated=FEATURE" clients using the deprecated feature.
+func (as *VAPI) ClientsHandler(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+	client, method, deprecated := string(args.Peek("client")), string(args.Peek("method")), string(args.Peek("deprecated"))
+	usages := as.ClientUsages(func(usage ClientUsage) bool {
+		return (client == "" || usage.Client == client) &&
+			(method == "" || usage.Methods[method] > 0) &&
+			(deprecated == "" || usage.Deprecated[deprecated] > 0)
+	})
+	if usages == nil {
+		usages = []ClientUsage{}
+	}
+	body, err := json.Marshal(usages)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// record accounts the finished call to its client
+func (analytics *clientAnalytics) record(ctx *fasthttp.RequestCtx, method string) {
+	if analytics == nil {
+		return
+	}
+
+	fingerprint := RequestFingerprint(ctx)
+	client := analytics.principal(ctx)
+	if client == "" {
+		client = "fingerprint:" + fingerprint
+	}
+	client = analytics.clients.Label(client)
+	status := ctx.Response.StatusCode()
+	userAgent := string(ctx.Request.Header.UserAgent())
+	deprecated, _ := ctx.UserValue(deprecatedUserValue).([]string)
+	now := time.Now()
+
+	analytics.mutex.Lock()
+	defer analytics.mutex.Unlock()
+	usage := analytics.usage[client]
+	if usage == nil {
+		usage = &ClientUsage{Client: client, FirstSeen: now, Methods: map[string]uint64{}}
+		analytics.usage[client] = usage
+	}
+	usage.LastSeen = now
+	usage.Calls++
+	if status >= 400 {
+		usage.Errors++
+	}
+	usage.BytesIn += uint64(len(ctx.Request.Body()))
+	usage.BytesOut += uint64(responseSize(ctx))
+	usage.Methods[method]++
+	for _, feature := range deprecated {
+		if usage.Deprecated == nil {
+			usage.Deprecated = map[string]uint64{}
+		}
+		usage.Deprecated[feature]++
+	}
+	usage.UserAgents = countVariant(usage.UserAgents, userAgent)
+	usage.Fingerprints = countVariant(usage.Fingerprints, fingerprint)
+}
+
+// countVariant counts value in counts holding up to clientVariantsKept values
+func countVariant(counts map[string]uint64, value string) map[string]uint64 {
+	if value == "" {
+		return counts
+	}
+	if counts == nil {
+		counts = map[string]uint64{}
+	}
+	if _, ok := counts[value]; ok || len(counts) < clientVariantsKept {
+		counts[value]++
+	}
+	return counts
+}
+
+// copyCounts returns a copy of counts, nil for empty ones
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	copied := make(map[string]uint64, len(counts))
+	for key, value := range counts {
+		copied[key] = value
+	}
+	return copied
+}
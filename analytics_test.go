@@ -0,0 +1,72 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_ClientsHandler(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.SetClientAnalytics(HeaderPrincipal("X-Api-Key"), 0)
+	if err := as.SetMethodArgsTransforms("demo.Test", ArgsTransform{Rename: map[string]string{"name": "ttt"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method, body, key string) {
+		as.callLocalWith(method, []byte(body), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.SetUserAgent("sdk/1.0")
+			if key != "" {
+				ctx.Request.Header.Set("X-Api-Key", key)
+			}
+		})
+	}
+	call("demo.Test", `{"id":"1","ttt":"x"}`, "alice")
+	call("demo.Test", `{"id":"1","name":"x"}`, "alice")
+	call("demo.ErrorTest", `{"id":"1"}`, "alice")
+	call("demo.Test", `{"id":"1"}`, "bob")
+	call("demo.Test", `{"id":"1"}`, "")
+
+	usages := as.ClientUsages(nil)
+	if len(usages) != 3 {
+		t.Fatal(fmt.Sprintf("expected 3 clients, got %d", len(usages)))
+	}
+	alice := usages[0]
+	if alice.Client != "alice" || alice.Calls != 3 || alice.Errors != 1 || alice.Methods["demo.Test"] != 2 {
+		t.Error(fmt.Sprintf("unexpected usage: %+v", alice))
+	}
+	if alice.Deprecated["demo.Test args name"] != 1 || alice.UserAgents["sdk/1.0"] != 3 || alice.BytesIn == 0 || alice.BytesOut == 0 {
+		t.Error(fmt.Sprintf("unexpected usage: %+v", alice))
+	}
+	anonymous := false
+	for _, usage := range usages {
+		anonymous = anonymous || strings.HasPrefix(usage.Client, "fingerprint:")
+	}
+	if !anonymous {
+		t.Error("anonymous calls must be accounted by fingerprint")
+	}
+
+	query := func(query string) []ClientUsage {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/clients?" + query)
+		as.ClientsHandler(ctx)
+		var usages []ClientUsage
+		if err := json.Unmarshal(ctx.Response.Body(), &usages); err != nil {
+			t.Fatal(err)
+		}
+		return usages
+	}
+	if usages := query("deprecated=demo.Test+args+name"); len(usages) != 1 || usages[0].Client != "alice" {
+		t.Error(fmt.Sprintf("unexpected deprecated usages: %+v", usages))
+	}
+	if usages := query("method=demo.ErrorTest"); len(usages) != 1 {
+		t.Error(fmt.Sprintf("unexpected method usages: %+v", usages))
+	}
+	if usages := query("client=carol"); len(usages) != 0 {
+		t.Error(fmt.Sprintf("unexpected client usages: %+v", usages))
+	}
+}
@@ -0,0 +1,364 @@
+package vapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// APIChange is a difference between two OpenAPI documents
+type APIChange struct {
+	// Path locates the change, e.g. "/api/user.Get request.address.city"
+	Path string
+	// Message describes the change
+	Message string
+	// Breaking is true when existing clients may fail after the change
+	Breaking bool
+}
+
+// String returns change description
+func (c APIChange) String() string {
+	kind := "info"
+	if c.Breaking {
+		kind = "BREAKING"
+	}
+	return fmt.Sprintf("[%s] %s: %s", kind, c.Path, c.Message)
+}
+
+// schemaDirection tells whether schema is sent by clients or received by them
+type schemaDirection int
+
+const (
+	directionRequest schemaDirection = iota
+	directionResponse
+)
+
+// DiffOpenAPI compares two documents generated by VAPI.OpenAPI and reports
+// removed methods and verbs, type changes, removed reply fields, new required
+// args fields, narrowed args enums and oneOf variants and widened reply ones.
+// Operations are compared per verb, args of the request body and query parameters
+// alike, changes of them are located as "path VERB".
+func DiffOpenAPI(oldDoc, newDoc *OpenAPIDocument) []APIChange {
+	d := &apiDiff{oldDoc: oldDoc, newDoc: newDoc}
+
+	for _, path := range sortedPathKeys(oldDoc.Paths, newDoc.Paths) {
+		oldPath, inOld := oldDoc.Paths[path]
+		newPath, inNew := newDoc.Paths[path]
+		switch {
+		case !inNew:
+			d.add(path, "method removed", true)
+		case !inOld:
+			d.add(path, "method added", false)
+		default:
+			oldOps, newOps := oldPath.operations(), newPath.operations()
+			for _, verb := range pathVerbs {
+				oldOp, newOp := oldOps[verb], newOps[verb]
+				switch {
+				case oldOp == nil && newOp == nil:
+				case newOp == nil:
+					d.add(path+" "+verb, "verb removed", true)
+				case oldOp == nil:
+					d.add(path+" "+verb, "verb added", false)
+				default:
+					d.operation(path+" "+verb, oldOp, newOp)
+				}
+			}
+		}
+	}
+
+	return d.changes
+}
+
+// pathVerbs are the verbs of OpenAPIPath operations in the order they are compared
+var pathVerbs = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// operations returns operations of the path item by verb
+func (p *OpenAPIPath) operations() map[string]*OpenAPIOperation {
+	return map[string]*OpenAPIOperation{"GET": p.Get, "POST": p.Post, "PUT": p.Put, "PATCH": p.Patch, "DELETE": p.Delete}
+}
+
+// HasBreakingChanges reports whether any of changes is breaking
+func HasBreakingChanges(changes []APIChange) bool {
+	for _, change := range changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// apiDiff collects changes between documents
+type apiDiff struct {
+	oldDoc  *OpenAPIDocument
+	newDoc  *OpenAPIDocument
+	changes []APIChange
+}
+
+func (d *apiDiff) add(path, message string, breaking bool) {
+	d.changes = append(d.changes, APIChange{Path: path, Message: message, Breaking: breaking})
+}
+
+// operation compares request body, parameter and success response schemas of a method,
+// parameters are located by name, e.g. "/user.Get GET args.id"
+func (d *apiDiff) operation(path string, oldOp, newOp *OpenAPIOperation) {
+	d.schema(path+" request", bodySchema(oldOp.RequestBody), bodySchema(newOp.RequestBody), directionRequest, map[string]bool{})
+
+	oldParams, newParams := parameterSet(oldOp.Parameters), parameterSet(newOp.Parameters)
+	for _, name := range sortedParameterKeys(oldParams, newParams) {
+		oldParam, inOld := oldParams[name]
+		newParam, inNew := newParams[name]
+		switch {
+		case !inNew:
+			d.add(path+" "+name, "parameter removed", true)
+		case !inOld:
+			d.add(path+" "+name, "parameter added", false)
+		default:
+			d.schema(path+" "+name, mediaSchema(oldParam.Content), mediaSchema(newParam.Content), directionRequest, map[string]bool{})
+		}
+	}
+
+	var oldReply, newReply *Schema
+	if r := oldOp.Responses["200"]; r != nil {
+		oldReply = mediaSchema(r.Content)
+	}
+	if r := newOp.Responses["200"]; r != nil {
+		newReply = mediaSchema(r.Content)
+	}
+	d.schema(path+" reply", oldReply, newReply, directionResponse, map[string]bool{})
+}
+
+// schema compares two schemas recursively
+func (d *apiDiff) schema(path string, oldSchema, newSchema *Schema, direction schemaDirection, seen map[string]bool) {
+	if oldSchema == nil || newSchema == nil {
+		if oldSchema != newSchema {
+			d.add(path, "body schema changed", true)
+		}
+		return
+	}
+
+	// every pair of referenced types is compared once, which also stops self referencing types
+	if oldSchema.Ref != "" && newSchema.Ref != "" {
+		key := fmt.Sprintf("%s|%s|%d", oldSchema.Ref, newSchema.Ref, direction)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+	}
+
+	oldSchema = resolveSchema(d.oldDoc, oldSchema)
+	newSchema = resolveSchema(d.newDoc, newSchema)
+
+	if oldSchema.Type != newSchema.Type {
+		d.add(path, fmt.Sprintf("type changed from %q to %q", oldSchema.Type, newSchema.Type), true)
+		return
+	}
+	if oldSchema.Format != newSchema.Format {
+		d.add(path, fmt.Sprintf("format changed from %q to %q", oldSchema.Format, newSchema.Format), true)
+	}
+	if !oldSchema.Deprecated && newSchema.Deprecated {
+		d.add(path, "deprecated", false)
+	}
+
+	d.enum(path, oldSchema.Enum, newSchema.Enum, direction)
+	d.oneOf(path, oldSchema.OneOf, newSchema.OneOf, direction, seen)
+
+	if oldSchema.Items != nil || newSchema.Items != nil {
+		d.schema(path+"[]", oldSchema.Items, newSchema.Items, direction, seen)
+	}
+	if oldSchema.AdditionalProperties != nil || newSchema.AdditionalProperties != nil {
+		d.schema(path+"{}", oldSchema.AdditionalProperties, newSchema.AdditionalProperties, direction, seen)
+	}
+
+	oldRequired := stringSet(oldSchema.Required)
+	newRequired := stringSet(newSchema.Required)
+
+	for _, name := range sortedSchemaKeys(oldSchema.Properties, newSchema.Properties) {
+		oldProp, inOld := oldSchema.Properties[name]
+		newProp, inNew := newSchema.Properties[name]
+		propPath := path + "." + name
+
+		switch {
+		case !inNew:
+			// clients may rely on reply fields, unknown args fields are ignored by decoders
+			d.add(propPath, "field removed", direction == directionResponse)
+		case !inOld:
+			d.add(propPath, "field added", direction == directionRequest && newRequired[name])
+		default:
+			if direction == directionRequest && !oldRequired[name] && newRequired[name] {
+				d.add(propPath, "field became required", true)
+			}
+			if direction == directionResponse && oldRequired[name] && !newRequired[name] {
+				d.add(propPath, "field became optional", true)
+			}
+			d.schema(propPath, oldProp, newProp, direction, seen)
+		}
+	}
+}
+
+// enum compares allowed values: fewer args values and more reply values break clients
+func (d *apiDiff) enum(path string, oldEnum, newEnum []interface{}, direction schemaDirection) {
+	if len(oldEnum) == 0 && len(newEnum) == 0 {
+		return
+	}
+	oldValues, newValues := enumSet(oldEnum), enumSet(newEnum)
+	if len(oldEnum) == 0 {
+		d.add(path, "values restricted to enum", direction == directionRequest)
+		return
+	}
+	if len(newEnum) == 0 {
+		d.add(path, "enum restriction removed", direction == directionResponse)
+		return
+	}
+	for _, value := range oldEnum {
+		if key := fmt.Sprint(value); !newValues[key] {
+			d.add(path, fmt.Sprintf("enum value %q removed", key), direction == directionRequest)
+		}
+	}
+	for _, value := range newEnum {
+		if key := fmt.Sprint(value); !oldValues[key] {
+			d.add(path, fmt.Sprintf("enum value %q added", key), direction == directionResponse)
+		}
+	}
+}
+
+// oneOf compares variants matched by $ref (position for inline ones): fewer args
+// variants and more reply variants break clients
+func (d *apiDiff) oneOf(path string, oldVariants, newVariants []*Schema, direction schemaDirection, seen map[string]bool) {
+	if len(oldVariants) == 0 && len(newVariants) == 0 {
+		return
+	}
+	newByKey := make(map[string]*Schema, len(newVariants))
+	for i, variant := range newVariants {
+		newByKey[variantKey(i, variant)] = variant
+	}
+	oldKeys := make(map[string]bool, len(oldVariants))
+	for i, variant := range oldVariants {
+		key := variantKey(i, variant)
+		oldKeys[key] = true
+		if newVariant, ok := newByKey[key]; ok {
+			d.schema(path+"|"+key, variant, newVariant, direction, seen)
+		} else {
+			d.add(path, fmt.Sprintf("oneOf variant %s removed", key), direction == directionRequest)
+		}
+	}
+	for i, variant := range newVariants {
+		if key := variantKey(i, variant); !oldKeys[key] {
+			d.add(path, fmt.Sprintf("oneOf variant %s added", key), direction == directionResponse)
+		}
+	}
+}
+
+// variantKey identifies oneOf variant by its referenced schema name or position
+func variantKey(i int, variant *Schema) string {
+	if variant.Ref != "" {
+		return strings.TrimPrefix(variant.Ref, "#/components/schemas/")
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// enumSet converts enum values to the set of their string forms
+func enumSet(values []interface{}) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[fmt.Sprint(value)] = true
+	}
+	return set
+}
+
+// resolveSchema follows $ref and single allOf wrappers
+func resolveSchema(doc *OpenAPIDocument, schema *Schema) *Schema {
+	for i := 0; i < 32; i++ {
+		switch {
+		case schema.Ref != "":
+			resolved, ok := doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+			if !ok {
+				return schema
+			}
+			schema = resolved
+		case len(schema.AllOf) == 1 && schema.Type == "":
+			schema = schema.AllOf[0]
+		default:
+			return schema
+		}
+	}
+	return schema
+}
+
+// bodySchema returns json schema of request body
+func bodySchema(body *OpenAPIBody) *Schema {
+	if body == nil {
+		return nil
+	}
+	return mediaSchema(body.Content)
+}
+
+// parameterSet indexes parameters by name
+func parameterSet(params []*OpenAPIParameter) map[string]*OpenAPIParameter {
+	set := make(map[string]*OpenAPIParameter, len(params))
+	for _, param := range params {
+		set[param.Name] = param
+	}
+	return set
+}
+
+// sortedParameterKeys returns sorted union of parameter names
+func sortedParameterKeys(a, b map[string]*OpenAPIParameter) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mediaSchema returns json schema of content
+func mediaSchema(content map[string]*OpenAPIMediaType) *Schema {
+	if media, ok := content["application/json"]; ok {
+		return media.Schema
+	}
+	return nil
+}
+
+// stringSet converts list to set
+func stringSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, v := range list {
+		set[v] = true
+	}
+	return set
+}
+
+// sortedPathKeys returns sorted union of keys
+func sortedPathKeys(a, b map[string]*OpenAPIPath) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedSchemaKeys returns sorted union of keys
+func sortedSchemaKeys(a, b map[string]*Schema) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffOpenAPI(t *testing.T) {
+	object := func(required []string, props map[string]*Schema) *Schema {
+		return &Schema{Type: "object", Properties: props, Required: required}
+	}
+	document := func(args, reply *Schema, extraMethod bool) *OpenAPIDocument {
+		op := &OpenAPIOperation{
+			RequestBody: &OpenAPIBody{Content: jsonContent(args)},
+			Responses:   map[string]*OpenAPIResponse{"200": {Content: jsonContent(reply)}},
+		}
+		doc := &OpenAPIDocument{Paths: map[string]*OpenAPIPath{"/user.Get": {Post: op}}}
+		if extraMethod {
+			doc.Paths["/user.Delete"] = &OpenAPIPath{Post: op}
+		}
+		return doc
+	}
+
+	oldDoc := document(
+		object(nil, map[string]*Schema{"id": {Type: "string"}}),
+		object(nil, map[string]*Schema{"name": {Type: "string"}, "age": {Type: "integer"}}),
+		true,
+	)
+	newDoc := document(
+		object([]string{"id", "token"}, map[string]*Schema{"id": {Type: "string"}, "token": {Type: "string"}}),
+		object(nil, map[string]*Schema{"name": {Type: "string"}, "age": {Type: "string"}, "email": {Type: "string"}}),
+		false,
+	)
+
+	var got []string
+	for _, change := range DiffOpenAPI(oldDoc, newDoc) {
+		got = append(got, change.String())
+	}
+
+	expected := []string{
+		"[BREAKING] /user.Delete: method removed",
+		"[BREAKING] /user.Get POST request.id: field became required",
+		"[BREAKING] /user.Get POST request.token: field added",
+		"[BREAKING] /user.Get POST reply.age: type changed from \"integer\" to \"string\"",
+		"[info] /user.Get POST reply.email: field added",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Error(fmt.Sprintf("wrong changes:\n%s", strings.Join(got, "\n")))
+	}
+}
+
+func TestDiffOpenAPI_Verbs(t *testing.T) {
+	operation := func(args, reply *Schema) *OpenAPIOperation {
+		return &OpenAPIOperation{
+			RequestBody: &OpenAPIBody{Content: jsonContent(args)},
+			Responses:   map[string]*OpenAPIResponse{"200": {Content: jsonContent(reply)}},
+		}
+	}
+	kind := func(values ...interface{}) *Schema {
+		return &Schema{Type: "object", Properties: map[string]*Schema{"kind": {Type: "string", Enum: values}}}
+	}
+	union := func(refs ...string) *Schema {
+		schema := &Schema{}
+		for _, ref := range refs {
+			schema.OneOf = append(schema.OneOf, &Schema{Ref: "#/components/schemas/" + ref})
+		}
+		return schema
+	}
+	components := OpenAPIComponents{Schemas: map[string]*Schema{"Card": {Type: "object"}, "Cash": {Type: "object"}, "Wire": {Type: "object"}}}
+
+	oldDoc := &OpenAPIDocument{Components: components, Paths: map[string]*OpenAPIPath{"/pay.Get": {
+		Get:  operation(kind("a", "b"), kind("a")),
+		Post: operation(union("Card", "Cash"), union("Card")),
+	}}}
+	newDoc := &OpenAPIDocument{Components: components, Paths: map[string]*OpenAPIPath{"/pay.Get": {
+		Get: operation(kind("a"), kind("a", "c")),
+		Put: operation(union("Card", "Wire"), union("Card", "Wire")),
+	}}}
+
+	var got []string
+	for _, change := range DiffOpenAPI(oldDoc, newDoc) {
+		got = append(got, change.String())
+	}
+	expected := []string{
+		"[BREAKING] /pay.Get GET request.kind: enum value \"b\" removed",
+		"[BREAKING] /pay.Get GET reply.kind: enum value \"c\" added",
+		"[BREAKING] /pay.Get POST: verb removed",
+		"[info] /pay.Get PUT: verb added",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Error(fmt.Sprintf("wrong changes:\n%s", strings.Join(got, "\n")))
+	}
+
+	newDoc.Paths["/pay.Get"].Post = newDoc.Paths["/pay.Get"].Put
+	got = nil
+	for _, change := range DiffOpenAPI(oldDoc, newDoc) {
+		if strings.Contains(change.Path, "POST") {
+			got = append(got, change.String())
+		}
+	}
+	expected = []string{
+		"[BREAKING] /pay.Get POST request: oneOf variant Cash removed",
+		"[info] /pay.Get POST request: oneOf variant Wire added",
+		"[BREAKING] /pay.Get POST reply: oneOf variant Wire added",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Error(fmt.Sprintf("wrong oneOf changes:\n%s", strings.Join(got, "\n")))
+	}
+}
+
+func TestDiffOpenAPI_Parameters(t *testing.T) {
+	operation := func(args *Schema) *OpenAPIOperation {
+		return &OpenAPIOperation{
+			Parameters: []*OpenAPIParameter{{Name: ArgsParam, In: "query", Content: jsonContent(args)}},
+			Responses:  map[string]*OpenAPIResponse{"200": {Content: jsonContent(&Schema{Type: "object"})}},
+		}
+	}
+	oldDoc := &OpenAPIDocument{Paths: map[string]*OpenAPIPath{"/user.Get": {
+		Get:    operation(&Schema{Type: "object", Properties: map[string]*Schema{"id": {Type: "string"}}}),
+		Delete: operation(&Schema{Type: "object"}),
+	}}}
+	newDoc := &OpenAPIDocument{Paths: map[string]*OpenAPIPath{"/user.Get": {
+		Get:    operation(&Schema{Type: "object", Required: []string{"id"}, Properties: map[string]*Schema{"id": {Type: "integer"}}}),
+		Delete: &OpenAPIOperation{Responses: map[string]*OpenAPIResponse{"200": {Content: jsonContent(&Schema{Type: "object"})}}},
+	}}}
+
+	var got []string
+	for _, change := range DiffOpenAPI(oldDoc, newDoc) {
+		got = append(got, change.String())
+	}
+	expected := []string{
+		"[BREAKING] /user.Get GET args.id: field became required",
+		"[BREAKING] /user.Get GET args.id: type changed from \"string\" to \"integer\"",
+		"[BREAKING] /user.Get DELETE args: parameter removed",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Error(fmt.Sprintf("wrong changes:\n%s", strings.Join(got, "\n")))
+	}
+}
@@ -0,0 +1,227 @@
+package vapi
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// userUserValue is the RequestCtx user value key of the authenticated user name
+const userUserValue = "vapi.user"
+
+var errUnauthorized = errors.New("vapi: authentication required")
+
+// CredentialStore looks up passwords of users for BasicAuth and DigestAuth
+type CredentialStore interface {
+	// Password returns the password of user, false for unknown users
+	Password(user string) (string, bool)
+}
+
+// StaticCredentials is a CredentialStore of user name to password pairs, e.g. for health-check probes
+type StaticCredentials map[string]string
+
+// Password implements CredentialStore
+func (c StaticCredentials) Password(user string) (string, bool) {
+	password, ok := c[user]
+	return password, ok
+}
+
+// AuthenticatedUser returns the user authenticated by BasicAuth or DigestAuth, empty when none
+func AuthenticatedUser(ctx *fasthttp.RequestCtx) string {
+	user, _ := ctx.UserValue(userUserValue).(string)
+	return user
+}
+
+// UserPrincipal is a PrincipalFunc using the authenticated user as principal
+func UserPrincipal(ctx *fasthttp.RequestCtx) string {
+	return AuthenticatedUser(ctx)
+}
+
+// BasicAuth is the HTTP Basic authentication middleware (RFC 7617).
+// Use it only over TLS: the password travels in every request.
+type BasicAuth struct {
+	Realm       string
+	Credentials CredentialStore
+}
+
+// NewBasicAuth returns Basic authentication against credentials
+func NewBasicAuth(realm string, credentials CredentialStore) *BasicAuth {
+	return &BasicAuth{Realm: realm, Credentials: credentials}
+}
+
+// Handler wraps next with Basic authentication, unauthenticated requests get 401
+func (ba *BasicAuth) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		user, ok := ba.authenticate(ctx)
+		if !ok {
+			ctx.Response.Header.Set("WWW-Authenticate", `Basic realm=`+strconv.Quote(ba.Realm)+`, charset="UTF-8"`)
+			writeHandlerError(ctx, fasthttp.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		ctx.SetUserValue(userUserValue, user)
+		next(ctx)
+	}
+}
+
+// authenticate checks Basic credentials of the request
+func (ba *BasicAuth) authenticate(ctx *fasthttp.RequestCtx) (string, bool) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if len(header) < 6 || !strings.EqualFold(header[:6], "basic ") {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[6:]))
+	if err != nil {
+		return "", false
+	}
+	colon := strings.IndexByte(string(decoded), ':')
+	if colon < 0 {
+		return "", false
+	}
+	user, password := string(decoded[:colon]), string(decoded[colon+1:])
+
+	expected, known := ba.Credentials.Password(user)
+	// compare digests so neither the password length nor unknown users are timing visible
+	given, wanted := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(expected))
+	if subtle.ConstantTimeCompare(given[:], wanted[:]) != 1 || !known {
+		return "", false
+	}
+	return user, true
+}
+
+// DigestAuth is the HTTP Digest authentication middleware (RFC 7616, MD5 with qop=auth).
+// Passwords don't travel over the wire, but MD5 is weak: prefer BasicAuth over TLS
+// unless clients support only Digest.
+type DigestAuth struct {
+	Realm       string
+	Credentials CredentialStore
+	// NonceTTL is how long a server nonce is accepted, 5 minutes by default
+	NonceTTL time.Duration
+
+	secret []byte
+	now    func() time.Time
+}
+
+// NewDigestAuth returns Digest authentication against credentials
+func NewDigestAuth(realm string, credentials CredentialStore) (*DigestAuth, error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, fmt.Errorf("vapi: can't create nonce key: %s", err.Error())
+	}
+	return &DigestAuth{Realm: realm, Credentials: credentials, NonceTTL: 5 * time.Minute, secret: secret, now: time.Now}, nil
+}
+
+// Handler wraps next with Digest authentication, unauthenticated requests get 401 with a fresh nonce
+func (da *DigestAuth) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		user, stale, ok := da.authenticate(ctx)
+		if !ok {
+			challenge := fmt.Sprintf(`Digest realm=%q, qop="auth", algorithm=MD5, nonce=%q`, da.Realm, da.nonce(da.now()))
+			if stale {
+				challenge += ", stale=true"
+			}
+			ctx.Response.Header.Set("WWW-Authenticate", challenge)
+			writeHandlerError(ctx, fasthttp.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		ctx.SetUserValue(userUserValue, user)
+		next(ctx)
+	}
+}
+
+// authenticate checks Digest credentials of the request, stale is true for valid responses to an expired nonce
+func (da *DigestAuth) authenticate(ctx *fasthttp.RequestCtx) (user string, stale bool, ok bool) {
+	header := string(ctx.Request.Header.Peek("Authorization"))
+	if len(header) < 7 || !strings.EqualFold(header[:7], "digest ") {
+		return "", false, false
+	}
+	params := parseAuthParams(header[7:])
+	user = params["username"]
+	if params["realm"] != da.Realm || params["qop"] != "auth" || params["uri"] != string(ctx.RequestURI()) {
+		return "", false, false
+	}
+	password, known := da.Credentials.Password(user)
+	if !known {
+		return "", false, false
+	}
+
+	ha1 := md5Hex(user + ":" + da.Realm + ":" + password)
+	ha2 := md5Hex(string(ctx.Method()) + ":" + params["uri"])
+	expected := md5Hex(ha1 + ":" + params["nonce"] + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(params["response"])) != 1 {
+		return "", false, false
+	}
+	if !da.validNonce(params["nonce"]) {
+		return "", true, false
+	}
+	return user, false, true
+}
+
+// nonce returns nonce issued at t: the timestamp and its mac
+func (da *DigestAuth) nonce(t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, da.secret)
+	mac.Write([]byte(timestamp))
+	return timestamp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validNonce checks the nonce was issued by da and hasn't expired
+func (da *DigestAuth) validNonce(nonce string) bool {
+	dot := strings.IndexByte(nonce, '.')
+	if dot < 0 {
+		return false
+	}
+	issued, err := strconv.ParseInt(nonce[:dot], 10, 64)
+	if err != nil || !hmac.Equal([]byte(nonce), []byte(da.nonce(time.Unix(issued, 0)))) {
+		return false
+	}
+	return da.now().Sub(time.Unix(issued, 0)) <= da.NonceTTL
+}
+
+// parseAuthParams parses comma separated key=value and key="value" pairs of the Authorization header
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				break
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// md5Hex returns hex encoded md5 of s
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
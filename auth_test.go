@@ -0,0 +1,81 @@
+package vapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func authCall(handler fasthttp.RequestHandler, authorization string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.SetRequestURI("/health")
+	if authorization != "" {
+		ctx.Request.Header.Set("Authorization", authorization)
+	}
+	handler(ctx)
+	return ctx
+}
+
+func TestBasicAuth(t *testing.T) {
+	auth := NewBasicAuth("probes", StaticCredentials{"probe": "s3cret"})
+	handler := auth.Handler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(AuthenticatedUser(ctx))
+	})
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	if ctx := authCall(handler, basic("probe:s3cret")); ctx.Response.StatusCode() != 200 || string(ctx.Response.Body()) != "probe" {
+		t.Error(fmt.Sprintf("valid credentials must pass: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	for _, authorization := range []string{"", basic("probe:wrong"), basic("other:s3cret"), "Basic !!!"} {
+		ctx := authCall(handler, authorization)
+		if ctx.Response.StatusCode() != 401 || !strings.HasPrefix(string(ctx.Response.Header.Peek("WWW-Authenticate")), `Basic realm="probes"`) {
+			t.Error(fmt.Sprintf("%q must be rejected: %d", authorization, ctx.Response.StatusCode()))
+		}
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	auth, err := NewDigestAuth("tools", StaticCredentials{"admin": "pa55"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.Handler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString(AuthenticatedUser(ctx))
+	})
+
+	challenge := authCall(handler, "")
+	params := parseAuthParams(strings.TrimPrefix(string(challenge.Response.Header.Peek("WWW-Authenticate")), "Digest "))
+	if challenge.Response.StatusCode() != 401 || params["nonce"] == "" {
+		t.Fatal(fmt.Sprintf("wrong challenge: %s", challenge.Response.Header.Peek("WWW-Authenticate")))
+	}
+
+	digest := func(password, nonce string) string {
+		ha1 := md5Hex("admin:tools:" + password)
+		ha2 := md5Hex("GET:/health")
+		response := md5Hex(ha1 + ":" + nonce + ":00000001:abc:auth:" + ha2)
+		return fmt.Sprintf(`Digest username="admin", realm="tools", nonce=%q, uri="/health", qop=auth, nc=00000001, cnonce="abc", response=%q`, nonce, response)
+	}
+
+	if ctx := authCall(handler, digest("pa55", params["nonce"])); ctx.Response.StatusCode() != 200 || string(ctx.Response.Body()) != "admin" {
+		t.Error(fmt.Sprintf("valid digest must pass: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	if ctx := authCall(handler, digest("wrong", params["nonce"])); ctx.Response.StatusCode() != 401 {
+		t.Error("wrong password must be rejected")
+	}
+	if ctx := authCall(handler, digest("pa55", "1.forged")); ctx.Response.StatusCode() != 401 {
+		t.Error("forged nonce must be rejected")
+	}
+
+	auth.now = func() time.Time { return time.Now().Add(time.Hour) }
+	ctx := authCall(handler, digest("pa55", params["nonce"]))
+	if ctx.Response.StatusCode() != 401 || !strings.HasSuffix(string(ctx.Response.Header.Peek("WWW-Authenticate")), "stale=true") {
+		t.Error(fmt.Sprintf("expired nonce must be stale: %s", ctx.Response.Header.Peek("WWW-Authenticate")))
+	}
+}
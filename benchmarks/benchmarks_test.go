@@ -0,0 +1,122 @@
+package benchmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/riftbit/go-vapi"
+	"github.com/valyala/fasthttp"
+)
+
+type Item struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Price string   `json:"price"`
+	Tags  []string `json:"tags"`
+}
+
+type Items struct {
+	Items []Item `json:"items"`
+}
+
+func (i *Items) MarshalJSON() ([]byte, error) {
+	type plain Items
+	return json.Marshal((*plain)(i))
+}
+
+func (i *Items) UnmarshalJSON(data []byte) error {
+	type plain Items
+	return json.Unmarshal(data, (*plain)(i))
+}
+
+type BenchAPI struct{}
+
+func (h *BenchAPI) Echo(ctx *fasthttp.RequestCtx, args *vapi.TestArgs, reply *vapi.TestReply) error {
+	reply.ID, reply.Ttt = args.ID, args.Ttt
+	return nil
+}
+
+func (h *BenchAPI) Items(ctx *fasthttp.RequestCtx, args *Items, reply *Items) error {
+	reply.Items = args.Items
+	return nil
+}
+
+func newServer(b *testing.B) *vapi.VAPI {
+	as := vapi.NewServer()
+	if err := as.RegisterService(new(BenchAPI), ""); err != nil {
+		b.Fatal(err)
+	}
+	return as
+}
+
+func itemsBody(count int) []byte {
+	items := Items{Items: make([]Item, count)}
+	for i := range items.Items {
+		items.Items[i] = Item{ID: i, Name: fmt.Sprintf("item %d", i), Price: "12.50", Tags: []string{"a", "b"}}
+	}
+	body, _ := json.Marshal(items)
+	return body
+}
+
+func benchmarkCall(b *testing.B, method string, body []byte) {
+	as := newServer(b)
+	ctx := &fasthttp.RequestCtx{}
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetBody(body)
+		as.CallAPI(ctx, method)
+		if ctx.Response.StatusCode() != fasthttp.StatusOK {
+			b.Fatal(string(ctx.Response.Body()))
+		}
+	}
+}
+
+func BenchmarkDispatchSmall(b *testing.B) {
+	benchmarkCall(b, "BenchAPI.Echo", []byte(`{"id":"1","ttt":"x"}`))
+}
+
+func BenchmarkDispatchNotFound(b *testing.B) {
+	as := newServer(b)
+	ctx := &fasthttp.RequestCtx{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ctx.Response.Reset()
+		as.CallAPI(ctx, "BenchAPI.Missing")
+	}
+}
+
+func BenchmarkItems1KB(b *testing.B) {
+	benchmarkCall(b, "BenchAPI.Items", itemsBody(16))
+}
+
+func BenchmarkItems64KB(b *testing.B) {
+	benchmarkCall(b, "BenchAPI.Items", itemsBody(1024))
+}
+
+func BenchmarkDecodeSmall(b *testing.B) {
+	body := []byte(`{"id":"` + strings.Repeat("x", 32) + `","ttt":"y"}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		args := vapi.TestArgs{}
+		if err := args.UnmarshalJSON(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeSmall(b *testing.B) {
+	reply := vapi.TestReply{ID: strings.Repeat("x", 32), Ttt: "y"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := reply.MarshalJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
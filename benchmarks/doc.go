@@ -0,0 +1,12 @@
+// Package benchmarks measures dispatch, decoding and encoding of vapi calls
+// for representative payload sizes.
+//
+// Run the suite and compare it with the stored baseline, the command fails
+// when any benchmark is more than 10% slower:
+//
+//	go test -run ^$ -bench . -benchmem -count 5 ./benchmarks > new.txt
+//	go run ./cmd/vapi-benchcmp -max 10 benchmarks/baseline.txt new.txt
+//
+// Refresh baseline.txt with the first command on the reference machine
+// whenever a performance change is accepted.
+package benchmarks
@@ -0,0 +1,206 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MaskedValue replaces masked json values in logged bodies
+const MaskedValue = "***"
+
+// BodyLogEntry is a logged request with bodies
+type BodyLogEntry struct {
+	Verb         string
+	Path         string
+	Status       int
+	RequestBody  string
+	ResponseBody string
+}
+
+// BodyLog is the middleware logging request and response bodies for debugging integrations.
+//
+// Json bodies are logged with values at MaskPaths replaced by MaskedValue. A path
+// lists object keys from the root separated by dots, arrays are transparent and
+// "*" matches any key, e.g. "card.number" or "*.password". Bodies larger than
+// MaxBodySize aren't logged at all, since they can't be masked reliably once truncated,
+// and non-json bodies are logged only when MaskPaths is empty.
+type BodyLog struct {
+	// MaxBodySize is the largest logged body size in bytes
+	MaxBodySize int
+	// MaskPaths are json paths of masked values
+	MaskPaths []string
+
+	sink func(entry BodyLogEntry)
+}
+
+// NewBodyLog returns body log passing entries to sink, nil sink writes them with the standard logger.
+// Use TaggedMaskPaths to mask fields of registered args and replies tagged with `vapi:"mask"`.
+func NewBodyLog(maxBodySize int, maskPaths []string, sink func(entry BodyLogEntry)) *BodyLog {
+	if sink == nil {
+		sink = func(entry BodyLogEntry) {
+			log.Printf("%s %s %d request=%s response=%s", entry.Verb, entry.Path, entry.Status, entry.RequestBody, entry.ResponseBody)
+		}
+	}
+	return &BodyLog{MaxBodySize: maxBodySize, MaskPaths: maskPaths, sink: sink}
+}
+
+// Handler wraps next with body logging
+func (bl *BodyLog) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		// the request body may be reused by next, so it is captured before the call
+		requestBody := bl.format(ctx.Request.Body())
+		next(ctx)
+
+		responseBody := fmt.Sprintf("<stream, %d bytes, not logged>", ctx.Response.Header.ContentLength())
+		if !ctx.Response.IsBodyStream() {
+			responseBody = bl.format(ctx.Response.Body())
+		}
+		bl.sink(BodyLogEntry{
+			Verb:         string(ctx.Method()),
+			Path:         string(ctx.Path()),
+			Status:       ctx.Response.StatusCode(),
+			RequestBody:  requestBody,
+			ResponseBody: responseBody,
+		})
+	}
+}
+
+// format returns masked body for logging
+func (bl *BodyLog) format(body []byte) string {
+	if len(body) > bl.MaxBodySize {
+		return fmt.Sprintf("<%d bytes, not logged>", len(body))
+	}
+	if len(body) == 0 {
+		return ""
+	}
+
+	if len(bl.MaskPaths) == 0 {
+		return string(body)
+	}
+	masked, err := maskBody(body, bl.MaskPaths)
+	if err != nil {
+		return fmt.Sprintf("<%d bytes of non-json, not logged>", len(body))
+	}
+	return string(masked)
+}
+
+// maskBody returns json body with values at paths replaced by MaskedValue
+func maskBody(body []byte, paths []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		value = maskJSON(value, strings.Split(path, "."))
+	}
+	return json.Marshal(value)
+}
+
+// maskJSON replaces values at path in decoded json value
+func maskJSON(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = maskJSON(v[i], path)
+		}
+	case map[string]interface{}:
+		for key, element := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = MaskedValue
+			} else {
+				v[key] = maskJSON(element, path[1:])
+			}
+		}
+	}
+	return value
+}
+
+// TaggedMaskPaths returns json paths of args and reply fields tagged with `vapi:"mask"` of all registered methods:
+//
+//	Password string `json:"password" vapi:"mask"`
+//
+// Args paths start at the request body root, reply paths at the "response"
+// member of the response envelope.
+func (as *VAPI) TaggedMaskPaths() []string {
+	paths := map[string]bool{}
+	as.mutex.RLock()
+	for _, methodSpec := range as.methods {
+		collectMaskPaths(methodSpec.argsType, "", paths, map[reflect.Type]bool{})
+		collectMaskPaths(methodSpec.replyType, "response", paths, map[reflect.Type]bool{})
+	}
+	as.mutex.RUnlock()
+
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// argsMaskPaths caches masked json paths of args types
+var argsMaskPaths sync.Map
+
+// maskPathsOf returns json paths of fields of t tagged with `vapi:"mask"`
+func maskPathsOf(t reflect.Type) []string {
+	if cached, ok := argsMaskPaths.Load(t); ok {
+		return cached.([]string)
+	}
+	paths := map[string]bool{}
+	collectMaskPaths(t, "", paths, map[reflect.Type]bool{})
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	argsMaskPaths.Store(t, result)
+	return result
+}
+
+// collectMaskPaths adds json paths of masked fields of t under prefix, visited stops recursive types
+func collectMaskPaths(t reflect.Type, prefix string, paths map[string]bool, visited map[reflect.Type]bool) {
+	t = indirectType(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if t.Kind() == reflect.Map {
+			prefix += ".*"
+		}
+		collectMaskPaths(t.Elem(), prefix, paths, visited)
+	case reflect.Struct:
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonFieldName(field)
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			path := prefix
+			if !field.Anonymous || field.Tag.Get("json") != "" {
+				path = strings.TrimPrefix(prefix+"."+name, ".")
+			}
+			if hasTagOption(field, "mask") {
+				paths[path] = true
+				continue
+			}
+			collectMaskPaths(field.Type, path, paths, visited)
+		}
+	}
+}
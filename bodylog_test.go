@@ -0,0 +1,106 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestBodyLog(t *testing.T) {
+	var entry BodyLogEntry
+	bl := NewBodyLog(200, []string{"card.number", "*.token"}, func(e BodyLogEntry) {
+		entry = e
+	})
+	handler := bl.Handler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBody([]byte(`{"session":{"token":"abc","ttl":60},"items":[{"token":"x"}]}`))
+	})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBody([]byte(`{"card":{"number":"4111","holder":"J"},"amount":1.50}`))
+	handler(ctx)
+
+	if entry.RequestBody != `{"amount":1.50,"card":{"holder":"J","number":"***"}}` {
+		t.Error(fmt.Sprintf("wrong request body: %s", entry.RequestBody))
+	}
+	if entry.ResponseBody != `{"items":[{"token":"***"}],"session":{"token":"***","ttl":60}}` {
+		t.Error(fmt.Sprintf("wrong response body: %s", entry.ResponseBody))
+	}
+
+	ctx.Request.SetBody(make([]byte, 201))
+	handler(ctx)
+	if entry.RequestBody != "<201 bytes, not logged>" {
+		t.Error(fmt.Sprintf("large body must not be logged: %s", entry.RequestBody))
+	}
+}
+
+func TestCollectMaskPaths(t *testing.T) {
+	type card struct {
+		Number string `json:"number" vapi:"mask"`
+	}
+	type args struct {
+		Password string          `json:"password" vapi:"mask"`
+		Cards    []card          `json:"cards"`
+		ByName   map[string]card `json:"by_name"`
+	}
+
+	paths := map[string]bool{}
+	collectMaskPaths(reflect.TypeOf(args{}), "", paths, map[reflect.Type]bool{})
+	if len(paths) != 3 || !paths["password"] || !paths["cards.number"] || !paths["by_name.*.number"] {
+		t.Error(fmt.Sprintf("wrong paths: %v", paths))
+	}
+}
+
+type LoginArgs struct {
+	User     string `json:"user"`
+	Password string `json:"password" vapi:"mask"`
+}
+
+type LoginReply struct {
+	User  string `json:"user"`
+	Token string `json:"token" vapi:"mask"`
+}
+
+func (a *LoginArgs) UnmarshalJSON(data []byte) error {
+	type plain LoginArgs
+	return json.Unmarshal(data, (*plain)(a))
+}
+
+func (r *LoginReply) MarshalJSON() ([]byte, error) {
+	type plain LoginReply
+	return json.Marshal((*plain)(r))
+}
+
+type LoginAPI struct{}
+
+func (l *LoginAPI) Login(ctx *fasthttp.RequestCtx, args *LoginArgs, reply *LoginReply) error {
+	reply.User, reply.Token = args.User, "secret-token"
+	return nil
+}
+
+func TestBodyLog_TaggedMaskPaths(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(LoginAPI), "auth"); err != nil {
+		t.Fatal(err)
+	}
+	paths := as.TaggedMaskPaths()
+	if fmt.Sprint(paths) != "[password response.token]" {
+		t.Error(fmt.Sprintf("wrong paths: %v", paths))
+	}
+
+	var entry BodyLogEntry
+	handler := NewBodyLog(1024, paths, func(e BodyLogEntry) { entry = e }).Handler(as.Handler("/"))
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/auth.Login")
+	ctx.Request.SetBodyString(`{"user":"ann","password":"hunter2"}`)
+	handler(ctx)
+
+	if entry.RequestBody != `{"password":"***","user":"ann"}` {
+		t.Error(fmt.Sprintf("wrong request body: %s", entry.RequestBody))
+	}
+	if entry.ResponseBody != `{"response":{"token":"***","user":"ann"}}` {
+		t.Error(fmt.Sprintf("reply field must be masked: %s", entry.ResponseBody))
+	}
+}
@@ -0,0 +1,200 @@
+package vapi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// slowCallsKept is the number of recent slow calls kept for diagnostics
+const slowCallsKept = 100
+
+// CallInfo describes an in-flight or finished call
+type CallInfo struct {
+	Method   string        `json:"method"`
+	RemoteIP string        `json:"remote_ip"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Status is the response status of finished calls
+	Status int `json:"status,omitempty"`
+}
+
+// callTable tracks in-flight calls and recent slow ones
+type callTable struct {
+	enabled  int32    // 1 while calls are tracked, accessed atomically
+	inFlight sync.Map // *fasthttp.RequestCtx -> CallInfo
+
+	mutex     sync.Mutex
+	threshold time.Duration
+	slow      []CallInfo
+	next      int
+}
+
+// SetCallTracking enables tracking of in-flight and slow calls reported by
+// InFlightCalls, SlowCalls and CaptureDiagnostics. It is off by default, as
+// it costs every call a few allocations.
+func (as *VAPI) SetCallTracking(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&as.calls.enabled, value)
+}
+
+// SetSlowCallThreshold makes tracked calls slower than threshold kept for
+// CaptureDiagnostics (the last 100 of them), 0 disables it. Calls are
+// tracked only after SetCallTracking.
+func (as *VAPI) SetSlowCallThreshold(threshold time.Duration) {
+	as.calls.mutex.Lock()
+	as.calls.threshold = threshold
+	as.calls.mutex.Unlock()
+}
+
+// InFlightCalls returns calls being served, the longest running first
+func (as *VAPI) InFlightCalls() []CallInfo {
+	now := time.Now()
+	calls := []CallInfo{}
+	as.calls.inFlight.Range(func(key, value interface{}) bool {
+		call := value.(CallInfo)
+		call.Duration = now.Sub(call.Started)
+		calls = append(calls, call)
+		return true
+	})
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Duration > calls[j].Duration })
+	return calls
+}
+
+// SlowCalls returns recent calls slower than the slow call threshold, the latest first
+func (as *VAPI) SlowCalls() []CallInfo {
+	as.calls.mutex.Lock()
+	defer as.calls.mutex.Unlock()
+	calls := make([]CallInfo, 0, len(as.calls.slow))
+	for i := 1; i <= len(as.calls.slow); i++ {
+		calls = append(calls, as.calls.slow[(as.calls.next-i+len(as.calls.slow))%len(as.calls.slow)])
+	}
+	return calls
+}
+
+// untracked is returned by trackCall while tracking is off
+func untracked() {}
+
+// trackCall adds the call to the in-flight table, the returned func removes it
+func (as *VAPI) trackCall(ctx *fasthttp.RequestCtx, method string) func() {
+	if atomic.LoadInt32(&as.calls.enabled) == 0 {
+		return untracked
+	}
+	call := CallInfo{Method: method, RemoteIP: ctx.RemoteIP().String(), Started: time.Now()}
+	as.calls.inFlight.Store(ctx, call)
+	return func() {
+		as.calls.inFlight.Delete(ctx)
+		call.Duration, call.Status = time.Since(call.Started), ctx.Response.StatusCode()
+
+		as.calls.mutex.Lock()
+		if as.calls.threshold > 0 && call.Duration >= as.calls.threshold {
+			if len(as.calls.slow) < slowCallsKept {
+				as.calls.slow = append(as.calls.slow, call)
+				as.calls.next = len(as.calls.slow) % slowCallsKept
+			} else {
+				as.calls.slow[as.calls.next] = call
+				as.calls.next = (as.calls.next + 1) % slowCallsKept
+			}
+		}
+		as.calls.mutex.Unlock()
+	}
+}
+
+// CaptureDiagnostics writes a support bundle to dir and returns its path. The
+// bundle is a tar.gz with heap profile, goroutine dump, in-flight calls, recent
+// slow calls, method stats and the watchdog observation.
+func (as *VAPI) CaptureDiagnostics(dir string) (string, error) {
+	prefix := filepath.Join(dir, "vapi-diagnostics-"+time.Now().UTC().Format("20060102-150405"))
+	name := prefix + ".tar.gz"
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	// captures within the same second get a numeric suffix
+	for i := 2; os.IsExist(err); i++ {
+		name = prefix + "-" + strconv.Itoa(i) + ".tar.gz"
+		file, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if err != nil {
+		return "", err
+	}
+	err = as.writeDiagnostics(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// DiagnosticsHandler is the admin api of diagnostics, mount it behind admin
+// authentication: it responds with the CaptureDiagnostics bundle
+func (as *VAPI) DiagnosticsHandler(ctx *fasthttp.RequestCtx) {
+	buffer := &bytes.Buffer{}
+	if err := as.writeDiagnostics(buffer); err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/gzip")
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="vapi-diagnostics.tar.gz"`)
+	ctx.SetBody(buffer.Bytes())
+}
+
+// writeDiagnostics writes the diagnostics bundle to w
+func (as *VAPI) writeDiagnostics(w io.Writer) error {
+	files := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"heap.pprof", func(w io.Writer) error { return pprof.Lookup("heap").WriteTo(w, 0) }},
+		{"goroutines.txt", func(w io.Writer) error { return pprof.Lookup("goroutine").WriteTo(w, 2) }},
+		{"inflight.json", jsonFile(as.InFlightCalls())},
+		{"slow.json", jsonFile(as.SlowCalls())},
+		{"stats.json", jsonFile(as.Stats())},
+		{"watchdog.json", jsonFile(as.WatchdogObservation())},
+	}
+
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	now := time.Now()
+	for _, file := range files {
+		content := &bytes.Buffer{}
+		if err := file.write(content); err != nil {
+			return err
+		}
+		header := &tar.Header{Name: file.name, Mode: 0600, Size: int64(content.Len()), ModTime: now}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(content.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// jsonFile returns writer of indented json of value
+func jsonFile(value interface{}) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+}
@@ -0,0 +1,90 @@
+package vapi
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DiagnosticsAPI inspects the server from inside a call
+type DiagnosticsAPI struct {
+	inFlight []CallInfo
+}
+
+// Probe records in-flight calls
+func (h *DiagnosticsAPI) Probe(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *TestReply) error {
+	h.inFlight = ctx.UserValue(serverUserValue).(*VAPI).InFlightCalls()
+	return nil
+}
+
+func TestVAPI_CaptureDiagnostics(t *testing.T) {
+	as := NewServer()
+	probe := &DiagnosticsAPI{}
+	as.RegisterService(probe, "diag")
+	as.SetCallTracking(true)
+	as.SetSlowCallThreshold(time.Nanosecond)
+
+	as.callLocal("diag.Probe", []byte(`{}`))
+	as.callLocal("diag.Probe", []byte(`{}`))
+	if len(probe.inFlight) != 1 || probe.inFlight[0].Method != "diag.Probe" {
+		t.Error(fmt.Sprintf("the call must be in flight while served: %+v", probe.inFlight))
+	}
+	if len(as.InFlightCalls()) != 0 {
+		t.Error("finished calls must leave the in-flight table")
+	}
+	if slow := as.SlowCalls(); len(slow) != 2 || slow[0].Method != "diag.Probe" || slow[0].Status != 200 {
+		t.Error(fmt.Sprintf("slow calls must be kept: %+v", slow))
+	}
+
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name, err := as.CaptureDiagnostics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, err := as.CaptureDiagnostics(dir); err != nil || second == name {
+		t.Error(fmt.Sprintf("captures in the same second must not collide: %s %v", second, err))
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(compressed)
+	contents := map[string]string{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(archive)
+		contents[header.Name] = string(content)
+	}
+	for _, name := range []string{"heap.pprof", "goroutines.txt", "inflight.json", "slow.json", "stats.json", "watchdog.json"} {
+		if _, ok := contents[name]; !ok {
+			t.Error(fmt.Sprintf("bundle misses %s", name))
+		}
+	}
+	if !strings.Contains(contents["slow.json"], `"diag.Probe"`) || !strings.Contains(contents["goroutines.txt"], "goroutine") {
+		t.Error(fmt.Sprintf("wrong bundle contents: %s", contents["slow.json"]))
+	}
+}
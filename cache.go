@@ -0,0 +1,128 @@
+package vapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// cacheUserValue is the RequestCtx user value key of the cache policy set by the method
+const cacheUserValue = "vapi.cache"
+
+// CachePolicy describes http cacheability of a successful reply
+type CachePolicy struct {
+	// MaxAge is the freshness lifetime for all caches
+	MaxAge time.Duration
+	// SharedMaxAge overrides MaxAge for shared caches (CDN, proxies), sent as s-maxage
+	// of public replies
+	SharedMaxAge time.Duration
+	// StaleWhileRevalidate allows serving stale replies while refreshing them in background
+	StaleWhileRevalidate time.Duration
+	// Public allows shared caches to store the reply, set it only for replies
+	// that are the same for every caller. Replies are private by default.
+	Public bool
+	// Private forbids shared caches to store the reply, it overrides Public
+	Private bool
+	// NoStore forbids caching at all, other fields are ignored
+	NoStore bool
+	// LastModified is sent as Last-Modified and checked against If-Modified-Since
+	LastModified time.Time
+}
+
+// Cacheable is implemented by replies declaring their cache policy
+type Cacheable interface {
+	CachePolicy() CachePolicy
+}
+
+// SetCachePolicy sets the cache policy of the current call.
+// It takes precedence over Cacheable replies and SetMethodCache.
+func SetCachePolicy(ctx *fasthttp.RequestCtx, policy CachePolicy) {
+	ctx.SetUserValue(cacheUserValue, policy)
+}
+
+// SetMethodCache sets the default cache policy of successful replies of the method
+func (as *VAPI) SetMethodCache(method string, policy CachePolicy) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.cache = &policy
+	as.mutex.Unlock()
+	return nil
+}
+
+// CacheControl returns the Cache-Control header value of the policy
+func (p CachePolicy) CacheControl() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	public := p.Public && !p.Private
+	directives := []string{"private"}
+	if public {
+		directives[0] = "public"
+	}
+	directives = append(directives, "max-age="+seconds(p.MaxAge))
+	if p.SharedMaxAge > 0 && public {
+		directives = append(directives, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	return strings.Join(directives, ", ")
+}
+
+// cacheVary is the Vary header of cached replies: they may be localized and rendered as html
+const cacheVary = "Accept, Accept-Language"
+
+// writeCacheHeaders sets cache headers of successful reply and answers
+// 304 Not Modified to conditional GET and HEAD requests, returns true when it did.
+// Replies vary by Cookie when sessions are enabled and by Authorization for
+// authenticated calls.
+func writeCacheHeaders(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, reply reflect.Value, sessions bool) bool {
+	policy, ok := ctx.UserValue(cacheUserValue).(CachePolicy)
+	if !ok {
+		if cacheable, isCacheable := reply.Interface().(Cacheable); isCacheable {
+			policy, ok = cacheable.CachePolicy(), true
+		} else if methodSpec.cache != nil {
+			policy, ok = *methodSpec.cache, true
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	ctx.Response.Header.Set("Cache-Control", policy.CacheControl())
+	vary := cacheVary
+	if sessions {
+		vary += ", Cookie"
+	}
+	if len(ctx.Request.Header.Peek("Authorization")) > 0 || AuthenticatedUser(ctx) != "" {
+		vary += ", Authorization"
+	}
+	ctx.Response.Header.Set("Vary", vary)
+	if policy.NoStore || policy.LastModified.IsZero() {
+		return false
+	}
+	ctx.Response.Header.Set("Last-Modified", string(fasthttp.AppendHTTPDate(nil, policy.LastModified)))
+
+	if !ctx.IsGet() && !ctx.IsHead() {
+		return false
+	}
+	since, err := fasthttp.ParseHTTPDate(ctx.Request.Header.Peek("If-Modified-Since"))
+	if err != nil || policy.LastModified.Truncate(time.Second).After(since) {
+		return false
+	}
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+	ctx.ResetBody()
+	return true
+}
+
+// seconds formats d as whole seconds
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
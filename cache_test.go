@@ -0,0 +1,71 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var cacheModified = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type CacheAPI struct{}
+
+func (c *CacheAPI) Get(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	if args.ID == "private" {
+		SetCachePolicy(ctx, CachePolicy{Private: true, MaxAge: time.Minute})
+	}
+	reply.ID = args.ID
+	return nil
+}
+
+func TestCachePolicy(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(CacheAPI), "cache")
+	as.SetMethodCache("cache.Get", CachePolicy{Public: true, MaxAge: time.Minute, SharedMaxAge: time.Hour, LastModified: cacheModified})
+
+	call := func(id, verb, since string) (int, *fasthttp.ResponseHeader) {
+		var response *fasthttp.RequestCtx
+		status, _, _ := as.callLocalWith("cache.Get", []byte(`{"id":"`+id+`"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+			ctx.Request.Header.SetMethod(verb)
+			if since != "" {
+				ctx.Request.Header.Set("If-Modified-Since", since)
+			}
+		})
+		return status, &response.Response.Header
+	}
+
+	status, header := call("1", "POST", "")
+	if status != 200 || string(header.Peek("Cache-Control")) != "public, max-age=60, s-maxage=3600" ||
+		string(header.Peek("Last-Modified")) != "Thu, 02 Jan 2020 03:04:05 GMT" || string(header.Peek("Vary")) != "Accept, Accept-Language" {
+		t.Error(fmt.Sprintf("wrong cache headers: %d %s", status, header))
+	}
+	if status, _ := call("1", "GET", "Thu, 02 Jan 2020 03:04:05 GMT"); status != fasthttp.StatusNotModified {
+		t.Error(fmt.Sprintf("unmodified reply must be 304: %d", status))
+	}
+	if status, _ := call("1", "GET", "Wed, 01 Jan 2020 00:00:00 GMT"); status != 200 {
+		t.Error(fmt.Sprintf("modified reply must be 200: %d", status))
+	}
+	if _, header := call("private", "POST", ""); string(header.Peek("Cache-Control")) != "private, max-age=60" {
+		t.Error(fmt.Sprintf("context policy must win: %s", header.Peek("Cache-Control")))
+	}
+	if cacheControl := (CachePolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour}).CacheControl(); cacheControl != "private, max-age=60" {
+		t.Error(fmt.Sprintf("replies must be private by default: %s", cacheControl))
+	}
+
+	keyring, _ := NewAESKeyring([]byte("0123456789abcdef"))
+	as.SetSessions(SessionOptions{Keyring: keyring})
+	var response *fasthttp.RequestCtx
+	as.callLocalWith("cache.Get", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		response = ctx
+		ctx.Request.Header.Set("Authorization", "Bearer token")
+	})
+	if vary := string(response.Response.Header.Peek("Vary")); vary != "Accept, Accept-Language, Cookie, Authorization" {
+		t.Error(fmt.Sprintf("replies must vary by credentials: %s", vary))
+	}
+	if (CachePolicy{NoStore: true, MaxAge: time.Hour}).CacheControl() != "no-store" {
+		t.Error("no-store must ignore lifetimes")
+	}
+}
@@ -0,0 +1,123 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// User value keys of in-process calls
+const (
+	serverUserValue    = "vapi.server"
+	callDepthUserValue = "vapi.call_depth"
+)
+
+// maxCallDepth limits nesting of in-process calls, so accidental recursion fails fast
+const maxCallDepth = 16
+
+// callHeaders are the request headers in-process calls inherit from the parent call,
+// DryRunHeader makes nested calls of a dry run dry runs too, or rejects them
+var callHeaders = []string{"Authorization", "Cookie", "Accept-Language", "User-Agent", "X-Forwarded-For", "X-Request-Id", "Traceparent", "Tracestate", DryRunHeader}
+
+// callUserValues are the framework user values in-process calls inherit from the parent call,
+// the headers buffer is shared so nested calls can set outer response headers
+var callUserValues = map[string]bool{
+	userUserValue:    true,
+	localeUserValue:  true,
+	countryUserValue: true,
+	tagsUserValue:    true,
+	deviceUserValue:  true,
+	headersUserValue: true,
+}
+
+// SetCallHeaders adds request headers in-process calls inherit besides the standard
+// ones (Authorization, Cookie, Accept-Language, DryRunHeader, tracing and forwarding headers),
+// e.g. the headers read by the configured PrincipalFunc
+func (as *VAPI) SetCallHeaders(headers ...string) {
+	as.mutex.Lock()
+	as.callHeaders = append([]string(nil), headers...)
+	as.mutex.Unlock()
+}
+
+// Call invokes method of the server handling ctx in-process, see VAPI.Call.
+// It can be used only inside methods called through CallAPI.
+func Call(ctx *fasthttp.RequestCtx, method string, args, reply interface{}) error {
+	as, ok := ctx.UserValue(serverUserValue).(*VAPI)
+	if !ok {
+		return fmt.Errorf("vapi: %s can't be called outside of a method call", method)
+	}
+	return as.Call(ctx, method, args, reply)
+}
+
+// Call invokes method directly through the local registry with the same
+// pipeline as http calls: limits, validation, stats and events apply. Reply
+// transforms meant for clients (encryption, html, caching, spilling) are skipped.
+// The remote address, the headers listed by SetCallHeaders and standard ones,
+// framework user values (principal, locale, country, tags) and application user
+// values of parent ctx are inherited, parent may be nil for calls outside
+// requests. Method errors are returned as *Error with ErrorHTTPCode set.
+func (as *VAPI) Call(parent *fasthttp.RequestCtx, method string, args, reply interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("vapi: can't encode args of %s: %s", method, err.Error())
+	}
+
+	depth := 0
+	if parent != nil {
+		depth, _ = parent.UserValue(callDepthUserValue).(int)
+	}
+	if depth >= maxCallDepth {
+		return fmt.Errorf("vapi: in-process calls are nested deeper than %d", maxCallDepth)
+	}
+
+	var remoteAddr net.Addr
+	if parent != nil {
+		remoteAddr = parent.RemoteAddr()
+	}
+	as.mutex.RLock()
+	headers := as.callHeaders
+	as.mutex.RUnlock()
+	status, response, _ := as.callLocalWith(method, body, remoteAddr, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(callDepthUserValue, depth+1)
+		if parent == nil {
+			return
+		}
+		for _, list := range [][]string{callHeaders, headers} {
+			for _, header := range list {
+				if value := parent.Request.Header.Peek(header); len(value) > 0 {
+					ctx.Request.Header.SetBytesV(header, value)
+				}
+			}
+		}
+		bufferedHeaders(parent)
+		parent.VisitUserValues(func(key []byte, value interface{}) {
+			if name := string(key); callUserValues[name] || !strings.HasPrefix(name, "vapi.") {
+				ctx.SetUserValueBytes(key, value)
+			}
+		})
+	})
+
+	if len(response) == 0 && isSuccessStatus(status) {
+		// NoContent or a reply without body
+		return nil
+	}
+
+	envelope := ServerResponse{}
+	if err = envelope.UnmarshalJSON(response); err != nil {
+		return fmt.Errorf("vapi: malformed reply of %s: %s", method, err.Error())
+	}
+	if envelope.Error != nil {
+		envelope.Error.ErrorHTTPCode = status
+		return envelope.Error
+	}
+	if reply == nil || len(envelope.Response) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(envelope.Response, reply); err != nil {
+		return fmt.Errorf("vapi: can't decode reply of %s: %s", method, err.Error())
+	}
+	return nil
+}
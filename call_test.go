@@ -0,0 +1,109 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type GatewayAPI struct{}
+
+func (h *GatewayAPI) Proxy(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	inner := TestReply{}
+	if err := Call(ctx, "demo.Test", &TestArgs{ID: args.ID + "!", Ttt: string(ctx.Request.Header.Peek("X-User"))}, &inner); err != nil {
+		return err.(*Error)
+	}
+	reply.ID, reply.Ttt = inner.ID, inner.Ttt
+	return nil
+}
+
+func (h *GatewayAPI) Loop(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	if err := Call(ctx, "GatewayAPI.Loop", args, reply); err != nil {
+		return &Error{ErrorHTTPCode: fasthttp.StatusLoopDetected, ErrorMessage: err.Error()}
+	}
+	return nil
+}
+
+// VaultSecret is a reply with encrypted field
+type VaultSecret struct {
+	Email string `json:"email" vapi:"encrypt"`
+}
+
+func (r *VaultSecret) MarshalJSON() ([]byte, error) {
+	type plain VaultSecret
+	return json.Marshal((*plain)(r))
+}
+
+type VaultAPI struct{}
+
+func (h *VaultAPI) Secret(ctx *fasthttp.RequestCtx, args *TestArgs, reply *VaultSecret) error {
+	reply.Email = "alice@example.com"
+	return nil
+}
+
+func (h *GatewayAPI) Reveal(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	inner := VaultSecret{}
+	if err := Call(ctx, "VaultAPI.Secret", args, &inner); err != nil {
+		return err
+	}
+	reply.ID = inner.Email
+	return nil
+}
+
+func TestVAPI_Call(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RegisterService(new(GatewayAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	as.SetCallHeaders("X-User")
+
+	status, body, _ := as.callLocalWith("GatewayAPI.Proxy", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		ctx.Request.Header.Set("X-User", "alice")
+	})
+	if status != 200 || string(body) != `{"response":{"id":"1!","ttt":"alice"}}` {
+		t.Error(fmt.Sprintf("wrong nested call: %d %s", status, body))
+	}
+
+	if status, _, _ = as.callLocal("GatewayAPI.Loop", []byte(`{}`)); status != fasthttp.StatusLoopDetected {
+		t.Error(fmt.Sprintf("recursion must be stopped, got %d", status))
+	}
+
+	err := as.Call(nil, "demo.ErrorTest", &TestArgs{}, nil)
+	if apiErr, ok := err.(*Error); !ok || apiErr.ErrorCode != 606 || apiErr.ErrorHTTPCode != fasthttp.StatusFailedDependency {
+		t.Error(fmt.Sprintf("wrong error: %#v", err))
+	}
+
+	if status, body, _ = as.callLocalWith("GatewayAPI.Proxy", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		ctx.Request.Header.Set("X-Other", "alice")
+	}); status != 200 || string(body) != `{"response":{"id":"1!"}}` {
+		t.Error(fmt.Sprintf("headers not listed must not be inherited: %d %s", status, body))
+	}
+}
+
+func TestVAPI_Call_ClientTransforms(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(VaultAPI), "")
+	as.RegisterService(new(GatewayAPI), "")
+	keyring, err := NewAESKeyring([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.SetKeyring(keyring)
+	as.SetMethodTemplate("VaultAPI.Secret", template.Must(template.New("page").Parse(`<p>{{.Email}}</p>`)))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set("Accept", "text/html")
+	ctx.Request.SetBody([]byte(`{}`))
+	as.CallAPI(ctx, "GatewayAPI.Reveal")
+
+	if status, body := ctx.Response.StatusCode(), string(ctx.Response.Body()); status != 200 || body != `{"response":{"id":"alice@example.com"}}` {
+		t.Error(fmt.Sprintf("nested reply must reach the method untransformed: %d %s", status, body))
+	}
+}
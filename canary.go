@@ -0,0 +1,227 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CanaryHeader marks requests sent to canary candidates, they are never compared again
+const CanaryHeader = "X-Vapi-Canary"
+
+// EventCanaryMismatch is emitted when a candidate response differs from the primary one with CanaryMismatch payload
+const EventCanaryMismatch = "canary.mismatch"
+
+// canaryUserValue marks in-process candidate calls
+const canaryUserValue = "vapi.canary"
+
+// DefaultCanaryInFlight is the default limit of concurrently running candidate calls of a method
+const DefaultCanaryInFlight = 64
+
+// Canary compares a share of method calls with a candidate implementation.
+// The candidate is executed after the primary response is ready and doesn't
+// delay it, the client always gets the primary response.
+type Canary struct {
+	// Rate is the share of calls executed against the candidate too, e.g. 0.05
+	Rate float64
+	// Method is the local candidate method, e.g. "users.GetV2"
+	Method string
+	// Endpoint is the base url of a remote candidate, used when Method is empty;
+	// the request is sent to the same path there
+	Endpoint string
+	// Timeout of remote candidate calls, 5s when zero
+	Timeout time.Duration
+	// DryRun sends candidate calls with DryRunHeader, so a candidate supporting
+	// dry runs doesn't repeat side effects of the primary call
+	DryRun bool
+	// Ignore lists dotted paths of response fields which are expected to
+	// differ, e.g. "response.created_at"
+	Ignore []string
+	// Normalize, when set, rewrites both response bodies before comparison
+	Normalize func(body []byte) []byte
+	// MaxInFlight limits concurrently running candidate calls, samples over it
+	// are skipped; DefaultCanaryInFlight when zero
+	MaxInFlight int64
+
+	inFlight int64
+}
+
+// CanaryMismatch is the payload of EventCanaryMismatch
+type CanaryMismatch struct {
+	Method          string
+	PrimaryStatus   int
+	CandidateStatus int
+	Primary         []byte
+	Candidate       []byte
+	// Err is set when the candidate couldn't be called
+	Err error
+}
+
+// SetMethodCanary enables comparison of the method with canary, nil disables it.
+//
+// Compared and mismatched calls are counted in MethodStats, mismatches are
+// logged with the standard logger and published as EventCanaryMismatch.
+func (as *VAPI) SetMethodCanary(method string, canary *Canary) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	if canary != nil {
+		if canary.Method == "" && canary.Endpoint == "" {
+			return fmt.Errorf("vapi: canary of %s needs a candidate method or endpoint", method)
+		}
+		if canary.Method == method {
+			return fmt.Errorf("vapi: %s can't be its own canary", method)
+		}
+		if canary.Method != "" {
+			if _, err := as.get(canary.Method); err != nil {
+				return err
+			}
+		}
+	}
+
+	as.mutex.Lock()
+	methodSpec.canary = canary
+	as.mutex.Unlock()
+	return nil
+}
+
+// compareCanary runs the candidate of sampled calls in background and compares its response with the primary one
+func (as *VAPI) compareCanary(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod) {
+	as.mutex.RLock()
+	canary := methodSpec.canary
+	as.mutex.RUnlock()
+	if canary == nil || rand.Float64() >= canary.Rate {
+		return
+	}
+	if ctx.UserValue(canaryUserValue) != nil || len(ctx.Request.Header.Peek(CanaryHeader)) > 0 {
+		return
+	}
+	limit := canary.MaxInFlight
+	if limit <= 0 {
+		limit = DefaultCanaryInFlight
+	}
+	if atomic.AddInt64(&canary.inFlight, 1) > limit {
+		atomic.AddInt64(&canary.inFlight, -1)
+		return
+	}
+
+	// ctx is reused after the handler returns, so everything the candidate needs is copied now
+	req := fasthttp.AcquireRequest()
+	ctx.Request.CopyTo(req)
+	req.Header.Set(CanaryHeader, "1")
+	if canary.DryRun {
+		req.Header.Set(DryRunHeader, "true")
+	}
+	primaryStatus := ctx.Response.StatusCode()
+	primary := append([]byte(nil), ctx.Response.Body()...)
+	remoteAddr := ctx.RemoteAddr()
+	values := map[string]interface{}{}
+	for _, key := range []string{userUserValue, localeUserValue, countryUserValue} {
+		if value := ctx.UserValue(key); value != nil {
+			values[key] = value
+		}
+	}
+
+	go func() {
+		defer atomic.AddInt64(&canary.inFlight, -1)
+		defer fasthttp.ReleaseRequest(req)
+
+		status, candidate, err := as.callCanary(canary, req, remoteAddr, values)
+		equal := err == nil && status == primaryStatus && canary.equal(primary, candidate)
+		methodSpec.stats.recordCanary(!equal)
+		if equal {
+			return
+		}
+
+		if err != nil {
+			log.Printf("vapi: canary of %s failed: %s", methodSpec.name, err.Error())
+		} else {
+			log.Printf("vapi: canary of %s differs: %d %s vs %d %s", methodSpec.name, primaryStatus, primary, status, candidate)
+		}
+		if as.Events().HasSubscribers(EventCanaryMismatch) {
+			as.Events().Publish(EventCanaryMismatch, CanaryMismatch{
+				Method: methodSpec.name, PrimaryStatus: primaryStatus, CandidateStatus: status,
+				Primary: primary, Candidate: candidate, Err: err,
+			})
+		}
+	}()
+}
+
+// callCanary executes req against the candidate, returns its status and body
+func (as *VAPI) callCanary(canary *Canary, req *fasthttp.Request, remoteAddr net.Addr, values map[string]interface{}) (int, []byte, error) {
+	if canary.Method != "" {
+		return as.callLocalWith(canary.Method, req.Body(), remoteAddr, func(ctx *fasthttp.RequestCtx) {
+			req.Header.CopyTo(&ctx.Request.Header)
+			ctx.Request.SetRequestURI("/" + canary.Method)
+			ctx.Request.Header.SetContentLength(len(req.Body()))
+			for key, value := range values {
+				ctx.SetUserValue(key, value)
+			}
+			ctx.SetUserValue(canaryUserValue, true)
+		})
+	}
+
+	timeout := canary.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(strings.TrimSuffix(canary.Endpoint, "/") + string(req.RequestURI()))
+	if ip := remoteIP(remoteAddr); ip != "" {
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+	if err := fasthttp.DoTimeout(req, resp, timeout); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode(), append([]byte(nil), resp.Body()...), nil
+}
+
+// equal reports whether primary and candidate bodies match after normalization
+func (canary *Canary) equal(primary, candidate []byte) bool {
+	if canary.Normalize != nil {
+		primary, candidate = canary.Normalize(primary), canary.Normalize(candidate)
+	}
+	var a, b interface{}
+	if json.Unmarshal(primary, &a) != nil || json.Unmarshal(candidate, &b) != nil {
+		return bytes.Equal(primary, candidate)
+	}
+	for _, path := range canary.Ignore {
+		deletePath(a, path)
+		deletePath(b, path)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// deletePath removes the field at dotted path from decoded json
+func deletePath(value interface{}, path string) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		value = object[name]
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		delete(object, names[len(names)-1])
+	}
+}
+
+// remoteIP returns ip address of addr, empty for unknown
+func remoteIP(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CanaryAPI has two implementations of the same call
+type CanaryAPI struct{}
+
+// Old returns the id
+func (h *CanaryAPI) Old(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *TestReply) error {
+	Reply.ID = Args.ID
+	return nil
+}
+
+// New returns the id and differs in ttt
+func (h *CanaryAPI) New(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *TestReply) error {
+	Reply.ID = Args.ID
+	Reply.Ttt = "new"
+	return nil
+}
+
+func TestVAPI_SetMethodCanary(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(CanaryAPI), "canary")
+
+	if err := as.SetMethodCanary("canary.Old", &Canary{Rate: 1}); err == nil {
+		t.Error("canary without candidate must fail")
+	}
+	if err := as.SetMethodCanary("canary.Old", &Canary{Rate: 1, Method: "canary.Missing"}); err == nil {
+		t.Error("unknown candidate must fail")
+	}
+
+	mismatches := make(chan CanaryMismatch, 4)
+	as.Events().Subscribe(EventCanaryMismatch, func(ctx context.Context, event Event) {
+		mismatches <- event.Payload.(CanaryMismatch)
+	})
+
+	wait := func(compared uint64) MethodStats {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			for _, stats := range as.Stats() {
+				if stats.Method == "canary.Old" && stats.CanaryCompared >= compared {
+					return stats
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatal("candidate wasn't compared")
+		return MethodStats{}
+	}
+
+	as.SetMethodCanary("canary.Old", &Canary{Rate: 1, Method: "canary.New"})
+	status, body, _ := as.callLocal("canary.Old", []byte(`{"id":"7"}`))
+	if status != 200 || string(body) != `{"response":{"id":"7"}}` {
+		t.Error(fmt.Sprintf("client must get the primary response: %d %s", status, body))
+	}
+	if stats := wait(1); stats.CanaryMismatches != 1 {
+		t.Error(fmt.Sprintf("mismatch must be counted: %+v", stats))
+	}
+	select {
+	case mismatch := <-mismatches:
+		if mismatch.Method != "canary.Old" || string(mismatch.Candidate) == string(mismatch.Primary) {
+			t.Error(fmt.Sprintf("wrong mismatch: %+v", mismatch))
+		}
+	case <-time.After(time.Second):
+		t.Error("mismatch event wasn't published")
+	}
+
+	as.SetMethodCanary("canary.Old", &Canary{Rate: 1, Method: "canary.New", Ignore: []string{"response.ttt"}})
+	as.callLocal("canary.Old", []byte(`{"id":"8"}`))
+	if stats := wait(2); stats.CanaryMismatches != 1 {
+		t.Error(fmt.Sprintf("ignored fields must not differ: %+v", stats))
+	}
+}
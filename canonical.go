@@ -0,0 +1,149 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CanonicalJSON re-encodes json data deterministically: object keys are sorted,
+// insignificant whitespace is removed, numbers use the shortest round-trip
+// form (integers without fraction or exponent) and strings are escaped
+// minimally. Equal values always produce identical bytes, which makes the
+// output suitable for signatures, ETags and golden files.
+func CanonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("vapi: can't canonicalize json: %s", err.Error())
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("vapi: can't canonicalize json: unexpected data after top-level value")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeCanonical(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SetCanonicalJSON enables canonical encoding of method replies
+func (as *VAPI) SetCanonicalJSON(enabled bool) {
+	as.mutex.Lock()
+	as.canonical = enabled
+	as.mutex.Unlock()
+}
+
+// writeCanonical writes decoded value in canonical form
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		number, err := canonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case string:
+		writeCanonicalString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("vapi: can't canonicalize json value of type %T", value)
+	}
+	return nil
+}
+
+// canonicalNumber formats number: integers as exact decimal digits whatever
+// their size, others in shortest float form
+func canonicalNumber(n json.Number) (string, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+	if i, ok := new(big.Int).SetString(string(n), 10); ok {
+		return i.String(), nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("vapi: can't canonicalize number %q", string(n))
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return strings.Replace(strconv.FormatFloat(f, 'e', -1, 64), "e-0", "e-", 1), nil
+}
+
+// writeCanonicalString writes json string escaping only quotes, backslashes and control characters
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c == '\b':
+			buf.WriteString(`\b`)
+		case c == '\f':
+			buf.WriteString(`\f`)
+		case c < 0x20:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xf])
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,31 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"b": 1, "a": {"d": [1.50, 2e3, -0], "c": null}}`: `{"a":{"c":null,"d":[1.5,2000,0]},"b":1}`,
+		`"<a href=\"x\">é\n"`:                              `"<a href=\"x\">é\n"`,
+		`[1e-7, 123456789012345678901234, 0.000001]`:       `[1e-7,123456789012345678901234,0.000001]`,
+		`[18446744073709551615, -92233720368547758080]`:    `[18446744073709551615,-92233720368547758080]`,
+		` true `: `true`,
+	}
+
+	for input, expected := range cases {
+		got, err := CanonicalJSON([]byte(input))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != expected {
+			t.Error(fmt.Sprintf("wrong canonical form of %s: %s", input, got))
+		}
+	}
+
+	if _, err := CanonicalJSON([]byte(`{"a":1} {}`)); err == nil {
+		t.Error("trailing data must be rejected")
+	}
+}
@@ -0,0 +1,311 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+var errCBORTruncated = errors.New("vapi: truncated cbor data")
+
+// maxCBORDepth limits nesting of decoded cbor values
+const maxCBORDepth = 64
+
+// cborToJSON converts a single cbor (RFC 7049) data item to json. Byte strings
+// become base64 strings like encoding/json produces for []byte, tags are ignored
+// and undefined becomes null.
+func cborToJSON(data []byte) ([]byte, error) {
+	decoder := &cborDecoder{data: data}
+	value, err := decoder.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.pos != len(data) {
+		return nil, fmt.Errorf("vapi: unexpected data after cbor item")
+	}
+	return json.Marshal(value)
+}
+
+// jsonToCBOR converts json to cbor, map keys are sorted for deterministic output
+func jsonToCBOR(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("vapi: can't convert json to cbor: %s", err.Error())
+	}
+	buf := &bytes.Buffer{}
+	if err := writeCBOR(buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cborDecoder reads cbor items from data
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads major type and argument of the next item
+func (d *cborDecoder) head() (major byte, info byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, errCBORTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info = b>>5, b&0x1f
+
+	size := 0
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == 31:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("vapi: invalid cbor additional info %d", info)
+	}
+	if d.pos+size > len(d.data) {
+		return 0, 0, 0, errCBORTruncated
+	}
+	for _, c := range d.data[d.pos : d.pos+size] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.pos += size
+	return major, info, arg, nil
+}
+
+// decode reads the next item
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("vapi: cbor data is nested too deep")
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if info == 31 && (major < 2 || major == 6) {
+		return nil, fmt.Errorf("vapi: invalid indefinite length cbor item")
+	}
+
+	switch major {
+	case 0:
+		return arg, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		chunk, err := d.readString(major, info, arg, depth)
+		if err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return chunk, nil
+		}
+		if !utf8.Valid(chunk) {
+			return nil, fmt.Errorf("vapi: invalid utf-8 in cbor text")
+		}
+		return string(chunk), nil
+	case 4:
+		items := []interface{}{}
+		for i := uint64(0); info == 31 || i < arg; i++ {
+			if info == 31 && d.isBreak() {
+				break
+			}
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		object := map[string]interface{}{}
+		for i := uint64(0); info == 31 || i < arg; i++ {
+			if info == 31 && d.isBreak() {
+				break
+			}
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			object[fmt.Sprint(key)] = value
+		}
+		return object, nil
+	case 6:
+		return d.decode(depth + 1)
+	}
+
+	// major 7: simple values and floats
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	}
+	return nil, fmt.Errorf("vapi: unsupported cbor simple value %d", arg)
+}
+
+// readString reads definite or indefinite byte or text string
+func (d *cborDecoder) readString(major, info byte, arg uint64, depth int) ([]byte, error) {
+	if info != 31 {
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errCBORTruncated
+		}
+		chunk := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		return chunk, nil
+	}
+
+	var result []byte
+	for !d.isBreak() {
+		chunkMajor, chunkInfo, chunkArg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkInfo == 31 {
+			return nil, fmt.Errorf("vapi: invalid chunk of indefinite cbor string")
+		}
+		chunk, err := d.readString(major, chunkInfo, chunkArg, depth)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, chunk...)
+	}
+	return result, nil
+}
+
+// isBreak consumes break code of indefinite items
+func (d *cborDecoder) isBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == 0xff {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// halfToFloat converts IEEE 754 half precision float
+func halfToFloat(h uint16) float64 {
+	exponent := int(h>>10) & 0x1f
+	mantissa := float64(h & 0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		value = math.Inf(1)
+		if mantissa != 0 {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if h&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
+
+// writeCBORHead writes major type with argument in the shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+// writeCBOR writes decoded json value as cbor
+func writeCBOR(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i >= 0 {
+				writeCBORHead(buf, 0, uint64(i))
+			} else {
+				writeCBORHead(buf, 1, uint64(-1-i))
+			}
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("vapi: can't convert number %s to cbor", v)
+		}
+		buf.WriteByte(0xfb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			if err := writeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, key := range keys {
+			writeCBORHead(buf, 3, uint64(len(key)))
+			buf.WriteString(key)
+			if err := writeCBOR(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("vapi: can't convert %T to cbor", value)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package vapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestCBOR(t *testing.T) {
+	tests := []struct {
+		cbor string
+		json string
+	}{
+		{"a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"f93e00", `1.5`},
+		{"3903e7", `-1000`},
+		{"9f01820203ff", `[1,[2,3]]`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"c11a514b67b0", `1363896240`},
+		{"a2616bf5616ef6", `{"k":true,"n":null}`},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.cbor)
+		converted, err := cborToJSON(data)
+		if err != nil || string(converted) != test.json {
+			t.Error(fmt.Sprintf("%s: expected %s, got %s %v", test.cbor, test.json, converted, err))
+		}
+	}
+
+	encoded, err := jsonToCBOR([]byte(`{"b":[2,3],"a":-1,"f":1.5,"s":"x"}`))
+	if err != nil || hex.EncodeToString(encoded) != "a461612061628202036166fb3ff800000000000061736178" {
+		t.Error(fmt.Sprintf("wrong cbor: %x %v", encoded, err))
+	}
+
+	for _, bad := range []string{"", "a1", "5f01ff", "1c", "9f"} {
+		data, _ := hex.DecodeString(bad)
+		if _, err := cborToJSON(data); err == nil {
+			t.Error(fmt.Sprintf("%q must be rejected", bad))
+		}
+	}
+}
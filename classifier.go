@@ -0,0 +1,185 @@
+package vapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// EventRequestFlagged is emitted for requests classified other than VerdictAllow with FlaggedRequest payload
+const EventRequestFlagged = "request.flagged"
+
+// tagsUserValue is the RequestCtx user value key of classification tags
+const tagsUserValue = "vapi.tags"
+
+var errRequestRejected = errors.New("vapi: request rejected")
+
+// Verdict is the action taken on a classified request, more severe verdicts have higher values
+type Verdict int
+
+// Verdicts of RequestClassifier
+const (
+	// VerdictAllow serves the request, tags are kept
+	VerdictAllow Verdict = iota
+	// VerdictTarpit delays the request by Classification.Delay before serving it
+	VerdictTarpit
+	// VerdictThrottle rejects the request with 429 and Classification.RetryAfter
+	VerdictThrottle
+	// VerdictReject rejects the request with 403
+	VerdictReject
+)
+
+// String returns verdict name
+func (v Verdict) String() string {
+	switch v {
+	case VerdictAllow:
+		return "allow"
+	case VerdictTarpit:
+		return "tarpit"
+	case VerdictThrottle:
+		return "throttle"
+	case VerdictReject:
+		return "reject"
+	}
+	return "verdict(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Classification is the result of RequestClassifier
+type Classification struct {
+	Verdict Verdict
+	// Tags label the request, e.g. "bot", available to methods through RequestTags
+	Tags []string
+	// Reason explains the verdict for logs and events
+	Reason string
+	// Delay of VerdictTarpit
+	Delay time.Duration
+	// RetryAfter of VerdictThrottle, 1 second when zero
+	RetryAfter time.Duration
+}
+
+// RequestClassifier inspects the request before dispatch to method, e.g. to spot
+// scrapers by headers, ip address or RequestFingerprint
+type RequestClassifier func(ctx *fasthttp.RequestCtx, method string) Classification
+
+// FlaggedRequest is the payload of EventRequestFlagged
+type FlaggedRequest struct {
+	Method         string
+	RemoteIP       string
+	Fingerprint    string
+	Classification Classification
+}
+
+// AddClassifier registers classifier run before every call. The most severe
+// verdict of all classifiers is applied and their tags are merged.
+func (as *VAPI) AddClassifier(classifier RequestClassifier) {
+	as.mutex.Lock()
+	as.classifiers = append(as.classifiers, classifier)
+	as.mutex.Unlock()
+}
+
+// RequestTags returns classification tags of the request
+func RequestTags(ctx *fasthttp.RequestCtx) []string {
+	tags, _ := ctx.UserValue(tagsUserValue).([]string)
+	return tags
+}
+
+// RequestFingerprint returns a hash of header names order and client hints,
+// stable across requests of the same client software and differing between
+// browsers and scripts which fake only the User-Agent
+func RequestFingerprint(ctx *fasthttp.RequestCtx) string {
+	hash := sha256.New()
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		hash.Write(key)
+		hash.Write([]byte{'\n'})
+	})
+	for _, header := range []string{"User-Agent", "Accept", "Accept-Language", "Accept-Encoding"} {
+		hash.Write(ctx.Request.Header.Peek(header))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// MissingUserAgent tags requests without User-Agent as "bot" and throttles them
+func MissingUserAgent(ctx *fasthttp.RequestCtx, method string) Classification {
+	if len(ctx.Request.Header.UserAgent()) > 0 {
+		return Classification{}
+	}
+	return Classification{Verdict: VerdictThrottle, Tags: []string{"bot"}, Reason: "missing user agent", RetryAfter: 10 * time.Second}
+}
+
+// ImpossibleHeaders rejects requests whose headers no real browser sends together:
+// browser User-Agent without Accept-Language, client hints on HTTP/1.0 or
+// fetch metadata with a non-browser User-Agent
+func ImpossibleHeaders(ctx *fasthttp.RequestCtx, method string) Classification {
+	userAgent := string(ctx.Request.Header.UserAgent())
+	browser := strings.HasPrefix(userAgent, "Mozilla/")
+	header := &ctx.Request.Header
+
+	reason := ""
+	switch {
+	case browser && len(header.Peek("Accept-Language")) == 0:
+		reason = "browser user agent without accept-language"
+	case len(header.Peek("Sec-Ch-Ua")) > 0 && !ctx.Request.Header.IsHTTP11():
+		reason = "client hints over http/1.0"
+	case len(header.Peek("Sec-Fetch-Mode")) > 0 && !browser:
+		reason = "fetch metadata from non-browser user agent"
+	}
+	if reason == "" {
+		return Classification{}
+	}
+	return Classification{Verdict: VerdictReject, Tags: []string{"bot"}, Reason: reason}
+}
+
+// classify runs classifiers, writes the rejection and returns false when the request must not be served
+func (as *VAPI) classify(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	classifiers := as.classifiers
+	as.mutex.RUnlock()
+	if len(classifiers) == 0 {
+		return true
+	}
+
+	result := Classification{}
+	for _, classifier := range classifiers {
+		c := classifier(ctx, methodSpec.name)
+		result.Tags = append(result.Tags, c.Tags...)
+		if c.Verdict > result.Verdict {
+			result.Verdict, result.Reason, result.Delay, result.RetryAfter = c.Verdict, c.Reason, c.Delay, c.RetryAfter
+		}
+	}
+	if len(result.Tags) > 0 {
+		ctx.SetUserValue(tagsUserValue, result.Tags)
+	}
+	if result.Verdict == VerdictAllow {
+		return true
+	}
+
+	if as.Events().HasSubscribers(EventRequestFlagged) {
+		as.Events().Publish(EventRequestFlagged, FlaggedRequest{
+			Method:         methodSpec.name,
+			RemoteIP:       ctx.RemoteIP().String(),
+			Fingerprint:    RequestFingerprint(ctx),
+			Classification: result,
+		})
+	}
+
+	switch result.Verdict {
+	case VerdictTarpit:
+		time.Sleep(result.Delay)
+		return true
+	case VerdictThrottle:
+		retryAfter := result.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		as.writeTooManyRequests(ctx, srvResponse, retryAfter, errRequestRejected)
+		return false
+	}
+	as.writeError(ctx, srvResponse, fasthttp.StatusForbidden, errRequestRejected)
+	return false
+}
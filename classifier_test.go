@@ -0,0 +1,82 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_AddClassifier(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.AddClassifier(MissingUserAgent)
+	as.AddClassifier(ImpossibleHeaders)
+	as.AddClassifier(func(ctx *fasthttp.RequestCtx, method string) Classification {
+		if string(ctx.Request.Header.Peek("X-Scraper")) == "slow" {
+			return Classification{Verdict: VerdictTarpit, Delay: 20 * time.Millisecond, Tags: []string{"scraper"}}
+		}
+		return Classification{}
+	})
+
+	flagged := make(chan FlaggedRequest, 4)
+	as.Events().Subscribe(EventRequestFlagged, func(ctx context.Context, event Event) {
+		flagged <- event.Payload.(FlaggedRequest)
+	})
+
+	call := func(headers ...string) (int, string) {
+		var response *fasthttp.RequestCtx
+		status, _, _ := as.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+			for i := 0; i < len(headers); i += 2 {
+				ctx.Request.Header.Set(headers[i], headers[i+1])
+			}
+		})
+		return status, string(response.Response.Header.Peek("Retry-After"))
+	}
+
+	if status, _ := call("User-Agent", "curl/7.0"); status != 200 {
+		t.Error(fmt.Sprintf("regular client must pass: %d", status))
+	}
+	if status, retryAfter := call(); status != 429 || retryAfter != "10" {
+		t.Error(fmt.Sprintf("missing user agent must be throttled: %d %q", status, retryAfter))
+	}
+	if status, _ := call("User-Agent", "Mozilla/5.0"); status != 403 {
+		t.Error(fmt.Sprintf("browser without accept-language must be rejected: %d", status))
+	}
+	started := time.Now()
+	if status, _ := call("User-Agent", "curl/7.0", "X-Scraper", "slow"); status != 200 || time.Since(started) < 20*time.Millisecond {
+		t.Error(fmt.Sprintf("tarpitted request must be delayed and served: %d %s", status, time.Since(started)))
+	}
+
+	verdicts := map[Verdict]string{}
+	for len(verdicts) < 3 {
+		select {
+		case event := <-flagged:
+			verdicts[event.Classification.Verdict] = event.Classification.Reason
+		case <-time.After(time.Second):
+			t.Fatal(fmt.Sprintf("flagged requests must be published: %v", verdicts))
+		}
+	}
+	if verdicts[VerdictThrottle] != "missing user agent" || verdicts[VerdictReject] != "browser user agent without accept-language" {
+		t.Error(fmt.Sprintf("wrong flagged events: %v", verdicts))
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := func(headers ...string) string {
+		ctx := &fasthttp.RequestCtx{}
+		for i := 0; i < len(headers); i += 2 {
+			ctx.Request.Header.Set(headers[i], headers[i+1])
+		}
+		return RequestFingerprint(ctx)
+	}
+	if fingerprint("Accept", "*/*", "Accept-Language", "en") == fingerprint("Accept-Language", "en", "Accept", "*/*") {
+		t.Error("header order must change the fingerprint")
+	}
+	if fingerprint("User-Agent", "a") != fingerprint("User-Agent", "a") {
+		t.Error("fingerprint must be stable")
+	}
+}
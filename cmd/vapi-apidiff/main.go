@@ -0,0 +1,88 @@
+// Command vapi-apidiff reports changes between two OpenAPI documents generated by vapi.
+//
+// Each argument is a json file or an http(s) url of a running server's OpenAPIHandler:
+//
+//	vapi-apidiff api-v1.json http://127.0.0.1:8080/openapi.json
+//
+// The exit code is 1 when breaking changes were found, so the tool can gate CI builds.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/riftbit/go-vapi"
+)
+
+func main() {
+	onlyBreaking := flag.Bool("breaking", false, "print breaking changes only")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: vapi-apidiff [-breaking] OLD NEW")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldDoc, err := load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	newDoc, err := load(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	changes := vapi.DiffOpenAPI(oldDoc, newDoc)
+	for _, change := range changes {
+		if *onlyBreaking && !change.Breaking {
+			continue
+		}
+		fmt.Println(change)
+	}
+
+	if vapi.HasBreakingChanges(changes) {
+		os.Exit(1)
+	}
+}
+
+// load reads document from file or url
+func load(source string) (*vapi.OpenAPIDocument, error) {
+	var (
+		body []byte
+		err  error
+	)
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, errGet := http.Get(source)
+		if errGet != nil {
+			return nil, errGet
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: unexpected status %s", source, resp.Status)
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+	} else {
+		body, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err.Error())
+	}
+
+	doc := &vapi.OpenAPIDocument{}
+	if err = json.Unmarshal(body, doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", source, err.Error())
+	}
+	return doc, nil
+}
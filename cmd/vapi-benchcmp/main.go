@@ -0,0 +1,121 @@
+// Command vapi-benchcmp compares two `go test -bench` outputs and fails on regressions.
+//
+// Results of repeated runs (-count) are averaged:
+//
+//	vapi-benchcmp -max 10 benchmarks/baseline.txt new.txt
+//
+// The exit code is 1 when any benchmark present in both files got slower
+// than the -max percentage, so the tool can gate CI builds.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// result is the average of benchmark runs
+type result struct {
+	nsPerOp     float64
+	allocsPerOp float64
+	runs        int
+}
+
+func main() {
+	maxRegression := flag.Float64("max", 10, "maximal allowed slowdown in percent")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: vapi-benchcmp [-max PERCENT] OLD NEW")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldResults, err := load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	newResults, err := load(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	names := make([]string, 0, len(newResults))
+	for name := range newResults {
+		if _, ok := oldResults[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	failed := false
+	for _, name := range names {
+		before, after := oldResults[name], newResults[name]
+		delta := (after.nsPerOp - before.nsPerOp) / before.nsPerOp * 100
+		mark := ""
+		if delta > *maxRegression {
+			mark, failed = "  REGRESSION", true
+		}
+		fmt.Printf("%-40s %12.1f ns/op %12.1f ns/op %+7.1f%% %8.1f allocs %8.1f allocs%s\n",
+			name, before.nsPerOp, after.nsPerOp, delta, before.allocsPerOp, after.allocsPerOp, mark)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// load parses benchmark lines of go test output
+func load(path string) (map[string]*result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	results := map[string]*result{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// strip the GOMAXPROCS suffix, e.g. BenchmarkItems1KB-8
+		name := fields[0]
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			name = name[:i]
+		}
+
+		res, ok := results[name]
+		if !ok {
+			res = &result{}
+			results[name] = res
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, errParse := strconv.ParseFloat(fields[i], 64)
+			if errParse != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.nsPerOp = (res.nsPerOp*float64(res.runs) + value) / float64(res.runs+1)
+			case "allocs/op":
+				res.allocsPerOp = (res.allocsPerOp*float64(res.runs) + value) / float64(res.runs+1)
+			}
+		}
+		res.runs++
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return results, nil
+}
@@ -0,0 +1,199 @@
+// Command vapi-vet reports service methods RegisterService would silently skip.
+//
+// Exported methods taking *fasthttp.RequestCtx as the first argument are
+// expected to be api methods when they have three arguments or their type has
+// other api methods (is a service), and must have the shape
+//
+//	func (s *Service) Method(ctx *fasthttp.RequestCtx, args *Args, reply *Reply) error
+//
+// Arguments are directories, "dir/..." checks the tree below dir:
+//
+//	vapi-vet ./...
+//
+// The exit code is 1 when problems were found, so the tool can gate CI builds.
+// The check is syntactic: it doesn't verify that args and reply implement the codec.
+// Test files are skipped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: vapi-vet [DIR | DIR/...]...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	fset := token.NewFileSet()
+	problems := 0
+	for _, pattern := range patterns {
+		dirs, err := expand(pattern)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, dir := range dirs {
+			packages, err := parser.ParseDir(fset, dir, skipTests, 0)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			for _, pkg := range packages {
+				for _, problem := range check(fset, pkg) {
+					fmt.Println(problem)
+					problems++
+				}
+			}
+		}
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
+
+// expand returns directories of pattern, "dir/..." includes subdirectories except vendor and testdata
+func expand(pattern string) ([]string, error) {
+	if !strings.HasSuffix(pattern, "/...") {
+		return []string{pattern}, nil
+	}
+	var dirs []string
+	err := filepath.Walk(strings.TrimSuffix(pattern, "/..."), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != "." && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// skipTests filters out test files
+func skipTests(info os.FileInfo) bool {
+	return !strings.HasSuffix(info.Name(), "_test.go")
+}
+
+// check returns problems of api methods declared in pkg
+func check(fset *token.FileSet, pkg *ast.Package) []string {
+	var methods []*ast.FuncDecl
+	services := map[string]bool{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || !fn.Name.IsExported() {
+				continue
+			}
+			params := flatten(fn.Type.Params)
+			if len(params) == 0 || !isRequestCtx(params[0]) {
+				continue
+			}
+			methods = append(methods, fn)
+			if shapeProblem(fn) == "" {
+				services[receiverName(fn.Recv)] = true
+			}
+		}
+	}
+
+	var problems []string
+	for _, fn := range methods {
+		reason := shapeProblem(fn)
+		if reason == "" || (len(flatten(fn.Type.Params)) != 3 && !services[receiverName(fn.Recv)]) {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s.%s %s", fset.Position(fn.Pos()), receiverName(fn.Recv), fn.Name.Name, reason))
+	}
+	return problems
+}
+
+// shapeProblem returns why fn isn't an api method, empty string when it is
+func shapeProblem(fn *ast.FuncDecl) string {
+	params := flatten(fn.Type.Params)
+	switch {
+	case len(params) != 3:
+		return fmt.Sprintf("has %d arguments, needs 3: *fasthttp.RequestCtx, *args, *reply", len(params))
+	case !isPointer(params[1]):
+		return "args must be a pointer"
+	case !isPointer(params[2]):
+		return "reply must be a pointer"
+	case !returnsError(fn.Type.Results):
+		return "must return only error"
+	}
+	return ""
+}
+
+// flatten returns type of every parameter, "a, b T" gives two entries
+func flatten(fields *ast.FieldList) []ast.Expr {
+	var types []ast.Expr
+	if fields == nil {
+		return types
+	}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// isRequestCtx reports whether expr is *<pkg>.RequestCtx
+func isRequestCtx(expr ast.Expr) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := star.X.(*ast.SelectorExpr)
+	return ok && selector.Sel.Name == "RequestCtx"
+}
+
+// isPointer reports whether expr is a pointer type
+func isPointer(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StarExpr)
+	return ok
+}
+
+// returnsError reports whether results are exactly error
+func returnsError(results *ast.FieldList) bool {
+	types := flatten(results)
+	if len(types) != 1 {
+		return false
+	}
+	ident, ok := types[0].(*ast.Ident)
+	return ok && ident.Name == "error"
+}
+
+// receiverName returns the receiver type name
+func receiverName(recv *ast.FieldList) string {
+	expr := recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return "?"
+}
@@ -22,6 +22,12 @@ type ServerResponse struct {
 	// null if there was no error.
 	// As per spec the member will be omitted if there was no error.
 	Error *Error `json:"error,omitempty"`
+
+	// Debug is the timing breakdown of the call returned in debug trace mode.
+	Debug json.RawMessage `json:"debug,omitempty"`
+
+	// Warnings are notices about the call, e.g. use of deprecated features.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // Error ...
@@ -216,6 +216,33 @@ func easyjson932ebafbDecodeGithubComRiftbitGoVapi2(in *jlexer.Lexer, out *Server
 				}
 				(*out.Error).UnmarshalEasyJSON(in)
 			}
+		case "debug":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Debug).UnmarshalJSON(data))
+			}
+		case "warnings":
+			if in.IsNull() {
+				in.Skip()
+				out.Warnings = nil
+			} else {
+				in.Delim('[')
+				if out.Warnings == nil {
+					if !in.IsDelim(']') {
+						out.Warnings = make([]string, 0, 4)
+					} else {
+						out.Warnings = []string{}
+					}
+				} else {
+					out.Warnings = (out.Warnings)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 string
+					v1 = string(in.String())
+					out.Warnings = append(out.Warnings, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -250,6 +277,35 @@ func easyjson932ebafbEncodeGithubComRiftbitGoVapi2(out *jwriter.Writer, in Serve
 		}
 		(*in.Error).MarshalEasyJSON(out)
 	}
+	if len(in.Debug) != 0 {
+		const prefix string = ",\"debug\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Raw((in.Debug).MarshalJSON())
+	}
+	if len(in.Warnings) != 0 {
+		const prefix string = ",\"warnings\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		{
+			out.RawByte('[')
+			for v2, v3 := range in.Warnings {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v3))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
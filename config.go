@@ -0,0 +1,266 @@
+package vapi
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Log levels of ServerConfig, they control access logging and startup output
+const (
+	LogDebug = "debug" // every request is logged, the route table is printed at start
+	LogInfo  = "info"  // failed, slow and 1% of successful requests are logged
+	LogWarn  = "warn"  // failed requests are logged
+	LogError = "error" // nothing is logged per request
+)
+
+// ServerConfig holds process level settings of a server, filled by ConfigFromEnv
+// or BindFlags and applied by RunConfig
+type ServerConfig struct {
+	// Addr is the tcp listen address
+	Addr string
+	// BaseURL is the public url of the api, its path is the method path prefix
+	BaseURL string
+	// TLSCert and TLSKey are certificate and key files, plain http when empty
+	TLSCert string
+	TLSKey  string
+	// LogLevel is one of LogDebug, LogInfo, LogWarn and LogError
+	LogLevel string
+	// Profile is the behavior profile name, see ParseProfile; none when empty
+	Profile string
+	// ReadTimeout and WriteTimeout of connections
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxBodySize is the max request body size in bytes
+	MaxBodySize int
+	// Concurrency is the max number of concurrently served connections
+	Concurrency int
+	// MemoryPerRequest and MemoryTotal are passed to SetMemoryLimit when set
+	MemoryPerRequest int64
+	MemoryTotal      int64
+	// LameDuck and DrainTimeout control graceful shutdown, see RunOptions
+	LameDuck     time.Duration
+	DrainTimeout time.Duration
+	// ObjectivesFile is a json file of service level objectives, see LoadObjectives
+	ObjectivesFile string
+}
+
+// DefaultServerConfig returns config with the defaults used by ConfigFromEnv
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:         ":8080",
+		BaseURL:      "/",
+		LogLevel:     LogInfo,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		MaxBodySize:  fasthttp.DefaultMaxRequestBodySize,
+		Concurrency:  fasthttp.DefaultConcurrency,
+		LameDuck:     5 * time.Second,
+		DrainTimeout: 30 * time.Second,
+	}
+}
+
+// ConfigFromEnv reads ServerConfig from environment variables, unset ones keep
+// the defaults: VAPI_ADDR (or PORT), VAPI_BASE_URL, VAPI_TLS_CERT, VAPI_TLS_KEY,
+// VAPI_LOG_LEVEL, VAPI_PROFILE, VAPI_READ_TIMEOUT, VAPI_WRITE_TIMEOUT,
+// VAPI_MAX_BODY_SIZE, VAPI_CONCURRENCY, VAPI_MEMORY_PER_REQUEST, VAPI_MEMORY_TOTAL,
+// VAPI_LAME_DUCK, VAPI_DRAIN_TIMEOUT and VAPI_SLO_FILE. Durations use Go syntax ("30s").
+func ConfigFromEnv() (ServerConfig, error) {
+	config := DefaultServerConfig()
+	if port := os.Getenv("PORT"); port != "" {
+		config.Addr = ":" + port
+	}
+
+	var errs []string
+	str := func(name string, target *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid duration %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	integer := func(name string, target *int64) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid number %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	str("VAPI_ADDR", &config.Addr)
+	str("VAPI_BASE_URL", &config.BaseURL)
+	str("VAPI_TLS_CERT", &config.TLSCert)
+	str("VAPI_TLS_KEY", &config.TLSKey)
+	str("VAPI_LOG_LEVEL", &config.LogLevel)
+	str("VAPI_PROFILE", &config.Profile)
+	str("VAPI_SLO_FILE", &config.ObjectivesFile)
+	duration("VAPI_READ_TIMEOUT", &config.ReadTimeout)
+	duration("VAPI_WRITE_TIMEOUT", &config.WriteTimeout)
+	duration("VAPI_LAME_DUCK", &config.LameDuck)
+	duration("VAPI_DRAIN_TIMEOUT", &config.DrainTimeout)
+	maxBodySize, concurrency := int64(config.MaxBodySize), int64(config.Concurrency)
+	integer("VAPI_MAX_BODY_SIZE", &maxBodySize)
+	integer("VAPI_CONCURRENCY", &concurrency)
+	integer("VAPI_MEMORY_PER_REQUEST", &config.MemoryPerRequest)
+	integer("VAPI_MEMORY_TOTAL", &config.MemoryTotal)
+	config.MaxBodySize, config.Concurrency = int(maxBodySize), int(concurrency)
+
+	if len(errs) > 0 {
+		return config, fmt.Errorf("vapi: invalid environment: %s", strings.Join(errs, "; "))
+	}
+	return config, config.Validate()
+}
+
+// BindFlags registers the standard server flags on fs, using current config
+// values as defaults and storing parsed values back into config. Binding config
+// returned by ConfigFromEnv makes flags override environment variables:
+//
+//	config, err := vapi.ConfigFromEnv()
+//	vapi.BindFlags(flag.CommandLine, &config)
+//	flag.Parse()
+//	err = server.RunConfig(config)
+func BindFlags(fs *flag.FlagSet, config *ServerConfig) {
+	fs.StringVar(&config.Addr, "addr", config.Addr, "listen address")
+	fs.StringVar(&config.BaseURL, "base-url", config.BaseURL, "public url of the api, its path prefixes methods")
+	fs.StringVar(&config.TLSCert, "tls-cert", config.TLSCert, "tls certificate file")
+	fs.StringVar(&config.TLSKey, "tls-key", config.TLSKey, "tls key file")
+	fs.StringVar(&config.LogLevel, "log-level", config.LogLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&config.Profile, "profile", config.Profile, "behavior profile: dev, stage or prod")
+	fs.DurationVar(&config.ReadTimeout, "read-timeout", config.ReadTimeout, "connection read timeout")
+	fs.DurationVar(&config.WriteTimeout, "write-timeout", config.WriteTimeout, "connection write timeout")
+	fs.IntVar(&config.MaxBodySize, "max-body-size", config.MaxBodySize, "max request body size in bytes")
+	fs.IntVar(&config.Concurrency, "concurrency", config.Concurrency, "max concurrently served connections")
+	fs.Int64Var(&config.MemoryPerRequest, "memory-per-request", config.MemoryPerRequest, "memory limit of a request in bytes, 0 disables it")
+	fs.Int64Var(&config.MemoryTotal, "memory-total", config.MemoryTotal, "memory limit of all requests in bytes, 0 disables it")
+	fs.DurationVar(&config.LameDuck, "lame-duck", config.LameDuck, "time to fail readiness before shutdown")
+	fs.DurationVar(&config.DrainTimeout, "drain-timeout", config.DrainTimeout, "max time to finish in-flight requests on shutdown")
+	fs.StringVar(&config.ObjectivesFile, "slo-file", config.ObjectivesFile, "json file of service level objectives")
+}
+
+// Validate checks the config is consistent
+func (c ServerConfig) Validate() error {
+	switch c.LogLevel {
+	case LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		return fmt.Errorf("vapi: unknown log level %q", c.LogLevel)
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("vapi: both tls certificate and key are needed")
+	}
+	if c.Profile != "" {
+		if _, err := ParseProfile(c.Profile); err != nil {
+			return err
+		}
+	}
+	if _, err := c.prefix(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// prefix returns the method path prefix from BaseURL
+func (c ServerConfig) prefix() (string, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("vapi: invalid base url %q", c.BaseURL)
+	}
+	path := base.Path
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path, nil
+}
+
+// RunFromEnv runs the server configured purely from environment variables (see
+// ConfigFromEnv), for containers without config files or flags
+func (as *VAPI) RunFromEnv() error {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	return as.RunConfig(config)
+}
+
+// RunConfig applies config to the server and runs it with Run
+func (as *VAPI) RunConfig(config ServerConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	options, err := as.applyConfig(config)
+	if err != nil {
+		return err
+	}
+	return as.Run(options)
+}
+
+// applyConfig applies server wide settings of config and returns options of Run
+func (as *VAPI) applyConfig(config ServerConfig) (RunOptions, error) {
+	prefix, err := config.prefix()
+	if err != nil {
+		return RunOptions{}, err
+	}
+	if config.Profile != "" {
+		profile, _ := ParseProfile(config.Profile)
+		as.SetProfile(profile)
+	}
+	if config.MemoryPerRequest > 0 || config.MemoryTotal > 0 {
+		as.SetMemoryLimit(config.MemoryPerRequest, config.MemoryTotal)
+	}
+	if config.ObjectivesFile != "" {
+		objectives, err := LoadObjectives(config.ObjectivesFile)
+		if err == nil {
+			err = as.SetObjectives(objectives...)
+		}
+		if err != nil {
+			return RunOptions{}, err
+		}
+	}
+
+	handler := as.Handler(prefix)
+	switch config.LogLevel {
+	case LogDebug:
+		handler = NewAccessLog(1, 1000, nil).Handler(handler)
+		as.PrintSummary(os.Stderr)
+	case LogInfo:
+		handler = NewAccessLog(0.01, 1000, nil).Handler(handler)
+	case LogWarn:
+		handler = NewAccessLog(0, 1000, nil).Handler(handler)
+	}
+	if config.LogLevel != LogError {
+		log.Printf("vapi: serving %s on %s", prefix, config.Addr)
+	}
+
+	return RunOptions{
+		Addr:         config.Addr,
+		Prefix:       prefix,
+		Handler:      handler,
+		TLSCert:      config.TLSCert,
+		TLSKey:       config.TLSKey,
+		LameDuck:     config.LameDuck,
+		DrainTimeout: config.DrainTimeout,
+		Configure: func(server *fasthttp.Server) {
+			server.ReadTimeout = config.ReadTimeout
+			server.WriteTimeout = config.WriteTimeout
+			server.MaxRequestBodySize = as.maxBodySize(config.MaxBodySize)
+			server.Concurrency = config.Concurrency
+		},
+	}, nil
+}
@@ -0,0 +1,95 @@
+package vapi
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func setEnv(t *testing.T, values map[string]string) {
+	for name, value := range values {
+		previous, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	config, err := ConfigFromEnv()
+	if err != nil || config.Addr != ":8080" || config.LogLevel != LogInfo {
+		t.Error(fmt.Sprintf("wrong defaults: %+v %v", config, err))
+	}
+
+	setEnv(t, map[string]string{
+		"PORT":                    "9000",
+		"VAPI_BASE_URL":           "https://api.example.com/v2",
+		"VAPI_LOG_LEVEL":          "error",
+		"VAPI_PROFILE":            "prod",
+		"VAPI_READ_TIMEOUT":       "3s",
+		"VAPI_MAX_BODY_SIZE":      "1024",
+		"VAPI_MEMORY_PER_REQUEST": "2048",
+	})
+	config, err = ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Addr != ":9000" || config.ReadTimeout != 3*time.Second || config.MaxBodySize != 1024 || config.MemoryPerRequest != 2048 {
+		t.Error(fmt.Sprintf("wrong config: %+v", config))
+	}
+
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	options, err := as.applyConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Prefix != "/v2/" || options.Addr != ":9000" || as.Profile().Name != "prod" || as.memory.perRequest != 2048 {
+		t.Error(fmt.Sprintf("wrong options: %+v", options))
+	}
+	server := &fasthttp.Server{}
+	options.Configure(server)
+	if server.MaxRequestBodySize != 1024 || server.ReadTimeout != 3*time.Second {
+		t.Error(fmt.Sprintf("wrong server: %+v", server))
+	}
+
+	setEnv(t, map[string]string{"VAPI_TLS_CERT": "cert.pem", "VAPI_CONCURRENCY": "many"})
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("invalid number must fail")
+	}
+	setEnv(t, map[string]string{"VAPI_CONCURRENCY": "10"})
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("certificate without key must fail")
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	config := DefaultServerConfig()
+	config.Addr = ":7000"
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	BindFlags(fs, &config)
+	if fs.Lookup("addr").DefValue != ":7000" {
+		t.Error("flag default must come from config")
+	}
+
+	err := fs.Parse([]string{"-base-url", "/api", "-read-timeout", "2s", "-log-level", "warn", "-memory-total", "4096"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Addr != ":7000" || config.BaseURL != "/api" || config.ReadTimeout != 2*time.Second || config.LogLevel != LogWarn || config.MemoryTotal != 4096 {
+		t.Error(fmt.Sprintf("wrong config: %+v", config))
+	}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
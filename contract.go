@@ -0,0 +1,257 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Contract holds the expectations of one api consumer
+type Contract struct {
+	Consumer     string                `json:"consumer"`
+	Interactions []ContractInteraction `json:"interactions"`
+}
+
+// ContractInteraction is a recorded call and the reply the consumer relies on.
+//
+// By default the reply is matched by shape: every field of the expected reply
+// must be present with the same json type, extra fields are allowed and array
+// elements are matched against the first expected element. Set Match to
+// "exact" to compare values as well.
+type ContractInteraction struct {
+	Description string          `json:"description"`
+	Method      string          `json:"method"`
+	Args        json.RawMessage `json:"args"`
+	Status      int             `json:"status,omitempty"`
+	Reply       json.RawMessage `json:"reply,omitempty"`
+	ErrorCode   *int            `json:"error_code,omitempty"`
+	Match       string          `json:"match,omitempty"`
+}
+
+// ContractMismatch describes an unsatisfied expectation
+type ContractMismatch struct {
+	Consumer    string
+	Interaction string
+	Path        string
+	Message     string
+}
+
+// String returns mismatch description
+func (m ContractMismatch) String() string {
+	return fmt.Sprintf("%s / %s: %s: %s", m.Consumer, m.Interaction, m.Path, m.Message)
+}
+
+// ContractCaller executes the call of method with json args and returns http status and response body
+type ContractCaller func(method string, args []byte) (status int, body []byte, err error)
+
+// LoadContract reads consumer contract from json file
+func LoadContract(path string) (*Contract, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't read contract: %s", err.Error())
+	}
+	contract := &Contract{}
+	if err = json.Unmarshal(body, contract); err != nil {
+		return nil, fmt.Errorf("vapi: can't parse contract %s: %s", path, err.Error())
+	}
+	return contract, nil
+}
+
+// VerifyContract calls registered methods in-process and returns mismatches with the contract
+func (as *VAPI) VerifyContract(contract *Contract) []ContractMismatch {
+	return VerifyContractWith(contract, as.callLocal)
+}
+
+// RemoteContractCaller returns ContractCaller posting calls to baseURL + "/" + method of a running server
+func RemoteContractCaller(baseURL string) ContractCaller {
+	return func(method string, args []byte) (int, []byte, error) {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI(strings.TrimRight(baseURL, "/") + "/" + method)
+		req.Header.SetMethod("POST")
+		req.Header.SetContentType("application/json")
+		req.SetBody(args)
+
+		if err := fasthttp.Do(req, resp); err != nil {
+			return 0, nil, err
+		}
+		return resp.StatusCode(), append([]byte(nil), resp.Body()...), nil
+	}
+}
+
+// VerifyContractWith executes contract interactions with caller and returns mismatches
+func VerifyContractWith(contract *Contract, caller ContractCaller) []ContractMismatch {
+	var mismatches []ContractMismatch
+
+	for _, interaction := range contract.Interactions {
+		report := func(path, message string) {
+			mismatches = append(mismatches, ContractMismatch{
+				Consumer:    contract.Consumer,
+				Interaction: interaction.Description,
+				Path:        path,
+				Message:     message,
+			})
+		}
+
+		args := []byte(interaction.Args)
+		if len(args) == 0 {
+			args = []byte("{}")
+		}
+
+		status, body, err := caller(interaction.Method, args)
+		if err != nil {
+			report(interaction.Method, "call failed: "+err.Error())
+			continue
+		}
+
+		expectedStatus := interaction.Status
+		if expectedStatus == 0 && interaction.ErrorCode == nil {
+			expectedStatus = fasthttp.StatusOK
+		}
+		if expectedStatus != 0 && status != expectedStatus {
+			report("status", fmt.Sprintf("expected %d, got %d", expectedStatus, status))
+		}
+
+		envelope := struct {
+			Response json.RawMessage `json:"response"`
+			Error    *Error          `json:"error"`
+		}{}
+		if err = json.Unmarshal(body, &envelope); err != nil {
+			report("body", "malformed response: "+err.Error())
+			continue
+		}
+
+		if interaction.ErrorCode != nil {
+			if envelope.Error == nil {
+				report("error", "expected error, got successful reply")
+			} else if envelope.Error.ErrorCode != *interaction.ErrorCode {
+				report("error.error_code", fmt.Sprintf("expected %d, got %d", *interaction.ErrorCode, envelope.Error.ErrorCode))
+			}
+			continue
+		}
+
+		if envelope.Error != nil {
+			report("error", "unexpected error: "+envelope.Error.ErrorMessage)
+			continue
+		}
+		if len(interaction.Reply) == 0 {
+			continue
+		}
+
+		var expected, actual interface{}
+		if err = json.Unmarshal(interaction.Reply, &expected); err != nil {
+			report("reply", "malformed expected reply: "+err.Error())
+			continue
+		}
+		if err = json.Unmarshal(envelope.Response, &actual); err != nil {
+			report("reply", "malformed reply: "+err.Error())
+			continue
+		}
+		matchJSON("reply", expected, actual, interaction.Match == "exact", report)
+	}
+
+	return mismatches
+}
+
+// matchJSON compares decoded json values, reporting differences
+func matchJSON(path string, expected, actual interface{}, exact bool, report func(path, message string)) {
+	if jsonType(expected) != jsonType(actual) {
+		report(path, fmt.Sprintf("expected %s, got %s", jsonType(expected), jsonType(actual)))
+		return
+	}
+
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualValue := actual.(map[string]interface{})
+		keys := make([]string, 0, len(expectedValue))
+		for key := range expectedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := actualValue[key]
+			if !ok {
+				report(path+"."+key, "field is missing")
+				continue
+			}
+			matchJSON(path+"."+key, expectedValue[key], value, exact, report)
+		}
+	case []interface{}:
+		actualValue := actual.([]interface{})
+		if exact && len(expectedValue) != len(actualValue) {
+			report(path, fmt.Sprintf("expected %d elements, got %d", len(expectedValue), len(actualValue)))
+			return
+		}
+		if len(expectedValue) == 0 {
+			return
+		}
+		for i, value := range actualValue {
+			expectedElement := expectedValue[0]
+			if exact {
+				expectedElement = expectedValue[i]
+			}
+			matchJSON(fmt.Sprintf("%s[%d]", path, i), expectedElement, value, exact, report)
+		}
+	default:
+		if exact && !reflect.DeepEqual(expected, actual) {
+			report(path, fmt.Sprintf("expected %v, got %v", expected, actual))
+		}
+	}
+}
+
+// jsonType returns json type name of decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// localCallUserValue is the RequestCtx user value key marking calls made by callLocalWith,
+// their replies are read by the framework (brokers, cron, Call), so they are always json
+const localCallUserValue = "vapi.local_call"
+
+// callLocal executes method in-process through CallAPI
+func (as *VAPI) callLocal(method string, args []byte) (int, []byte, error) {
+	return as.callLocalWith(method, args, nil, nil)
+}
+
+// callLocalWith executes method in-process through CallAPI as called from remoteAddr (nil for unknown),
+// prepare (if any) sets up the request context
+func (as *VAPI) callLocalWith(method string, args []byte, remoteAddr net.Addr, prepare func(ctx *fasthttp.RequestCtx)) (int, []byte, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod("POST")
+	req.SetRequestURI("/" + method)
+	req.SetBody(args)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(req, remoteAddr, nil)
+	ctx.SetUserValue(localCallUserValue, true)
+	if prepare != nil {
+		prepare(ctx)
+	}
+	as.CallAPI(ctx, method)
+
+	return ctx.Response.StatusCode(), append([]byte(nil), ctx.Response.Body()...), nil
+}
@@ -0,0 +1,42 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVAPI_VerifyContract(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := &Contract{}
+	err := json.Unmarshal([]byte(`{
+		"consumer": "frontend",
+		"interactions": [
+			{"description": "echo", "method": "demo.Test", "args": {"id": "1", "ttt": "x"}, "reply": {"id": "any", "ttt": "any"}},
+			{"description": "echo exact", "method": "demo.Test", "args": {"id": "1"}, "reply": {"id": "2"}, "match": "exact"},
+			{"description": "missing field", "method": "demo.Test", "args": {"id": "1"}, "reply": {"id": "1", "name": "john"}},
+			{"description": "error", "method": "demo.ErrorTest", "error_code": 606, "status": 424}
+		]
+	}`), contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, mismatch := range as.VerifyContract(contract) {
+		got = append(got, mismatch.String())
+	}
+
+	expected := []string{
+		`frontend / echo exact: reply.id: expected 2, got 1`,
+		`frontend / missing field: reply.name: field is missing`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Error(fmt.Sprintf("wrong mismatches:\n%s", strings.Join(got, "\n")))
+	}
+}
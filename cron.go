@@ -0,0 +1,342 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cronField bounds
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// cronAliases are the supported predefined schedules
+var cronAliases = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// cronSchedule is a parsed five field cron expression
+type cronSchedule struct {
+	fields [5]uint64 // minute, hour, day of month, month, day of week bitsets
+	anyDom bool
+	anyDow bool
+}
+
+// parseCron parses "minute hour day-of-month month day-of-week" expression with
+// lists, ranges and steps ("*/15 9-18 * * 1-5") or one of the @ aliases
+func parseCron(spec string) (*cronSchedule, error) {
+	if alias, ok := cronAliases[strings.TrimSpace(spec)]; ok {
+		spec = alias
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("vapi: cron expression %q must have 5 fields", spec)
+	}
+
+	schedule := &cronSchedule{anyDom: parts[2] == "*", anyDow: parts[4] == "*"}
+	for i, part := range parts {
+		for _, item := range strings.Split(part, ",") {
+			bits, err := parseCronItem(item, cronBounds[i][0], cronBounds[i][1])
+			if err != nil {
+				return nil, fmt.Errorf("vapi: invalid cron expression %q: %s", spec, err.Error())
+			}
+			schedule.fields[i] |= bits
+		}
+	}
+	// 7 is sunday too
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+	return schedule, nil
+}
+
+// parseCronItem parses "*", "n", "a-b" with optional "/step" into bitset
+func parseCronItem(item string, min, max int) (uint64, error) {
+	step := 1
+	if i := strings.IndexByte(item, '/'); i >= 0 {
+		parsed, err := strconv.Atoi(item[i+1:])
+		if err != nil || parsed <= 0 {
+			return 0, fmt.Errorf("bad step in %q", item)
+		}
+		step, item = parsed, item[:i]
+	}
+
+	from, to := min, max
+	if item != "*" {
+		bounds := strings.SplitN(item, "-", 2)
+		var err error
+		if from, err = strconv.Atoi(bounds[0]); err != nil {
+			return 0, fmt.Errorf("bad value %q", item)
+		}
+		to = from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("bad range %q", item)
+			}
+		} else if step > 1 {
+			to = max
+		}
+	}
+	if max == 6 {
+		// day of week accepts 7 for sunday
+		max = 7
+	}
+	if from < min || to > max || from > to {
+		return 0, fmt.Errorf("%q is out of range %d-%d", item, min, max)
+	}
+
+	bits := uint64(0)
+	for v := from; v <= to; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+// matches reports whether bit v is set
+func cronMatches(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// dayMatches applies cron rule: when both day fields are restricted either may match
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	dom := cronMatches(cs.fields[2], t.Day())
+	dow := cronMatches(cs.fields[4], int(t.Weekday()))
+	if cs.anyDom || cs.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after t matching the schedule, zero time when there is none within 5 years
+func (cs *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !cronMatches(cs.fields[3], int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cronMatches(cs.fields[1], t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cronMatches(cs.fields[0], t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// cronHistorySize is the number of runs kept per job
+const cronHistorySize = 100
+
+// ScheduledRun is a record of a scheduled invocation
+type ScheduledRun struct {
+	Job      string
+	Method   string
+	Started  time.Time
+	Duration time.Duration
+	// Status is the http status of the call, 0 when the run was skipped
+	Status int
+	// Skipped is true when the previous run of the job was still in progress
+	Skipped bool
+}
+
+// cronJob is a scheduled method
+type cronJob struct {
+	name     string
+	method   string
+	args     []byte
+	schedule *cronSchedule
+	due      time.Time
+	running  int32
+	history  []ScheduledRun
+}
+
+// Scheduler invokes registered methods on cron schedules, replacing external cron jobs
+// calling the api. Runs of a job never overlap: a run due while the previous one is
+// still in progress is skipped and recorded as such.
+type Scheduler struct {
+	as       *VAPI
+	location *time.Location
+	now      func() time.Time
+
+	mutex  sync.Mutex
+	jobs   []*cronJob
+	stop   chan struct{}
+	wakeup chan struct{}
+}
+
+// NewScheduler returns stopped scheduler evaluating schedules in location (nil for local time)
+func (as *VAPI) NewScheduler(location *time.Location) *Scheduler {
+	if location == nil {
+		location = time.Local
+	}
+	return &Scheduler{as: as, location: location, now: time.Now, wakeup: make(chan struct{}, 1)}
+}
+
+// Add schedules calls of method with json args, name identifies the job in history
+func (s *Scheduler) Add(name, spec, method string, args []byte) error {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	if _, err = s.as.get(method); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []byte("{}")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, job := range s.jobs {
+		if job.name == name {
+			return fmt.Errorf("vapi: job %q is already scheduled", name)
+		}
+	}
+	s.jobs = append(s.jobs, &cronJob{
+		name:     name,
+		method:   method,
+		args:     args,
+		schedule: schedule,
+		due:      schedule.next(s.now().In(s.location)),
+	})
+
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start runs the scheduler until Stop
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	if s.stop != nil {
+		s.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mutex.Unlock()
+
+	go func() {
+		for {
+			timer := time.NewTimer(s.untilDue())
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-s.wakeup:
+				timer.Stop()
+			case <-timer.C:
+				s.runDue()
+			}
+		}
+	}()
+}
+
+// Stop stops scheduling new runs, running ones are finished
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.mutex.Unlock()
+}
+
+// History returns recorded runs of the job, oldest first
+func (s *Scheduler) History(name string) []ScheduledRun {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, job := range s.jobs {
+		if job.name == name {
+			return append([]ScheduledRun(nil), job.history...)
+		}
+	}
+	return nil
+}
+
+// untilDue returns time until the earliest due job
+func (s *Scheduler) untilDue() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	wait := time.Hour
+	now := s.now()
+	for _, job := range s.jobs {
+		if job.due.IsZero() {
+			continue
+		}
+		if d := job.due.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// runDue starts all due jobs
+func (s *Scheduler) runDue() {
+	now := s.now().In(s.location)
+
+	s.mutex.Lock()
+	var due []*cronJob
+	for _, job := range s.jobs {
+		if !job.due.IsZero() && !job.due.After(now) {
+			due = append(due, job)
+			job.due = job.schedule.next(now)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, job := range due {
+		s.run(job, now)
+	}
+}
+
+// run executes job unless its previous run is in progress
+func (s *Scheduler) run(job *cronJob, started time.Time) {
+	if !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		s.record(job, ScheduledRun{Job: job.name, Method: job.method, Started: started, Skipped: true})
+		return
+	}
+
+	err := s.as.Go(context.Background(), func(ctx context.Context) {
+		defer atomic.StoreInt32(&job.running, 0)
+		status, _, _ := s.as.callLocal(job.method, job.args)
+		s.record(job, ScheduledRun{Job: job.name, Method: job.method, Started: started, Duration: time.Since(started), Status: status})
+	})
+	if err != nil {
+		atomic.StoreInt32(&job.running, 0)
+		s.record(job, ScheduledRun{Job: job.name, Method: job.method, Started: started, Skipped: true})
+	}
+}
+
+// record appends run to job history
+func (s *Scheduler) record(job *cronJob, run ScheduledRun) {
+	s.mutex.Lock()
+	job.history = append(job.history, run)
+	if len(job.history) > cronHistorySize {
+		job.history = job.history[len(job.history)-cronHistorySize:]
+	}
+	s.mutex.Unlock()
+}
@@ -0,0 +1,65 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2021, 3, 5, 10, 7, 30, 0, time.UTC) // friday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2021, 3, 5, 10, 15, 0, 0, time.UTC)},
+		{"0 9-18 * * 1-5", time.Date(2021, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2021, 3, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := parseCron(test.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if next := schedule.next(from); !next.Equal(test.expected) {
+			t.Error(fmt.Sprintf("%s: expected %s, got %s", test.spec, test.expected, next))
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Error(fmt.Sprintf("%q must be rejected", spec))
+		}
+	}
+}
+
+func TestScheduler_Overlap(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	s := as.NewScheduler(time.UTC)
+	if err := s.Add("ping", "* * * * *", "demo.Test", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("bad", "* * * * *", "demo.Missing", nil); err == nil {
+		t.Error("unknown method must be rejected")
+	}
+
+	job := s.jobs[0]
+	job.running = 1
+	s.run(job, time.Now())
+	job.running = 0
+	s.run(job, time.Now())
+	as.Workers().Drain(time.Second)
+
+	history := s.History("ping")
+	if len(history) != 2 || !history[0].Skipped || history[1].Status != 200 {
+		t.Error(fmt.Sprintf("wrong history: %+v", history))
+	}
+}
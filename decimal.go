@@ -0,0 +1,220 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact fixed-point decimal number for money and other values
+// which must not suffer from float rounding.
+//
+// It is encoded in json as a string ("12.50") and decoded from both json
+// strings and numbers. The zero value is 0.
+type Decimal struct {
+	unscaled big.Int
+	scale    int32
+}
+
+var bigTen = big.NewInt(10)
+
+// maxDecimalExponent limits exponents of parsed decimals, so "1e999999999" can't exhaust memory
+const maxDecimalExponent = 1000
+
+// NewDecimal returns unscaled * 10^-scale, e.g. NewDecimal(1250, 2) is 12.50
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	d := Decimal{scale: scale}
+	d.unscaled.SetInt64(unscaled)
+	return d
+}
+
+// ParseDecimal parses decimal in plain notation ("-12.50") or with exponent ("1.25e1")
+func ParseDecimal(s string) (Decimal, error) {
+	d := Decimal{}
+	value := s
+
+	exponent := int64(0)
+	if i := strings.IndexAny(value, "eE"); i >= 0 {
+		parsed, err := strconv.ParseInt(value[i+1:], 10, 32)
+		if err != nil || parsed > maxDecimalExponent || parsed < -maxDecimalExponent {
+			return d, fmt.Errorf("vapi: invalid decimal %q", s)
+		}
+		exponent = parsed
+		value = value[:i]
+	}
+
+	digits := value
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		digits = value[:i] + value[i+1:]
+		exponent -= int64(len(value) - i - 1)
+	}
+	if digits == "" || digits == "-" || digits == "+" || strings.ContainsAny(digits[1:], "+-") {
+		return d, fmt.Errorf("vapi: invalid decimal %q", s)
+	}
+	if _, ok := d.unscaled.SetString(digits, 10); !ok {
+		return d, fmt.Errorf("vapi: invalid decimal %q", s)
+	}
+
+	if exponent > 0 {
+		d.unscaled.Mul(&d.unscaled, new(big.Int).Exp(bigTen, big.NewInt(exponent), nil))
+		exponent = 0
+	}
+	d.scale = int32(-exponent)
+	return d, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics on malformed input
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// String returns the decimal in plain notation keeping its scale, e.g. "12.50"
+func (d Decimal) String() string {
+	digits := d.unscaled.String()
+	if d.scale <= 0 {
+		if d.unscaled.Sign() == 0 {
+			return "0"
+		}
+		return digits + strings.Repeat("0", int(-d.scale))
+	}
+
+	sign := ""
+	if digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Scale returns the number of digits after the decimal point
+func (d Decimal) Scale() int32 {
+	if d.scale < 0 {
+		return 0
+	}
+	return d.scale
+}
+
+// Precision returns the number of significant digits
+func (d Decimal) Precision() int {
+	digits := len(new(big.Int).Abs(&d.unscaled).String())
+	if d.scale < 0 {
+		digits += int(-d.scale)
+	}
+	return digits
+}
+
+// Sign returns -1, 0 or +1
+func (d Decimal) Sign() int {
+	return d.unscaled.Sign()
+}
+
+// Cmp compares d and other, returns -1, 0 or +1
+func (d Decimal) Cmp(other Decimal) int {
+	a, b := align(d, other)
+	return a.Cmp(b)
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b := align(d, other)
+	return decimalOf(new(big.Int).Add(a, b), maxScale(d, other))
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b := align(d, other)
+	return decimalOf(new(big.Int).Sub(a, b), maxScale(d, other))
+}
+
+// Mul returns d * other with the sum of scales
+func (d Decimal) Mul(other Decimal) Decimal {
+	return decimalOf(new(big.Int).Mul(&d.unscaled, &other.unscaled), d.scale+other.scale)
+}
+
+// Round returns d rounded half away from zero to scale digits after the decimal point
+func (d Decimal) Round(scale int32) Decimal {
+	if scale >= d.scale {
+		return decimalOf(new(big.Int).Mul(&d.unscaled, pow10(scale-d.scale)), scale)
+	}
+
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(&d.unscaled, divisor, new(big.Int))
+	// |remainder| * 2 >= divisor rounds away from zero
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(divisor) >= 0 {
+		if d.unscaled.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return decimalOf(quotient, scale)
+}
+
+// MarshalJSON encodes decimal as json string
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes decimal from json string or number
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	value := string(bytes.Trim(data, `"`))
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// decimalOf builds decimal from unscaled value
+func decimalOf(unscaled *big.Int, scale int32) Decimal {
+	d := Decimal{scale: scale}
+	d.unscaled.Set(unscaled)
+	return d
+}
+
+// align returns unscaled values of a and b brought to the same scale
+func align(a, b Decimal) (*big.Int, *big.Int) {
+	scale := maxScale(a, b)
+	return new(big.Int).Mul(&a.unscaled, pow10(scale-a.scale)), new(big.Int).Mul(&b.unscaled, pow10(scale-b.scale))
+}
+
+// maxScale returns the larger scale
+func maxScale(a, b Decimal) int32 {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+// pow10 returns 10^n
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,87 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := map[string]string{
+		"12.50":  "12.50",
+		"-0.05":  "-0.05",
+		"1.25e1": "12.5",
+		"3e2":    "300",
+		"0.00":   "0.00",
+		"+7":     "7",
+		"1e-3":   "0.001",
+		".5":     "0.5",
+	}
+	for input, expected := range cases {
+		d, err := ParseDecimal(input)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if d.String() != expected {
+			t.Error(fmt.Sprintf("wrong decimal %q: %s", input, d))
+		}
+	}
+
+	for _, input := range []string{"", "-", "1.2.3", "1-2", "abc", "1e", "1e99999"} {
+		if _, err := ParseDecimal(input); err == nil {
+			t.Error(fmt.Sprintf("invalid decimal %q must be rejected", input))
+		}
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	price := MustParseDecimal("19.99")
+	quantity := NewDecimal(3, 0)
+	tax := MustParseDecimal("0.2")
+
+	total := price.Mul(quantity)
+	if total.String() != "59.97" {
+		t.Error(fmt.Sprintf("wrong total: %s", total))
+	}
+	withTax := total.Add(total.Mul(tax)).Round(2)
+	if withTax.String() != "71.96" {
+		t.Error(fmt.Sprintf("wrong total with tax: %s", withTax))
+	}
+	if MustParseDecimal("-2.345").Round(2).String() != "-2.35" {
+		t.Error("negative values must be rounded away from zero")
+	}
+	if price.Sub(price).Sign() != 0 || price.Cmp(MustParseDecimal("19.990")) != 0 {
+		t.Error("wrong comparison")
+	}
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	var args struct {
+		Price  Decimal  `json:"price" decimal:"precision=5,scale=2"`
+		Amount *Decimal `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 123.45, "amount": "0.1"}`), &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Price.String() != "123.45" || args.Amount.String() != "0.1" {
+		t.Error(fmt.Sprintf("wrong decoded values: %s %s", args.Price, args.Amount))
+	}
+	body, _ := json.Marshal(args)
+	if string(body) != `{"price":"123.45","amount":"0.1"}` {
+		t.Error(fmt.Sprintf("wrong encoded value: %s", body))
+	}
+
+	if err := validateArgs(reflect.ValueOf(&args)); err != nil {
+		t.Error(err)
+	}
+	args.Price = MustParseDecimal("1.005")
+	if err := validateArgs(reflect.ValueOf(&args)); err == nil {
+		t.Error("scale overflow must be rejected")
+	}
+	args.Price = MustParseDecimal("1234.5")
+	if err := validateArgs(reflect.ValueOf(&args)); err == nil {
+		t.Error("precision overflow must be rejected")
+	}
+}
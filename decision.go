@@ -0,0 +1,286 @@
+package vapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// decisionUserValue is the RequestCtx user value key of the policy decision of the call
+const decisionUserValue = "vapi.decision"
+
+var errDecisionFailed = errors.New("vapi: policy evaluation failed")
+
+var errUnredactable = errors.New("vapi: access denied: file replies can't hide fields")
+
+// PolicyInput is the structured input of a policy decision point
+type PolicyInput struct {
+	Principal string            `json:"principal"`
+	Method    string            `json:"method"`
+	Verb      string            `json:"verb"`
+	Roles     []string          `json:"roles,omitempty"`
+	Country   string            `json:"country,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	RemoteIP  string            `json:"remote_ip"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// ArgsDigest is the hex sha256 of the raw args, so policies can pin
+	// approved payloads without receiving them
+	ArgsDigest string `json:"args_digest"`
+}
+
+// PolicyDecision is the result of a policy decision point
+type PolicyDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+	// HideFields are obligations to remove reply fields, dotted paths like "owner.email"
+	HideFields []string `json:"hide_fields,omitempty"`
+}
+
+// DecisionPoint evaluates access policies, e.g. an embedded OPA engine or an external PDP
+type DecisionPoint interface {
+	Decide(ctx context.Context, input PolicyInput) (PolicyDecision, error)
+}
+
+// DecisionPointFunc adapts a function to DecisionPoint
+type DecisionPointFunc func(ctx context.Context, input PolicyInput) (PolicyDecision, error)
+
+// Decide implements DecisionPoint
+func (f DecisionPointFunc) Decide(ctx context.Context, input PolicyInput) (PolicyDecision, error) {
+	return f(ctx, input)
+}
+
+// decisionPoint is the configured DecisionPoint
+type decisionPoint struct {
+	pdp       DecisionPoint
+	principal PrincipalFunc
+	headers   []string
+	timeout   time.Duration
+}
+
+// SetDecisionPoint makes every call ask pdp before dispatch. Denied calls get 403,
+// failed evaluations 503 (fail closed). headers are the request headers copied to
+// PolicyInput. Passing nil pdp disables the hook.
+func (as *VAPI) SetDecisionPoint(pdp DecisionPoint, principal PrincipalFunc, timeout time.Duration, headers ...string) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if pdp == nil {
+		as.decisions = nil
+		return
+	}
+	as.decisions = &decisionPoint{pdp: pdp, principal: principal, headers: headers, timeout: timeout}
+}
+
+// OPADecisionPoint queries the OPA REST data api at url, e.g.
+// "http://localhost:8181/v1/data/vapi/authz", posting {"input": PolicyInput}
+// and reading PolicyDecision from "result"
+func OPADecisionPoint(url string) DecisionPoint {
+	return DecisionPointFunc(func(ctx context.Context, input PolicyInput) (PolicyDecision, error) {
+		decision := PolicyDecision{}
+		body, err := json.Marshal(map[string]PolicyInput{"input": input})
+		if err != nil {
+			return decision, err
+		}
+
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(url)
+		req.Header.SetMethod("POST")
+		req.Header.SetContentType("application/json")
+		req.SetBody(body)
+
+		timeout := time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		if err = fasthttp.DoTimeout(req, resp, timeout); err != nil {
+			return decision, err
+		}
+		if resp.StatusCode() != fasthttp.StatusOK {
+			return decision, fmt.Errorf("vapi: decision point responded with status %d", resp.StatusCode())
+		}
+		result := struct {
+			Result *PolicyDecision `json:"result"`
+		}{}
+		if err = json.Unmarshal(resp.Body(), &result); err != nil {
+			return decision, err
+		}
+		if result.Result == nil {
+			return decision, errors.New("vapi: decision point returned no result, is the policy loaded?")
+		}
+		return *result.Result, nil
+	})
+}
+
+// decide asks the decision point, writes the rejection and returns false when the call must not proceed
+func (as *VAPI) decide(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, verb string) bool {
+	as.mutex.RLock()
+	dp, roles := as.decisions, as.roles
+	as.mutex.RUnlock()
+	if dp == nil {
+		return true
+	}
+
+	digest := sha256.Sum256(ctx.Request.Body())
+	input := PolicyInput{
+		Method:     methodSpec.name,
+		Verb:       verb,
+		Country:    GetCountry(ctx),
+		Tags:       RequestTags(ctx),
+		RemoteIP:   ctx.RemoteIP().String(),
+		ArgsDigest: hex.EncodeToString(digest[:]),
+	}
+	if dp.principal != nil {
+		input.Principal = dp.principal(ctx)
+	}
+	if roles != nil {
+		input.Roles = roles(ctx)
+	}
+	for _, header := range dp.headers {
+		if value := ctx.Request.Header.Peek(header); len(value) > 0 {
+			if input.Headers == nil {
+				input.Headers = make(map[string]string, len(dp.headers))
+			}
+			input.Headers[header] = string(value)
+		}
+	}
+
+	evalCtx := context.Background()
+	if dp.timeout > 0 {
+		var cancel context.CancelFunc
+		evalCtx, cancel = context.WithTimeout(evalCtx, dp.timeout)
+		defer cancel()
+	}
+	decision, err := dp.pdp.Decide(evalCtx, input)
+	if err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errDecisionFailed)
+		return false
+	}
+	if !decision.Allow {
+		reason := errAccessDenied
+		if decision.Reason != "" {
+			reason = fmt.Errorf("vapi: access denied: %s", decision.Reason)
+		}
+		as.writeError(ctx, srvResponse, fasthttp.StatusForbidden, reason)
+		return false
+	}
+	if len(decision.HideFields) > 0 {
+		ctx.SetUserValue(decisionUserValue, decision)
+	}
+	return true
+}
+
+// hideReplyFields zeroes reply fields hidden by the policy decision of the call, so the
+// obligations hold for html replies too. File replies can't be redacted and are refused.
+func hideReplyFields(ctx *fasthttp.RequestCtx, reply reflect.Value) error {
+	decision, ok := ctx.UserValue(decisionUserValue).(PolicyDecision)
+	if !ok {
+		return nil
+	}
+	if _, ok = reply.Interface().(*FileReply); ok {
+		return errUnredactable
+	}
+	for _, path := range decision.HideFields {
+		if err := zeroField(reply, strings.Split(path, ".")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zeroField zeroes the field at json path, descending into pointers, maps and slices
+func zeroField(v reflect.Value, path []string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return zeroField(v.Elem(), path)
+	case reflect.Interface:
+		return walkInterface(v, func(elem reflect.Value) error {
+			return zeroField(elem, path)
+		})
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || jsonFieldName(field) != path[0] {
+				continue
+			}
+			if len(path) > 1 {
+				return zeroField(v.Field(i), path[1:])
+			}
+			if !v.Field(i).CanSet() {
+				return fmt.Errorf("vapi: hidden field %s can't be set", field.Name)
+			}
+			v.Field(i).Set(reflect.Zero(field.Type))
+			return nil
+		}
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		if len(path) == 1 {
+			v.SetMapIndex(key, reflect.Value{})
+			return nil
+		}
+		item := v.MapIndex(key)
+		if !item.IsValid() {
+			return nil
+		}
+		copied := reflect.New(item.Type()).Elem()
+		copied.Set(item)
+		if err := zeroField(copied, path[1:]); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, copied)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := zeroField(v.Index(i), path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyObligations removes reply fields hidden by the policy decision of the call
+func applyObligations(ctx *fasthttp.RequestCtx, reply []byte) ([]byte, error) {
+	decision, ok := ctx.UserValue(decisionUserValue).(PolicyDecision)
+	if !ok {
+		return reply, nil
+	}
+	var document interface{}
+	if err := json.Unmarshal(reply, &document); err != nil {
+		return nil, err
+	}
+	for _, path := range decision.HideFields {
+		hideField(document, strings.Split(path, "."))
+	}
+	return json.Marshal(document)
+}
+
+// hideField deletes the field at path, descending into arrays
+func hideField(document interface{}, path []string) {
+	switch v := document.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		hideField(v[path[0]], path[1:])
+	case []interface{}:
+		for _, item := range v {
+			hideField(item, path)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type ProfileReply struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (r *ProfileReply) MarshalJSON() ([]byte, error) {
+	type plain ProfileReply
+	return json.Marshal((*plain)(r))
+}
+
+type ProfileAPI struct{}
+
+func (p *ProfileAPI) Get(ctx *fasthttp.RequestCtx, args *TestArgs, reply *ProfileReply) error {
+	reply.Name, reply.Email = "alice", "alice@example.com"
+	return nil
+}
+
+func TestVAPI_SetDecisionPoint(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(ProfileAPI), "profile")
+
+	var input PolicyInput
+	as.SetDecisionPoint(DecisionPointFunc(func(ctx context.Context, in PolicyInput) (PolicyDecision, error) {
+		input = in
+		switch in.Principal {
+		case "admin":
+			return PolicyDecision{Allow: true}, nil
+		case "support":
+			return PolicyDecision{Allow: true, HideFields: []string{"email"}}, nil
+		case "broken":
+			return PolicyDecision{}, errors.New("pdp down")
+		}
+		return PolicyDecision{Reason: "not a member"}, nil
+	}), HeaderPrincipal("X-User"), time.Second, "X-Tenant")
+
+	call := func(user string) (int, string) {
+		status, body, _ := as.callLocalWith("profile.Get", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.Set("X-User", user)
+			ctx.Request.Header.Set("X-Tenant", "acme")
+		})
+		return status, string(body)
+	}
+
+	if status, body := call("admin"); status != 200 || !strings.Contains(body, "alice@example.com") {
+		t.Error(fmt.Sprintf("allowed call must get full reply: %d %s", status, body))
+	}
+	if input.Method != "profile.Get" || input.Verb != "POST" || input.Headers["X-Tenant"] != "acme" || len(input.ArgsDigest) != 64 {
+		t.Error(fmt.Sprintf("wrong policy input: %+v", input))
+	}
+	if status, body := call("support"); status != 200 || strings.Contains(body, "email") || !strings.Contains(body, "alice") {
+		t.Error(fmt.Sprintf("obligation must hide the field: %d %s", status, body))
+	}
+	if status, body := call("guest"); status != 403 || !strings.Contains(body, "not a member") {
+		t.Error(fmt.Sprintf("denied call must be 403: %d %s", status, body))
+	}
+	if status, _ := call("broken"); status != 503 {
+		t.Error(fmt.Sprintf("failed evaluation must fail closed: %d", status))
+	}
+}
+
+func TestOPADecisionPoint(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		request := struct{ Input PolicyInput }{}
+		json.Unmarshal(ctx.Request.Body(), &request)
+		ctx.SetBodyString(fmt.Sprintf(`{"result":{"allow":%t}}`, request.Input.Principal == "admin"))
+	}}).Serve(ln)
+
+	pdp := OPADecisionPoint("http://" + ln.Addr().String() + "/v1/data/vapi/authz")
+	for principal, allow := range map[string]bool{"admin": true, "guest": false} {
+		decision, err := pdp.Decide(context.Background(), PolicyInput{Principal: principal})
+		if err != nil || decision.Allow != allow {
+			t.Error(fmt.Sprintf("%s: wrong decision %+v %v", principal, decision, err))
+		}
+	}
+}
+
+func TestHideField(t *testing.T) {
+	var document interface{}
+	json.Unmarshal([]byte(`{"items":[{"owner":{"email":"a","name":"b"}}],"email":"c"}`), &document)
+	hideField(document, []string{"items", "owner", "email"})
+	if body, _ := json.Marshal(document); string(body) != `{"email":"c","items":[{"owner":{"name":"b"}}]}` {
+		t.Error(fmt.Sprintf("wrong document: %s", body))
+	}
+}
+
+func TestDecisionPoint_ReplyForms(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(ProfileAPI), "profile")
+	as.RegisterService(new(DownloadAPI), "download")
+	as.SetMethodTemplate("profile.Get", template.Must(template.New("page").Parse(`{{.Name}} {{.Email}}`)))
+	as.SetDecisionPoint(DecisionPointFunc(func(ctx context.Context, in PolicyInput) (PolicyDecision, error) {
+		return PolicyDecision{Allow: true, HideFields: []string{"email"}}, nil
+	}), nil, time.Second)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set("Accept", "text/html")
+	ctx.Request.SetBodyString(`{"id":"1"}`)
+	as.CallAPI(ctx, "profile.Get")
+	if status, body := ctx.Response.StatusCode(), ctx.Response.Body(); status != 200 || string(body) != "alice " {
+		t.Error(fmt.Sprintf("obligation must hide the field in html: %d %s", status, body))
+	}
+
+	if status, body, _ := as.callLocal("download.Export", []byte(`{"id":"1"}`)); status != 403 {
+		t.Error(fmt.Sprintf("file reply must not be sent unredacted: %d %s", status, body))
+	}
+}
+
+func TestZeroField(t *testing.T) {
+	reply := struct {
+		Items []map[string]ProfileReply `json:"items"`
+	}{Items: []map[string]ProfileReply{{"owner": {Name: "b", Email: "a"}}}}
+	if err := zeroField(reflect.ValueOf(&reply), []string{"items", "owner", "email"}); err != nil {
+		t.Fatal(err)
+	}
+	if owner := reply.Items[0]["owner"]; owner.Name != "b" || owner.Email != "" {
+		t.Error(fmt.Sprintf("wrong reply: %+v", owner))
+	}
+}
@@ -0,0 +1,54 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SkippedMethod is an exported method RegisterService ignored
+type SkippedMethod struct {
+	Method string `json:"method"`
+	Reason string `json:"reason"`
+}
+
+// RegistrationReport lists registered and skipped methods of a service
+type RegistrationReport struct {
+	Service    string          `json:"service"`
+	Registered []string        `json:"registered"`
+	Skipped    []SkippedMethod `json:"skipped"`
+}
+
+// String returns the report as text, one skipped method per line
+func (r *RegistrationReport) String() string {
+	lines := []string{fmt.Sprintf("%s: %d methods registered, %d skipped", r.Service, len(r.Registered), len(r.Skipped))}
+	for _, skipped := range r.Skipped {
+		lines = append(lines, fmt.Sprintf("  %s.%s: %s", r.Service, skipped.Method, skipped.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RegisterServiceReport is RegisterService returning which exported methods
+// were registered and why the others were skipped
+func (as *VAPI) RegisterServiceReport(receiver interface{}, name string) (*RegistrationReport, error) {
+	report := &RegistrationReport{}
+	err := as.register(receiver, name, report)
+	return report, err
+}
+
+// MustRegisterService is RegisterService which panics when registration fails
+// or an exported method is skipped, except the methods listed in allowSkipped
+// (helpers that aren't api methods). Use it at startup, so a method with a
+// wrong signature fails the deploy instead of silently disappearing.
+func (as *VAPI) MustRegisterService(receiver interface{}, name string, allowSkipped ...string) {
+	report, err := as.RegisterServiceReport(receiver, name)
+	if err != nil {
+		panic(err)
+	}
+
+	allowed := stringSet(allowSkipped)
+	for _, skipped := range report.Skipped {
+		if !allowed[skipped.Method] {
+			panic(fmt.Sprintf("vapi: method %s.%s is skipped: %s", report.Service, skipped.Method, skipped.Reason))
+		}
+	}
+}
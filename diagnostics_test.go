@@ -0,0 +1,49 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type MixedAPI struct{}
+
+func (m *MixedAPI) Get(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return nil
+}
+
+func (m *MixedAPI) Put(ctx *fasthttp.RequestCtx, args TestArgs, reply *TestReply) error {
+	return nil
+}
+
+func (m *MixedAPI) Close() {}
+
+func TestVAPI_RegisterServiceReport(t *testing.T) {
+	as := NewServer()
+	report, err := as.RegisterServiceReport(new(MixedAPI), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(report.Registered) != "[Get]" || len(report.Skipped) != 2 || report.Skipped[0].Method != "Close" {
+		t.Error(fmt.Sprintf("wrong report: %s", report))
+	}
+	if !strings.Contains(report.String(), "MixedAPI.Put: args vapi.TestArgs is not an exported pointer") {
+		t.Error(fmt.Sprintf("wrong report text: %s", report))
+	}
+
+	mustRegister := func(allowSkipped ...string) (recovered interface{}) {
+		defer func() {
+			recovered = recover()
+		}()
+		NewServer().MustRegisterService(new(MixedAPI), "", allowSkipped...)
+		return nil
+	}
+	if recovered := mustRegister("Close"); recovered == nil || !strings.Contains(fmt.Sprint(recovered), "MixedAPI.Put is skipped") {
+		t.Error(fmt.Sprintf("skipped method must panic: %v", recovered))
+	}
+	if recovered := mustRegister("Close", "Put"); recovered != nil {
+		t.Error(fmt.Sprintf("allowed methods must not panic: %v", recovered))
+	}
+}
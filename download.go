@@ -0,0 +1,142 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var typeOfFileReply = reflect.TypeOf(FileReply{})
+
+// FileReply is the reply of download methods. It is written as the raw response
+// body instead of json envelope and honors Range and If-Range request headers,
+// so large exports can be resumed:
+//
+//	func (h *Reports) Export(ctx *fasthttp.RequestCtx, args *ExportArgs, reply *vapi.FileReply) error {
+//		f, err := os.Open(path)
+//		...
+//		reply.Name, reply.Content, reply.ModTime = "report.csv", f, stat.ModTime()
+//		return nil
+//	}
+//
+// Content is closed after the response is sent when it implements io.Closer.
+type FileReply struct {
+	// Name is the suggested file name sent with Content-Disposition
+	Name string
+	// ContentType defaults to application/octet-stream
+	ContentType string
+	// ModTime is sent as Last-Modified and checked against If-Range dates
+	ModTime time.Time
+	// ETag is the strong entity tag sent as is and checked against If-Range tags, e.g. `"v42"`
+	ETag string
+	// Content is the file data
+	Content io.ReadSeeker
+}
+
+// MarshalJSON encodes file metadata, the content is never part of json
+func (f *FileReply) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name        string `json:"name,omitempty"`
+		ContentType string `json:"content_type,omitempty"`
+	}{f.Name, f.ContentType})
+}
+
+// readCloser closes the file after partial reads
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// writeFile writes file reply as full or partial content
+func (as *VAPI) writeFile(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, file *FileReply) {
+	if file.Content == nil {
+		file.Content = bytes.NewReader(nil)
+	}
+	size, err := file.Content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = file.Content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.SetContentType(contentType)
+	if file.Name != "" {
+		ctx.Response.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	}
+	ctx.Response.Header.Set("Accept-Ranges", "bytes")
+	if !file.ModTime.IsZero() {
+		ctx.Response.Header.Set("Last-Modified", string(fasthttp.AppendHTTPDate(nil, file.ModTime)))
+	}
+	if file.ETag != "" {
+		ctx.Response.Header.Set("ETag", file.ETag)
+	}
+
+	start, end := 0, int(size)-1
+	status := fasthttp.StatusOK
+	if byteRange := ctx.Request.Header.Peek("Range"); len(byteRange) > 0 && size > 0 && ifRangeMatches(ctx, file) {
+		// multiple ranges are answered with the whole file, which RFC 7233 allows
+		if bytes.IndexByte(byteRange, ',') < 0 {
+			start, end, err = fasthttp.ParseByteRange(byteRange, int(size))
+			if err != nil {
+				ctx.Response.Header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+				ctx.Response.Header.SetContentLength(0)
+				ctx.SetStatusCode(fasthttp.StatusRequestedRangeNotSatisfiable)
+				closeContent(file)
+				return
+			}
+			status = fasthttp.StatusPartialContent
+			ctx.Response.Header.SetContentRange(start, end, int(size))
+		}
+	}
+
+	if _, err = file.Content.Seek(int64(start), io.SeekStart); err != nil {
+		closeContent(file)
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	length := end - start + 1
+	var body io.Reader = io.LimitReader(file.Content, int64(length))
+	if closer, ok := file.Content.(io.Closer); ok {
+		body = readCloser{Reader: body, Closer: closer}
+	}
+	ctx.SetStatusCode(status)
+	ctx.SetBodyStream(as.throttle(ctx, methodSpec, body), length)
+}
+
+// ifRangeMatches reports whether If-Range precondition (if any) allows partial response
+func ifRangeMatches(ctx *fasthttp.RequestCtx, file *FileReply) bool {
+	ifRange := strings.TrimSpace(string(ctx.Request.Header.Peek("If-Range")))
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		// weak tags never match If-Range
+		return file.ETag != "" && !strings.HasPrefix(file.ETag, "W/") && ifRange == file.ETag
+	}
+	date, err := fasthttp.ParseHTTPDate([]byte(ifRange))
+	if err != nil || file.ModTime.IsZero() {
+		return false
+	}
+	return file.ModTime.Truncate(time.Second).Equal(date)
+}
+
+// closeContent closes file content which won't be streamed
+func closeContent(file *FileReply) {
+	if closer, ok := file.Content.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,64 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type DownloadAPI struct{}
+
+func (h *DownloadAPI) Export(ctx *fasthttp.RequestCtx, args *TestArgs, reply *FileReply) error {
+	reply.Name = "export.txt"
+	reply.ETag = `"v1"`
+	reply.ModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	reply.Content = strings.NewReader("0123456789")
+	return nil
+}
+
+func TestFileReply_Range(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DownloadAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(headers ...string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetBody([]byte("{}"))
+		for i := 0; i < len(headers); i += 2 {
+			ctx.Request.Header.Set(headers[i], headers[i+1])
+		}
+		as.CallAPI(ctx, "DownloadAPI.Export")
+		return ctx
+	}
+
+	ctx := call()
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != "0123456789" {
+		t.Error(fmt.Sprintf("wrong full response: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+
+	ctx = call("Range", "bytes=2-4")
+	if ctx.Response.StatusCode() != fasthttp.StatusPartialContent || string(ctx.Response.Body()) != "234" ||
+		string(ctx.Response.Header.Peek("Content-Range")) != "bytes 2-4/10" {
+		t.Error(fmt.Sprintf("wrong partial response: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+
+	ctx = call("Range", "bytes=-3", "If-Range", `"v1"`)
+	if string(ctx.Response.Body()) != "789" {
+		t.Error(fmt.Sprintf("wrong suffix range: %s", ctx.Response.Body()))
+	}
+
+	ctx = call("Range", "bytes=2-4", "If-Range", `"v0"`)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != "0123456789" {
+		t.Error("stale If-Range must return full content")
+	}
+
+	ctx = call("Range", "bytes=20-")
+	if ctx.Response.StatusCode() != fasthttp.StatusRequestedRangeNotSatisfiable ||
+		string(ctx.Response.Header.Peek("Content-Range")) != "bytes */10" {
+		t.Error(fmt.Sprintf("wrong unsatisfiable response: %d", ctx.Response.StatusCode()))
+	}
+}
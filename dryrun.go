@@ -0,0 +1,49 @@
+package vapi
+
+import (
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DryRunHeader is the request header asking to preview the call without side effects
+const DryRunHeader = "X-Dry-Run"
+
+// IsDryRun reports whether the call is a dry run. Methods supporting dry runs
+// must skip side effects outside the transaction scope (emails, external apis).
+func IsDryRun(ctx *fasthttp.RequestCtx) bool {
+	header := ctx.Request.Header.Peek(DryRunHeader)
+	if len(header) == 0 {
+		return false
+	}
+	dryRun, _ := strconv.ParseBool(string(header))
+	return dryRun
+}
+
+// SetMethodDryRun declares whether the method supports dry runs, no method does by default.
+//
+// In a dry run the method runs as usual and its reply is returned, but the
+// transaction scope is rolled back instead of committed and emitted events are
+// dropped. Dry runs of methods without support are rejected with 400, so a
+// preview never executes for real. In-process calls made during a dry run are
+// dry runs too, so a method supporting dry runs may call only such methods.
+func (as *VAPI) SetMethodDryRun(method string, supported bool) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.dryRun = supported
+	as.mutex.Unlock()
+	return nil
+}
+
+// discardDryRun rolls back the transaction scope and drops events, purges and session changes of the successful dry run
+func discardDryRun(ctx *fasthttp.RequestCtx) {
+	rollbackTx(ctx)
+	ctx.SetUserValue(outboxUserValue, nil)
+	ctx.SetUserValue(purgeUserValue, nil)
+	ctx.SetUserValue(sessionUserValue, nil)
+	ctx.Response.Header.Set(DryRunHeader, "true")
+}
@@ -0,0 +1,71 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetMethodDryRun(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+	call := func() (int, []byte) {
+		log = nil
+		status, body, _ := as.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.Set(DryRunHeader, "true")
+			AttachTx(ctx, "db", TxHooks{
+				OnCommit:   func() error { log = append(log, "commit"); return nil },
+				OnRollback: func() error { log = append(log, "rollback"); return nil },
+			})
+		})
+		return status, body
+	}
+
+	if status, _ := call(); status != 400 || fmt.Sprint(log) != "[rollback]" {
+		t.Error(fmt.Sprintf("dry run of unsupported method must be rejected: %d %v", status, log))
+	}
+
+	if err := as.SetMethodDryRun("demo.Test", true); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := call(); status != 200 || string(body) != `{"response":{"id":"1"}}` || fmt.Sprint(log) != "[rollback]" {
+		t.Error(fmt.Sprintf("dry run must return reply without commit: %d %s %v", status, body, log))
+	}
+}
+
+func TestVAPI_SetMethodDryRun_Nested(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RegisterService(new(GatewayAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodDryRun("GatewayAPI.Proxy", true); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func() (int, []byte) {
+		status, body, _ := as.callLocalWith("GatewayAPI.Proxy", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.Set(DryRunHeader, "true")
+		})
+		return status, body
+	}
+
+	if status, body := call(); status != 400 || !strings.Contains(string(body), "doesn't support dry run") {
+		t.Error(fmt.Sprintf("nested call of unsupported method must be rejected: %d %s", status, body))
+	}
+
+	if err := as.SetMethodDryRun("demo.Test", true); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := call(); status != 200 || string(body) != `{"response":{"id":"1!"}}` {
+		t.Error(fmt.Sprintf("nested dry run must pass: %d %s", status, body))
+	}
+}
@@ -0,0 +1,193 @@
+package vapi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Keyring is the interface implemented by key providers (local keys, KMS clients)
+// used to encrypt and decrypt struct fields tagged with `vapi:"encrypt"`.
+type Keyring interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// aesKeyring is a Keyring backed by a single AES-GCM key
+type aesKeyring struct {
+	aead cipher.AEAD
+}
+
+// NewAESKeyring returns a Keyring which seals values with AES-GCM.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewAESKeyring(key []byte) (Keyring, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't create keyring: %s", err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't create keyring: %s", err.Error())
+	}
+	return &aesKeyring{aead: aead}, nil
+}
+
+// Encrypt seals plaintext and prepends the random nonce to the result
+func (k *aesKeyring) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens ciphertext produced by Encrypt
+func (k *aesKeyring) Decrypt(ciphertext []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	return k.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+}
+
+// SetKeyring enables transparent encryption of tagged fields.
+//
+// String fields of args and reply structs tagged with `vapi:"encrypt"` are
+// expected base64 encoded ciphertext on the wire: args fields are decrypted
+// before the method is called and reply fields are encrypted before the reply
+// is marshaled. Passing nil disables field encryption.
+func (as *VAPI) SetKeyring(keyring Keyring) {
+	as.mutex.Lock()
+	as.keyring = keyring
+	as.mutex.Unlock()
+}
+
+// hasTagOption reports whether the vapi struct tag of field contains option
+func hasTagOption(field reflect.StructField, option string) bool {
+	for _, opt := range strings.Split(field.Tag.Get("vapi"), ",") {
+		if strings.TrimSpace(opt) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptFields replaces plaintext of all tagged string fields of v with base64 encoded ciphertext
+func encryptFields(keyring Keyring, v reflect.Value) error {
+	return walkEncryptedFields(v, func(field reflect.Value) error {
+		if field.Len() == 0 {
+			return nil
+		}
+		sealed, err := keyring.Encrypt([]byte(field.String()))
+		if err != nil {
+			return err
+		}
+		field.SetString(base64.StdEncoding.EncodeToString(sealed))
+		return nil
+	})
+}
+
+// decryptFields replaces base64 encoded ciphertext of all tagged string fields of v with plaintext
+func decryptFields(keyring Keyring, v reflect.Value) error {
+	return walkEncryptedFields(v, func(field reflect.Value) error {
+		if field.Len() == 0 {
+			return nil
+		}
+		sealed, err := base64.StdEncoding.DecodeString(field.String())
+		if err != nil {
+			return err
+		}
+		plain, err := keyring.Decrypt(sealed)
+		if err != nil {
+			return err
+		}
+		field.SetString(string(plain))
+		return nil
+	})
+}
+
+// walkInterface calls walk with the value held by interface v. Structs and
+// arrays held by value aren't addressable, so they are walked in a copy which
+// is stored back; changes to a copy which can't be stored back fail.
+func walkInterface(v reflect.Value, walk func(elem reflect.Value) error) error {
+	if v.IsNil() {
+		return nil
+	}
+	elem := v.Elem()
+	if elem.Kind() != reflect.Struct && elem.Kind() != reflect.Array {
+		return walk(elem)
+	}
+
+	copied := reflect.New(elem.Type()).Elem()
+	copied.Set(elem)
+	if err := walk(copied); err != nil {
+		return err
+	}
+	if v.CanSet() {
+		v.Set(copied)
+	} else if !reflect.DeepEqual(copied.Interface(), elem.Interface()) {
+		return fmt.Errorf("vapi: value of %s held by interface can't be set", elem.Type())
+	}
+	return nil
+}
+
+// walkEncryptedFields calls fn for every settable string field tagged with `vapi:"encrypt"`,
+// descending into nested structs, pointers, slices and map values.
+func walkEncryptedFields(v reflect.Value, fn func(field reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return walkEncryptedFields(v.Elem(), fn)
+	case reflect.Interface:
+		return walkInterface(v, func(elem reflect.Value) error {
+			return walkEncryptedFields(elem, fn)
+		})
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkEncryptedFields(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map values aren't addressable, each is walked in a copy which is stored back
+		iter := v.MapRange()
+		for iter.Next() {
+			copied := reflect.New(v.Type().Elem()).Elem()
+			copied.Set(iter.Value())
+			if err := walkEncryptedFields(copied, fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), copied)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if fv.Kind() == reflect.String && hasTagOption(field, "encrypt") {
+				if !fv.CanSet() {
+					return fmt.Errorf("vapi: field %q can't be set", field.Name)
+				}
+				if err := fn(fv); err != nil {
+					return fmt.Errorf("vapi: field %q: %s", field.Name, err.Error())
+				}
+				continue
+			}
+			if err := walkEncryptedFields(fv, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type encryptedNested struct {
+	Secret string `vapi:"encrypt"`
+}
+
+type encryptedArgs struct {
+	Name   string
+	Email  string `json:"email" vapi:"encrypt"`
+	Nested *encryptedNested
+	List   []encryptedNested
+	ByName map[string]encryptedNested
+}
+
+func TestEncryptFields(t *testing.T) {
+	keyring, err := NewAESKeyring([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := &encryptedArgs{
+		Name:   "john",
+		Email:  "john@example.com",
+		Nested: &encryptedNested{Secret: "nested"},
+		List:   []encryptedNested{{Secret: "first"}, {Secret: ""}},
+		ByName: map[string]encryptedNested{"key": {Secret: "mapped"}},
+	}
+
+	err = encryptFields(keyring, reflect.ValueOf(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args.Name != "john" {
+		t.Error(fmt.Sprintf("untagged field modified: %s", args.Name))
+	}
+	if args.Email == "john@example.com" || args.Nested.Secret == "nested" || args.List[0].Secret == "first" || args.ByName["key"].Secret == "mapped" {
+		t.Error("tagged fields are not encrypted")
+	}
+	if args.List[1].Secret != "" {
+		t.Error("empty field should stay empty")
+	}
+
+	err = decryptFields(keyring, reflect.ValueOf(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args.Email != "john@example.com" || args.Nested.Secret != "nested" || args.List[0].Secret != "first" || args.ByName["key"].Secret != "mapped" {
+		t.Error(fmt.Sprintf("wrong decrypted values: %+v", args))
+	}
+}
+
+func TestDecryptFields_Invalid(t *testing.T) {
+	keyring, err := NewAESKeyring([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := &encryptedArgs{Email: "plaintext"}
+	if err = decryptFields(keyring, reflect.ValueOf(args)); err == nil {
+		t.Error("expected error on plaintext value")
+	}
+}
+
+type encryptedEnvelope struct {
+	Payload interface{}
+}
+
+func TestEncryptFields_Interface(t *testing.T) {
+	keyring, err := NewAESKeyring([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := &encryptedEnvelope{Payload: encryptedNested{Secret: "held by value"}}
+	if err = encryptFields(keyring, reflect.ValueOf(reply)); err != nil {
+		t.Fatal(err)
+	}
+	if secret := reply.Payload.(encryptedNested).Secret; secret == "held by value" || secret == "" {
+		t.Error(fmt.Sprintf("field held by interface is not encrypted: %q", secret))
+	}
+	if err = decryptFields(keyring, reflect.ValueOf(reply)); err != nil {
+		t.Fatal(err)
+	}
+	if secret := reply.Payload.(encryptedNested).Secret; secret != "held by value" {
+		t.Error(fmt.Sprintf("wrong decrypted value: %q", secret))
+	}
+
+	// interface which can't be stored back must fail instead of leaking plaintext
+	unsettable := reflect.ValueOf(encryptedEnvelope{Payload: encryptedNested{Secret: "x"}}).Field(0)
+	if err = encryptFields(keyring, unsettable); err == nil {
+		t.Error("expected error on unsettable interface")
+	}
+}
@@ -0,0 +1,96 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Enum is implemented by types which accept a fixed set of values:
+//
+//	type Color string
+//
+//	func (Color) EnumValues() []interface{} { return []interface{}{"red", "green"} }
+//
+// Fields of enum types are validated after decoding and documented in OpenAPI
+// with the allowed values. Plain fields can declare values with a tag instead:
+//
+//	Status string `json:"status" enum:"active|blocked"`
+//
+// Zero values of optional fields are accepted as omitted, fields tagged
+// `openapi:"required"` must hold one of the values.
+type Enum interface {
+	EnumValues() []interface{}
+}
+
+var typeOfEnum = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// enumValuesOf returns values allowed for values of type t, nil when t is not an enum
+func enumValuesOf(t reflect.Type) []interface{} {
+	t = indirectType(t)
+	if t.Kind() == reflect.Interface || !t.Implements(typeOfEnum) {
+		return nil
+	}
+	return reflect.Zero(t).Interface().(Enum).EnumValues()
+}
+
+// enumValues returns values allowed for field from the enum tag or its type, nil when unrestricted
+func enumValues(field reflect.StructField) []interface{} {
+	t := indirectType(field.Type)
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = indirectType(t.Elem())
+	}
+
+	tag, ok := field.Tag.Lookup("enum")
+	if !ok {
+		return enumValuesOf(t)
+	}
+
+	schemaType := ""
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schemaType = "integer"
+	case reflect.Float32, reflect.Float64:
+		schemaType = "number"
+	}
+
+	parts := strings.Split(tag, "|")
+	values := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		values = append(values, exampleValue(schemaType, part))
+	}
+	return values
+}
+
+// validateEnum checks that v (or every element of v) is one of allowed values
+func validateEnum(v reflect.Value, allowed []interface{}, path string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if kind := v.Kind(); (kind == reflect.Slice || kind == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			if err := validateEnum(v.Index(i), allowed, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	value := fmt.Sprint(v.Interface())
+	for _, candidate := range allowed {
+		if fmt.Sprint(candidate) == value {
+			return nil
+		}
+	}
+
+	names := make([]string, len(allowed))
+	for i, candidate := range allowed {
+		names[i] = fmt.Sprint(candidate)
+	}
+	return &ValidationError{Field: path, Message: fmt.Sprintf("%q is not one of allowed values: %s", value, strings.Join(names, ", "))}
+}
@@ -0,0 +1,81 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testColor string
+
+func (testColor) EnumValues() []interface{} {
+	return []interface{}{"red", "green"}
+}
+
+type enumArgs struct {
+	Status string      `json:"status" enum:"active|blocked"`
+	Level  int         `json:"level" enum:"1|2|3"`
+	Color  testColor   `json:"color"`
+	Tags   []testColor `json:"tags"`
+}
+
+func TestValidateEnum(t *testing.T) {
+	args := enumArgs{Status: "active", Level: 2, Color: "red", Tags: []testColor{"green"}}
+	if err := validateArgs(reflect.ValueOf(&args)); err != nil {
+		t.Fatal(err)
+	}
+
+	args.Tags = append(args.Tags, "blue")
+	err := validateArgs(reflect.ValueOf(&args))
+	if err == nil || err.(*ValidationError).Field != "Tags[1]" || !strings.Contains(err.Error(), "red, green") {
+		t.Error(fmt.Sprintf("wrong error for invalid enum element: %v", err))
+	}
+
+	args.Tags, args.Level = nil, 5
+	if err = validateArgs(reflect.ValueOf(&args)); err == nil || err.(*ValidationError).Field != "Level" {
+		t.Error(fmt.Sprintf("wrong error for invalid tag enum: %v", err))
+	}
+}
+
+func TestOpenAPI_Enum(t *testing.T) {
+	doc := &OpenAPIDocument{Components: OpenAPIComponents{Schemas: make(map[string]*Schema)}}
+	schema := structSchema(doc, reflect.TypeOf(enumArgs{}))
+
+	if got := fmt.Sprint(schema.Properties["status"].Enum); got != "[active blocked]" {
+		t.Error(fmt.Sprintf("wrong status enum: %s", got))
+	}
+	if level := schema.Properties["level"].Enum; len(level) != 3 || level[0] != int64(1) {
+		t.Error(fmt.Sprintf("wrong level enum: %v", level))
+	}
+	if got := fmt.Sprint(schema.Properties["tags"].Items.Enum); got != "[red green]" {
+		t.Error(fmt.Sprintf("wrong tags enum: %s", got))
+	}
+}
+
+type colorer interface {
+	Enum
+	Hex() string
+}
+
+type optionalEnumArgs struct {
+	Status string    `json:"status,omitempty" enum:"active|blocked"`
+	Color  testColor `json:"color" openapi:"required"`
+	Shade  colorer   `json:"shade,omitempty"`
+}
+
+func TestValidateEnum_Optional(t *testing.T) {
+	args := optionalEnumArgs{Color: "red"}
+	if err := validateArgs(reflect.ValueOf(&args)); err != nil {
+		t.Error(fmt.Sprintf("omitted optional enum must be accepted: %v", err))
+	}
+
+	args.Color = ""
+	if err := validateArgs(reflect.ValueOf(&args)); err == nil || err.(*ValidationError).Field != "Color" {
+		t.Error(fmt.Sprintf("wrong error for empty required enum: %v", err))
+	}
+
+	if values := enumValuesOf(reflect.TypeOf((*colorer)(nil)).Elem()); values != nil {
+		t.Error(fmt.Sprintf("interface type must not be treated as enum: %v", values))
+	}
+}
@@ -0,0 +1,188 @@
+package vapi
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Topics of events emitted by the framework
+const (
+	// EventCallCompleted is emitted after successful method calls with CallEvent payload
+	EventCallCompleted = "call.completed"
+	// EventCallFailed is emitted after failed method calls (status >= 400) with CallEvent payload
+	EventCallFailed = "call.failed"
+	// EventAnyTopic subscribes to all topics
+	EventAnyTopic = "*"
+)
+
+// Event is a notification delivered to subscribers
+type Event struct {
+	Topic   string
+	Time    time.Time
+	Payload interface{}
+}
+
+// CallEvent is the payload of call events
+type CallEvent struct {
+	Method   string
+	Status   int
+	Duration time.Duration
+}
+
+// EventHandler receives events of subscribed topics
+type EventHandler func(ctx context.Context, event Event)
+
+// subscription is a registered handler
+type subscription struct {
+	id      uint64
+	handler EventHandler
+}
+
+// topicSubscribers are handlers of one topic, locked separately so
+// subscribing to one topic doesn't block deliveries of the others
+type topicSubscribers struct {
+	mutex sync.RWMutex
+	list  []subscription
+}
+
+// EventBus delivers events to subscribers asynchronously through the server worker pool,
+// so side effects like emails or cache invalidation stay out of the request path.
+type EventBus struct {
+	as *VAPI
+
+	mutex  sync.RWMutex // guards topics map, handlers are guarded by their topic
+	topics map[string]*topicSubscribers
+	lastID uint64 // accessed atomically
+}
+
+// Events returns the event bus of the server
+func (as *VAPI) Events() *EventBus {
+	as.mutex.RLock()
+	events := as.events
+	as.mutex.RUnlock()
+	if events != nil {
+		return events
+	}
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.events == nil {
+		as.events = &EventBus{as: as, topics: make(map[string]*topicSubscribers)}
+	}
+	return as.events
+}
+
+// Subscribe registers handler for topic (EventAnyTopic for all) and returns the function cancelling the subscription
+func (eb *EventBus) Subscribe(topic string, handler EventHandler) (unsubscribe func()) {
+	id := atomic.AddUint64(&eb.lastID, 1)
+	subscribers := eb.topic(topic, true)
+
+	subscribers.mutex.Lock()
+	subscribers.list = append(subscribers.list, subscription{id: id, handler: handler})
+	subscribers.mutex.Unlock()
+
+	return func() {
+		subscribers.mutex.Lock()
+		defer subscribers.mutex.Unlock()
+		for i, s := range subscribers.list {
+			if s.id == id {
+				subscribers.list = append(subscribers.list[:i:i], subscribers.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// topic returns subscribers of topic, creating them when create is set
+func (eb *EventBus) topic(topic string, create bool) *topicSubscribers {
+	eb.mutex.RLock()
+	subscribers := eb.topics[topic]
+	eb.mutex.RUnlock()
+	if subscribers != nil || !create {
+		return subscribers
+	}
+
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+	if subscribers = eb.topics[topic]; subscribers == nil {
+		subscribers = &topicSubscribers{}
+		eb.topics[topic] = subscribers
+	}
+	return subscribers
+}
+
+// count returns the number of handlers, zero for nil subscribers
+func (ts *topicSubscribers) count() int {
+	if ts == nil {
+		return 0
+	}
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	return len(ts.list)
+}
+
+// appendTo appends handlers to list
+func (ts *topicSubscribers) appendTo(list []EventHandler) []EventHandler {
+	if ts == nil {
+		return list
+	}
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	for _, s := range ts.list {
+		list = append(list, s.handler)
+	}
+	return list
+}
+
+// Publish delivers payload to subscribers of topic. Handlers run in the worker
+// pool, Publish waits only when the pool is full. Returns the first error of
+// scheduling deliveries, e.g. ErrWorkersDraining during shutdown.
+func (eb *EventBus) Publish(topic string, payload interface{}) error {
+	handlers := eb.handlers(topic)
+	if len(handlers) == 0 {
+		return nil
+	}
+
+	event := Event{Topic: topic, Time: time.Now(), Payload: payload}
+	for _, handler := range handlers {
+		handler := handler
+		if err := eb.as.Go(context.Background(), func(ctx context.Context) { handler(ctx, event) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasSubscribers reports whether any handler receives topic
+func (eb *EventBus) HasSubscribers(topic string) bool {
+	return eb.topic(topic, false).count() > 0 || eb.topic(EventAnyTopic, false).count() > 0
+}
+
+// handlers returns handlers of topic
+func (eb *EventBus) handlers(topic string) []EventHandler {
+	handlers := eb.topic(topic, false).appendTo(nil)
+	if topic != EventAnyTopic {
+		handlers = eb.topic(EventAnyTopic, false).appendTo(handlers)
+	}
+	return handlers
+}
+
+// emitCallEvent publishes call event if the event bus is in use
+func (as *VAPI) emitCallEvent(method string, status int, duration time.Duration) {
+	as.mutex.RLock()
+	events := as.events
+	as.mutex.RUnlock()
+	if events == nil {
+		return
+	}
+
+	topic := EventCallCompleted
+	if status >= 400 {
+		topic = EventCallFailed
+	}
+	if events.HasSubscribers(topic) {
+		events.Publish(topic, CallEvent{Method: method, Status: status, Duration: duration})
+	}
+}
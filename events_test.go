@@ -0,0 +1,52 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var received []string
+	record := func(ctx context.Context, event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch payload := event.Payload.(type) {
+		case CallEvent:
+			received = append(received, event.Topic+" "+payload.Method)
+		default:
+			received = append(received, fmt.Sprintf("%s %v", event.Topic, payload))
+		}
+	}
+
+	as.Events().Subscribe(EventCallCompleted, record)
+	as.Events().Subscribe(EventCallFailed, record)
+	unsubscribe := as.Events().Subscribe("order.created", record)
+
+	as.callLocal("demo.Test", []byte(`{}`))
+	as.callLocal("demo.ErrorTest", []byte(`{}`))
+	as.Events().Publish("order.created", 42)
+	unsubscribe()
+	as.Events().Publish("order.created", 43)
+	as.Workers().Drain(time.Second)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	expected := map[string]bool{"call.completed demo.Test": true, "call.failed demo.ErrorTest": true, "order.created 42": true}
+	if len(received) != 3 {
+		t.Fatal(fmt.Sprintf("wrong events: %v", received))
+	}
+	for _, event := range received {
+		if !expected[event] {
+			t.Error(fmt.Sprintf("unexpected event %q", event))
+		}
+	}
+}
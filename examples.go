@@ -0,0 +1,51 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// methodExample is a sample call of a method
+type methodExample struct {
+	args  interface{}
+	reply interface{}
+}
+
+// AddExample registers a sample call of method ("Service.Method") shown in
+// generated documentation instead of auto-derived zero values.
+// Args and reply must be values or pointers of the method args and reply types.
+func (as *VAPI) AddExample(method string, args, reply interface{}) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	if t := indirectType(reflect.TypeOf(args)); t != methodSpec.argsType {
+		return fmt.Errorf("vapi: example args of %q must be %s, got %s", method, methodSpec.argsType, t)
+	}
+	if t := indirectType(reflect.TypeOf(reply)); t != methodSpec.replyType {
+		return fmt.Errorf("vapi: example reply of %q must be %s, got %s", method, methodSpec.replyType, t)
+	}
+
+	as.mutex.Lock()
+	methodSpec.examples = append(methodSpec.examples, methodExample{args: args, reply: reply})
+	as.mutex.Unlock()
+	return nil
+}
+
+// openAPIExamples returns documentation examples of args and replies wrapped into response envelope
+func openAPIExamples(examples []methodExample) (args, replies map[string]*OpenAPIExample) {
+	if len(examples) == 0 {
+		return nil, nil
+	}
+
+	args = make(map[string]*OpenAPIExample, len(examples))
+	replies = make(map[string]*OpenAPIExample, len(examples))
+	for i, example := range examples {
+		name := "example" + strconv.Itoa(i+1)
+		args[name] = &OpenAPIExample{Value: example.args}
+		replies[name] = &OpenAPIExample{Value: map[string]interface{}{"response": example.reply}}
+	}
+	return args, replies
+}
@@ -0,0 +1,187 @@
+package vapi
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FairScheduler limits the number of concurrently executed requests and
+// shares free slots between principals with weighted round robin, so a single
+// heavy principal can't monopolize the workers while others wait.
+type FairScheduler struct {
+	// Workers is the maximum number of concurrently executed requests
+	Workers int
+	// MaxQueue is the maximum number of waiting requests per principal, 0 means unlimited
+	MaxQueue int
+	// QueueTimeout is the maximum time request waits for a free slot, 0 means unlimited
+	QueueTimeout time.Duration
+	// DefaultWeight is the weight of principals without explicitly set weight
+	DefaultWeight int
+
+	principal PrincipalFunc
+	after     func(d time.Duration) <-chan time.Time // queue timeout clock, nil uses timers
+
+	mutex   sync.Mutex
+	running int
+	weights map[string]int
+	queues  map[string]*fairQueue
+	waiting int
+}
+
+// fairQueue holds waiting requests of one principal
+type fairQueue struct {
+	waiters []chan struct{}
+	current int // smooth weighted round robin state
+}
+
+var (
+	errQueueFull    = errors.New("vapi: too many queued requests")
+	errQueueTimeout = errors.New("vapi: timed out waiting for a free worker")
+)
+
+// NewFairScheduler returns scheduler executing at most workers requests at once
+func NewFairScheduler(workers int, principal PrincipalFunc) *FairScheduler {
+	return &FairScheduler{
+		Workers:       workers,
+		DefaultWeight: 1,
+		principal:     principal,
+		weights:       make(map[string]int),
+		queues:        make(map[string]*fairQueue),
+	}
+}
+
+// SetWeight sets the share of free slots given to principal relative to other waiting principals
+func (fs *FairScheduler) SetWeight(principal string, weight int) {
+	fs.mutex.Lock()
+	fs.weights[principal] = weight
+	fs.mutex.Unlock()
+}
+
+// Handler wraps next with fair scheduling
+func (fs *FairScheduler) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if err := fs.acquire(ctx.Done(), fs.principal(ctx)); err != nil {
+			ctx.Response.Header.Set("Retry-After", "1")
+			writeHandlerError(ctx, fasthttp.StatusServiceUnavailable, err)
+			return
+		}
+		defer fs.release()
+		next(ctx)
+	}
+}
+
+// acquire blocks until a worker slot is granted to the request
+func (fs *FairScheduler) acquire(done <-chan struct{}, principal string) error {
+	fs.mutex.Lock()
+	if fs.running < fs.Workers && fs.waiting == 0 {
+		fs.running++
+		fs.mutex.Unlock()
+		return nil
+	}
+
+	queue, ok := fs.queues[principal]
+	if !ok {
+		queue = &fairQueue{}
+		fs.queues[principal] = queue
+	}
+	if fs.MaxQueue > 0 && len(queue.waiters) >= fs.MaxQueue {
+		fs.mutex.Unlock()
+		return errQueueFull
+	}
+	granted := make(chan struct{})
+	queue.waiters = append(queue.waiters, granted)
+	fs.waiting++
+	fs.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if fs.QueueTimeout > 0 && fs.after != nil {
+		timeout = fs.after(fs.QueueTimeout)
+	} else if fs.QueueTimeout > 0 {
+		timer := time.NewTimer(fs.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-granted:
+		return nil
+	case <-timeout:
+	case <-done:
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if !fs.dequeue(principal, granted) {
+		// slot was granted concurrently with the timeout, give it back
+		fs.grantNext()
+	}
+	return errQueueTimeout
+}
+
+// release hands the freed slot to the next waiting request
+func (fs *FairScheduler) release() {
+	fs.mutex.Lock()
+	fs.grantNext()
+	fs.mutex.Unlock()
+}
+
+// grantNext passes the slot of a finished request to the next waiter picked with
+// smooth weighted round robin, or frees it when nobody waits. Called under mutex.
+func (fs *FairScheduler) grantNext() {
+	var (
+		chosen      string
+		chosenQueue *fairQueue
+		total       int
+	)
+	for principal, queue := range fs.queues {
+		weight := fs.weight(principal)
+		queue.current += weight
+		total += weight
+		if chosenQueue == nil || queue.current > chosenQueue.current {
+			chosen, chosenQueue = principal, queue
+		}
+	}
+
+	if chosenQueue == nil {
+		fs.running--
+		return
+	}
+
+	chosenQueue.current -= total
+	granted := chosenQueue.waiters[0]
+	fs.dequeue(chosen, granted)
+	close(granted)
+}
+
+// dequeue removes waiter from principal queue, returns false when it was not queued. Called under mutex.
+func (fs *FairScheduler) dequeue(principal string, waiter chan struct{}) bool {
+	queue, ok := fs.queues[principal]
+	if !ok {
+		return false
+	}
+	for i := range queue.waiters {
+		if queue.waiters[i] == waiter {
+			queue.waiters = append(queue.waiters[:i], queue.waiters[i+1:]...)
+			fs.waiting--
+			if len(queue.waiters) == 0 {
+				delete(fs.queues, principal)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// weight returns principal weight. Called under mutex.
+func (fs *FairScheduler) weight(principal string) int {
+	if weight, ok := fs.weights[principal]; ok && weight > 0 {
+		return weight
+	}
+	if fs.DefaultWeight > 0 {
+		return fs.DefaultWeight
+	}
+	return 1
+}
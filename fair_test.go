@@ -0,0 +1,114 @@
+package vapi
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairScheduler_Interleaving(t *testing.T) {
+	fs := NewFairScheduler(1, nil)
+	fs.SetWeight("light", 2)
+
+	// occupy the only worker
+	if err := fs.acquire(nil, "heavy"); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mutex sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	enqueue := func(principal string) {
+		fs.mutex.Lock()
+		queued := fs.waiting
+		fs.mutex.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fs.acquire(nil, principal); err != nil {
+				t.Error(err)
+				return
+			}
+			mutex.Lock()
+			order = append(order, principal)
+			mutex.Unlock()
+			fs.release()
+		}()
+
+		// wait until request is queued to make queue order deterministic
+		waitQueued(fs, queued+1)
+	}
+
+	enqueue("heavy")
+	enqueue("heavy")
+	enqueue("heavy")
+	enqueue("light")
+	enqueue("light")
+
+	fs.release()
+	wg.Wait()
+
+	if got := strings.Join(order, ","); got != "light,heavy,light,heavy,heavy" {
+		t.Error(fmt.Sprintf("wrong scheduling order: %s", got))
+	}
+}
+
+func TestFairScheduler_Limits(t *testing.T) {
+	fs := NewFairScheduler(1, nil)
+	fs.MaxQueue = 1
+	fs.QueueTimeout = time.Minute
+
+	expired := make(chan time.Time)
+	close(expired)
+	fs.after = func(d time.Duration) <-chan time.Time {
+		if d != time.Minute {
+			t.Error(fmt.Sprintf("wrong queue timeout: %s", d))
+		}
+		return expired
+	}
+
+	if err := fs.acquire(nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.acquire(nil, "a"); err != errQueueTimeout {
+		t.Error(fmt.Sprintf("expected queue timeout, got %v", err))
+	}
+
+	// the queued request waits until cancelled
+	fs.after = func(time.Duration) <-chan time.Time { return nil }
+	cancel := make(chan struct{})
+	result := make(chan error, 1)
+	go func() { result <- fs.acquire(cancel, "a") }()
+	waitQueued(fs, 1)
+
+	if err := fs.acquire(nil, "a"); err != errQueueFull {
+		t.Error(fmt.Sprintf("expected full queue, got %v", err))
+	}
+
+	close(cancel)
+	if err := <-result; err != errQueueTimeout {
+		t.Error(fmt.Sprintf("cancelled request must give up, got %v", err))
+	}
+	if fs.waiting != 0 || fs.running != 1 {
+		t.Error(fmt.Sprintf("wrong state after cancel: waiting %d, running %d", fs.waiting, fs.running))
+	}
+}
+
+// waitQueued blocks until n requests wait in fs
+func waitQueued(fs *FairScheduler, n int) {
+	for {
+		fs.mutex.Lock()
+		queued := fs.waiting >= n
+		fs.mutex.Unlock()
+		if queued {
+			return
+		}
+		runtime.Gosched()
+	}
+}
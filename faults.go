@@ -0,0 +1,148 @@
+package vapi
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// errInjectedFault is the error of injected failures
+var errInjectedFault = errors.New("vapi: injected fault")
+
+// Fault describes failures injected into calls of a method for resilience testing.
+// Rates are probabilities from 0 to 1 evaluated independently for every call.
+type Fault struct {
+	// LatencyRate is the share of calls delayed by Latency
+	LatencyRate float64 `json:"latency_rate,omitempty"`
+	// Latency is the injected delay
+	Latency Duration `json:"latency,omitempty"`
+	// ErrorRate is the share of calls failed with ErrorStatus
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// ErrorStatus is the http status of failed calls, 503 by default
+	ErrorStatus int `json:"error_status,omitempty"`
+	// DropRate is the share of calls whose connection is closed without response
+	DropRate float64 `json:"drop_rate,omitempty"`
+}
+
+// faultRule is the FaultsHandler request body
+type faultRule struct {
+	Method string `json:"method"`
+	Fault  *Fault `json:"fault"`
+}
+
+// SetMethodFault injects fault into calls of the method, nil removes it.
+// Never enable faults in production without a kill switch.
+func (as *VAPI) SetMethodFault(method string, fault *Fault) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.fault = fault
+	as.mutex.Unlock()
+	return nil
+}
+
+// Faults returns injected faults by method name
+func (as *VAPI) Faults() map[string]Fault {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	faults := map[string]Fault{}
+	for name, methodSpec := range as.methods {
+		if methodSpec.fault != nil {
+			faults[name] = *methodSpec.fault
+		}
+	}
+	return faults
+}
+
+// FaultsHandler is the admin api of fault injection, mount it behind admin authentication:
+// GET lists faults, POST sets {"method": "Service.Method", "fault": {...}} and
+// DELETE with ?method=Service.Method removes the fault.
+func (as *VAPI) FaultsHandler(ctx *fasthttp.RequestCtx) {
+	switch {
+	case ctx.IsPost() || ctx.IsPut():
+		rule := faultRule{}
+		if err := json.Unmarshal(ctx.PostBody(), &rule); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusBadRequest, err)
+			return
+		}
+		if err := as.SetMethodFault(rule.Method, rule.Fault); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusNotFound, err)
+			return
+		}
+	case ctx.IsDelete():
+		if err := as.SetMethodFault(string(ctx.QueryArgs().Peek("method")), nil); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusNotFound, err)
+			return
+		}
+	}
+
+	faults := as.Faults()
+	rules := make([]faultRule, 0, len(faults))
+	for method := range faults {
+		fault := faults[method]
+		rules = append(rules, faultRule{Method: method, Fault: &fault})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Method < rules[j].Method })
+
+	body, err := json.Marshal(rules)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// injectFault applies fault of the method, false when the call was failed or dropped
+func (as *VAPI) injectFault(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	fault := methodSpec.fault
+	as.mutex.RUnlock()
+	if fault == nil {
+		return true
+	}
+
+	if fault.LatencyRate > 0 && rand.Float64() < fault.LatencyRate {
+		time.Sleep(time.Duration(fault.Latency))
+	}
+	if fault.DropRate > 0 && rand.Float64() < fault.DropRate {
+		dropConnection(ctx)
+		return false
+	}
+	if fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate {
+		status := fault.ErrorStatus
+		if status == 0 {
+			status = fasthttp.StatusServiceUnavailable
+		}
+		as.writeError(ctx, srvResponse, status, errInjectedFault)
+		return false
+	}
+	return true
+}
+
+// placeholderClosePanic is the panic of closing the placeholder connection of RequestCtx.Init
+const placeholderClosePanic = "BUG: unexpected Close call"
+
+// dropConnection closes the client connection so the response is never delivered.
+// Contexts without a real connection (in-process calls) get an empty response
+// with Connection: close instead.
+func dropConnection(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Reset()
+	ctx.SetConnectionClose()
+	if conn := ctx.Conn(); conn != nil {
+		defer func() {
+			if r := recover(); r != nil && r != placeholderClosePanic {
+				panic(r)
+			}
+		}()
+		conn.Close()
+	}
+}
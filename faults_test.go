@@ -0,0 +1,82 @@
+package vapi
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_FaultsHandler(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(`{"method":"demo.Test","fault":{"error_rate":1,"error_status":502,"latency_rate":1,"latency":"20ms"}}`)
+	as.FaultsHandler(ctx)
+	if ctx.Response.StatusCode() != 200 {
+		t.Fatal(fmt.Sprintf("can't set fault: %s", ctx.Response.Body()))
+	}
+
+	started := time.Now()
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 502 || time.Since(started) < 20*time.Millisecond {
+		t.Error(fmt.Sprintf("fault is not injected: %d %s", status, time.Since(started)))
+	}
+
+	if err := as.SetMethodFault("demo.Test", &Fault{DropRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if status, body, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 200 || len(body) != 0 {
+		t.Error(fmt.Sprintf("in-process drop must be empty: %d %s", status, body))
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("DELETE")
+	ctx.Request.SetRequestURI("/faults?method=demo.Test")
+	as.FaultsHandler(ctx)
+	if string(ctx.Response.Body()) != `[]` {
+		t.Error(fmt.Sprintf("fault is not removed: %s", ctx.Response.Body()))
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 200 {
+		t.Error(fmt.Sprintf("call must succeed without faults, got %d", status))
+	}
+}
+
+// panickingConn panics on Close like a broken connection implementation
+type panickingConn struct {
+	net.Conn
+}
+
+func (pc panickingConn) Close() error {
+	panic("conn is broken")
+}
+
+func TestDropConnection(t *testing.T) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(req, nil, nil)
+	ctx.SetBodyString("reply")
+
+	dropConnection(ctx)
+	if len(ctx.Response.Body()) != 0 || !ctx.Response.ConnectionClose() {
+		t.Error(fmt.Sprintf("in-process call must get empty response with connection close: %s", ctx.Response.String()))
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Init2(panickingConn{server}, nil, false)
+	defer func() {
+		if r := recover(); r != "conn is broken" {
+			t.Error(fmt.Sprintf("unexpected panics must be propagated, got %v", r))
+		}
+	}()
+	dropConnection(ctx)
+	t.Error("panic of the connection must not be swallowed")
+}
@@ -0,0 +1,169 @@
+package vapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// maxUnknownFields caps distinct unknown field paths counted per method
+const maxUnknownFields = 100
+
+// typeOfTextUnmarshaler is implemented by structs encoded as json strings, e.g. time.Time
+var typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// FieldUsage shows which args fields clients of a method send. Paths are
+// dotted, array elements are "[]", e.g. "items[].name".
+type FieldUsage struct {
+	Method string `json:"method"`
+	Calls  uint64 `json:"calls"`
+	// Fields counts calls sending each field of the args type
+	Fields map[string]uint64 `json:"fields"`
+	// Unused are fields of the args type no call has sent
+	Unused []string `json:"unused"`
+	// Unknown counts calls sending each field the args type doesn't have, up to 100 fields
+	Unknown map[string]uint64 `json:"unknown"`
+}
+
+// fieldStats counts args fields sent to a method
+type fieldStats struct {
+	mutex   sync.Mutex
+	known   map[string]bool
+	calls   uint64
+	sent    map[string]uint64
+	unknown map[string]uint64
+}
+
+// SetMethodFieldStats enables or disables counting of the args fields sent
+// to the method, both fields of the args type and fields it doesn't know,
+// which decoding drops silently. Use FieldUsages or StatsHandler "?fields" to
+// see what clients actually send before tightening the args schema.
+func (as *VAPI) SetMethodFieldStats(method string, enabled bool) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	var stats *fieldStats
+	if enabled {
+		stats = &fieldStats{known: map[string]bool{}, sent: map[string]uint64{}, unknown: map[string]uint64{}}
+		collectFields(methodSpec.argsType, "", stats.known, map[reflect.Type]bool{})
+	}
+
+	as.mutex.Lock()
+	methodSpec.fieldStats = stats
+	as.mutex.Unlock()
+	return nil
+}
+
+// FieldUsages returns field usage of methods with field stats sorted by method
+func (as *VAPI) FieldUsages() []FieldUsage {
+	as.mutex.RLock()
+	usages := []FieldUsage{}
+	for name, methodSpec := range as.methods {
+		if methodSpec.fieldStats != nil {
+			usages = append(usages, methodSpec.fieldStats.usage(name))
+		}
+	}
+	as.mutex.RUnlock()
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Method < usages[j].Method })
+	return usages
+}
+
+// recordFields counts fields of raw args sent to the method
+func (as *VAPI) recordFields(methodSpec *serviceMethod, raw []byte) {
+	as.mutex.RLock()
+	stats := methodSpec.fieldStats
+	as.mutex.RUnlock()
+	if stats == nil {
+		return
+	}
+	var args map[string]interface{}
+	if json.Unmarshal(raw, &args) != nil {
+		return
+	}
+
+	paths := map[string]bool{}
+	collectPaths(args, "", paths)
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.calls++
+	for path := range paths {
+		switch {
+		case stats.known[path]:
+			stats.sent[path]++
+		case stats.unknown[path] > 0 || len(stats.unknown) < maxUnknownFields:
+			stats.unknown[path]++
+		}
+	}
+}
+
+// usage returns the counted usage of the method
+func (stats *fieldStats) usage(method string) FieldUsage {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	usage := FieldUsage{Method: method, Calls: stats.calls, Fields: map[string]uint64{}, Unused: []string{}, Unknown: map[string]uint64{}}
+	for path := range stats.known {
+		if count := stats.sent[path]; count > 0 {
+			usage.Fields[path] = count
+		} else {
+			usage.Unused = append(usage.Unused, path)
+		}
+	}
+	for path, count := range stats.unknown {
+		usage.Unknown[path] = count
+	}
+	sort.Strings(usage.Unused)
+	return usage
+}
+
+// collectFields adds json paths of t under prefix to known
+func collectFields(t reflect.Type, prefix string, known map[string]bool, visited map[reflect.Type]bool) {
+	t = indirectType(t)
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8, t.Kind() == reflect.Array:
+		collectFields(t.Elem(), prefix+"[]", known, visited)
+		return
+	case t.Kind() != reflect.Struct || reflect.PtrTo(t).Implements(typeOfTextUnmarshaler) || visited[t]:
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			collectFields(field.Type, prefix, known, visited)
+			continue
+		}
+		path := joinPath(prefix, name)
+		known[path] = true
+		collectFields(field.Type, path, known, visited)
+	}
+}
+
+// collectPaths adds json paths of decoded value under prefix to paths
+func collectPaths(value interface{}, prefix string, paths map[string]bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, field := range value {
+			path := joinPath(prefix, name)
+			paths[path] = true
+			collectPaths(field, path, paths)
+		}
+	case []interface{}:
+		for _, element := range value {
+			collectPaths(element, prefix+"[]", paths)
+		}
+	}
+}
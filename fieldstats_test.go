@@ -0,0 +1,63 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestCollectFields(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	type args struct {
+		ID      string    `json:"id"`
+		Items   []item    `json:"items"`
+		Created time.Time `json:"created"`
+		Skipped string    `json:"-"`
+	}
+	known := map[string]bool{}
+	collectFields(reflect.TypeOf(args{}), "", known, map[reflect.Type]bool{})
+	expected := map[string]bool{"id": true, "items": true, "items[].name": true, "created": true}
+	if !reflect.DeepEqual(known, expected) {
+		t.Error(fmt.Sprintf("unexpected fields: %v", known))
+	}
+}
+
+func TestVAPI_FieldUsages(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	if err := as.SetMethodFieldStats("demo.Test", true); err != nil {
+		t.Fatal(err)
+	}
+
+	as.callLocal("demo.Test", []byte(`{"id":"1","extra":{"a":1}}`))
+	as.callLocal("demo.Test", []byte(`{"id":"2"}`))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/_stats?fields")
+	as.StatsHandler(ctx)
+	var usages []FieldUsage
+	if err := json.Unmarshal(ctx.Response.Body(), &usages); err != nil {
+		t.Fatal(err)
+	}
+	expected := []FieldUsage{{
+		Method:  "demo.Test",
+		Calls:   2,
+		Fields:  map[string]uint64{"id": 2},
+		Unused:  []string{"ttt"},
+		Unknown: map[string]uint64{"extra": 1, "extra.a": 1},
+	}}
+	if !reflect.DeepEqual(usages, expected) {
+		t.Error(fmt.Sprintf("unexpected usages: %+v", usages))
+	}
+
+	as.SetMethodFieldStats("demo.Test", false)
+	if usages := as.FieldUsages(); len(usages) != 0 {
+		t.Error(fmt.Sprintf("field stats must be disabled: %+v", usages))
+	}
+}
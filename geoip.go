@@ -0,0 +1,100 @@
+package vapi
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// countryUserValue is the RequestCtx user value key of the resolved country
+const countryUserValue = "vapi.country"
+
+var errCountryRestricted = errors.New("vapi: method is not available in your country")
+
+// GeoIPReader resolves ip addresses to ISO 3166-1 alpha-2 country codes,
+// implement it with a MaxMind (GeoLite2/GeoIP2) database reader
+type GeoIPReader interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GeoIPReaderFunc adapts a function to GeoIPReader
+type GeoIPReaderFunc func(ip net.IP) (string, error)
+
+// Country implements GeoIPReader
+func (f GeoIPReaderFunc) Country(ip net.IP) (string, error) {
+	return f(ip)
+}
+
+// countryRule restricts a method by caller country
+type countryRule struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// SetGeoIP enables resolution of the caller country, nil disables it.
+// The country is available through GetCountry and counted in method stats.
+func (as *VAPI) SetGeoIP(reader GeoIPReader) {
+	as.mutex.Lock()
+	as.geoip = reader
+	as.mutex.Unlock()
+}
+
+// SetMethodCountries restricts the method to callers from allow countries (any when empty)
+// except deny countries. Restricted calls get 451 Unavailable For Legal Reasons;
+// with an allow list, callers of unknown country are restricted too.
+func (as *VAPI) SetMethodCountries(method string, allow, deny []string) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	var rule *countryRule
+	if len(allow) > 0 || len(deny) > 0 {
+		rule = &countryRule{allow: countrySet(allow), deny: countrySet(deny)}
+	}
+	as.mutex.Lock()
+	methodSpec.countries = rule
+	as.mutex.Unlock()
+	return nil
+}
+
+// GetCountry returns country code of the caller, empty when unknown or GeoIP is disabled
+func GetCountry(ctx *fasthttp.RequestCtx) string {
+	country, _ := ctx.UserValue(countryUserValue).(string)
+	return country
+}
+
+// resolveCountry stores the caller country, writes 451 and returns false when the method is restricted there
+func (as *VAPI) resolveCountry(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	if as.geoip == nil {
+		return true
+	}
+	country, err := as.geoip.Country(ctx.RemoteIP())
+	if err != nil {
+		country = ""
+	}
+	country = strings.ToUpper(country)
+	ctx.SetUserValue(countryUserValue, country)
+	methodSpec.stats.recordCountry(country)
+
+	rule := methodSpec.countries
+	if rule == nil {
+		return true
+	}
+	if rule.deny[country] || (len(rule.allow) > 0 && !rule.allow[country]) {
+		as.writeError(ctx, srvResponse, fasthttp.StatusUnavailableForLegalReasons, errCountryRestricted)
+		return false
+	}
+	return true
+}
+
+// countrySet returns upper cased set of country codes
+func countrySet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(code)] = true
+	}
+	return set
+}
@@ -0,0 +1,49 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestVAPI_SetGeoIP(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.SetGeoIP(GeoIPReaderFunc(func(ip net.IP) (string, error) {
+		switch ip.String() {
+		case "10.0.0.1":
+			return "de", nil
+		case "10.0.0.2":
+			return "KP", nil
+		}
+		return "", errors.New("not found")
+	}))
+	as.SetMethodCountries("demo.Test", []string{"DE", "FR"}, nil)
+	as.SetMethodCountries("demo.ErrorTest", nil, []string{"kp"})
+
+	call := func(method, ip string) int {
+		status, _, _ := as.callLocalWith(method, []byte(`{"id":"1"}`), &net.TCPAddr{IP: net.ParseIP(ip)}, nil)
+		return status
+	}
+
+	for _, c := range []struct {
+		method, ip string
+		status     int
+	}{
+		{"demo.Test", "10.0.0.1", 200},
+		{"demo.Test", "10.0.0.2", 451},
+		{"demo.Test", "10.0.0.3", 451},
+		{"demo.ErrorTest", "10.0.0.2", 451},
+		{"demo.ErrorTest", "10.0.0.3", 424},
+	} {
+		if status := call(c.method, c.ip); status != c.status {
+			t.Error(fmt.Sprintf("%s from %s: expected %d, got %d", c.method, c.ip, c.status, status))
+		}
+	}
+
+	stats := as.Stats()
+	if stats[1].Method != "demo.Test" || stats[1].Countries["DE"] != 1 || stats[1].Countries["KP"] != 1 || stats[1].Countries[""] != 1 {
+		t.Error(fmt.Sprintf("wrong country stats: %+v", stats[1].Countries))
+	}
+}
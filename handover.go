@@ -0,0 +1,91 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// inheritedListenerEnv holds the descriptor number of the listener passed by the parent process
+const inheritedListenerEnv = "VAPI_INHERITED_LISTENER_FD"
+
+// filer is implemented by listeners able to expose their socket descriptor
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Listen returns the listener inherited from the parent process during a
+// graceful restart, or announces a new one on the network address.
+//
+// A graceful restart looks like:
+//
+//	ln, _ := vapi.Listen("tcp", ":8080")
+//	go server.Serve(ln)
+//	...
+//	// on deploy signal
+//	vapi.Restart(ln)     // new binary starts accepting on the same socket
+//	api.EnterLameDuck()
+//	server.Shutdown()    // old process stops accepting and drains
+func Listen(network, addr string) (net.Listener, error) {
+	fdValue := os.Getenv(inheritedListenerEnv)
+	if fdValue == "" {
+		return net.Listen(network, addr)
+	}
+
+	// don't pass the listener further to our own children
+	os.Unsetenv(inheritedListenerEnv)
+
+	fd, err := strconv.Atoi(fdValue)
+	if err != nil {
+		return nil, fmt.Errorf("vapi: malformed %s: %q", inheritedListenerEnv, fdValue)
+	}
+
+	file := os.NewFile(uintptr(fd), "vapi-listener")
+	if file == nil {
+		return nil, fmt.Errorf("vapi: invalid inherited listener descriptor %d", fd)
+	}
+	defer file.Close()
+
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't use inherited listener: %s", err.Error())
+	}
+	return ln, nil
+}
+
+// Restart starts a new instance of the running binary with the same arguments
+// and environment, handing ln over to it. The caller keeps serving until it
+// decides to shut down; connections queued on the socket are accepted by the
+// new instance, so none are dropped.
+func Restart(ln net.Listener) (*os.Process, error) {
+	lnFiler, ok := ln.(filer)
+	if !ok {
+		return nil, errors.New("vapi: listener doesn't support descriptor passing")
+	}
+	file, err := lnFiler.File()
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't get listener descriptor: %s", err.Error())
+	}
+	defer file.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("vapi: can't find executable: %s", err.Error())
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// ExtraFiles entry i becomes descriptor 3+i in the child
+	cmd.ExtraFiles = []*os.File{file}
+	cmd.Env = append(os.Environ(), inheritedListenerEnv+"=3")
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("vapi: can't start new process: %s", err.Error())
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,125 @@
+package vapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// handoverChildEnv makes TestHandoverChild serve the inherited listener
+const handoverChildEnv = "VAPI_TEST_HANDOVER_CHILD"
+
+// TestHandoverChild is the new instance started by TestRestart
+func TestHandoverChild(t *testing.T) {
+	if os.Getenv(handoverChildEnv) == "" {
+		t.Skip("started by TestRestart only")
+	}
+	ln, err := Listen("tcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(inheritedListenerEnv) != "" {
+		t.Error("inherited listener must not be passed further")
+	}
+	go (&fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) { ctx.SetBodyString("child") }}).Serve(ln)
+	// the parent kills the child when done
+	time.Sleep(30 * time.Second)
+}
+
+func TestRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("descriptor passing is not supported")
+	}
+
+	ln, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "http://" + ln.Addr().String() + "/"
+	parent := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) { ctx.SetBodyString("parent") }}
+	go parent.Serve(ln)
+
+	// new connection per request, so every request goes through accept
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	var served, failed, fromChild int64
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			resp, err := client.Get(addr)
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				continue
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+				continue
+			}
+			atomic.AddInt64(&served, 1)
+			if string(body) == "child" {
+				atomic.AddInt64(&fromChild, 1)
+			}
+		}
+	}()
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestHandoverChild$"}
+	os.Setenv(handoverChildEnv, "1")
+	child, err := Restart(ln)
+	os.Unsetenv(handoverChildEnv)
+	os.Args = args
+	if err != nil {
+		close(stop)
+		t.Fatal(err)
+	}
+	defer func() {
+		child.Kill()
+		child.Wait()
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		deadline := time.Now().Add(10 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal(what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("child must start accepting", func() bool { return atomic.LoadInt64(&fromChild) > 0 })
+
+	// the old process stops accepting, queued connections go to the child
+	if err = parent.Shutdown(); err != nil {
+		t.Error(err)
+	}
+	afterShutdown := atomic.LoadInt64(&served)
+	waitFor("child must serve after parent shutdown", func() bool { return atomic.LoadInt64(&served) > afterShutdown+20 })
+	close(stop)
+	<-done
+
+	if failed := atomic.LoadInt64(&failed); failed != 0 {
+		t.Error(fmt.Sprintf("%d of %d requests failed during handover", failed, failed+atomic.LoadInt64(&served)))
+	}
+}
+
+func TestRestart_Unsupported(t *testing.T) {
+	if _, err := Restart(nil); err == nil || !strings.Contains(err.Error(), "descriptor passing") {
+		t.Error(fmt.Sprintf("listener without descriptor must fail: %v", err))
+	}
+}
@@ -0,0 +1,55 @@
+package vapi
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+// headersUserValue is the RequestCtx user value key of buffered response headers
+const headersUserValue = "vapi.headers"
+
+// responseHeaders are headers and cookies set by the method
+type responseHeaders struct {
+	headers [][2]string
+	cookies []*fasthttp.Cookie
+}
+
+// SetHeader sets response header of the current call. Headers are applied after
+// the reply is encoded, so they override headers set by the codec, and are sent
+// with error replies too. Headers set by methods called in-process with Call
+// reach the outer response.
+func SetHeader(ctx *fasthttp.RequestCtx, key, value string) {
+	buffered := bufferedHeaders(ctx)
+	buffered.headers = append(buffered.headers, [2]string{key, value})
+}
+
+// SetCookie sets response cookie of the current call, the cookie is copied
+func SetCookie(ctx *fasthttp.RequestCtx, cookie *fasthttp.Cookie) {
+	buffered := bufferedHeaders(ctx)
+	c := &fasthttp.Cookie{}
+	c.CopyTo(cookie)
+	buffered.cookies = append(buffered.cookies, c)
+}
+
+// bufferedHeaders returns headers buffer of the request, creating it on first use
+func bufferedHeaders(ctx *fasthttp.RequestCtx) *responseHeaders {
+	buffered, ok := ctx.UserValue(headersUserValue).(*responseHeaders)
+	if !ok {
+		buffered = &responseHeaders{}
+		ctx.SetUserValue(headersUserValue, buffered)
+	}
+	return buffered
+}
+
+// applyHeaders copies buffered headers and cookies to the response
+func applyHeaders(ctx *fasthttp.RequestCtx) {
+	buffered, ok := ctx.UserValue(headersUserValue).(*responseHeaders)
+	if !ok {
+		return
+	}
+	for _, header := range buffered.headers {
+		ctx.Response.Header.Set(header[0], header[1])
+	}
+	for _, cookie := range buffered.cookies {
+		ctx.Response.Header.SetCookie(cookie)
+	}
+}
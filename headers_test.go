@@ -0,0 +1,53 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type HeadersAPI struct{}
+
+func (h *HeadersAPI) Login(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	cookie := &fasthttp.Cookie{}
+	cookie.SetKey("session")
+	cookie.SetValue(args.ID)
+	SetCookie(ctx, cookie)
+	SetHeader(ctx, "Cache-Control", "no-store")
+	return nil
+}
+
+func (h *HeadersAPI) Outer(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	if err := Call(ctx, "HeadersAPI.Login", args, reply); err != nil {
+		return err.(*Error)
+	}
+	SetHeader(ctx, "X-Outer", "1")
+	return nil
+}
+
+func TestSetHeader(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(HeadersAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{"id":"abc"}`)
+	as.CallAPI(ctx, "HeadersAPI.Outer")
+
+	if ctx.Response.StatusCode() != 200 {
+		t.Fatal(fmt.Sprintf("unexpected status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	if value := string(ctx.Response.Header.Peek("Cache-Control")); value != "no-store" {
+		t.Error(fmt.Sprintf("nested call header is missing: %q", value))
+	}
+	if value := string(ctx.Response.Header.Peek("X-Outer")); value != "1" {
+		t.Error(fmt.Sprintf("header is missing: %q", value))
+	}
+	cookie := &fasthttp.Cookie{}
+	cookie.SetKey("session")
+	if !ctx.Response.Header.Cookie(cookie) || string(cookie.Value()) != "abc" {
+		t.Error(fmt.Sprintf("cookie is missing: %s", ctx.Response.Header.String()))
+	}
+}
@@ -38,3 +38,54 @@ func WriteResponse(ctx *fasthttp.RequestCtx, status int, resp ServerResponse) {
 	ctx.Response.Header.Set("x-content-type-options", "nosniff")
 	ctx.SetContentType("application/json; charset=utf-8")
 }
+
+// writeResponse writes response with WriteResponse and applies server wide response options
+func (as *VAPI) writeResponse(ctx *fasthttp.RequestCtx, status int, resp ServerResponse) {
+	resp.Debug = traceDebug(ctx)
+	WriteResponse(ctx, status, resp)
+	if as.profile.PrettyJSON {
+		indentResponse(ctx)
+	}
+	as.wrapJSONP(ctx)
+	as.finishResponse(ctx)
+}
+
+// finishResponse applies server wide options to the fully written buffered response
+func (as *VAPI) finishResponse(ctx *fasthttp.RequestCtx) {
+	as.setHSTS(ctx)
+	as.mutex.RLock()
+	signer := as.signer
+	as.mutex.RUnlock()
+	if signer != nil {
+		signResponse(ctx, signer)
+	}
+}
+
+// writeError wraps err into a pooled Error and writes it with the given http status code
+func (as *VAPI) writeError(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, status int, err error) {
+	errAPI := acquireError()
+	errAPI.ErrorHTTPCode = status
+	errAPI.ErrorCode = 0
+	errAPI.ErrorMessage = err.Error()
+	if as.profile.RedactErrors && status >= fasthttp.StatusInternalServerError {
+		errAPI.ErrorMessage = redactedError
+	}
+
+	srvResponse.Error = errAPI
+	as.writeResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
+	releaseError(errAPI)
+}
+
+// writeHandlerError writes err as ServerResponse error from request handlers working outside of CallAPI
+func writeHandlerError(ctx *fasthttp.RequestCtx, status int, err error) {
+	srvResponse := acquireResponse()
+	defer releaseResponse(srvResponse)
+
+	errAPI := acquireError()
+	errAPI.ErrorHTTPCode = status
+	errAPI.ErrorMessage = err.Error()
+
+	srvResponse.Error = errAPI
+	WriteResponse(ctx, status, *srvResponse)
+	releaseError(errAPI)
+}
@@ -0,0 +1,76 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Templater is implemented by replies choosing their html template by name
+type Templater interface {
+	// Template returns name of the template in the set given to SetTemplates
+	Template() string
+}
+
+// SetTemplates sets templates looked up by replies implementing Templater
+func (as *VAPI) SetTemplates(templates *template.Template) {
+	as.mutex.Lock()
+	as.templates = templates
+	as.mutex.Unlock()
+}
+
+// SetMethodTemplate renders replies of the method with tmpl, nil restores json replies.
+//
+// Replies are rendered as text/html only for clients explicitly accepting it
+// (Accept header with text/html, as browsers send), others get json. Errors
+// returned by the method and replies of in-process calls are always json.
+func (as *VAPI) SetMethodTemplate(method string, tmpl *template.Template) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.template = tmpl
+	as.mutex.Unlock()
+	return nil
+}
+
+// renderHTML writes reply rendered with its template, false when reply has no template,
+// the client doesn't ask for html or the call is in-process
+func (as *VAPI) renderHTML(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, reply reflect.Value) bool {
+	as.mutex.RLock()
+	tmpl, templates := methodSpec.template, as.templates
+	as.mutex.RUnlock()
+	if templater, ok := reply.Interface().(Templater); ok && templates != nil {
+		if name := templater.Template(); name != "" {
+			if tmpl = templates.Lookup(name); tmpl == nil {
+				as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, fmt.Errorf("vapi: template %q not found", name))
+				return true
+			}
+		}
+	}
+	if tmpl == nil || ctx.UserValue(localCallUserValue) != nil || !acceptsHTML(ctx) {
+		return false
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, reply.Interface()); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, fmt.Errorf("vapi: template %q failed: %s", tmpl.Name(), err.Error()))
+		return true
+	}
+
+	ctx.SetStatusCode(successStatus(ctx, reply))
+	ctx.SetContentType("text/html; charset=utf-8")
+	ctx.SetBody(buf.Bytes())
+	as.finishResponse(ctx)
+	return true
+}
+
+// acceptsHTML reports whether Accept header of the request names text/html
+func acceptsHTML(ctx *fasthttp.RequestCtx) bool {
+	return bytes.Contains(ctx.Request.Header.Peek("Accept"), []byte("text/html"))
+}
@@ -0,0 +1,73 @@
+package vapi
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetMethodTemplate(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("page").Parse(`<h1>{{.ID}}</h1>`))
+	if err := as.SetMethodTemplate("demo.Test", tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(args, accept string) (int, string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.Set("Accept", accept)
+		ctx.Request.SetBodyString(args)
+		as.CallAPI(ctx, "demo.Test")
+		return ctx.Response.StatusCode(), string(ctx.Response.Body())
+	}
+
+	if status, body := call(`{"id":"<b>"}`, "text/html,*/*"); status != 200 || body != `<h1>&lt;b&gt;</h1>` {
+		t.Error(fmt.Sprintf("wrong html reply: %d %s", status, body))
+	}
+	for _, accept := range []string{"application/json", "*/*", ""} {
+		if status, body := call(`{"id":"1"}`, accept); status != 200 || !strings.HasPrefix(body, `{"response":`) {
+			t.Error(fmt.Sprintf("clients not asking for html must get json (%q): %d %s", accept, status, body))
+		}
+	}
+
+	status, body, _ := as.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		ctx.Request.Header.Set("Accept", "text/html")
+	})
+	if status != 200 || !strings.HasPrefix(string(body), `{"response":`) {
+		t.Error(fmt.Sprintf("in-process calls must get json: %d %s", status, body))
+	}
+}
+
+func TestVAPI_SetMethodTemplate_Transforms(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(CommentAPI), "comments"); err != nil {
+		t.Fatal(err)
+	}
+	as.SetContentFilter(WordlistFilter([]string{"spam"}, false))
+	as.SetResponseSigner(NewHMACSigner("k1", []byte("secret")))
+	tmpl := template.Must(template.New("page").Parse(`<p>{{.Text}}</p>`))
+	if err := as.SetMethodTemplate("comments.Latest", tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.Set("Accept", "text/html")
+	ctx.Request.SetBody([]byte(`{}`))
+	as.CallAPI(ctx, "comments.Latest")
+
+	if body := string(ctx.Response.Body()); body != `<p>**** ****</p>` {
+		t.Error(fmt.Sprintf("html reply must be moderated: %s", body))
+	}
+	jws := string(ctx.Response.Header.Peek(SignatureHeader))
+	if err := VerifyDetached(NewHMACVerifier([]byte("secret")), ctx.Response.Body(), jws); err != nil {
+		t.Error(fmt.Sprintf("html reply must be signed: %s", err))
+	}
+}
@@ -0,0 +1,235 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Journal defaults
+const (
+	DefaultJournalSize      = 1000
+	DefaultJournalArgsLimit = 256
+)
+
+// JournalOptions configures the request journal.
+// Args fields tagged with `vapi:"mask"` are kept as MaskedValue.
+type JournalOptions struct {
+	// Path is the file the journal is flushed to
+	Path string
+	// Size is the number of kept calls, DefaultJournalSize when zero
+	Size int
+	// ArgsLimit is the number of args bytes kept, DefaultJournalArgsLimit when
+	// zero, negative keeps no args
+	ArgsLimit int
+	// Principal identifies callers in entries, none when nil
+	Principal PrincipalFunc
+	// FlushInterval flushes the journal periodically, so crashes which can't be
+	// intercepted (runtime fatal errors, OOM kills) leave a recent journal; 0 disables it
+	FlushInterval time.Duration
+}
+
+// JournalEntry is a call recorded in the journal
+type JournalEntry struct {
+	Method    string        `json:"method"`
+	Principal string        `json:"principal,omitempty"`
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+	Status    int           `json:"status"`
+	Args      string        `json:"args,omitempty"`
+	// InFlight is set for calls which didn't finish when the journal was taken
+	InFlight bool `json:"in_flight,omitempty"`
+	// Panic is the panic value of the call
+	Panic string `json:"panic,omitempty"`
+}
+
+// journal is a ring buffer of recent calls
+type journal struct {
+	JournalOptions
+
+	mutex   sync.Mutex
+	entries []JournalEntry
+	seqs    []uint64
+	seq     uint64
+	stop    chan struct{}
+}
+
+// SetJournal keeps the last options.Size calls in memory and flushes them to
+// options.Path when a method panics (then the panic goes on), on FlushJournal
+// and every FlushInterval. nil disables the journal.
+func (as *VAPI) SetJournal(options *JournalOptions) error {
+	var j *journal
+	if options != nil {
+		if options.Path == "" {
+			return fmt.Errorf("vapi: journal needs a path")
+		}
+		j = &journal{JournalOptions: *options, stop: make(chan struct{})}
+		if j.Size <= 0 {
+			j.Size = DefaultJournalSize
+		}
+		if j.ArgsLimit == 0 {
+			j.ArgsLimit = DefaultJournalArgsLimit
+		}
+		j.entries, j.seqs = make([]JournalEntry, j.Size), make([]uint64, j.Size)
+	}
+
+	as.mutex.Lock()
+	previous := as.journal
+	as.journal = j
+	as.mutex.Unlock()
+
+	if previous != nil {
+		close(previous.stop)
+	}
+	if j != nil && j.FlushInterval > 0 {
+		go j.flushPeriodically()
+	}
+	return nil
+}
+
+// Journal returns the journaled calls, the oldest first
+func (as *VAPI) Journal() []JournalEntry {
+	as.mutex.RLock()
+	j := as.journal
+	as.mutex.RUnlock()
+	if j == nil {
+		return nil
+	}
+	return j.snapshot()
+}
+
+// FlushJournal writes the journal to its file as json lines
+func (as *VAPI) FlushJournal() error {
+	as.mutex.RLock()
+	j := as.journal
+	as.mutex.RUnlock()
+	if j == nil {
+		return nil
+	}
+	return j.flush()
+}
+
+// journalArgs returns args for the journal with fields tagged `vapi:"mask"`
+// masked and cut to limit, args which can't be masked aren't kept
+func journalArgs(args []byte, maskPaths []string, limit int) string {
+	if len(maskPaths) > 0 && len(args) > 0 {
+		masked, err := maskBody(args, maskPaths)
+		if err != nil {
+			return fmt.Sprintf("<%d bytes of non-json, not kept>", len(args))
+		}
+		args = masked
+	}
+	if len(args) > limit {
+		args = args[:limit]
+	}
+	return string(args)
+}
+
+// journalCall records the call start, the returned func must be deferred: it
+// records the result and flushes the journal when the method panics
+func journalCall(ctx *fasthttp.RequestCtx, j *journal, methodSpec *serviceMethod) func() {
+	if j == nil {
+		return func() {}
+	}
+
+	entry := JournalEntry{Method: methodSpec.name, Started: time.Now(), InFlight: true}
+	if j.Principal != nil {
+		entry.Principal = j.Principal(ctx)
+	}
+	if j.ArgsLimit > 0 {
+		entry.Args = journalArgs(ctx.Request.Body(), maskPathsOf(methodSpec.argsType), j.ArgsLimit)
+	}
+	seq := j.record(entry)
+
+	return func() {
+		entry.Duration, entry.Status, entry.InFlight = time.Since(entry.Started), ctx.Response.StatusCode(), false
+		recovered := recover()
+		if recovered != nil {
+			entry.Panic = fmt.Sprint(recovered)
+		}
+		j.update(seq, entry)
+		if recovered != nil {
+			j.flush()
+			panic(recovered)
+		}
+	}
+}
+
+// record adds entry to the ring and returns its sequence number
+func (j *journal) record(entry JournalEntry) uint64 {
+	j.mutex.Lock()
+	j.seq++
+	slot := int(j.seq % uint64(len(j.entries)))
+	j.entries[slot], j.seqs[slot] = entry, j.seq
+	j.mutex.Unlock()
+	return j.seq
+}
+
+// update replaces the entry with sequence number seq unless it was overwritten
+func (j *journal) update(seq uint64, entry JournalEntry) {
+	j.mutex.Lock()
+	slot := int(seq % uint64(len(j.entries)))
+	if j.seqs[slot] == seq {
+		j.entries[slot] = entry
+	}
+	j.mutex.Unlock()
+}
+
+// snapshot returns the recorded entries, the oldest first
+func (j *journal) snapshot() []JournalEntry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	entries := make([]JournalEntry, 0, len(j.entries))
+	first := uint64(1)
+	if j.seq >= uint64(len(j.entries)) {
+		first = j.seq - uint64(len(j.entries)) + 1
+	}
+	for seq := first; seq <= j.seq; seq++ {
+		entries = append(entries, j.entries[int(seq%uint64(len(j.entries)))])
+	}
+	return entries
+}
+
+// flush writes the journal to a temporary file renamed over Path, so a crash while flushing keeps the previous one
+func (j *journal) flush() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(j.Path), filepath.Base(j.Path)+".*")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, entry := range j.snapshot() {
+		if err = encoder.Encode(entry); err != nil {
+			break
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// flushPeriodically flushes the journal every FlushInterval until it is replaced
+func (j *journal) flushPeriodically() {
+	ticker := time.NewTicker(j.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			j.flush()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package vapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CrashAPI panics
+type CrashAPI struct{}
+
+// Crash panics with the id
+func (h *CrashAPI) Crash(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *TestReply) error {
+	panic("crash " + Args.ID)
+}
+
+func TestVAPI_SetJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.jsonl")
+
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.RegisterService(new(CrashAPI), "crash")
+	if err := as.SetJournal(&JournalOptions{Path: path, Size: 3, ArgsLimit: 8, Principal: HeaderPrincipal("X-User")}); err != nil {
+		t.Fatal(err)
+	}
+	defer as.SetJournal(nil)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		as.callLocalWith("demo.Test", []byte(`{"id":"`+id+`"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.Set("X-User", "alice")
+		})
+	}
+	entries := as.Journal()
+	if len(entries) != 3 || entries[0].Args != `{"id":"2` || entries[2].Principal != "alice" || entries[2].Status != 200 || entries[2].InFlight {
+		t.Error(fmt.Sprintf("wrong journal: %+v", entries))
+	}
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "crash 5" {
+				t.Error(fmt.Sprintf("panic must go on, got %v", recovered))
+			}
+		}()
+		as.callLocal("crash.Crash", []byte(`{"id":"5"}`))
+	}()
+
+	flushed, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(flushed)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], `"panic":"crash 5"`) || !strings.Contains(lines[2], `"method":"crash.Crash"`) {
+		t.Error(fmt.Sprintf("journal must be flushed on panic: %s", flushed))
+	}
+}
+
+func TestVAPI_SetJournal_Masked(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(LoginAPI), "auth"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetJournal(&JournalOptions{Path: filepath.Join(os.TempDir(), "unused.jsonl")}); err != nil {
+		t.Fatal(err)
+	}
+	defer as.SetJournal(nil)
+
+	as.callLocal("auth.Login", []byte(`{"user":"ann","password":"hunter2"}`))
+	as.callLocal("auth.Login", []byte(`password=hunter2`))
+
+	entries := as.Journal()
+	if len(entries) != 2 || entries[0].Args != `{"password":"***","user":"ann"}` {
+		t.Error(fmt.Sprintf("masked args must not be journaled: %+v", entries))
+	}
+	if len(entries) == 2 && strings.Contains(entries[1].Args, "hunter2") {
+		t.Error(fmt.Sprintf("args which can't be masked must not be journaled: %s", entries[1].Args))
+	}
+}
@@ -0,0 +1,98 @@
+package vapi
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/valyala/fasthttp"
+)
+
+// JSONPCallbackParam is the query parameter naming the JSONP callback
+const JSONPCallbackParam = "callback"
+
+// jsonpCallbackPattern accepts plain and dotted javascript identifiers, e.g. "jQuery123.cb"
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// maxJSONPCallback limits callback name length
+const maxJSONPCallback = 128
+
+// jsonpUserValue marks calls answered with JSONP, holds the callback name
+const jsonpUserValue = "vapi.jsonp"
+
+// SetJSONP enables JSONP responses for legacy browser clients on the server,
+// methods opt in with SetMethodJSONP. GET calls of those methods with
+// JSONPCallbackParam get the successful json response wrapped into the
+// callback call and served as application/javascript. Error responses keep
+// their status and stay json, so the script fails to load instead of calling
+// back with an error.
+//
+// Any page can include a JSONP script, so calls carrying cookies are refused
+// with 403: the browser would attach them and leak the reply to the page.
+func (as *VAPI) SetJSONP(enabled bool) {
+	as.mutex.Lock()
+	as.jsonp = enabled
+	as.mutex.Unlock()
+}
+
+// SetMethodJSONP allows JSONP responses of method, see SetJSONP
+func (as *VAPI) SetMethodJSONP(method string, enabled bool) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.jsonp = enabled
+	as.mutex.Unlock()
+	return nil
+}
+
+// checkJSONP validates JSONP request of the call and marks it for wrapping,
+// returns false when the call was answered
+func (as *VAPI) checkJSONP(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	if !ctx.IsGet() {
+		return true
+	}
+	callback := ctx.QueryArgs().Peek(JSONPCallbackParam)
+	if len(callback) == 0 {
+		return true
+	}
+
+	as.mutex.RLock()
+	enabled := as.jsonp && methodSpec.jsonp
+	as.mutex.RUnlock()
+	if !enabled {
+		return true
+	}
+
+	if len(callback) > maxJSONPCallback || !jsonpCallbackPattern.Match(callback) {
+		as.writeError(ctx, srvResponse, fasthttp.StatusBadRequest, fmt.Errorf("vapi: invalid jsonp callback"))
+		return false
+	}
+	if len(ctx.Request.Header.Peek("Cookie")) > 0 {
+		as.writeError(ctx, srvResponse, fasthttp.StatusForbidden, fmt.Errorf("vapi: jsonp is not allowed for calls with cookies"))
+		return false
+	}
+	ctx.SetUserValue(jsonpUserValue, string(callback))
+	return true
+}
+
+// wrapJSONP rewrites successful json response of the JSONP call into the script
+func (as *VAPI) wrapJSONP(ctx *fasthttp.RequestCtx) {
+	callback, ok := ctx.UserValue(jsonpUserValue).(string)
+	if !ok || ctx.Response.StatusCode() >= fasthttp.StatusBadRequest {
+		return
+	}
+
+	body := ctx.Response.Body()
+	// the comment prevents content sniffing attacks (Rosetta Flash) using the callback name
+	script := make([]byte, 0, len(body)+len(callback)+8)
+	script = append(script, "/**/"...)
+	script = append(script, callback...)
+	script = append(script, '(')
+	script = append(script, body...)
+	script = append(script, ");"...)
+
+	ctx.SetBody(script)
+	ctx.SetContentType("application/javascript; charset=utf-8")
+}
@@ -0,0 +1,65 @@
+package vapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetJSONP(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	as.SetJSONP(true)
+
+	get := func(method, callback string, prepare func(ctx *fasthttp.RequestCtx)) (int, string, string) {
+		var response *fasthttp.RequestCtx
+		status, body, _ := as.callLocalWith(method, nil, nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+			ctx.Request.Header.SetMethod("GET")
+			ctx.Request.SetRequestURI("/" + method + "?" + ArgsParam + "=" + url.QueryEscape(`{"id":"1"}`) + "&" + JSONPCallbackParam + "=" + url.QueryEscape(callback))
+			if prepare != nil {
+				prepare(ctx)
+			}
+		})
+		return status, string(body), string(response.Response.Header.ContentType())
+	}
+
+	if status, body, _ := get("demo.Test", "cb", nil); status != 200 || strings.HasPrefix(body, "/**/") {
+		t.Error(fmt.Sprintf("methods must opt in to jsonp: %d %s", status, body))
+	}
+
+	if err := as.SetMethodJSONP("demo.Test", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodJSONP("demo.ErrorTest", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodJSONP("demo.Missing", true); err == nil {
+		t.Error("unknown method must fail")
+	}
+
+	if status, body, _ := get("demo.Test", "jQuery1.cb", nil); status != 200 || !strings.HasPrefix(body, "/**/jQuery1.cb({") || !strings.HasSuffix(body, ");") {
+		t.Error(fmt.Sprintf("reply must be wrapped: %d %s", status, body))
+	}
+
+	for _, callback := range []string{"alert(1)", "1cb", "cb.", strings.Repeat("a", maxJSONPCallback+1)} {
+		if status, body, _ := get("demo.Test", callback, nil); status != 400 || strings.HasPrefix(body, "/**/") {
+			t.Error(fmt.Sprintf("callback %q must be rejected: %d %s", callback, status, body))
+		}
+	}
+
+	withCookie := func(ctx *fasthttp.RequestCtx) { ctx.Request.Header.Set("Cookie", "sid=1") }
+	if status, body, _ := get("demo.Test", "cb", withCookie); status != 403 || strings.HasPrefix(body, "/**/") {
+		t.Error(fmt.Sprintf("calls with cookies must be refused: %d %s", status, body))
+	}
+
+	status, body, contentType := get("demo.ErrorTest", "cb", nil)
+	if status < 400 || strings.HasPrefix(body, "/**/") || !strings.HasPrefix(contentType, "application/json") {
+		t.Error(fmt.Sprintf("errors must keep their status and stay json: %d %s %s", status, body, contentType))
+	}
+}
@@ -0,0 +1,172 @@
+package vapi
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Kafka record headers used by KafkaBridge
+const (
+	KafkaMethodHeader        = "vapi-method"
+	KafkaCorrelationIDHeader = "vapi-correlation-id"
+	KafkaStatusHeader        = "vapi-status"
+)
+
+// KafkaRecord is a message read from or written to a Kafka topic
+type KafkaRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaReader reads records of the request topic with consumer group semantics.
+// It is a thin adapter over a Kafka client, e.g. kafka-go Reader.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (KafkaRecord, error)
+	CommitMessages(ctx context.Context, records ...KafkaRecord) error
+}
+
+// KafkaWriter writes records, e.g. kafka-go Writer
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, records ...KafkaRecord) error
+}
+
+// KafkaBridge invokes registered methods from request topic records and writes
+// replies to the response topic.
+//
+// The method is taken from KafkaMethodHeader and args from the record value.
+// Replies carry the response envelope as value, KafkaStatusHeader with the
+// http status and the correlation id (KafkaCorrelationIDHeader or the request
+// key) as key. Records are committed after the reply is written, so delivery
+// is at-least-once. With Concurrency above 1 records complete out of order but
+// are committed in fetch order: a record is committed only when all records
+// fetched before it are, so a restart never skips records still in flight.
+type KafkaBridge struct {
+	// ResponseTopic receives replies
+	ResponseTopic string
+	// Concurrency is the number of records processed at once
+	Concurrency int
+
+	as     *VAPI
+	reader KafkaReader
+	writer KafkaWriter
+}
+
+// NewKafkaBridge returns bridge reading requests from reader and writing replies with writer
+func (as *VAPI) NewKafkaBridge(reader KafkaReader, writer KafkaWriter, responseTopic string) *KafkaBridge {
+	return &KafkaBridge{ResponseTopic: responseTopic, Concurrency: 1, as: as, reader: reader, writer: writer}
+}
+
+// Run processes records until ctx is done or the reader fails
+func (kb *KafkaBridge) Run(ctx context.Context) error {
+	concurrency := kb.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records := make(chan sequencedRecord)
+	errs := make(chan error, concurrency+1)
+	commits := &kafkaCommits{done: map[uint64]KafkaRecord{}}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range records {
+				err := kb.handle(ctx, record.KafkaRecord)
+				if err == nil {
+					err = commits.complete(ctx, kb.reader, record.seq, record.KafkaRecord)
+				}
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+	for seq := uint64(0); err == nil; seq++ {
+		var record KafkaRecord
+		if record, err = kb.reader.FetchMessage(ctx); err != nil {
+			break
+		}
+		select {
+		case records <- sequencedRecord{KafkaRecord: record, seq: seq}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(records)
+	wg.Wait()
+
+	select {
+	case handleErr := <-errs:
+		return handleErr
+	default:
+	}
+	return err
+}
+
+// handle invokes method of record and writes the reply, the record is committed by the caller
+func (kb *KafkaBridge) handle(ctx context.Context, record KafkaRecord) error {
+	correlationID := record.Headers[KafkaCorrelationIDHeader]
+	if correlationID == "" {
+		correlationID = string(record.Key)
+	}
+
+	status, body, _ := kb.as.callLocal(record.Headers[KafkaMethodHeader], record.Value)
+	reply := KafkaRecord{
+		Topic: kb.ResponseTopic,
+		Key:   []byte(correlationID),
+		Value: body,
+		Headers: map[string]string{
+			KafkaCorrelationIDHeader: correlationID,
+			KafkaStatusHeader:        strconv.Itoa(status),
+		},
+	}
+	return kb.writer.WriteMessages(ctx, reply)
+}
+
+// sequencedRecord is a record numbered in fetch order
+type sequencedRecord struct {
+	KafkaRecord
+	seq uint64
+}
+
+// kafkaCommits commits handled records in fetch order
+type kafkaCommits struct {
+	mutex sync.Mutex
+	next  uint64 // sequence of the oldest record not committed
+	done  map[uint64]KafkaRecord
+}
+
+// complete marks record seq handled and commits the contiguous run of handled records
+// starting at the oldest uncommitted one
+func (kc *kafkaCommits) complete(ctx context.Context, reader KafkaReader, seq uint64, record KafkaRecord) error {
+	kc.mutex.Lock()
+	defer kc.mutex.Unlock()
+	kc.done[seq] = record
+
+	var ready []KafkaRecord
+	for {
+		record, ok := kc.done[kc.next]
+		if !ok {
+			break
+		}
+		ready = append(ready, record)
+		delete(kc.done, kc.next)
+		kc.next++
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+	// committing under the lock keeps commits ordered across workers
+	return reader.CommitMessages(ctx, ready...)
+}
@@ -0,0 +1,109 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+type fakeKafka struct {
+	mutex     sync.Mutex
+	pending   []KafkaRecord
+	written   []KafkaRecord
+	committed int
+	commits   []string
+	// onWrite (if any) is called before a reply is written
+	onWrite func(reply KafkaRecord)
+}
+
+func (fk *fakeKafka) FetchMessage(ctx context.Context) (KafkaRecord, error) {
+	fk.mutex.Lock()
+	defer fk.mutex.Unlock()
+	if len(fk.pending) == 0 {
+		return KafkaRecord{}, io.EOF
+	}
+	record := fk.pending[0]
+	fk.pending = fk.pending[1:]
+	return record, nil
+}
+
+func (fk *fakeKafka) CommitMessages(ctx context.Context, records ...KafkaRecord) error {
+	fk.mutex.Lock()
+	fk.committed += len(records)
+	for _, record := range records {
+		fk.commits = append(fk.commits, string(record.Key))
+	}
+	fk.mutex.Unlock()
+	return nil
+}
+
+func (fk *fakeKafka) WriteMessages(ctx context.Context, records ...KafkaRecord) error {
+	if fk.onWrite != nil {
+		for _, record := range records {
+			fk.onWrite(record)
+		}
+	}
+	fk.mutex.Lock()
+	fk.written = append(fk.written, records...)
+	fk.mutex.Unlock()
+	return nil
+}
+
+func TestKafkaBridge(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	fk := &fakeKafka{pending: []KafkaRecord{
+		{Key: []byte("c1"), Value: []byte(`{"id":"1"}`), Headers: map[string]string{KafkaMethodHeader: "demo.Test"}},
+		{Value: []byte(`{}`), Headers: map[string]string{KafkaMethodHeader: "demo.Missing", KafkaCorrelationIDHeader: "c2"}},
+	}}
+
+	if err := as.NewKafkaBridge(fk, fk, "replies").Run(context.Background()); err != io.EOF {
+		t.Error(fmt.Sprintf("bridge must stop with reader error, got %v", err))
+	}
+	if fk.committed != 2 || len(fk.written) != 2 {
+		t.Fatal(fmt.Sprintf("wrong processing: committed %d, written %d", fk.committed, len(fk.written)))
+	}
+	first, second := fk.written[0], fk.written[1]
+	if first.Topic != "replies" || string(first.Key) != "c1" || first.Headers[KafkaStatusHeader] != "200" || string(first.Value) != `{"response":{"id":"1"}}` {
+		t.Error(fmt.Sprintf("wrong reply: %+v", first))
+	}
+	if string(second.Key) != "c2" || second.Headers[KafkaStatusHeader] != "404" {
+		t.Error(fmt.Sprintf("wrong error reply: %+v", second))
+	}
+}
+
+func TestKafkaBridge_CommitOrder(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	fk := &fakeKafka{}
+	for _, key := range []string{"c1", "c2", "c3"} {
+		fk.pending = append(fk.pending, KafkaRecord{Key: []byte(key), Value: []byte(`{}`), Headers: map[string]string{KafkaMethodHeader: "demo.Test"}})
+	}
+	// c1 completes last
+	laterDone := make(chan struct{}, 2)
+	fk.onWrite = func(reply KafkaRecord) {
+		if string(reply.Key) == "c1" {
+			<-laterDone
+			<-laterDone
+			return
+		}
+		laterDone <- struct{}{}
+	}
+
+	bridge := as.NewKafkaBridge(fk, fk, "replies")
+	bridge.Concurrency = 3
+	if err := bridge.Run(context.Background()); err != io.EOF {
+		t.Error(fmt.Sprintf("bridge must stop with reader error, got %v", err))
+	}
+	if fmt.Sprint(fk.commits) != "[c1 c2 c3]" {
+		t.Error(fmt.Sprintf("records must be committed in fetch order: %v", fk.commits))
+	}
+}
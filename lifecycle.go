@@ -0,0 +1,126 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ServerState is the readiness state of the api server
+type ServerState int32
+
+// Server states
+const (
+	// StateWarmingUp is the initial state, readiness reports not-ready until WarmUp completes
+	StateWarmingUp ServerState = iota
+	// StateReady means the server accepts traffic
+	StateReady
+	// StateLameDuck means the server is going to shut down: readiness reports not-ready,
+	// but calls are still served until the load balancer stops sending them
+	StateLameDuck
+)
+
+// String returns state name
+func (s ServerState) String() string {
+	switch s {
+	case StateWarmingUp:
+		return "warming_up"
+	case StateReady:
+		return "ready"
+	case StateLameDuck:
+		return "lame_duck"
+	}
+	return fmt.Sprintf("state(%d)", int32(s))
+}
+
+// Warmer prepares the server for traffic (cache priming, connection pools)
+type Warmer func() error
+
+// warmer is a named Warmer
+type warmer struct {
+	name string
+	fn   Warmer
+}
+
+// AddWarmer registers warmer executed by WarmUp
+func (as *VAPI) AddWarmer(name string, fn Warmer) {
+	as.mutex.Lock()
+	as.warmers = append(as.warmers, warmer{name: name, fn: fn})
+	as.mutex.Unlock()
+}
+
+// WarmUp runs all registered warmers and switches the server to StateReady.
+// The server stays in StateWarmingUp if any warmer fails. Under systemd
+// with Type=notify the readiness is reported with READY=1.
+func (as *VAPI) WarmUp() error {
+	as.mutex.RLock()
+	warmers := as.warmers
+	as.mutex.RUnlock()
+
+	for _, w := range warmers {
+		if err := w.fn(); err != nil {
+			return fmt.Errorf("vapi: warmer %q failed: %s", w.name, err.Error())
+		}
+	}
+
+	if atomic.CompareAndSwapInt32(&as.state, int32(StateWarmingUp), int32(StateReady)) {
+		SdNotify("READY=1")
+	}
+	return nil
+}
+
+// EnterLameDuck switches the server to StateLameDuck before shutdown
+// and releases calls waiting in WaitEvent. Systemd is told the service is stopping.
+func (as *VAPI) EnterLameDuck() {
+	atomic.StoreInt32(&as.state, int32(StateLameDuck))
+	as.drainPolls()
+	SdNotify("STOPPING=1")
+}
+
+// State returns current server state
+func (as *VAPI) State() ServerState {
+	return ServerState(atomic.LoadInt32(&as.state))
+}
+
+// InFlight returns the number of calls being processed by CallAPI
+func (as *VAPI) InFlight() int64 {
+	return atomic.LoadInt64(&as.inFlight)
+}
+
+// WaitIdle blocks until there are no in-flight calls or timeout passes.
+// Returns false on timeout.
+func (as *VAPI) WaitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for as.InFlight() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// ReadinessHandler responds 200 when the server is ready for traffic and all
+// registered resources answer ping, 503 otherwise. During maintenance it stays
+// 200 with "maintenance" set, so callers keep getting the maintenance notice.
+// Mount it on the path polled by the load balancer.
+func (as *VAPI) ReadinessHandler(ctx *fasthttp.RequestCtx) {
+	state := as.State()
+	if state != StateReady {
+		writeHandlerError(ctx, fasthttp.StatusServiceUnavailable, errors.New("vapi: server is not ready: "+state.String()))
+		return
+	}
+	if err := as.pingResources(); err != nil {
+		writeHandlerError(ctx, fasthttp.StatusServiceUnavailable, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	if _, ok := as.InMaintenance(); ok {
+		ctx.SetBodyString(`{"response":{"state":"ready","maintenance":true}}`)
+		return
+	}
+	ctx.SetBodyString(`{"response":{"state":"ready"}}`)
+}
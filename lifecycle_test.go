@@ -0,0 +1,38 @@
+package vapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVAPI_WarmUp(t *testing.T) {
+	as := NewServer()
+	if as.State() != StateWarmingUp {
+		t.Error("new server must be warming up")
+	}
+
+	fail := true
+	as.AddWarmer("cache", func() error {
+		if fail {
+			return errors.New("cache is down")
+		}
+		return nil
+	})
+
+	if err := as.WarmUp(); err == nil || as.State() != StateWarmingUp {
+		t.Error("failed warmer must keep server warming up")
+	}
+
+	fail = false
+	if err := as.WarmUp(); err != nil || as.State() != StateReady {
+		t.Error("server must be ready after warm up")
+	}
+
+	as.EnterLameDuck()
+	if as.State() != StateLameDuck {
+		t.Error("server must be in lame duck mode")
+	}
+	if err := as.WarmUp(); err != nil || as.State() != StateLameDuck {
+		t.Error("warm up must not leave lame duck mode")
+	}
+}
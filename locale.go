@@ -0,0 +1,156 @@
+package vapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TimezoneHeader is the request header carrying IANA time zone name of the caller
+const TimezoneHeader = "X-Timezone"
+
+// localeUserValue is the RequestCtx user value key of the resolved Locale
+const localeUserValue = "vapi.locale"
+
+// Locale is the language and time zone of the caller
+type Locale struct {
+	// Language is the preferred language tag, e.g. "en-US"
+	Language string
+	// Location is the time zone of the caller
+	Location *time.Location
+}
+
+// LocaleResolver returns the locale of the request, e.g. from headers or token claims
+type LocaleResolver func(ctx *fasthttp.RequestCtx) *Locale
+
+var (
+	locationsMutex sync.RWMutex
+	locations      = map[string]*time.Location{}
+)
+
+// SetLocaleResolver enables per-request locale resolution, nil disables it.
+//
+// The resolved Locale is available to methods through GetLocale and
+// time.Time fields of replies tagged with `vapi:"localtime"` are converted
+// to the caller time zone before encoding.
+func (as *VAPI) SetLocaleResolver(resolver LocaleResolver) {
+	as.mutex.Lock()
+	as.localeResolver = resolver
+	as.mutex.Unlock()
+}
+
+// HeaderLocaleResolver resolves language from Accept-Language and time zone from TimezoneHeader,
+// falling back to the given defaults.
+func HeaderLocaleResolver(defaultLanguage string, defaultLocation *time.Location) LocaleResolver {
+	if defaultLocation == nil {
+		defaultLocation = time.UTC
+	}
+	return func(ctx *fasthttp.RequestCtx) *Locale {
+		locale := &Locale{Language: defaultLanguage, Location: defaultLocation}
+		if language := preferredLanguage(string(ctx.Request.Header.Peek("Accept-Language"))); language != "" {
+			locale.Language = language
+		}
+		if location := loadLocation(string(ctx.Request.Header.Peek(TimezoneHeader))); location != nil {
+			locale.Location = location
+		}
+		return locale
+	}
+}
+
+// GetLocale returns locale of the request, nil when locale resolution is disabled
+func GetLocale(ctx *fasthttp.RequestCtx) *Locale {
+	locale, _ := ctx.UserValue(localeUserValue).(*Locale)
+	return locale
+}
+
+// preferredLanguage returns the language with the highest quality from Accept-Language value
+func preferredLanguage(header string) string {
+	best, bestQuality := "", -1.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		language := strings.TrimSpace(fields[0])
+		if language == "" || language == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = language, quality
+		}
+	}
+	return best
+}
+
+// loadLocation returns cached time zone by IANA name, nil for unknown names
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+
+	locationsMutex.RLock()
+	location, ok := locations[name]
+	locationsMutex.RUnlock()
+	if ok {
+		return location
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		location = nil
+	}
+
+	locationsMutex.Lock()
+	// cache unknown names too, but don't let garbage headers grow the cache unbounded
+	if location != nil || len(locations) < 1024 {
+		locations[name] = location
+	}
+	locationsMutex.Unlock()
+	return location
+}
+
+// localizeTimes converts time.Time fields tagged with `vapi:"localtime"` to location
+func localizeTimes(v reflect.Value, location *time.Location) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			localizeTimes(v.Elem(), location)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			localizeTimes(v.Index(i), location)
+		}
+	case reflect.Struct:
+		if v.Type() == typeOfTime {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if hasTagOption(field, "localtime") {
+				switch {
+				case field.Type == typeOfTime && fv.CanSet():
+					fv.Set(reflect.ValueOf(fv.Interface().(time.Time).In(location)))
+				case field.Type.Kind() == reflect.Ptr && field.Type.Elem() == typeOfTime && !fv.IsNil():
+					fv.Elem().Set(reflect.ValueOf(fv.Elem().Interface().(time.Time).In(location)))
+				}
+				continue
+			}
+			localizeTimes(fv, location)
+		}
+	}
+}
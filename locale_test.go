@@ -0,0 +1,48 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type localeReply struct {
+	Start    time.Time  `vapi:"localtime"`
+	End      *time.Time `vapi:"localtime"`
+	Created  time.Time
+	Children []localeReply
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                          "",
+		"de":                        "de",
+		"fr;q=0.5, en-US, en;q=0.8": "en-US",
+		"*;q=1, ru;q=0.3, uk;q=0.7": "uk",
+	}
+	for header, expected := range cases {
+		if got := preferredLanguage(header); got != expected {
+			t.Error(fmt.Sprintf("wrong language of %q: %q", header, got))
+		}
+	}
+}
+
+func TestLocalizeTimes(t *testing.T) {
+	location := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	end := now.Add(time.Hour)
+
+	reply := &localeReply{Start: now, End: &end, Created: now, Children: []localeReply{{Start: now}}}
+	localizeTimes(reflect.ValueOf(reply), location)
+
+	if reply.Start.Location() != location || reply.End.Location() != location || reply.Children[0].Start.Location() != location {
+		t.Error("tagged fields must be converted to caller location")
+	}
+	if reply.Created.Location() != time.UTC {
+		t.Error("untagged fields must not be converted")
+	}
+	if !reply.Start.Equal(now) {
+		t.Error("converted time must be the same instant")
+	}
+}
@@ -0,0 +1,117 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultLongPollMax is the longest wait of WaitEvent unless changed by SetLongPollMax
+const DefaultLongPollMax = 30 * time.Second
+
+var (
+	// ErrPollTimeout is returned by WaitEvent when nothing happened in time,
+	// methods usually reply with an empty result and the client polls again
+	ErrPollTimeout = errors.New("vapi: long poll timed out")
+	// ErrPollDraining is returned by WaitEvent when the server enters lame duck
+	// state, so waiting calls don't hold the shutdown
+	ErrPollDraining = errors.New("vapi: server is draining")
+)
+
+// SetLongPollMax caps the wait of WaitEvent, zero restores DefaultLongPollMax
+func (as *VAPI) SetLongPollMax(max time.Duration) {
+	as.mutex.Lock()
+	as.longPollMax = max
+	as.mutex.Unlock()
+}
+
+// LongPolls returns the number of calls waiting in WaitEvent
+func (as *VAPI) LongPolls() int64 {
+	return atomic.LoadInt64(&as.polling)
+}
+
+// WaitEvent blocks the method until an event of topic matching match (any
+// event when nil) is published on the server event bus, timeout passes or
+// the server starts draining or shuts down.
+//
+// The wait is capped by SetLongPollMax. It returns ErrPollTimeout or
+// ErrPollDraining when no event arrived; re-check the condition the method
+// waits for after subscribing to avoid missing events published just before.
+// Client disconnects aren't detected, the call waits until its timeout, so keep
+// the cap below the proxy idle timeout.
+func WaitEvent(ctx *fasthttp.RequestCtx, topic string, timeout time.Duration, match func(event Event) bool) (Event, error) {
+	as, ok := ctx.UserValue(serverUserValue).(*VAPI)
+	if !ok {
+		return Event{}, errors.New("vapi: WaitEvent called outside of CallAPI")
+	}
+
+	as.mutex.RLock()
+	max, drain := as.longPollMax, as.pollDrain
+	as.mutex.RUnlock()
+
+	// drainPolls runs after the state change, so either the state or the channel tells about draining
+	if as.State() == StateLameDuck {
+		return Event{}, ErrPollDraining
+	}
+
+	if max <= 0 {
+		max = DefaultLongPollMax
+	}
+	if timeout <= 0 || timeout > max {
+		timeout = max
+	}
+
+	events := make(chan Event, 1)
+	unsubscribe := as.Events().Subscribe(topic, func(_ context.Context, event Event) {
+		if match != nil && !match(event) {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	atomic.AddInt64(&as.polling, 1)
+	defer atomic.AddInt64(&as.polling, -1)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case event := <-events:
+		return event, nil
+	case <-timer.C:
+		return Event{}, ErrPollTimeout
+	case <-drain:
+		return Event{}, ErrPollDraining
+	case <-requestDone(ctx):
+		return Event{}, ErrPollDraining
+	}
+}
+
+// drainPolls releases calls waiting in WaitEvent and arms a new channel for
+// calls made after the server leaves lame duck state
+func (as *VAPI) drainPolls() {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.pollDrain != nil {
+		close(as.pollDrain)
+	}
+	as.pollDrain = make(chan struct{})
+}
+
+// requestDone returns the channel closed on server shutdown, nil for contexts not served by fasthttp.Server
+func requestDone(ctx *fasthttp.RequestCtx) (done <-chan struct{}) {
+	// fasthttp panics on contexts created with RequestCtx.Init
+	defer func() {
+		if recover() != nil {
+			done = nil
+		}
+	}()
+	return ctx.Done()
+}
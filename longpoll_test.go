@@ -0,0 +1,72 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type PollAPI struct{}
+
+func (p *PollAPI) Wait(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	event, err := WaitEvent(ctx, "orders", 0, func(event Event) bool { return event.Payload == args.ID })
+	if err != nil {
+		reply.ID = err.Error()
+		return nil
+	}
+	reply.ID = "got " + event.Payload.(string)
+	return nil
+}
+
+func TestWaitEvent(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(PollAPI), "poll")
+	as.SetLongPollMax(50 * time.Millisecond)
+
+	poll := func() chan string {
+		result := make(chan string, 1)
+		go func() {
+			_, body, _ := as.callLocal("poll.Wait", []byte(`{"id":"7"}`))
+			result <- string(body)
+		}()
+		for as.LongPolls() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return result
+	}
+
+	result := poll()
+	as.Events().Publish("orders", "6")
+	as.Events().Publish("orders", "7")
+	if body := <-result; !strings.Contains(body, "got 7") {
+		t.Error(fmt.Sprintf("matching event must complete the poll: %s", body))
+	}
+
+	if body := <-poll(); !strings.Contains(body, ErrPollTimeout.Error()) {
+		t.Error(fmt.Sprintf("poll must time out at the server max: %s", body))
+	}
+
+	as.SetLongPollMax(time.Minute)
+	result = poll()
+	as.EnterLameDuck()
+	if body := <-result; !strings.Contains(body, ErrPollDraining.Error()) {
+		t.Error(fmt.Sprintf("draining must release the poll: %s", body))
+	}
+	if as.LongPolls() != 0 {
+		t.Error(fmt.Sprintf("polls must be released: %d", as.LongPolls()))
+	}
+	if _, body, _ := as.callLocal("poll.Wait", []byte(`{"id":"7"}`)); !strings.Contains(string(body), ErrPollDraining.Error()) {
+		t.Error(fmt.Sprintf("polls during lame duck must not wait: %s", body))
+	}
+
+	// a server leaving lame duck gets a fresh drain channel
+	atomic.StoreInt32(&as.state, int32(StateReady))
+	as.SetLongPollMax(20 * time.Millisecond)
+	if body := <-poll(); !strings.Contains(body, ErrPollTimeout.Error()) {
+		t.Error(fmt.Sprintf("poll after lame duck must wait again: %s", body))
+	}
+}
@@ -0,0 +1,169 @@
+package vapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Maintenance defaults
+const (
+	defaultMaintenanceMessage    = "vapi: service is under maintenance"
+	defaultMaintenanceRetryAfter = time.Minute
+)
+
+// Maintenance describes the maintenance window the server is in
+type Maintenance struct {
+	// Message is returned to callers, a generic one when empty
+	Message string `json:"message"`
+	// RetryAfter is sent in Retry-After header, a minute when zero
+	RetryAfter Duration `json:"retry_after"`
+	// Allow lists methods served during maintenance, e.g. status checks
+	Allow []string `json:"allow,omitempty"`
+}
+
+// MaintenanceNotice is the data of errors returned during maintenance
+type MaintenanceNotice struct {
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"`
+}
+
+// maintenanceMode is the active maintenance
+type maintenanceMode struct {
+	Maintenance
+	allow    map[string]bool
+	fromFile bool // entered by WatchMaintenanceFile, left when the file is removed
+}
+
+// EnterMaintenance puts the server into maintenance: calls of methods not in
+// Allow fail with 503, Retry-After and MaintenanceNotice as error data.
+// Readiness stays ok, so callers get the notice instead of no backend.
+func (as *VAPI) EnterMaintenance(maintenance Maintenance) {
+	as.enterMaintenance(maintenance, false)
+}
+
+// ExitMaintenance resumes serving all methods
+func (as *VAPI) ExitMaintenance() {
+	as.mutex.Lock()
+	as.maintenance = nil
+	as.mutex.Unlock()
+}
+
+// InMaintenance returns the active maintenance, false when the server isn't in maintenance
+func (as *VAPI) InMaintenance() (Maintenance, bool) {
+	as.mutex.RLock()
+	mode := as.maintenance
+	as.mutex.RUnlock()
+	if mode == nil {
+		return Maintenance{}, false
+	}
+	return mode.Maintenance, true
+}
+
+// WatchMaintenanceFile keeps the server in maintenance while the sentinel
+// file at path exists, checking every interval until ctx is done. The file
+// may hold Maintenance as json or the message as plain text. Maintenance
+// entered with EnterMaintenance is not ended by the file removal.
+func (as *VAPI) WatchMaintenanceFile(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		content, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			maintenance := Maintenance{}
+			if json.Unmarshal(content, &maintenance) != nil {
+				maintenance = Maintenance{Message: string(bytes.TrimSpace(content))}
+			}
+			as.enterMaintenance(maintenance, true)
+		case os.IsNotExist(err):
+			as.mutex.Lock()
+			if as.maintenance != nil && as.maintenance.fromFile {
+				as.maintenance = nil
+			}
+			as.mutex.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// MaintenanceHandler switches maintenance, mount it behind admin
+// authentication. POST or PUT with Maintenance json enters it, DELETE exits,
+// every request is answered with the state.
+func (as *VAPI) MaintenanceHandler(ctx *fasthttp.RequestCtx) {
+	switch {
+	case ctx.IsPost() || ctx.IsPut():
+		maintenance := Maintenance{}
+		if err := json.Unmarshal(ctx.PostBody(), &maintenance); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusBadRequest, err)
+			return
+		}
+		as.EnterMaintenance(maintenance)
+	case ctx.IsDelete():
+		as.ExitMaintenance()
+	}
+
+	state := struct {
+		Maintenance *Maintenance `json:"maintenance"`
+	}{}
+	if maintenance, ok := as.InMaintenance(); ok {
+		state.Maintenance = &maintenance
+	}
+	body, err := json.Marshal(state)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// enterMaintenance activates maintenance with defaults applied
+func (as *VAPI) enterMaintenance(maintenance Maintenance, fromFile bool) {
+	if maintenance.Message == "" {
+		maintenance.Message = defaultMaintenanceMessage
+	}
+	if maintenance.RetryAfter <= 0 {
+		maintenance.RetryAfter = Duration(defaultMaintenanceRetryAfter)
+	}
+	mode := &maintenanceMode{Maintenance: maintenance, allow: map[string]bool{}, fromFile: fromFile}
+	for _, method := range maintenance.Allow {
+		mode.allow[method] = true
+	}
+
+	as.mutex.Lock()
+	as.maintenance = mode
+	as.mutex.Unlock()
+}
+
+// checkMaintenance rejects calls during maintenance, returns false when the call was answered
+func (as *VAPI) checkMaintenance(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, mode *maintenanceMode, methodSpec *serviceMethod) bool {
+	if mode == nil || mode.allow[methodSpec.name] {
+		return true
+	}
+
+	retryAfter := int((time.Duration(mode.RetryAfter) + time.Second - 1) / time.Second)
+	ctx.Response.Header.Set("Retry-After", strconv.Itoa(retryAfter))
+	errAPI := acquireError()
+	errAPI.ErrorHTTPCode = fasthttp.StatusServiceUnavailable
+	errAPI.ErrorMessage = mode.Message
+	errAPI.Data = MaintenanceNotice{Message: mode.Message, RetryAfter: retryAfter}
+	srvResponse.Error = errAPI
+	as.writeResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
+	releaseError(errAPI)
+	return false
+}
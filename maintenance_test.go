@@ -0,0 +1,91 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_EnterMaintenance(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.WarmUp()
+
+	as.EnterMaintenance(Maintenance{Message: "database upgrade", RetryAfter: Duration(30 * time.Second), Allow: []string{"demo.Test"}})
+
+	var response *fasthttp.RequestCtx
+	status, body, _ := as.callLocalWith("demo.ErrorTest", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) { response = ctx })
+	if status != fasthttp.StatusServiceUnavailable || string(response.Response.Header.Peek("Retry-After")) != "30" {
+		t.Error(fmt.Sprintf("expected 503 with Retry-After, got %d %s", status, response.Response.Header.Peek("Retry-After")))
+	}
+	if !strings.Contains(string(body), `"data":{"message":"database upgrade","retry_after":30}`) {
+		t.Error(fmt.Sprintf("unexpected maintenance error: %s", body))
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{"id":"1"}`)); status != 200 {
+		t.Error(fmt.Sprintf("allowed methods must be served, got %d", status))
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	as.ReadinessHandler(ctx)
+	if ctx.Response.StatusCode() != 200 || !strings.Contains(string(ctx.Response.Body()), `"maintenance":true`) {
+		t.Error(fmt.Sprintf("readiness must reflect maintenance: %s", ctx.Response.Body()))
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("DELETE")
+	as.MaintenanceHandler(ctx)
+	if string(ctx.Response.Body()) != `{"maintenance":null}` {
+		t.Error(fmt.Sprintf("unexpected state: %s", ctx.Response.Body()))
+	}
+	if status, _, _ := as.callLocal("demo.ErrorTest", []byte(`{"id":"1"}`)); status == fasthttp.StatusServiceUnavailable {
+		t.Error("maintenance must be over")
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(`{"message":"back soon","retry_after":"2m"}`)
+	as.MaintenanceHandler(ctx)
+	if maintenance, ok := as.InMaintenance(); !ok || maintenance.Message != "back soon" || time.Duration(maintenance.RetryAfter) != 2*time.Minute {
+		t.Error(fmt.Sprintf("unexpected maintenance: %+v", maintenance))
+	}
+}
+
+func TestVAPI_WatchMaintenanceFile(t *testing.T) {
+	as := NewServer()
+	dir, err := ioutil.TempDir("", "maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "maintenance")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go as.WatchMaintenanceFile(ctx, path, 10*time.Millisecond)
+
+	waitFor := func(inMaintenance bool) Maintenance {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if maintenance, ok := as.InMaintenance(); ok == inMaintenance {
+				return maintenance
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatal(fmt.Sprintf("maintenance must be %v", inMaintenance))
+		return Maintenance{}
+	}
+
+	ioutil.WriteFile(path, []byte("migrating storage\n"), 0600)
+	if maintenance := waitFor(true); maintenance.Message != "migrating storage" || time.Duration(maintenance.RetryAfter) != time.Minute {
+		t.Error(fmt.Sprintf("unexpected maintenance: %+v", maintenance))
+	}
+	os.Remove(path)
+	waitFor(false)
+}
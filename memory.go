@@ -0,0 +1,141 @@
+package vapi
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+// memoryAccount tracks approximate memory held by in-flight calls
+type memoryAccount struct {
+	inUse    int64 // accessed atomically
+	peak     int64 // accessed atomically
+	rejected int64 // accessed atomically
+	aborted  int64 // accessed atomically
+
+	perRequest int64
+	total      int64
+}
+
+// MemoryStats are memory accounting counters
+type MemoryStats struct {
+	// InUse is the memory held by in-flight calls
+	InUse int64 `json:"in_use"`
+	// Peak is the highest InUse seen
+	Peak int64 `json:"peak"`
+	// Rejected counts calls rejected before decoding
+	Rejected int64 `json:"rejected"`
+	// Aborted counts calls aborted because of the reply size
+	Aborted int64 `json:"aborted"`
+}
+
+// memoryReservation is the memory accounted to one call
+type memoryReservation struct {
+	account *memoryAccount
+	size    int64
+}
+
+// SetMemoryLimit enables memory accounting of in-flight calls: the request body
+// and the encoded reply of every call are counted. Requests with bodies over
+// perRequest get 413, calls whose reply pushes them over perRequest are aborted
+// with 500, and calls arriving while in-flight calls hold more than total get
+// 503. Zero limit means unlimited, both zero disable accounting.
+//
+// The body check runs after fasthttp buffered the body, so Server and Run cap
+// MaxRequestBodySize at perRequest to refuse oversized bodies while reading them.
+// Call SetMemoryLimit before Server, servers built elsewhere should set
+// MaxRequestBodySize themselves.
+func (as *VAPI) SetMemoryLimit(perRequest, total int64) {
+	var account *memoryAccount
+	if perRequest > 0 || total > 0 {
+		account = &memoryAccount{perRequest: perRequest, total: total}
+	}
+
+	as.mutex.Lock()
+	as.memory = account
+	as.mutex.Unlock()
+}
+
+// MemoryStats returns memory accounting counters, zero when accounting is disabled
+func (as *VAPI) MemoryStats() MemoryStats {
+	as.mutex.RLock()
+	account := as.memory
+	as.mutex.RUnlock()
+	if account == nil {
+		return MemoryStats{}
+	}
+	return MemoryStats{
+		InUse:    atomic.LoadInt64(&account.inUse),
+		Peak:     atomic.LoadInt64(&account.peak),
+		Rejected: atomic.LoadInt64(&account.rejected),
+		Aborted:  atomic.LoadInt64(&account.aborted),
+	}
+}
+
+// maxBodySize caps server body size limit at the per request memory limit
+func (as *VAPI) maxBodySize(limit int) int {
+	as.mutex.RLock()
+	account := as.memory
+	as.mutex.RUnlock()
+
+	if account == nil || account.perRequest <= 0 {
+		return limit
+	}
+	if limit <= 0 || int64(limit) > account.perRequest {
+		return int(account.perRequest)
+	}
+	return limit
+}
+
+// reserve accounts size bytes to a new call, returns http status and error when it doesn't fit
+func (ma *memoryAccount) reserve(size int64) (*memoryReservation, int, error) {
+	if ma == nil {
+		return nil, 0, nil
+	}
+	if ma.perRequest > 0 && size > ma.perRequest {
+		atomic.AddInt64(&ma.rejected, 1)
+		return nil, fasthttp.StatusRequestEntityTooLarge, fmt.Errorf("vapi: request of %d bytes exceeds memory limit of %d bytes", size, ma.perRequest)
+	}
+	if ma.total > 0 && atomic.LoadInt64(&ma.inUse)+size > ma.total {
+		atomic.AddInt64(&ma.rejected, 1)
+		return nil, fasthttp.StatusServiceUnavailable, errOverloaded
+	}
+	reservation := &memoryReservation{account: ma}
+	reservation.add(size)
+	return reservation, 0, nil
+}
+
+// grow accounts size more bytes to the call, error when the call exceeds the per request limit
+func (mr *memoryReservation) grow(size int64) error {
+	if mr == nil {
+		return nil
+	}
+	if limit := mr.account.perRequest; limit > 0 && mr.size+size > limit {
+		atomic.AddInt64(&mr.account.aborted, 1)
+		return fmt.Errorf("vapi: call needs %d bytes, memory limit is %d bytes", mr.size+size, limit)
+	}
+	mr.add(size)
+	return nil
+}
+
+// add accounts size bytes and updates the peak
+func (mr *memoryReservation) add(size int64) {
+	mr.size += size
+	inUse := atomic.AddInt64(&mr.account.inUse, size)
+	for {
+		peak := atomic.LoadInt64(&mr.account.peak)
+		if inUse <= peak || atomic.CompareAndSwapInt64(&mr.account.peak, peak, inUse) {
+			return
+		}
+	}
+}
+
+// release returns memory of the finished call
+func (mr *memoryReservation) release() {
+	if mr == nil {
+		return
+	}
+	atomic.AddInt64(&mr.account.inUse, -mr.size)
+	mr.size = 0
+}
@@ -0,0 +1,49 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVAPI_SetMemoryLimit(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	as.SetMemoryLimit(64, 0)
+
+	if status, body, _ := as.callLocal("demo.Test", []byte(`{"id":"1"}`)); status != 200 {
+		t.Error(fmt.Sprintf("small call must pass: %d %s", status, body))
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{"id":"`+strings.Repeat("x", 64)+`"}`)); status != 413 {
+		t.Error(fmt.Sprintf("large request must be rejected, got %d", status))
+	}
+	// the reply echoes the id, so together with the request it doesn't fit
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{"id":"`+strings.Repeat("x", 30)+`"}`)); status != 500 {
+		t.Error(fmt.Sprintf("large reply must be aborted, got %d", status))
+	}
+
+	stats := as.MemoryStats()
+	if stats.InUse != 0 || stats.Peak == 0 || stats.Rejected != 1 || stats.Aborted != 1 {
+		t.Error(fmt.Sprintf("wrong stats: %+v", stats))
+	}
+}
+
+func TestVAPI_SetMemoryLimit_BodySize(t *testing.T) {
+	as := NewServer()
+	if size := as.Server("/").MaxRequestBodySize; size != 0 {
+		t.Error(fmt.Sprintf("body size must be left to fasthttp default, got %d", size))
+	}
+
+	as.SetMemoryLimit(1024, 0)
+	if size := as.Server("/").MaxRequestBodySize; size != 1024 {
+		t.Error(fmt.Sprintf("body size must be capped by memory limit, got %d", size))
+	}
+	if size := as.maxBodySize(512); size != 512 {
+		t.Error(fmt.Sprintf("smaller configured body size must be kept, got %d", size))
+	}
+	if size := as.maxBodySize(4096); size != 1024 {
+		t.Error(fmt.Sprintf("larger configured body size must be capped, got %d", size))
+	}
+}
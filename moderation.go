@@ -0,0 +1,242 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/valyala/fasthttp"
+)
+
+var errModerationFailed = errors.New("vapi: content moderation failed")
+
+// ContentAction is the outcome of content moderation
+type ContentAction int
+
+// Content actions
+const (
+	ContentAllow  ContentAction = iota // the text is kept
+	ContentRedact                      // the text is replaced with ContentResult.Text
+	ContentReject                      // args are rejected with 422, reply fields are emptied
+)
+
+// ContentResult is the verdict of a ContentFilter
+type ContentResult struct {
+	Action ContentAction `json:"action"`
+	// Text replaces the original text of redacted fields
+	Text string `json:"text,omitempty"`
+	// Reason is returned to the caller of rejected args
+	Reason string `json:"reason,omitempty"`
+}
+
+// ContentFilter inspects text of fields tagged with `vapi:"moderate"`, e.g. a
+// wordlist or an external moderation api. field is the path of the field.
+type ContentFilter interface {
+	Check(ctx context.Context, field, text string) (ContentResult, error)
+}
+
+// ContentFilterFunc adapts a function to ContentFilter
+type ContentFilterFunc func(ctx context.Context, field, text string) (ContentResult, error)
+
+// Check implements ContentFilter
+func (f ContentFilterFunc) Check(ctx context.Context, field, text string) (ContentResult, error) {
+	return f(ctx, field, text)
+}
+
+// SetContentFilter makes filter moderate string fields tagged with `vapi:"moderate"`
+// of decoded args and replies, nil disables it:
+//
+//	Comment string `json:"comment" vapi:"moderate"`
+//
+// Args are checked after sanitizing, before validation. Rejected args get 422
+// with the reason, failed checks 503 (fail closed).
+func (as *VAPI) SetContentFilter(filter ContentFilter) {
+	as.mutex.Lock()
+	as.contentFilter = filter
+	as.mutex.Unlock()
+}
+
+// WordlistFilter redacts words of the list (case insensitive) with asterisks,
+// or rejects texts containing them when reject is set
+func WordlistFilter(words []string, reject bool) ContentFilter {
+	banned := make(map[string]bool, len(words))
+	for _, word := range words {
+		banned[strings.ToLower(word)] = true
+	}
+	return ContentFilterFunc(func(ctx context.Context, field, text string) (ContentResult, error) {
+		result := ContentResult{}
+		redacted := []rune(text)
+		start := -1
+		for i, r := range append([]rune(text), ' ') {
+			letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+			if letter && start < 0 {
+				start = i
+			}
+			if letter || start < 0 {
+				continue
+			}
+			if banned[strings.ToLower(string(redacted[start:i]))] {
+				if reject {
+					return ContentResult{Action: ContentReject, Reason: "disallowed language"}, nil
+				}
+				for j := start; j < i; j++ {
+					redacted[j] = '*'
+				}
+				result = ContentResult{Action: ContentRedact}
+			}
+			start = -1
+		}
+		result.Text = string(redacted)
+		return result, nil
+	})
+}
+
+// ModerationAPIFilter posts {"field": ..., "text": ...} to url of an external
+// moderation service and reads ContentResult from the response, the action
+// encoded as 0 (allow), 1 (redact) or 2 (reject)
+func ModerationAPIFilter(url string, timeout time.Duration) ContentFilter {
+	return ContentFilterFunc(func(ctx context.Context, field, text string) (ContentResult, error) {
+		result := ContentResult{}
+		body, err := json.Marshal(map[string]string{"field": field, "text": text})
+		if err != nil {
+			return result, err
+		}
+
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(url)
+		req.Header.SetMethod("POST")
+		req.Header.SetContentType("application/json")
+		req.SetBody(body)
+
+		if err = fasthttp.DoTimeout(req, resp, timeout); err != nil {
+			return result, err
+		}
+		if resp.StatusCode() != fasthttp.StatusOK {
+			return result, fmt.Errorf("vapi: moderation api responded with status %d", resp.StatusCode())
+		}
+		err = json.Unmarshal(resp.Body(), &result)
+		return result, err
+	})
+}
+
+// moderateArgs checks args, writes the rejection and returns false when the call must not proceed
+func (as *VAPI) moderateArgs(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, args reflect.Value) bool {
+	if !methodSpec.argsPlan.moderated {
+		return true
+	}
+	as.mutex.RLock()
+	filter := as.contentFilter
+	as.mutex.RUnlock()
+	if filter == nil {
+		return true
+	}
+
+	err := moderateValue(ctx, filter, args, "", false)
+	if rejected, ok := err.(*ValidationError); ok {
+		as.writeError(ctx, srvResponse, fasthttp.StatusUnprocessableEntity, rejected)
+		return false
+	}
+	if err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errModerationFailed)
+		return false
+	}
+	return true
+}
+
+// moderateReply checks reply, rejected fields are emptied
+func (as *VAPI) moderateReply(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, reply reflect.Value) error {
+	if !methodSpec.replyPlan.moderated {
+		return nil
+	}
+	as.mutex.RLock()
+	filter := as.contentFilter
+	as.mutex.RUnlock()
+	if filter == nil {
+		return nil
+	}
+	if err := moderateValue(ctx, filter, reply, "", true); err != nil {
+		return errModerationFailed
+	}
+	return nil
+}
+
+// moderateValue walks v checking tagged string fields, path is the field path of v.
+// Rejections fail with *ValidationError unless emptyRejected is set.
+func moderateValue(ctx context.Context, filter ContentFilter, v reflect.Value, path string, emptyRejected bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return moderateValue(ctx, filter, v.Elem(), path, emptyRejected)
+		}
+	case reflect.Interface:
+		return walkInterface(v, func(elem reflect.Value) error {
+			return moderateValue(ctx, filter, elem, path, emptyRejected)
+		})
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := moderateValue(ctx, filter, v.Index(i), fmt.Sprintf("%s[%d]", path, i), emptyRejected); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			fv := v.Field(i)
+			if hasTagOption(field, "moderate") && fv.Kind() == reflect.String {
+				if err := moderateText(ctx, filter, fv, fieldPath, emptyRejected); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := moderateValue(ctx, filter, fv, fieldPath, emptyRejected); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moderateText checks a single string field
+func moderateText(ctx context.Context, filter ContentFilter, v reflect.Value, path string, emptyRejected bool) error {
+	if v.Len() == 0 {
+		return nil
+	}
+	if !v.CanSet() {
+		return fmt.Errorf("vapi: field %q can't be set", path)
+	}
+	result, err := filter.Check(ctx, path, v.String())
+	if err != nil {
+		return err
+	}
+	switch result.Action {
+	case ContentRedact:
+		v.SetString(result.Text)
+	case ContentReject:
+		if emptyRejected {
+			v.SetString("")
+			return nil
+		}
+		reason := result.Reason
+		if reason == "" {
+			reason = "content is not allowed"
+		}
+		return &ValidationError{Field: path, Message: reason}
+	}
+	return nil
+}
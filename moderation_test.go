@@ -0,0 +1,88 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CommentPost is a user generated comment
+type CommentPost struct {
+	Author string   `json:"author"`
+	Text   string   `json:"text" vapi:"moderate"`
+	Tags   []string `json:"tags"`
+}
+
+func (c *CommentPost) UnmarshalJSON(data []byte) error {
+	type plain CommentPost
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+func (c *CommentPost) MarshalJSON() ([]byte, error) {
+	type plain CommentPost
+	return json.Marshal((*plain)(c))
+}
+
+// CommentAPI stores comments
+type CommentAPI struct{}
+
+// Post echoes the comment
+func (h *CommentAPI) Post(ctx *fasthttp.RequestCtx, Args *CommentPost, Reply *CommentPost) error {
+	*Reply = *Args
+	return nil
+}
+
+// Latest returns a stored comment
+func (h *CommentAPI) Latest(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *CommentPost) error {
+	Reply.Author, Reply.Text = "bob", "spam spam"
+	return nil
+}
+
+// CommentFeed holds a comment by value behind an interface
+type CommentFeed struct {
+	Item interface{} `json:"item"`
+}
+
+func (c *CommentFeed) MarshalJSON() ([]byte, error) {
+	type plain CommentFeed
+	return json.Marshal((*plain)(c))
+}
+
+// Feed returns a comment held by an interface field
+func (h *CommentAPI) Feed(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *CommentFeed) error {
+	Reply.Item = CommentPost{Author: "bob", Text: "darn spam"}
+	return nil
+}
+
+func TestVAPI_SetContentFilter(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(CommentAPI), "comments")
+
+	as.SetContentFilter(WordlistFilter([]string{"darn"}, false))
+	status, body, _ := as.callLocal("comments.Post", []byte(`{"author":"darn","text":"Darn it, darnation!"}`))
+	if status != 200 || string(body) != `{"response":{"author":"darn","text":"**** it, darnation!","tags":null}}` {
+		t.Error(fmt.Sprintf("text must be redacted: %d %s", status, body))
+	}
+
+	as.SetContentFilter(WordlistFilter([]string{"darn", "spam"}, true))
+	if status, body, _ := as.callLocal("comments.Post", []byte(`{"text":"darn"}`)); status != 422 {
+		t.Error(fmt.Sprintf("text must be rejected: %d %s", status, body))
+	}
+	if status, body, _ := as.callLocal("comments.Latest", []byte(`{}`)); status != 200 || string(body) != `{"response":{"author":"bob","text":"","tags":null}}` {
+		t.Error(fmt.Sprintf("rejected reply field must be emptied: %d %s", status, body))
+	}
+	if status, body, _ := as.callLocal("comments.Feed", []byte(`{}`)); status != 200 || string(body) != `{"response":{"item":{"author":"bob","text":"","tags":null}}}` {
+		t.Error(fmt.Sprintf("field held by interface must be moderated: %d %s", status, body))
+	}
+
+	as.SetContentFilter(ContentFilterFunc(func(ctx context.Context, field, text string) (ContentResult, error) {
+		return ContentResult{}, errors.New("moderation api is down")
+	}))
+	if status, _, _ := as.callLocal("comments.Post", []byte(`{"text":"hello"}`)); status != 503 {
+		t.Error(fmt.Sprintf("failed moderation must fail closed, got %d", status))
+	}
+}
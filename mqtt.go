@@ -0,0 +1,91 @@
+package vapi
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// deviceUserValue is the RequestCtx user value key of the calling device id
+const deviceUserValue = "vapi.device"
+
+// MQTTMessage is a received message
+type MQTTMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+// MQTTClient is a thin adapter over an MQTT client, e.g. paho.mqtt.golang
+type MQTTClient interface {
+	Subscribe(topic string, qos byte, handler func(message MQTTMessage)) error
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// MQTTAuthorizer decides whether device may call method
+type MQTTAuthorizer func(deviceID, method string) bool
+
+// MQTTServer maps MQTT topics to registered methods for device fleets.
+//
+// Devices publish cbor encoded args to
+//
+//	<prefix>/<device id>/call/<Service.Method>[/<request id>]
+//
+// and receive the cbor encoded response envelope on the same topic with "call"
+// replaced by "reply". The broker authenticates connections and must restrict
+// devices to their own topics; Authorize adds per-device method permissions.
+// Methods get the device id with DeviceID.
+type MQTTServer struct {
+	// QoS is used for subscriptions and replies
+	QoS byte
+	// Authorize restricts methods per device, nil allows all
+	Authorize MQTTAuthorizer
+
+	as     *VAPI
+	client MQTTClient
+	prefix string
+}
+
+// NewMQTTServer returns server handling calls published under prefix
+func (as *VAPI) NewMQTTServer(client MQTTClient, prefix string, qos byte) *MQTTServer {
+	return &MQTTServer{QoS: qos, as: as, client: client, prefix: strings.Trim(prefix, "/")}
+}
+
+// Start subscribes to call topics of all devices
+func (s *MQTTServer) Start() error {
+	return s.client.Subscribe(s.prefix+"/+/call/#", s.QoS, s.handle)
+}
+
+// DeviceID returns id of the device calling the method over MQTT, empty for other transports
+func DeviceID(ctx *fasthttp.RequestCtx) string {
+	device, _ := ctx.UserValue(deviceUserValue).(string)
+	return device
+}
+
+// handle invokes the method of message and publishes the reply
+func (s *MQTTServer) handle(message MQTTMessage) {
+	// <prefix>/<device>/call/<method>[/<request id>]
+	parts := strings.Split(strings.TrimPrefix(message.Topic, s.prefix+"/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[1] != "call" || parts[0] == "" {
+		return
+	}
+	device, method := parts[0], parts[2]
+	parts[1] = "reply"
+	replyTopic := s.prefix + "/" + strings.Join(parts, "/")
+
+	var body []byte
+	if s.Authorize != nil && !s.Authorize(device, method) {
+		body = []byte(`{"error":{"error_code":0,"error_msg":"vapi: method is not allowed for the device"}}`)
+	} else if args, err := cborToJSON(message.Payload); err != nil {
+		body = []byte(`{"error":{"error_code":0,"error_msg":"vapi: malformed cbor payload"}}`)
+	} else {
+		_, body, _ = s.as.callLocalWith(method, args, nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.SetUserValue(deviceUserValue, device)
+		})
+	}
+
+	payload, err := jsonToCBOR(body)
+	if err != nil {
+		return
+	}
+	s.client.Publish(replyTopic, s.QoS, false, payload)
+}
@@ -0,0 +1,110 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DeviceAPI replies with the calling device
+type DeviceAPI struct{}
+
+func (d *DeviceAPI) Whoami(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	reply.ID = DeviceID(ctx) + ":" + args.ID
+	return nil
+}
+
+type fakeMQTT struct {
+	mutex     sync.Mutex
+	filter    string
+	qos       byte
+	handler   func(message MQTTMessage)
+	published map[string][]byte
+}
+
+func (fm *fakeMQTT) Subscribe(topic string, qos byte, handler func(message MQTTMessage)) error {
+	fm.filter, fm.qos, fm.handler = topic, qos, handler
+	return nil
+}
+
+func (fm *fakeMQTT) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	fm.mutex.Lock()
+	fm.published[topic] = payload
+	fm.mutex.Unlock()
+	return nil
+}
+
+// deliver passes json args encoded as cbor to the subscribed handler
+func (fm *fakeMQTT) deliver(t *testing.T, topic, args string) {
+	payload, err := jsonToCBOR([]byte(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.handler(MQTTMessage{Topic: topic, Payload: payload})
+}
+
+// reply returns json of the reply published to topic, empty when none
+func (fm *fakeMQTT) reply(t *testing.T, topic string) string {
+	payload, ok := fm.published[topic]
+	if !ok {
+		return ""
+	}
+	body, err := cborToJSON(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMQTTServer(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DeviceAPI), "device"); err != nil {
+		t.Fatal(err)
+	}
+
+	fm := &fakeMQTT{published: map[string][]byte{}}
+	server := as.NewMQTTServer(fm, "/fleet/", 1)
+	server.Authorize = func(deviceID, method string) bool { return deviceID != "blocked" }
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if fm.filter != "fleet/+/call/#" || fm.qos != 1 {
+		t.Error(fmt.Sprintf("wrong subscription: %q qos %d", fm.filter, fm.qos))
+	}
+
+	fm.deliver(t, "fleet/d1/call/device.Whoami/r1", `{"id":"1"}`)
+	if reply := fm.reply(t, "fleet/d1/reply/device.Whoami/r1"); !strings.Contains(reply, `"id":"d1:1"`) {
+		t.Error(fmt.Sprintf("wrong reply with request id: %s", reply))
+	}
+
+	fm.deliver(t, "fleet/d2/call/device.Whoami", `{"id":"2"}`)
+	if reply := fm.reply(t, "fleet/d2/reply/device.Whoami"); !strings.Contains(reply, `"id":"d2:2"`) {
+		t.Error(fmt.Sprintf("wrong reply without request id: %s", reply))
+	}
+
+	fm.deliver(t, "fleet/blocked/call/device.Whoami", `{}`)
+	if reply := fm.reply(t, "fleet/blocked/reply/device.Whoami"); !strings.Contains(reply, "not allowed") {
+		t.Error(fmt.Sprintf("unauthorized device must get an error: %s", reply))
+	}
+
+	fm.handler(MQTTMessage{Topic: "fleet/d3/call/device.Whoami", Payload: []byte{0xff}})
+	if reply := fm.reply(t, "fleet/d3/reply/device.Whoami"); !strings.Contains(reply, "malformed cbor") {
+		t.Error(fmt.Sprintf("malformed payload must get an error: %s", reply))
+	}
+
+	fm.deliver(t, "fleet/d4/call/device.Missing", `{}`)
+	if reply := fm.reply(t, "fleet/d4/reply/device.Missing"); !strings.Contains(reply, "error") {
+		t.Error(fmt.Sprintf("unknown method must get an error: %s", reply))
+	}
+
+	published := len(fm.published)
+	for _, topic := range []string{"fleet/d1/status", "fleet//call/device.Whoami", "fleet/d1/call/device.Whoami/r1/extra"} {
+		fm.deliver(t, topic, `{}`)
+	}
+	if len(fm.published) != published {
+		t.Error(fmt.Sprintf("topics outside of the call pattern must be ignored: %v", fm.published))
+	}
+}
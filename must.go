@@ -0,0 +1,39 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MustImplement panics unless receiver implements the interface iface points to
+// and every method of the interface has the shape RegisterService accepts.
+// Use it next to the service declaration, so a method which would be silently
+// skipped at registration fails at package initialization:
+//
+//	type UsersService interface {
+//		Get(ctx *fasthttp.RequestCtx, args *GetArgs, reply *User) error
+//	}
+//
+//	var _ = vapi.MustImplement((*UsersService)(nil), (*Users)(nil))
+//
+// It returns receiver.
+func MustImplement(iface, receiver interface{}) interface{} {
+	ifaceType := reflect.TypeOf(iface)
+	if ifaceType == nil || ifaceType.Kind() != reflect.Ptr || ifaceType.Elem().Kind() != reflect.Interface {
+		panic("vapi: MustImplement needs a nil pointer to interface, e.g. (*Service)(nil)")
+	}
+	ifaceType = ifaceType.Elem()
+
+	rcvrType := reflect.TypeOf(receiver)
+	if rcvrType == nil || !rcvrType.Implements(ifaceType) {
+		panic(fmt.Sprintf("vapi: %v does not implement %v", rcvrType, ifaceType))
+	}
+
+	for i := 0; i < ifaceType.NumMethod(); i++ {
+		method := ifaceType.Method(i)
+		if reason := methodShape(method.Type, 0); reason != "" {
+			panic(fmt.Sprintf("vapi: %v.%s %s", ifaceType, method.Name, reason))
+		}
+	}
+	return receiver
+}
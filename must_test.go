@@ -0,0 +1,41 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type demoService interface {
+	Test(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error
+}
+
+type brokenService interface {
+	Test(ctx *fasthttp.RequestCtx, args *TestArgs, reply TestReply) error
+}
+
+type brokenAPI struct{}
+
+func (b *brokenAPI) Test(ctx *fasthttp.RequestCtx, args *TestArgs, reply TestReply) error {
+	return nil
+}
+
+func TestMustImplement(t *testing.T) {
+	mustPanic := func(expected string, fn func()) {
+		defer func() {
+			if recovered := recover(); recovered == nil || !strings.Contains(fmt.Sprint(recovered), expected) {
+				t.Error(fmt.Sprintf("expected panic with %q, got %v", expected, recovered))
+			}
+		}()
+		fn()
+	}
+
+	if receiver := MustImplement((*demoService)(nil), new(DemoAPI)); receiver == nil {
+		t.Error("receiver must be returned")
+	}
+	mustPanic("does not implement", func() { MustImplement((*demoService)(nil), new(brokenAPI)) })
+	mustPanic("reply vapi.TestReply is not an exported pointer", func() { MustImplement((*brokenService)(nil), new(brokenAPI)) })
+	mustPanic("nil pointer to interface", func() { MustImplement(demoService(nil), new(DemoAPI)) })
+}
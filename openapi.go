@@ -0,0 +1,529 @@
+package vapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage{})
+)
+
+// OpenAPIDocument is an OpenAPI 3.0 document describing registered methods
+type OpenAPIDocument struct {
+	OpenAPI    string                  `json:"openapi"`
+	Info       OpenAPIInfo             `json:"info"`
+	Paths      map[string]*OpenAPIPath `json:"paths"`
+	Components OpenAPIComponents       `json:"components"`
+}
+
+// OpenAPIInfo is the document metadata
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents holds shared schemas referenced from operations
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// OpenAPIPath is the path item of a method
+type OpenAPIPath struct {
+	Get    *OpenAPIOperation `json:"get,omitempty"`
+	Post   *OpenAPIOperation `json:"post,omitempty"`
+	Put    *OpenAPIOperation `json:"put,omitempty"`
+	Patch  *OpenAPIOperation `json:"patch,omitempty"`
+	Delete *OpenAPIOperation `json:"delete,omitempty"`
+}
+
+// OpenAPIOperation describes the call of a method
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a query parameter of an operation
+type OpenAPIParameter struct {
+	Name        string                       `json:"name"`
+	In          string                       `json:"in"`
+	Description string                       `json:"description,omitempty"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIBody is a request body
+type OpenAPIBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a body
+type OpenAPIMediaType struct {
+	Schema   *Schema                    `json:"schema"`
+	Examples map[string]*OpenAPIExample `json:"examples,omitempty"`
+}
+
+// OpenAPIExample is a sample body
+type OpenAPIExample struct {
+	Value interface{} `json:"value"`
+}
+
+// Schema is an OpenAPI schema object
+type Schema struct {
+	Ref                  string                `json:"$ref,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Example              interface{}           `json:"example,omitempty"`
+	Minimum              *float64              `json:"minimum,omitempty"`
+	Maximum              *float64              `json:"maximum,omitempty"`
+	MinLength            *int                  `json:"minLength,omitempty"`
+	MaxLength            *int                  `json:"maxLength,omitempty"`
+	Pattern              string                `json:"pattern,omitempty"`
+	Deprecated           bool                  `json:"deprecated,omitempty"`
+	Enum                 []interface{}         `json:"enum,omitempty"`
+	Items                *Schema               `json:"items,omitempty"`
+	Properties           map[string]*Schema    `json:"properties,omitempty"`
+	AdditionalProperties *Schema               `json:"additionalProperties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	AllOf                []*Schema             `json:"allOf,omitempty"`
+	OneOf                []*Schema             `json:"oneOf,omitempty"`
+	Discriminator        *OpenAPIDiscriminator `json:"discriminator,omitempty"`
+}
+
+// OpenAPIDiscriminator maps discriminator property values to oneOf schemas
+type OpenAPIDiscriminator struct {
+	PropertyName string            `json:"propertyName"`
+	Mapping      map[string]string `json:"mapping,omitempty"`
+}
+
+// OpenAPI returns the document describing all registered methods.
+// Every method is documented as POST to basePath + "/Service.Method".
+//
+// Fields of args and reply structs may be annotated with the openapi tag:
+//
+//	ID string `json:"id" openapi:"description=user id,format=uuid,example=8d1c...,required"`
+//
+// Supported options are description, example, format, pattern, minimum, maximum,
+// minLength, maxLength, required and deprecated. Commas inside values are kept
+// when the following part has no "=", so descriptions may contain them.
+// Values of enum tags and Enum types are exported as schema enums.
+func (as *VAPI) OpenAPI(title, version, basePath string) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI:    "3.0.0",
+		Info:       OpenAPIInfo{Title: title, Version: version},
+		Paths:      make(map[string]*OpenAPIPath),
+		Components: OpenAPIComponents{Schemas: make(map[string]*Schema)},
+	}
+	doc.Components.Schemas["Error"] = errorSchema()
+
+	as.mutex.RLock()
+	names := make([]string, 0, len(as.methods))
+	for name := range as.methods {
+		names = append(names, name)
+	}
+	as.mutex.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		methodSpec, err := as.get(name)
+		if err != nil {
+			continue
+		}
+		doc.Paths[strings.TrimRight(basePath, "/")+"/"+name] = as.pathItem(doc, name, methodSpec)
+	}
+
+	return doc
+}
+
+// OpenAPIHandler serves the OpenAPI document of registered methods as json.
+// In schema-first mode (see UseSchema) the declared document is served instead.
+func (as *VAPI) OpenAPIHandler(title, version, basePath string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		as.mutex.RLock()
+		doc, hidden := as.schema, as.profile.HideDocs
+		as.mutex.RUnlock()
+		if hidden {
+			ctx.NotFound()
+			return
+		}
+		if doc == nil {
+			doc = as.OpenAPI(title, version, basePath)
+		}
+		body, err := json.Marshal(doc)
+		if err != nil {
+			writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+			return
+		}
+		ctx.SetContentType("application/json; charset=utf-8")
+		ctx.SetBody(body)
+	}
+}
+
+// pathItem builds operations for every verb of the method, POST when verbs aren't restricted
+func (as *VAPI) pathItem(doc *OpenAPIDocument, name string, methodSpec *serviceMethod) *OpenAPIPath {
+	as.mutex.RLock()
+	verbs := methodSpec.verbs
+	as.mutex.RUnlock()
+	if len(verbs) == 0 {
+		return &OpenAPIPath{Post: as.operation(doc, name, methodSpec)}
+	}
+
+	item := &OpenAPIPath{}
+	for _, verb := range verbs {
+		operation := as.operation(doc, name, methodSpec)
+		if len(verbs) > 1 {
+			operation.OperationID += "_" + verb
+		}
+		if (verb == "GET" || verb == "DELETE") && methodSpec.bodyField == nil {
+			operation.Parameters = []*OpenAPIParameter{{
+				Name:        ArgsParam,
+				In:          "query",
+				Description: "json encoded args",
+				Content:     operation.RequestBody.Content,
+			}}
+			operation.RequestBody = nil
+		}
+
+		switch verb {
+		case "GET":
+			item.Get = operation
+		case "POST":
+			item.Post = operation
+		case "PUT":
+			item.Put = operation
+		case "PATCH":
+			item.Patch = operation
+		case "DELETE":
+			item.Delete = operation
+		}
+	}
+	return item
+}
+
+// operation builds operation object of the method
+func (as *VAPI) operation(doc *OpenAPIDocument, name string, methodSpec *serviceMethod) *OpenAPIOperation {
+	service := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		service = name[:i]
+	}
+
+	argsSchema := schemaOf(doc, methodSpec.argsType)
+	replySchema := &Schema{Type: "object"}
+	if methodSpec.replyType != typeOfFileReply {
+		replySchema = schemaOf(doc, methodSpec.replyType)
+	}
+
+	as.mutex.RLock()
+	argsExamples, replyExamples := openAPIExamples(methodSpec.examples)
+	as.mutex.RUnlock()
+
+	operation := &OpenAPIOperation{
+		OperationID: name,
+		Tags:        []string{service},
+		RequestBody: &OpenAPIBody{
+			Required: true,
+			Content:  jsonContent(argsSchema),
+		},
+		Responses: map[string]*OpenAPIResponse{
+			"200": {
+				Description: "Successful call",
+				Content: jsonContent(&Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"response": replySchema},
+					Required:   []string{"response"},
+				}),
+			},
+			"default": {
+				Description: "Failed call",
+				Content: jsonContent(&Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{"error": {Ref: "#/components/schemas/Error"}},
+					Required:   []string{"error"},
+				}),
+			},
+		},
+	}
+	operation.RequestBody.Content["application/json"].Examples = argsExamples
+	operation.Responses["200"].Content["application/json"].Examples = replyExamples
+
+	if methodSpec.replyType == typeOfFileReply {
+		binary := map[string]*OpenAPIMediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}}
+		operation.Responses["200"] = &OpenAPIResponse{Description: "File content", Content: binary}
+		operation.Responses["206"] = &OpenAPIResponse{Description: "Requested range of file content", Content: binary}
+	}
+
+	if methodSpec.bodyField != nil {
+		// streaming methods take args from the query and raw bytes as body
+		operation.Parameters = []*OpenAPIParameter{{
+			Name:        ArgsParam,
+			In:          "query",
+			Description: "json encoded args",
+			Content:     jsonContent(argsSchema),
+		}}
+		operation.RequestBody.Content = map[string]*OpenAPIMediaType{
+			"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}},
+		}
+	}
+
+	return operation
+}
+
+// jsonContent wraps schema into application/json content map
+func jsonContent(schema *Schema) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// errorSchema describes Error
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error_code": {Type: "integer"},
+			"error_msg":  {Type: "string"},
+			"data":       {},
+		},
+		Required: []string{"error_code", "error_msg"},
+	}
+}
+
+// schemaOf returns schema of t, named struct types are added to components and referenced
+func schemaOf(doc *OpenAPIDocument, t reflect.Type) *Schema {
+	t = indirectType(t)
+
+	schema := typeSchema(doc, t)
+	if values := enumValuesOf(t); values != nil && schema.Ref == "" {
+		schema.Enum = values
+	}
+	return schema
+}
+
+// typeSchema returns schema of non-pointer type t
+func typeSchema(doc *OpenAPIDocument, t reflect.Type) *Schema {
+	switch {
+	case t == typeOfTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == typeOfRawMessage:
+		return &Schema{}
+	case t == typeOfUUID:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t == typeOfDuration:
+		return &Schema{Type: "string", Format: "duration", Example: "30s"}
+	case t == typeOfIP:
+		return &Schema{Type: "string", Format: "ip"}
+	case t == typeOfCIDR:
+		return &Schema{Type: "string", Format: "cidr"}
+	case t == typeOfURL:
+		return &Schema{Type: "string", Format: "uri"}
+	case t == typeOfDecimal:
+		return &Schema{Type: "string", Format: "decimal", Pattern: `^-?[0-9]+(\.[0-9]+)?$`}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(doc, t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(doc, t.Elem())}
+	case reflect.Interface:
+		if union := lookupUnion(t); union != nil {
+			return unionSchema(doc, union)
+		}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(doc, t)
+		}
+		if _, ok := doc.Components.Schemas[t.Name()]; !ok {
+			// placeholder stops recursion on self referencing types
+			doc.Components.Schemas[t.Name()] = &Schema{}
+			*doc.Components.Schemas[t.Name()] = *structSchema(doc, t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	return &Schema{}
+}
+
+// structSchema returns object schema of struct type t
+func structSchema(doc *OpenAPIDocument, t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		// fields of embedded structs are promoted like encoding/json does
+		if embedded := indirectType(field.Type); field.Anonymous && field.Tag.Get("json") == "" && embedded.Kind() == reflect.Struct {
+			promoted := structSchema(doc, embedded)
+			for key, value := range promoted.Properties {
+				schema.Properties[key] = value
+			}
+			schema.Required = append(schema.Required, promoted.Required...)
+			continue
+		}
+
+		fieldSchema := schemaOf(doc, field.Type)
+		if values, ok := field.Tag.Lookup("enum"); ok && values != "" {
+			target := fieldSchema
+			if target.Items != nil {
+				target = target.Items
+			}
+			target.Enum = enumValues(field)
+		}
+		tag := field.Tag.Get("openapi")
+		if fieldSchema.Ref != "" && tag != "" {
+			// siblings of $ref are ignored, so annotations wrap the reference
+			fieldSchema = &Schema{AllOf: []*Schema{fieldSchema}}
+		}
+		if applyOpenAPITag(fieldSchema, tag) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fieldSchema
+	}
+
+	return schema
+}
+
+// jsonFieldName returns the json key of field, "-" for skipped fields
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "-"
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// indirectType returns the type pointed to by t
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// openAPIFlags are the openapi tag options without value
+var openAPIFlags = map[string]bool{"required": true, "deprecated": true}
+
+// parseTagOptions splits "key=value,flag,key2=value, with comma" into options
+func parseTagOptions(tag string) map[string]string {
+	options := make(map[string]string)
+	last := ""
+	for _, part := range strings.Split(tag, ",") {
+		key, value := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			key, value = part[:i], part[i+1:]
+		} else if last != "" && !openAPIFlags[strings.TrimSpace(part)] {
+			// comma inside the previous value
+			options[last] += "," + part
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		options[key] = value
+		last = key
+	}
+	return options
+}
+
+// applyOpenAPITag annotates field schema with openapi tag options, returns true for required fields
+func applyOpenAPITag(schema *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	options := parseTagOptions(tag)
+
+	if v, ok := options["description"]; ok {
+		schema.Description = v
+	}
+	if v, ok := options["format"]; ok {
+		schema.Format = v
+	}
+	if v, ok := options["pattern"]; ok {
+		schema.Pattern = v
+	}
+	if v, ok := options["example"]; ok {
+		schema.Example = exampleValue(schema.Type, v)
+	}
+	if v, err := strconv.ParseFloat(options["minimum"], 64); err == nil {
+		schema.Minimum = &v
+	}
+	if v, err := strconv.ParseFloat(options["maximum"], 64); err == nil {
+		schema.Maximum = &v
+	}
+	if v, err := strconv.Atoi(options["minLength"]); err == nil {
+		schema.MinLength = &v
+	}
+	if v, err := strconv.Atoi(options["maxLength"]); err == nil {
+		schema.MaxLength = &v
+	}
+	if _, ok := options["deprecated"]; ok {
+		schema.Deprecated = true
+	}
+
+	_, required := options["required"]
+	return required
+}
+
+// exampleValue converts tag example to the json type of the schema
+func exampleValue(schemaType, value string) interface{} {
+	switch schemaType {
+	case "integer":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+	}
+	return value
+}
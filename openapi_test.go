@@ -0,0 +1,95 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type openAPIAddress struct {
+	City string `json:"city"`
+}
+
+type openAPIUser struct {
+	ID      string          `json:"id" openapi:"description=user id, unique,format=uuid,required"`
+	Age     int             `json:"age,omitempty" openapi:"minimum=1,example=33"`
+	Address *openAPIAddress `json:"address" openapi:"description=home address"`
+	Tags    []string        `json:"tags"`
+	Skipped string          `json:"-"`
+}
+
+func TestSchemaOf_OpenAPITag(t *testing.T) {
+	doc := &OpenAPIDocument{Components: OpenAPIComponents{Schemas: make(map[string]*Schema)}}
+
+	ref := schemaOf(doc, reflect.TypeOf(&openAPIUser{}))
+	if ref.Ref != "#/components/schemas/openAPIUser" {
+		t.Fatal(fmt.Sprintf("wrong reference: %q", ref.Ref))
+	}
+
+	user := doc.Components.Schemas["openAPIUser"]
+	if len(user.Properties) != 4 {
+		t.Error(fmt.Sprintf("wrong properties count: %d", len(user.Properties)))
+	}
+
+	id := user.Properties["id"]
+	if id.Description != "user id, unique" || id.Format != "uuid" {
+		t.Error(fmt.Sprintf("wrong id annotations: %+v", id))
+	}
+	if !reflect.DeepEqual(user.Required, []string{"id"}) {
+		t.Error(fmt.Sprintf("wrong required fields: %v", user.Required))
+	}
+
+	age := user.Properties["age"]
+	if age.Minimum == nil || *age.Minimum != 1 || age.Example != int64(33) {
+		t.Error(fmt.Sprintf("wrong age annotations: %+v", age))
+	}
+
+	address := user.Properties["address"]
+	if address.Description != "home address" || len(address.AllOf) != 1 || address.AllOf[0].Ref != "#/components/schemas/openAPIAddress" {
+		t.Error(fmt.Sprintf("wrong address annotations: %+v", address))
+	}
+}
+
+func TestVAPI_OpenAPI(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := as.OpenAPI("demo", "1.0", "/api/")
+	if _, ok := doc.Paths["/api/demo.Test"]; !ok {
+		t.Error("method path is missing")
+	}
+	if _, ok := doc.Components.Schemas["TestArgs"]; !ok {
+		t.Error("args schema is missing")
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVAPI_AddExample(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := as.AddExample("demo.Test", &TestArgs{ID: "42"}, TestReply{ID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.AddExample("demo.Test", &TestReply{}, &TestReply{}); err == nil {
+		t.Error("example of wrong type must be rejected")
+	}
+
+	body, err := json.Marshal(as.OpenAPI("demo", "1.0", "/api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(body)
+	if !strings.Contains(doc, `"examples":{"example1":{"value":{"id":"42"}}}`) ||
+		!strings.Contains(doc, `"examples":{"example1":{"value":{"response":{"id":"42"}}}}`) {
+		t.Error(fmt.Sprintf("examples are missing: %s", doc))
+	}
+}
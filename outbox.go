@@ -0,0 +1,461 @@
+package vapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// outboxUserValue is the RequestCtx user value key of events emitted by the method
+const outboxUserValue = "vapi.outbox"
+
+// OutboxMessage is a stored event waiting for delivery
+type OutboxMessage struct {
+	ID          string
+	Topic       string
+	Payload     json.RawMessage
+	Created     time.Time
+	Attempts    int
+	NextAttempt time.Time
+}
+
+// OutboxStore persists outbox messages. Add must store all messages or none.
+type OutboxStore interface {
+	Add(messages []OutboxMessage) error
+	// Pending returns up to limit messages due for delivery at now
+	Pending(now time.Time, limit int) ([]OutboxMessage, error)
+	// Ack removes delivered message
+	Ack(id string) error
+	// Retry postpones delivery of message after failed attempt
+	Retry(id string, attempts int, next time.Time) error
+}
+
+// OutboxSink delivers message, e.g. to Kafka or a webhook. A nil error acknowledges it.
+type OutboxSink func(ctx context.Context, message OutboxMessage) error
+
+// EmitEvent records domain event of the current call. Events are stored in the
+// outbox after the method succeeds and before the reply is sent, so an accepted
+// call never loses its events; they are dropped when the method fails. Without
+// an outbox events are published on the event bus directly, without durability.
+func EmitEvent(ctx *fasthttp.RequestCtx, topic string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("vapi: can't encode %s event: %s", topic, err.Error())
+	}
+	id, err := NewUUID()
+	if err != nil {
+		return err
+	}
+
+	messages, _ := ctx.UserValue(outboxUserValue).([]OutboxMessage)
+	ctx.SetUserValue(outboxUserValue, append(messages, OutboxMessage{
+		ID:      id.String(),
+		Topic:   topic,
+		Payload: body,
+		Created: time.Now(),
+	}))
+	return nil
+}
+
+// SetOutbox enables durable delivery of events emitted with EmitEvent
+func (as *VAPI) SetOutbox(outbox *Outbox) {
+	as.mutex.Lock()
+	as.outbox = outbox
+	as.mutex.Unlock()
+}
+
+// addEventsTx stores events emitted during the call within the first *sql.Tx
+// attached to the request scope when the outbox uses SQLOutboxStore, so events
+// are committed or rolled back together with the data. Delivery is woken up
+// after the scope commits. Without such tx it does nothing and flushEvents
+// stores events after the commit.
+func (as *VAPI) addEventsTx(ctx *fasthttp.RequestCtx) error {
+	messages, _ := ctx.UserValue(outboxUserValue).([]OutboxMessage)
+	if len(messages) == 0 {
+		return nil
+	}
+
+	as.mutex.RLock()
+	outbox := as.outbox
+	as.mutex.RUnlock()
+	if outbox == nil {
+		return nil
+	}
+	store, ok := outbox.store.(*SQLOutboxStore)
+	if !ok {
+		return nil
+	}
+
+	var tx *sql.Tx
+	entries, _ := ctx.UserValue(txUserValue).([]txEntry)
+	for _, entry := range entries {
+		if tx, ok = entry.resource.(*sql.Tx); ok {
+			break
+		}
+	}
+	if tx == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range messages {
+		messages[i].NextAttempt = now
+	}
+	if err := store.AddTx(tx, messages); err != nil {
+		return fmt.Errorf("vapi: can't store events: %s", err.Error())
+	}
+	ctx.SetUserValue(outboxUserValue, nil)
+	AttachTx(ctx, "vapi.outbox", TxHooks{OnCommit: func() error {
+		outbox.wake()
+		return nil
+	}})
+	return nil
+}
+
+// flushEvents stores or publishes events emitted during the call
+func (as *VAPI) flushEvents(ctx *fasthttp.RequestCtx) error {
+	messages, _ := ctx.UserValue(outboxUserValue).([]OutboxMessage)
+	if len(messages) == 0 {
+		return nil
+	}
+	ctx.SetUserValue(outboxUserValue, nil)
+
+	as.mutex.RLock()
+	outbox := as.outbox
+	as.mutex.RUnlock()
+	if outbox != nil {
+		return outbox.add(messages)
+	}
+
+	for _, message := range messages {
+		as.Events().Publish(message.Topic, message.Payload)
+	}
+	return nil
+}
+
+// Outbox delivers stored events to the sink with retries and exponential backoff,
+// guaranteeing at-least-once delivery: sinks must tolerate duplicates, e.g. by message ID.
+type Outbox struct {
+	// PollInterval is the delay between delivery rounds
+	PollInterval time.Duration
+	// BatchSize is the number of messages delivered per round
+	BatchSize int
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+
+	store OutboxStore
+	sink  OutboxSink
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	kick   chan struct{}
+}
+
+// NewOutbox returns stopped outbox delivering messages from store to sink
+func NewOutbox(store OutboxStore, sink OutboxSink) *Outbox {
+	return &Outbox{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MaxBackoff:   10 * time.Minute,
+		store:        store,
+		sink:         sink,
+		kick:         make(chan struct{}, 1),
+	}
+}
+
+// add stores messages and wakes up delivery
+func (o *Outbox) add(messages []OutboxMessage) error {
+	now := time.Now()
+	for i := range messages {
+		messages[i].NextAttempt = now
+	}
+	if err := o.store.Add(messages); err != nil {
+		return fmt.Errorf("vapi: can't store events: %s", err.Error())
+	}
+	o.wake()
+	return nil
+}
+
+// wake starts delivery round without waiting for PollInterval
+func (o *Outbox) wake() {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs delivery until Stop
+func (o *Outbox) Start() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel, o.done = cancel, make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(o.PollInterval)
+		defer ticker.Stop()
+		for {
+			o.Deliver(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-o.kick:
+			}
+		}
+	}(o.done)
+}
+
+// Stop stops delivery and waits for the current round
+func (o *Outbox) Stop() {
+	o.mutex.Lock()
+	cancel, done := o.cancel, o.done
+	o.cancel = nil
+	o.mutex.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Deliver sends due messages once and returns the number of delivered ones
+func (o *Outbox) Deliver(ctx context.Context) int {
+	messages, err := o.store.Pending(time.Now(), o.BatchSize)
+	if err != nil {
+		return 0
+	}
+
+	delivered := 0
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		if err = o.sink(ctx, message); err != nil {
+			attempts := message.Attempts + 1
+			o.store.Retry(message.ID, attempts, time.Now().Add(o.backoff(attempts)))
+			continue
+		}
+		if o.store.Ack(message.ID) == nil {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// backoff returns delay before attempt
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxBackoff {
+		delay = o.MaxBackoff
+	}
+	return delay
+}
+
+// EventBusSink publishes outbox messages on the event bus with json.RawMessage payload
+func EventBusSink(bus *EventBus) OutboxSink {
+	return func(ctx context.Context, message OutboxMessage) error {
+		return bus.Publish(message.Topic, message.Payload)
+	}
+}
+
+// WebhookSink posts message payload to url with X-Event-Topic and X-Event-ID headers,
+// any 2xx response acknowledges delivery
+func WebhookSink(url string, timeout time.Duration) OutboxSink {
+	return func(ctx context.Context, message OutboxMessage) error {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI(url)
+		req.Header.SetMethod("POST")
+		req.Header.SetContentType("application/json")
+		req.Header.Set("X-Event-Topic", message.Topic)
+		req.Header.Set("X-Event-ID", message.ID)
+		req.SetBody(message.Payload)
+
+		if err := fasthttp.DoTimeout(req, resp, timeout); err != nil {
+			return err
+		}
+		if status := resp.StatusCode(); status < 200 || status >= 300 {
+			return fmt.Errorf("vapi: webhook responded with status %d", status)
+		}
+		return nil
+	}
+}
+
+// MemoryOutboxStore keeps messages in memory, for tests and development: messages don't survive restarts
+type MemoryOutboxStore struct {
+	mutex    sync.Mutex
+	messages map[string]OutboxMessage
+}
+
+// NewMemoryOutboxStore returns empty in-memory outbox store
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{messages: make(map[string]OutboxMessage)}
+}
+
+// Add implements OutboxStore
+func (ms *MemoryOutboxStore) Add(messages []OutboxMessage) error {
+	ms.mutex.Lock()
+	for _, message := range messages {
+		ms.messages[message.ID] = message
+	}
+	ms.mutex.Unlock()
+	return nil
+}
+
+// Pending implements OutboxStore
+func (ms *MemoryOutboxStore) Pending(now time.Time, limit int) ([]OutboxMessage, error) {
+	ms.mutex.Lock()
+	pending := make([]OutboxMessage, 0, len(ms.messages))
+	for _, message := range ms.messages {
+		if !message.NextAttempt.After(now) {
+			pending = append(pending, message)
+		}
+	}
+	ms.mutex.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Created.Before(pending[j].Created) })
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+// Ack implements OutboxStore
+func (ms *MemoryOutboxStore) Ack(id string) error {
+	ms.mutex.Lock()
+	delete(ms.messages, id)
+	ms.mutex.Unlock()
+	return nil
+}
+
+// Retry implements OutboxStore
+func (ms *MemoryOutboxStore) Retry(id string, attempts int, next time.Time) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if message, ok := ms.messages[id]; ok {
+		message.Attempts, message.NextAttempt = attempts, next
+		ms.messages[id] = message
+	}
+	return nil
+}
+
+// SQLOutboxStore keeps messages in a database table:
+//
+//	CREATE TABLE vapi_outbox (
+//		id           VARCHAR(36) PRIMARY KEY,
+//		topic        VARCHAR(255) NOT NULL,
+//		payload      TEXT NOT NULL,
+//		created_at   BIGINT NOT NULL, -- unix nanoseconds
+//		attempts     INT NOT NULL,
+//		next_attempt BIGINT NOT NULL  -- unix nanoseconds
+//	)
+//
+// Events emitted by a method with *sql.Tx attached to the request scope (AttachTx)
+// are stored in that transaction before it commits, which makes the outbox exactly
+// as durable as the data; the tx must belong to the database of the store.
+// Handlers managing transactions themselves can do the same with AddTx.
+type SQLOutboxStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+}
+
+// NewSQLOutboxStore returns store using table of db. Postgres should be true for
+// drivers using $1 placeholders instead of ?.
+func NewSQLOutboxStore(db *sql.DB, table string, postgres bool) *SQLOutboxStore {
+	placeholder := func(int) string { return "?" }
+	if postgres {
+		placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+	}
+	return &SQLOutboxStore{db: db, table: table, placeholder: placeholder}
+}
+
+// Add implements OutboxStore
+func (ss *SQLOutboxStore) Add(messages []OutboxMessage) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = ss.AddTx(tx, messages); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddTx stores messages within tx
+func (ss *SQLOutboxStore) AddTx(tx *sql.Tx, messages []OutboxMessage) error {
+	query := fmt.Sprintf("INSERT INTO %s (id, topic, payload, created_at, attempts, next_attempt) VALUES (%s, %s, %s, %s, %s, %s)",
+		ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3), ss.placeholder(4), ss.placeholder(5), ss.placeholder(6))
+	for _, message := range messages {
+		next := message.NextAttempt
+		if next.IsZero() {
+			next = time.Now()
+		}
+		_, err := tx.Exec(query, message.ID, message.Topic, string(message.Payload), message.Created.UnixNano(), message.Attempts, next.UnixNano())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending implements OutboxStore
+func (ss *SQLOutboxStore) Pending(now time.Time, limit int) ([]OutboxMessage, error) {
+	rows, err := ss.db.Query(
+		fmt.Sprintf("SELECT id, topic, payload, created_at, attempts, next_attempt FROM %s WHERE next_attempt <= %s ORDER BY created_at LIMIT %d",
+			ss.table, ss.placeholder(1), limit),
+		now.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var message OutboxMessage
+		var payload string
+		var created, next int64
+		if err = rows.Scan(&message.ID, &message.Topic, &payload, &created, &message.Attempts, &next); err != nil {
+			return nil, err
+		}
+		message.Payload = json.RawMessage(payload)
+		message.Created, message.NextAttempt = time.Unix(0, created), time.Unix(0, next)
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// Ack implements OutboxStore
+func (ss *SQLOutboxStore) Ack(id string) error {
+	_, err := ss.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = %s", ss.table, ss.placeholder(1)), id)
+	return err
+}
+
+// Retry implements OutboxStore
+func (ss *SQLOutboxStore) Retry(id string, attempts int, next time.Time) error {
+	_, err := ss.db.Exec(
+		fmt.Sprintf("UPDATE %s SET attempts = %s, next_attempt = %s WHERE id = %s", ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3)),
+		attempts, next.UnixNano(), id,
+	)
+	return err
+}
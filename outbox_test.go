@@ -0,0 +1,153 @@
+package vapi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type OrdersAPI struct{}
+
+func (h *OrdersAPI) Create(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	EmitEvent(ctx, "order.created", map[string]string{"id": args.ID})
+	if args.Ttt == "fail" {
+		return &Error{ErrorHTTPCode: fasthttp.StatusConflict, ErrorMessage: "conflict"}
+	}
+	reply.ID = args.ID
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(OrdersAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivered []string
+	failures := 1
+	store := NewMemoryOutboxStore()
+	outbox := NewOutbox(store, func(ctx context.Context, message OutboxMessage) error {
+		if failures > 0 {
+			failures--
+			return errors.New("sink is down")
+		}
+		delivered = append(delivered, message.Topic+" "+string(message.Payload))
+		return nil
+	})
+	as.SetOutbox(outbox)
+
+	as.callLocal("OrdersAPI.Create", []byte(`{"id":"1"}`))
+	as.callLocal("OrdersAPI.Create", []byte(`{"id":"2","ttt":"fail"}`))
+
+	if pending, _ := store.Pending(maxTime(), 10); len(pending) != 1 {
+		t.Fatal(fmt.Sprintf("only events of successful calls must be stored, got %d", len(pending)))
+	}
+
+	if n := outbox.Deliver(context.Background()); n != 0 {
+		t.Error("failed delivery must not be acknowledged")
+	}
+	pending, _ := store.Pending(maxTime(), 10)
+	if len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Fatal(fmt.Sprintf("failed message must be retried later: %+v", pending))
+	}
+
+	store.Retry(pending[0].ID, 1, pending[0].Created)
+	if n := outbox.Deliver(context.Background()); n != 1 || len(delivered) != 1 || delivered[0] != `order.created {"id":"1"}` {
+		t.Error(fmt.Sprintf("wrong delivery: %v", delivered))
+	}
+}
+
+// recorder is registered once, tests may run several times
+var recorder = &recordingDriver{}
+
+func init() {
+	sql.Register("vapi-outbox-recorder", recorder)
+}
+
+// recordingDriver is a database/sql driver logging statements and transaction ends
+type recordingDriver struct {
+	mutex sync.Mutex
+	log   []string
+}
+
+func (d *recordingDriver) record(entry string) {
+	d.mutex.Lock()
+	d.log = append(d.log, entry)
+	d.mutex.Unlock()
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{d: c.d, query: query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { c.d.record("begin"); return recordingTx{c.d}, nil }
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx recordingTx) Commit() error   { tx.d.record("commit"); return nil }
+func (tx recordingTx) Rollback() error { tx.d.record("rollback"); return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.d.record(strings.Fields(s.query)[0])
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestOutbox_SQLTx(t *testing.T) {
+	db, err := sql.Open("vapi-outbox-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	as := NewServer()
+	if err = as.RegisterService(new(OrdersAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	as.SetOutbox(NewOutbox(NewSQLOutboxStore(db, "vapi_outbox", false), nil))
+
+	call := func(args string) []string {
+		recorder.log = nil
+		as.callLocalWith("OrdersAPI.Create", []byte(args), nil, func(ctx *fasthttp.RequestCtx) {
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			AttachTx(ctx, "db", tx)
+		})
+		return recorder.log
+	}
+
+	if log := call(`{"id":"1"}`); strings.Join(log, ",") != "begin,INSERT,commit" {
+		t.Error(fmt.Sprintf("events must be stored within the attached tx: %v", log))
+	}
+	if log := call(`{"id":"2","ttt":"fail"}`); strings.Join(log, ",") != "begin,rollback" {
+		t.Error(fmt.Sprintf("events of failed call must not be stored: %v", log))
+	}
+}
+
+// maxTime returns time after all pending attempts
+func maxTime() time.Time {
+	return time.Now().Add(24 * time.Hour)
+}
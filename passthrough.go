@@ -0,0 +1,20 @@
+package vapi
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// passthroughArgs hands the raw args to methods taking *json.RawMessage.
+//
+// Such methods are passthrough: args are neither decoded nor copied, the
+// method gets the request body itself, so it must copy the bytes to keep
+// them after returning. A json.RawMessage reply is written into the response
+// envelope as is, without encoding, so proxy-style methods pay no marshal cost:
+//
+//	func (p *Proxy) Forward(ctx *fasthttp.RequestCtx, args *json.RawMessage, reply *json.RawMessage) error
+func passthroughArgs(ctx *fasthttp.RequestCtx, args reflect.Value, verb string) {
+	*args.Interface().(*json.RawMessage) = requestArgs(ctx, verb)
+}
@@ -0,0 +1,31 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type ProxyAPI struct{}
+
+func (p *ProxyAPI) Forward(ctx *fasthttp.RequestCtx, args *json.RawMessage, reply *json.RawMessage) error {
+	if len(*args) > 0 && &(*args)[0] != &ctx.Request.Body()[0] {
+		return &Error{ErrorHTTPCode: fasthttp.StatusInternalServerError, ErrorMessage: "args were copied"}
+	}
+	*reply = append(json.RawMessage(nil), *args...)
+	return nil
+}
+
+func TestPassthrough(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(ProxyAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	status, body, _ := as.callLocal("ProxyAPI.Forward", []byte(`{"b": 1,  "a": [2]}`))
+	if status != 200 || string(body) != `{"response":{"b": 1,  "a": [2]}}` {
+		t.Error(fmt.Sprintf("wrong passthrough reply: %d %s", status, body))
+	}
+}
@@ -0,0 +1,76 @@
+package vapi
+
+import (
+	"reflect"
+)
+
+// typePlan tells which reflection passes over values of a type are needed.
+// Plans are computed once at registration, so calls of methods whose args and
+// replies have no tagged fields skip walking the values.
+type typePlan struct {
+	encrypted bool // has `vapi:"encrypt"` fields
+	localtime bool // has `vapi:"localtime"` fields
+	sanitized bool // has `sanitize` fields
+	moderated bool // has `vapi:"moderate"` fields
+}
+
+// dynamicPlan runs every pass, it is used for types with interface fields
+var dynamicPlan = typePlan{encrypted: true, localtime: true, sanitized: true, moderated: true}
+
+// planOf computes plan of type t
+func planOf(t reflect.Type) typePlan {
+	plan := typePlan{}
+	collectPlan(t, &plan, map[reflect.Type]bool{})
+	return plan
+}
+
+// collectPlan walks t the same way the passes walk values
+func collectPlan(t reflect.Type, plan *typePlan, visited map[reflect.Type]bool) {
+	if visited[t] || *plan == dynamicPlan {
+		return
+	}
+	visited[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		// the concrete type is known only at call time
+		*plan = dynamicPlan
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		collectPlan(t.Elem(), plan, visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Type.Kind() == reflect.String && hasTagOption(field, "encrypt") {
+				plan.encrypted = true
+			}
+			if hasTagOption(field, "localtime") {
+				plan.localtime = true
+			}
+			if _, ok := field.Tag.Lookup("sanitize"); ok {
+				plan.sanitized = true
+			}
+			if hasTagOption(field, "moderate") {
+				plan.moderated = true
+			}
+			collectPlan(field.Type, plan, visited)
+		}
+	}
+}
+
+// SetDynamicTypes disables type plans of the method: args and replies are
+// walked on every call. Use it for methods whose values are changed in ways
+// the declared types don't show, e.g. replies built with reflection.
+func (as *VAPI) SetDynamicTypes(method string) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.argsPlan, methodSpec.replyPlan = dynamicPlan, dynamicPlan
+	as.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,43 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type planNested struct {
+	Secret string `vapi:"encrypt"`
+}
+
+type planReply struct {
+	Plain   string
+	Created time.Time `vapi:"localtime"`
+	Items   []*planNested
+}
+
+type planMapped struct {
+	ByName map[string]planNested
+}
+
+type planDynamic struct {
+	Payload interface{}
+}
+
+func TestPlanOf(t *testing.T) {
+	for _, tc := range []struct {
+		value    interface{}
+		expected typePlan
+	}{
+		{TestReply{}, typePlan{}},
+		{planReply{}, typePlan{encrypted: true, localtime: true}},
+		{planNested{}, typePlan{encrypted: true}},
+		{planMapped{}, typePlan{encrypted: true}},
+		{planDynamic{}, dynamicPlan},
+	} {
+		if plan := planOf(reflect.TypeOf(tc.value)); plan != tc.expected {
+			t.Error(fmt.Sprintf("%T: expected %+v, got %+v", tc.value, tc.expected, plan))
+		}
+	}
+}
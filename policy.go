@@ -0,0 +1,96 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+var errAccessDenied = errors.New("vapi: access denied")
+
+// Policy restricts access to a method by roles of the caller.
+// Deny rules win over allow rules; empty AllowRoles allows every role not denied.
+type Policy struct {
+	AllowRoles []string `json:"allow_roles,omitempty"`
+	DenyRoles  []string `json:"deny_roles,omitempty"`
+}
+
+// PolicyProvider is implemented by service receivers declaring access policies
+// beside their methods. Policies is keyed by method name without the service
+// name and is read once by RegisterService.
+type PolicyProvider interface {
+	Policies() map[string]Policy
+}
+
+// RoleResolver returns roles of the caller, e.g. from token claims or the session
+type RoleResolver func(ctx *fasthttp.RequestCtx) []string
+
+// SetRoleResolver sets the resolver of caller roles checked against method policies.
+// Calls of methods with a policy are denied while no resolver is set.
+func (as *VAPI) SetRoleResolver(resolver RoleResolver) {
+	as.mutex.Lock()
+	as.roles = resolver
+	as.mutex.Unlock()
+}
+
+// SetMethodPolicy sets access policy of the method, overriding the one from PolicyProvider
+func (as *VAPI) SetMethodPolicy(method string, policy Policy) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.policy = &policy
+	as.mutex.Unlock()
+	return nil
+}
+
+// Allows reports whether a caller with roles passes the policy
+func (p Policy) Allows(roles []string) bool {
+	granted := stringSet(roles)
+	for _, role := range p.DenyRoles {
+		if granted[role] {
+			return false
+		}
+	}
+	if len(p.AllowRoles) == 0 {
+		return true
+	}
+	for _, role := range p.AllowRoles {
+		if granted[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverPolicies returns policies declared by the receiver, checking they name api methods
+func receiverPolicies(rcvr interface{}, rcvrType reflect.Type) (map[string]Policy, error) {
+	provider, ok := rcvr.(PolicyProvider)
+	if !ok {
+		return nil, nil
+	}
+	policies := provider.Policies()
+	for name := range policies {
+		method, found := rcvrType.MethodByName(name)
+		if !found || methodShape(method.Type, 1) != "" {
+			return nil, fmt.Errorf("vapi: policy of unknown method %q", name)
+		}
+	}
+	return policies, nil
+}
+
+// authorize checks the method policy against caller roles, writes 403 and returns false when denied
+func (as *VAPI) authorize(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	if methodSpec.policy == nil {
+		return true
+	}
+	if as.roles == nil || !methodSpec.policy.Allows(as.roles(ctx)) {
+		as.writeError(ctx, srvResponse, fasthttp.StatusForbidden, errAccessDenied)
+		return false
+	}
+	return true
+}
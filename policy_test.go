@@ -0,0 +1,77 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type AdminAPI struct{}
+
+func (a *AdminAPI) Policies() map[string]Policy {
+	return map[string]Policy{
+		"Delete": {AllowRoles: []string{"admin"}, DenyRoles: []string{"suspended"}},
+	}
+}
+
+func (a *AdminAPI) Delete(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return nil
+}
+
+func (a *AdminAPI) List(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return nil
+}
+
+type BrokenPolicyAPI struct {
+	AdminAPI
+}
+
+func (b *BrokenPolicyAPI) Policies() map[string]Policy {
+	return map[string]Policy{"Remove": {AllowRoles: []string{"admin"}}}
+}
+
+func TestPolicies(t *testing.T) {
+	as := NewServer()
+	report, err := as.RegisterServiceReport(new(AdminAPI), "admin")
+	if err != nil || len(report.Skipped) != 0 {
+		t.Fatal(fmt.Sprintf("Policies must not be reported as skipped: %v %s", err, report))
+	}
+	if err := NewServer().RegisterService(new(BrokenPolicyAPI), ""); err == nil || !strings.Contains(err.Error(), `"Remove"`) {
+		t.Error(fmt.Sprintf("policy of unknown method must fail: %v", err))
+	}
+
+	call := func(method string, roles ...string) int {
+		status, _, _ := as.callLocalWith(method, []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.SetUserValue("roles", roles)
+		})
+		return status
+	}
+
+	if status := call("admin.Delete", "admin"); status != 403 {
+		t.Error(fmt.Sprintf("policy without role resolver must deny: %d", status))
+	}
+	as.SetRoleResolver(func(ctx *fasthttp.RequestCtx) []string {
+		return ctx.UserValue("roles").([]string)
+	})
+	for _, c := range []struct {
+		method string
+		roles  []string
+		status int
+	}{
+		{"admin.Delete", []string{"admin"}, 200},
+		{"admin.Delete", []string{"user"}, 403},
+		{"admin.Delete", []string{"admin", "suspended"}, 403},
+		{"admin.List", nil, 200},
+	} {
+		if status := call(c.method, c.roles...); status != c.status {
+			t.Error(fmt.Sprintf("%s %v: expected %d, got %d", c.method, c.roles, c.status, status))
+		}
+	}
+
+	routes := as.Routes()
+	if routes[0].Method != "admin.Delete" || routes[0].Policy == nil || routes[0].Policy.AllowRoles[0] != "admin" {
+		t.Error(fmt.Sprintf("policy must show up in routes: %+v", routes[0]))
+	}
+}
@@ -27,6 +27,8 @@ func acquireResponse() *ServerResponse {
 func releaseResponse(resp *ServerResponse) {
 	resp.Response = nil
 	resp.Error = nil
+	resp.Debug = nil
+	resp.Warnings = nil
 	responsePool.Put(resp)
 }
 
@@ -0,0 +1,19 @@
+package vapi
+
+import "github.com/valyala/fasthttp"
+
+// PrincipalFunc returns the identity (api key, user id, client ip) the request is accounted to.
+// An empty result means the request has no known principal.
+type PrincipalFunc func(ctx *fasthttp.RequestCtx) string
+
+// HeaderPrincipal returns PrincipalFunc taking the principal from the request header
+func HeaderPrincipal(header string) PrincipalFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		return string(ctx.Request.Header.Peek(header))
+	}
+}
+
+// RemoteIPPrincipal is a PrincipalFunc using the client ip address as principal
+func RemoteIPPrincipal(ctx *fasthttp.RequestCtx) string {
+	return ctx.RemoteIP().String()
+}
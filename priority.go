@@ -0,0 +1,135 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority is the load shedding class of a method
+type Priority int
+
+// Method priorities, methods are registered with PriorityNormal
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// String returns priority name
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+var errOverloaded = errors.New("vapi: server is overloaded, try again later")
+
+// ConcurrencyLimiter decides whether a call may be executed right now.
+type ConcurrencyLimiter interface {
+	// Acquire reserves a slot for a call of given priority, false means the call must be shed
+	Acquire(priority Priority) bool
+	// Release frees the slot acquired for the call which took elapsed time
+	Release(priority Priority, elapsed time.Duration)
+}
+
+// PriorityLimiter is a ConcurrencyLimiter with a global limit of in-flight calls
+// and slots reserved for higher priorities: a call is admitted only while
+// the number of in-flight calls is below Limit minus the slots reserved for
+// all priorities above its own. Low priority calls are shed first and high
+// priority calls can always use the whole limit.
+type PriorityLimiter struct {
+	mutex    sync.Mutex
+	limit    int
+	reserved map[Priority]int
+	inFlight int
+}
+
+// NewPriorityLimiter returns limiter admitting at most limit calls at once
+func NewPriorityLimiter(limit int) *PriorityLimiter {
+	return &PriorityLimiter{
+		limit:    limit,
+		reserved: make(map[Priority]int),
+	}
+}
+
+// Reserve keeps slots available only for calls of priority p or higher
+func (pl *PriorityLimiter) Reserve(p Priority, slots int) {
+	pl.mutex.Lock()
+	pl.reserved[p] = slots
+	pl.mutex.Unlock()
+}
+
+// SetLimit changes the global limit of in-flight calls
+func (pl *PriorityLimiter) SetLimit(limit int) {
+	pl.mutex.Lock()
+	pl.limit = limit
+	pl.mutex.Unlock()
+}
+
+// Limit returns the global limit of in-flight calls
+func (pl *PriorityLimiter) Limit() int {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+	return pl.limit
+}
+
+// InFlight returns the number of currently executed calls
+func (pl *PriorityLimiter) InFlight() int {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+	return pl.inFlight
+}
+
+// Acquire implements ConcurrencyLimiter
+func (pl *PriorityLimiter) Acquire(priority Priority) bool {
+	pl.mutex.Lock()
+	defer pl.mutex.Unlock()
+
+	available := pl.limit
+	for p, slots := range pl.reserved {
+		if p > priority {
+			available -= slots
+		}
+	}
+	if pl.inFlight >= available {
+		return false
+	}
+	pl.inFlight++
+	return true
+}
+
+// Release implements ConcurrencyLimiter
+func (pl *PriorityLimiter) Release(priority Priority, elapsed time.Duration) {
+	pl.mutex.Lock()
+	pl.inFlight--
+	pl.mutex.Unlock()
+}
+
+// SetLimiter enables load shedding of calls by limiter, nil disables it
+func (as *VAPI) SetLimiter(limiter ConcurrencyLimiter) {
+	as.mutex.Lock()
+	as.limiter = limiter
+	as.mutex.Unlock()
+}
+
+// SetMethodPriority sets priority of registered method ("Service.Method").
+// It should be called before the server starts handling requests.
+func (as *VAPI) SetMethodPriority(method string, priority Priority) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.priority = priority
+	as.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,36 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPriorityLimiter(t *testing.T) {
+	pl := NewPriorityLimiter(4)
+	pl.Reserve(PriorityHigh, 1)
+	pl.Reserve(PriorityNormal, 1)
+
+	if !pl.Acquire(PriorityLow) || !pl.Acquire(PriorityLow) {
+		t.Fatal("low priority calls must be admitted under the limit")
+	}
+	if pl.Acquire(PriorityLow) {
+		t.Error("low priority call must be shed when only reserved slots left")
+	}
+	if !pl.Acquire(PriorityNormal) {
+		t.Error("normal priority call must use its reserved slot")
+	}
+	if pl.Acquire(PriorityNormal) {
+		t.Error("normal priority call must not use slots reserved for high priority")
+	}
+	if !pl.Acquire(PriorityHigh) {
+		t.Error("high priority call must use its reserved slot")
+	}
+	if pl.Acquire(PriorityHigh) {
+		t.Error("limit must not be exceeded")
+	}
+
+	pl.Release(PriorityLow, 0)
+	if pl.InFlight() != 3 {
+		t.Error(fmt.Sprintf("wrong in-flight counter: %d", pl.InFlight()))
+	}
+}
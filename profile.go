@@ -0,0 +1,108 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Profile is a bundle of behaviors differing between environments.
+// The zero value keeps the server defaults.
+type Profile struct {
+	// Name of the environment, e.g. "dev" or "prod"
+	Name string
+	// PrettyJSON indents json responses
+	PrettyJSON bool
+	// RedactErrors replaces messages of internal (5xx) errors with a generic one,
+	// errors returned by methods are kept
+	RedactErrors bool
+	// HideDocs makes OpenAPIHandler reply 404
+	HideDocs bool
+	// HSTS is the max-age of Strict-Transport-Security header, none when zero
+	HSTS time.Duration
+	// MemoryPerRequest and MemoryTotal are passed to SetMemoryLimit when set
+	MemoryPerRequest int64
+	MemoryTotal      int64
+	// ServerTiming enables Server-Timing header
+	ServerTiming bool
+	// DebugTrace allows ?debug=trace to every caller
+	DebugTrace bool
+}
+
+var (
+	// DevProfile is meant for local development: readable responses with full details
+	DevProfile = Profile{Name: "dev", PrettyJSON: true, ServerTiming: true, DebugTrace: true}
+	// StageProfile is production limits with api docs and timings left visible
+	StageProfile = Profile{Name: "stage", RedactErrors: true, HSTS: 24 * time.Hour, ServerTiming: true,
+		MemoryPerRequest: 8 << 20, MemoryTotal: 256 << 20}
+	// ProdProfile hides internals and enforces limits
+	ProdProfile = Profile{Name: "prod", RedactErrors: true, HideDocs: true, HSTS: 365 * 24 * time.Hour,
+		MemoryPerRequest: 8 << 20, MemoryTotal: 256 << 20}
+)
+
+// redactedError replaces messages of internal errors when Profile.RedactErrors is set
+const redactedError = "vapi: internal error"
+
+// ParseProfile returns the predefined profile by name: "dev", "stage" or "prod"
+// (long forms "development", "staging" and "production" are accepted too)
+func ParseProfile(name string) (Profile, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "dev", "development":
+		return DevProfile, nil
+	case "stage", "staging":
+		return StageProfile, nil
+	case "prod", "production":
+		return ProdProfile, nil
+	}
+	return Profile{}, fmt.Errorf("vapi: unknown profile %q", name)
+}
+
+// SetProfile switches the server to the behaviors of p.
+//
+// Start from a predefined profile and change single fields to deviate from it,
+// e.g. ProdProfile with HideDocs false. Every behavior the profile controls is
+// reset, including memory limits, Server-Timing and debug trace set before, so
+// switching from DevProfile to ProdProfile leaves no dev behavior on. Setters
+// called after SetProfile (SetMemoryLimit, SetServerTiming, SetDebugTrace)
+// override the profile.
+func (as *VAPI) SetProfile(p Profile) {
+	as.mutex.Lock()
+	as.profile = p
+	as.mutex.Unlock()
+
+	as.SetMemoryLimit(p.MemoryPerRequest, p.MemoryTotal)
+	as.SetServerTiming(p.ServerTiming)
+	if p.DebugTrace {
+		as.SetDebugTrace(func(ctx *fasthttp.RequestCtx) bool { return true })
+	} else {
+		as.SetDebugTrace(nil)
+	}
+}
+
+// Profile returns the current profile
+func (as *VAPI) Profile() Profile {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	return as.profile
+}
+
+// setHSTS sets Strict-Transport-Security header when the profile asks for it
+func (as *VAPI) setHSTS(ctx *fasthttp.RequestCtx) {
+	if as.profile.HSTS > 0 {
+		ctx.Response.Header.Set("Strict-Transport-Security",
+			"max-age="+strconv.FormatInt(int64(as.profile.HSTS/time.Second), 10)+"; includeSubDomains")
+	}
+}
+
+// indentResponse indents json body of the response
+func indentResponse(ctx *fasthttp.RequestCtx) {
+	buf := &bytes.Buffer{}
+	if json.Indent(buf, ctx.Response.Body(), "", "  ") == nil {
+		ctx.SetBody(buf.Bytes())
+	}
+}
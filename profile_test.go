@@ -0,0 +1,75 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetProfile(t *testing.T) {
+	if _, err := ParseProfile("qa"); err == nil {
+		t.Error("unknown profile must fail")
+	}
+	if p, _ := ParseProfile("Production"); p.Name != "prod" {
+		t.Error(fmt.Sprintf("wrong profile: %+v", p))
+	}
+
+	dev := NewServer()
+	dev.RegisterService(new(DemoAPI), "demo")
+	dev.SetProfile(DevProfile)
+	if _, body, _ := dev.callLocal("demo.Test", []byte(`{"id":"1"}`)); !strings.Contains(string(body), "\n  ") {
+		t.Error(fmt.Sprintf("dev response must be indented: %s", body))
+	}
+
+	prod := NewServer()
+	prod.RegisterService(new(DemoAPI), "demo")
+	prod.SetProfile(ProdProfile)
+	if prod.memory == nil || prod.memory.perRequest != ProdProfile.MemoryPerRequest {
+		t.Error("prod profile must set memory limit")
+	}
+
+	var response *fasthttp.RequestCtx
+	status, body, _ := prod.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		response = ctx
+		AttachTx(ctx, "db", TxHooks{OnCommit: func() error { return errors.New("deadlock on table users") }})
+	})
+	if status != 500 || strings.Contains(string(body), "deadlock") || !strings.Contains(string(body), redactedError) {
+		t.Error(fmt.Sprintf("internal error must be redacted: %d %s", status, body))
+	}
+	if hsts := string(response.Response.Header.Peek("Strict-Transport-Security")); hsts != "max-age=31536000; includeSubDomains" {
+		t.Error(fmt.Sprintf("wrong hsts: %q", hsts))
+	}
+	if _, body, _ := prod.callLocal("demo.ErrorTest", []byte(`{"id":"1"}`)); !strings.Contains(string(body), "Test Wrong answer") {
+		t.Error(fmt.Sprintf("method errors must be kept: %s", body))
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	prod.OpenAPIHandler("demo", "1", "/")(ctx)
+	if ctx.Response.StatusCode() != 404 {
+		t.Error(fmt.Sprintf("prod must hide docs: %d", ctx.Response.StatusCode()))
+	}
+}
+
+func TestVAPI_SetProfile_Switch(t *testing.T) {
+	as := NewServer()
+	as.SetProfile(DevProfile)
+	if !as.serverTiming || as.debugTrace == nil || as.memory != nil {
+		t.Error("dev profile must enable timing and trace without memory limit")
+	}
+
+	as.SetProfile(ProdProfile)
+	if as.serverTiming || as.debugTrace != nil {
+		t.Error("prod profile must turn off dev timing and trace")
+	}
+	if as.memory == nil {
+		t.Error("prod profile must set memory limit")
+	}
+
+	as.SetProfile(DevProfile)
+	if as.memory != nil {
+		t.Error("dev profile must drop prod memory limit")
+	}
+}
@@ -0,0 +1,73 @@
+package vapi
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// anonymousQuotaPrincipal is the shared bucket of calls without a principal
+const anonymousQuotaPrincipal = "-"
+
+// methodQuota limits calls of a method per principal in fixed windows
+type methodQuota struct {
+	limit     int64
+	window    time.Duration
+	principal PrincipalFunc
+}
+
+// SetMethodQuota allows every principal limit calls of the method per window,
+// further calls get 429 Too Many Requests with Retry-After until the window ends.
+//
+// Counters live in the Store (see SetStore), so with a shared store they
+// survive rolling restarts and are enforced across instances. Calls are
+// allowed when the store fails, a quota outage must not take the api down.
+// Calls principal returns no identity for share one anonymous bucket, so they
+// can't bypass the quota. Zero limit removes the quota.
+func (as *VAPI) SetMethodQuota(method string, limit int64, window time.Duration, principal PrincipalFunc) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && (window <= 0 || principal == nil) {
+		return fmt.Errorf("vapi: quota of %q needs positive window and principal", method)
+	}
+
+	var quota *methodQuota
+	if limit > 0 {
+		quota = &methodQuota{limit: limit, window: window, principal: principal}
+	}
+
+	as.mutex.Lock()
+	methodSpec.quota = quota
+	as.mutex.Unlock()
+	return nil
+}
+
+// checkQuota counts the call, writes 429 and returns false when the quota is exhausted
+func (as *VAPI) checkQuota(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	quota := methodSpec.quota
+	as.mutex.RUnlock()
+	if quota == nil {
+		return true
+	}
+	principal := quota.principal(ctx)
+	if principal == "" {
+		principal = anonymousQuotaPrincipal
+	}
+
+	now := time.Now()
+	windowStart := now.Truncate(quota.window)
+	key := "vapi:quota:" + methodSpec.name + ":" + principal + ":" + strconv.FormatInt(windowStart.Unix(), 10)
+	count, err := as.Store().Incr(key, 1, quota.window)
+	if err != nil || count <= quota.limit {
+		return true
+	}
+
+	retryAfter := windowStart.Add(quota.window).Sub(now).Truncate(time.Second) + time.Second
+	as.writeTooManyRequests(ctx, srvResponse, retryAfter, fmt.Errorf("vapi: quota of %d calls per %s exceeded", quota.limit, quota.window))
+	return false
+}
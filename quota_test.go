@@ -0,0 +1,50 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVAPI_SetMethodQuota(t *testing.T) {
+	store := NewMemoryStore()
+	newServer := func() *VAPI {
+		as := NewServer()
+		as.SetStore(store)
+		if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+			t.Fatal(err)
+		}
+		if err := as.SetMethodQuota("demo.Test", 2, time.Hour, RemoteIPPrincipal); err != nil {
+			t.Fatal(err)
+		}
+		return as
+	}
+
+	as := newServer()
+	for i, expected := range []int{200, 200, 429} {
+		if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != expected {
+			t.Error(fmt.Sprintf("call %d: expected %d, got %d", i, expected, status))
+		}
+	}
+
+	// a restarted instance sharing the store keeps the counters
+	if status, _, _ := newServer().callLocal("demo.Test", []byte(`{}`)); status != 429 {
+		t.Error(fmt.Sprintf("quota must survive restart, got %d", status))
+	}
+}
+
+func TestVAPI_SetMethodQuota_Anonymous(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodQuota("demo.Test", 1, time.Hour, HeaderPrincipal("X-Api-Key")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []int{200, 429} {
+		if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != expected {
+			t.Error(fmt.Sprintf("anonymous call %d: expected %d, got %d", i, expected, status))
+		}
+	}
+}
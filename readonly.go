@@ -0,0 +1,159 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultReadOnlyMessage is returned to rejected calls without a configured message
+const defaultReadOnlyMessage = "vapi: service is read-only"
+
+// mutability tells whether a method changes state
+type mutability uint8
+
+const (
+	// mutabilityByVerbs decides by the verbs set with SetMethodVerbs: methods
+	// restricted to GET read, others write
+	mutabilityByVerbs mutability = iota
+	mutabilityRead
+	mutabilityWrite
+)
+
+// MutationProvider is implemented by service receivers declaring which of
+// their methods change state, keyed by method name without the service name,
+// true for mutating ones. It is read once by RegisterService. Methods not
+// listed are mutating unless SetMethodVerbs restricted them to GET, the verb
+// of the request itself is never trusted.
+type MutationProvider interface {
+	Mutations() map[string]bool
+}
+
+// ReadOnlyNotice is the data of errors returned to mutating calls in read-only mode
+type ReadOnlyNotice struct {
+	Message string `json:"message"`
+	Method  string `json:"method"`
+}
+
+// SetMethodMutating declares whether the method changes state, overriding
+// MutationProvider and the declared verbs
+func (as *VAPI) SetMethodMutating(method string, mutating bool) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.mutability = mutabilityOf(mutating)
+	as.mutex.Unlock()
+	return nil
+}
+
+// EnterReadOnly rejects mutating calls with 503 and ReadOnlyNotice as error
+// data until ExitReadOnly, e.g. during database failovers and migrations.
+// Dry runs of mutating methods are still served.
+func (as *VAPI) EnterReadOnly(message string) {
+	if message == "" {
+		message = defaultReadOnlyMessage
+	}
+	as.mutex.Lock()
+	as.readOnly = message
+	as.mutex.Unlock()
+}
+
+// ExitReadOnly serves mutating calls again
+func (as *VAPI) ExitReadOnly() {
+	as.mutex.Lock()
+	as.readOnly = ""
+	as.mutex.Unlock()
+}
+
+// IsReadOnly reports whether the server is in read-only mode
+func (as *VAPI) IsReadOnly() bool {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	return as.readOnly != ""
+}
+
+// ReadOnlyHandler switches read-only mode, mount it behind admin
+// authentication. POST or PUT with {"message":"..."} enters it, DELETE exits,
+// every request is answered with the state.
+func (as *VAPI) ReadOnlyHandler(ctx *fasthttp.RequestCtx) {
+	switch {
+	case ctx.IsPost() || ctx.IsPut():
+		request := struct {
+			Message string `json:"message"`
+		}{}
+		if body := ctx.PostBody(); len(body) > 0 {
+			if err := json.Unmarshal(body, &request); err != nil {
+				writeHandlerError(ctx, fasthttp.StatusBadRequest, err)
+				return
+			}
+		}
+		as.EnterReadOnly(request.Message)
+	case ctx.IsDelete():
+		as.ExitReadOnly()
+	}
+
+	as.mutex.RLock()
+	state := struct {
+		ReadOnly bool   `json:"read_only"`
+		Message  string `json:"message,omitempty"`
+	}{as.readOnly != "", as.readOnly}
+	as.mutex.RUnlock()
+	body, err := json.Marshal(state)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// checkReadOnly rejects mutating calls in read-only mode, returns false when the call was answered
+func (as *VAPI) checkReadOnly(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, message string, methodSpec *serviceMethod) bool {
+	if message == "" || IsDryRun(ctx) {
+		return true
+	}
+	as.mutex.RLock()
+	mutability, verbs := methodSpec.mutability, methodSpec.verbs
+	as.mutex.RUnlock()
+	if mutability == mutabilityRead || mutability == mutabilityByVerbs && len(verbs) == 1 && verbs[0] == "GET" {
+		return true
+	}
+
+	errAPI := acquireError()
+	errAPI.ErrorHTTPCode = fasthttp.StatusServiceUnavailable
+	errAPI.ErrorMessage = message
+	errAPI.Data = ReadOnlyNotice{Message: message, Method: methodSpec.name}
+	srvResponse.Error = errAPI
+	as.writeResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
+	releaseError(errAPI)
+	return false
+}
+
+// receiverMutations returns mutations declared by the receiver, checking they name api methods
+func receiverMutations(rcvr interface{}, rcvrType reflect.Type) (map[string]bool, error) {
+	provider, ok := rcvr.(MutationProvider)
+	if !ok {
+		return nil, nil
+	}
+	mutations := provider.Mutations()
+	for name := range mutations {
+		method, found := rcvrType.MethodByName(name)
+		if !found || methodShape(method.Type, 1) != "" {
+			return nil, fmt.Errorf("vapi: mutation of unknown method %q", name)
+		}
+	}
+	return mutations, nil
+}
+
+// mutabilityOf converts the declared flag to mutability
+func mutabilityOf(mutating bool) mutability {
+	if mutating {
+		return mutabilityWrite
+	}
+	return mutabilityRead
+}
@@ -0,0 +1,76 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// LedgerAPI declares which methods change state
+type LedgerAPI struct{}
+
+func (l *LedgerAPI) Mutations() map[string]bool {
+	return map[string]bool{"Post": true, "Balance": false}
+}
+
+func (l *LedgerAPI) Post(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	reply.ID = args.ID
+	return nil
+}
+
+func (l *LedgerAPI) Balance(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	reply.ID = args.ID
+	return nil
+}
+
+func TestVAPI_EnterReadOnly(t *testing.T) {
+	as := NewServer()
+	report, err := as.RegisterServiceReport(new(LedgerAPI), "ledger")
+	if err != nil || len(report.Skipped) != 0 {
+		t.Fatal(fmt.Sprintf("unexpected registration: %v %s", err, report))
+	}
+	as.RegisterService(new(DemoAPI), "demo")
+
+	call := func(method, verb string) (int, string) {
+		status, body, _ := as.callLocalWith(method, []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.SetMethod(verb)
+		})
+		return status, string(body)
+	}
+
+	as.EnterReadOnly("database failover")
+	if status, body := call("ledger.Post", "POST"); status != fasthttp.StatusServiceUnavailable ||
+		!strings.Contains(body, `"data":{"message":"database failover","method":"ledger.Post"}`) {
+		t.Error(fmt.Sprintf("mutating method must be rejected, got %d: %s", status, body))
+	}
+	if status, _ := call("ledger.Balance", "POST"); status != 200 {
+		t.Error(fmt.Sprintf("read-only method must be served, got %d", status))
+	}
+	if status, _ := call("demo.Test", "GET"); status != fasthttp.StatusServiceUnavailable {
+		t.Error(fmt.Sprintf("undeclared methods must be rejected whatever the verb, got %d", status))
+	}
+	as.SetMethodVerbs("demo.Test", "GET")
+	if status, _ := call("demo.Test", "GET"); status != 200 {
+		t.Error(fmt.Sprintf("GET-only methods must be served, got %d", status))
+	}
+	as.SetMethodVerbs("demo.Test", "GET", "POST")
+	if status, _ := call("demo.Test", "GET"); status != fasthttp.StatusServiceUnavailable {
+		t.Error(fmt.Sprintf("methods accepting POST must be rejected, got %d", status))
+	}
+	as.SetMethodMutating("demo.Test", false)
+	if status, _ := call("demo.Test", "POST"); status != 200 {
+		t.Error(fmt.Sprintf("declared read-only method must be served, got %d", status))
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("DELETE")
+	as.ReadOnlyHandler(ctx)
+	if string(ctx.Response.Body()) != `{"read_only":false}` || as.IsReadOnly() {
+		t.Error(fmt.Sprintf("unexpected state: %s", ctx.Response.Body()))
+	}
+	if status, _ := call("ledger.Post", "POST"); status != 200 {
+		t.Error(fmt.Sprintf("writes must be served again, got %d", status))
+	}
+}
@@ -0,0 +1,55 @@
+package vapi
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// replyDirective is returned by a method in place of error to replace the json reply
+type replyDirective interface {
+	error
+	writeReply(ctx *fasthttp.RequestCtx)
+}
+
+// redirectReply redirects the client
+type redirectReply struct {
+	url  string
+	code int
+}
+
+// Redirect returns sentinel the method returns instead of error to redirect the client:
+//
+//	return vapi.Redirect("/login", fasthttp.StatusFound)
+//
+// Codes outside 3xx are replaced with 302 Found. The call counts as
+// successful: the transaction scope is committed and events are emitted.
+func Redirect(url string, code int) error {
+	if code < 300 || code > 399 {
+		code = fasthttp.StatusFound
+	}
+	return &redirectReply{url: url, code: code}
+}
+
+func (r *redirectReply) Error() string {
+	return fmt.Sprintf("vapi: redirect %d to %s", r.code, r.url)
+}
+
+func (r *redirectReply) writeReply(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Location", r.url)
+	ctx.SetStatusCode(r.code)
+}
+
+// noContentReply is the NoContent sentinel
+type noContentReply struct{}
+
+// NoContent is returned by a method instead of error to respond 204 No Content without body
+var NoContent error = noContentReply{}
+
+func (noContentReply) Error() string {
+	return "vapi: no content"
+}
+
+func (noContentReply) writeReply(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type NavigationAPI struct{}
+
+func (h *NavigationAPI) Login(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return Redirect("/login?next="+args.ID, fasthttp.StatusSeeOther)
+}
+
+func (h *NavigationAPI) Ping(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return NoContent
+}
+
+func TestRedirect(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(NavigationAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	committed := false
+	status, _, _ := as.callLocalWith("NavigationAPI.Login", []byte(`{"id":"home"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		AttachTx(ctx, "probe", TxHooks{OnCommit: func() error {
+			committed = true
+			return nil
+		}})
+	})
+	if status != fasthttp.StatusSeeOther || !committed {
+		t.Error(fmt.Sprintf("wrong redirect: %d %t", status, committed))
+	}
+
+	status, body, _ := as.callLocal("NavigationAPI.Ping", []byte(`{}`))
+	if status != fasthttp.StatusNoContent || len(body) != 0 {
+		t.Error(fmt.Sprintf("wrong no content reply: %d %s", status, body))
+	}
+}
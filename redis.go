@@ -0,0 +1,109 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// RedisMessage is a message received from a subscribed channel
+type RedisMessage struct {
+	Channel string
+	Payload []byte
+}
+
+// RedisPubSub is a thin adapter over a Redis client pub/sub API, e.g. go-redis.
+// The returned channel is closed when the subscription ends.
+type RedisPubSub interface {
+	Subscribe(ctx context.Context, channels ...string) (<-chan RedisMessage, error)
+	Publish(ctx context.Context, channel string, payload []byte) error
+}
+
+// RedisCommand is the payload of invocation messages
+type RedisCommand struct {
+	ID     string          `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	// ReplyTo overrides the results channel of the listener
+	ReplyTo string `json:"reply_to,omitempty"`
+}
+
+// RedisResult is published after command execution
+type RedisResult struct {
+	ID       string          `json:"id,omitempty"`
+	Instance string          `json:"instance"`
+	Method   string          `json:"method"`
+	Status   int             `json:"status"`
+	Body     json.RawMessage `json:"body,omitempty"`
+}
+
+// RedisCommandListener invokes registered methods from RedisCommand messages.
+//
+// Every instance subscribed to a channel executes its commands, which makes it
+// suitable for fan-out tasks like cache invalidation and ops tooling. Results
+// are published to ResultsChannel (or the command ReplyTo) tagged with Instance.
+type RedisCommandListener struct {
+	// ResultsChannel receives results, empty disables them
+	ResultsChannel string
+	// Instance identifies this process in results, hostname by default
+	Instance string
+
+	as     *VAPI
+	pubsub RedisPubSub
+}
+
+// NewRedisCommandListener returns listener executing commands received through pubsub
+func (as *VAPI) NewRedisCommandListener(pubsub RedisPubSub, resultsChannel string) *RedisCommandListener {
+	instance, _ := os.Hostname()
+	return &RedisCommandListener{ResultsChannel: resultsChannel, Instance: instance, as: as, pubsub: pubsub}
+}
+
+// Listen executes commands of channels until ctx is done or the subscription ends
+func (rl *RedisCommandListener) Listen(ctx context.Context, channels ...string) error {
+	messages, err := rl.pubsub.Subscribe(ctx, channels...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			rl.execute(ctx, message)
+		}
+	}
+}
+
+// execute invokes command of message and publishes the result
+func (rl *RedisCommandListener) execute(ctx context.Context, message RedisMessage) {
+	command := RedisCommand{}
+	result := RedisResult{Instance: rl.Instance}
+	if err := json.Unmarshal(message.Payload, &command); err != nil || command.Method == "" {
+		result.Status = 400
+		result.Body, _ = json.Marshal(map[string]string{"error": "malformed command"})
+	} else {
+		args := []byte(command.Args)
+		if len(args) == 0 {
+			args = []byte("{}")
+		}
+		var body []byte
+		result.Status, body, _ = rl.as.callLocal(command.Method, args)
+		result.Body = body
+	}
+	result.ID, result.Method = command.ID, command.Method
+
+	channel := rl.ResultsChannel
+	if command.ReplyTo != "" {
+		channel = command.ReplyTo
+	}
+	if channel == "" {
+		return
+	}
+	if payload, err := json.Marshal(result); err == nil {
+		rl.pubsub.Publish(ctx, channel, payload)
+	}
+}
@@ -0,0 +1,51 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type fakePubSub struct {
+	messages  chan RedisMessage
+	published map[string][]string
+}
+
+func (fp *fakePubSub) Subscribe(ctx context.Context, channels ...string) (<-chan RedisMessage, error) {
+	return fp.messages, nil
+}
+
+func (fp *fakePubSub) Publish(ctx context.Context, channel string, payload []byte) error {
+	fp.published[channel] = append(fp.published[channel], string(payload))
+	return nil
+}
+
+func TestRedisCommandListener(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	fp := &fakePubSub{messages: make(chan RedisMessage, 3), published: map[string][]string{}}
+	fp.messages <- RedisMessage{Channel: "commands", Payload: []byte(`{"id":"1","method":"demo.Test","args":{"id":"x"}}`)}
+	fp.messages <- RedisMessage{Channel: "commands", Payload: []byte(`{"id":"2","method":"demo.Test","reply_to":"ops"}`)}
+	fp.messages <- RedisMessage{Channel: "commands", Payload: []byte(`garbage`)}
+	close(fp.messages)
+
+	listener := as.NewRedisCommandListener(fp, "results")
+	listener.Instance = "node-1"
+	if err := listener.Listen(context.Background(), "commands"); err != nil {
+		t.Fatal(err)
+	}
+
+	results := fp.published["results"]
+	if len(results) != 2 || results[0] != `{"id":"1","instance":"node-1","method":"demo.Test","status":200,"body":{"response":{"id":"x"}}}` {
+		t.Error(fmt.Sprintf("wrong results: %v", results))
+	}
+	if len(results) == 2 && results[1] != `{"instance":"node-1","method":"","status":400,"body":{"error":"malformed command"}}` {
+		t.Error(fmt.Sprintf("wrong malformed command result: %s", results[1]))
+	}
+	if len(fp.published["ops"]) != 1 {
+		t.Error("result must be published to reply_to channel")
+	}
+}
@@ -0,0 +1,114 @@
+package vapi
+
+import (
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ForwardedRegionHeader marks calls proxied from another region, they are never proxied again
+const ForwardedRegionHeader = "X-Vapi-Forwarded-Region"
+
+// statusMisdirectedRequest is 421 Misdirected Request, missing from fasthttp status codes
+const statusMisdirectedRequest = 421
+
+// RegionOptions configures data residency of a multi-region deployment
+type RegionOptions struct {
+	// Local is the region of this server, e.g. "eu"
+	Local string
+	// Endpoints are base urls of regional servers, e.g. {"us": "https://us.api.example.com"}
+	Endpoints map[string]string
+	// Tenant returns the home region of the caller, empty when the caller isn't pinned
+	Tenant func(ctx *fasthttp.RequestCtx) string
+	// Proxy forwards calls of other regions to their endpoint, otherwise they
+	// are rejected with 421 and the home region in the error data
+	Proxy bool
+	// Timeout of proxied calls, 10 seconds when zero
+	Timeout time.Duration
+}
+
+// RegionRedirect is the error data of calls rejected for landing in a wrong region
+type RegionRedirect struct {
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// SetRegions enables region pinning, empty Local disables it
+func (as *VAPI) SetRegions(options RegionOptions) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if options.Local == "" {
+		as.regions = nil
+		return
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 10 * time.Second
+	}
+	as.regions = &options
+}
+
+// SetMethodRegion pins the method to region regardless of the tenant, empty region unpins it
+func (as *VAPI) SetMethodRegion(method, region string) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.region = region
+	as.mutex.Unlock()
+	return nil
+}
+
+// routeRegion proxies or rejects calls of other regions, returns false when the call was handled here
+func (as *VAPI) routeRegion(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	regions, home := as.regions, methodSpec.region
+	as.mutex.RUnlock()
+	if regions == nil {
+		return true
+	}
+	if home == "" && regions.Tenant != nil {
+		home = regions.Tenant(ctx)
+	}
+	if home == "" || home == regions.Local {
+		return true
+	}
+
+	endpoint := regions.Endpoints[home]
+	if regions.Proxy && endpoint != "" && len(ctx.Request.Header.Peek(ForwardedRegionHeader)) == 0 {
+		if err := forwardCall(ctx, endpoint, regions.Local, regions.Timeout); err != nil {
+			as.writeError(ctx, srvResponse, fasthttp.StatusBadGateway, err)
+		}
+		return false
+	}
+
+	errAPI := acquireError()
+	errAPI.ErrorHTTPCode = statusMisdirectedRequest
+	errAPI.ErrorMessage = "vapi: call belongs to region " + home
+	errAPI.Data = RegionRedirect{Region: home, Endpoint: endpoint}
+	srvResponse.Error = errAPI
+	as.writeResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
+	releaseError(errAPI)
+	return false
+}
+
+// forwardCall sends the request to the same path at endpoint and copies the response back
+func forwardCall(ctx *fasthttp.RequestCtx, endpoint, from string, timeout time.Duration) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	ctx.Request.CopyTo(req)
+	req.SetRequestURI(strings.TrimSuffix(endpoint, "/") + string(ctx.RequestURI()))
+	req.Header.Set(ForwardedRegionHeader, from)
+	req.Header.Set("X-Forwarded-For", ctx.RemoteIP().String())
+
+	if err := fasthttp.DoTimeout(req, resp, timeout); err != nil {
+		return err
+	}
+	resp.CopyTo(&ctx.Response)
+	return nil
+}
@@ -0,0 +1,60 @@
+package vapi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetRegions(t *testing.T) {
+	us := NewServer()
+	us.RegisterService(new(DemoAPI), "demo")
+	us.SetRegions(RegionOptions{Local: "us", Tenant: HeaderPrincipal("X-Region")})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go us.Server("/").Serve(ln)
+
+	eu := NewServer()
+	eu.RegisterService(new(DemoAPI), "demo")
+	options := RegionOptions{
+		Local:     "eu",
+		Endpoints: map[string]string{"us": "http://" + ln.Addr().String()},
+		Tenant:    HeaderPrincipal("X-Region"),
+	}
+	eu.SetRegions(options)
+
+	call := func(region string) (int, string) {
+		status, body, _ := eu.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.Set("X-Region", region)
+		})
+		return status, string(body)
+	}
+
+	if status, _ := call("eu"); status != 200 {
+		t.Error(fmt.Sprintf("local tenant must be served: %d", status))
+	}
+	if status, body := call("us"); status != 421 || !strings.Contains(body, `"region":"us"`) {
+		t.Error(fmt.Sprintf("foreign tenant must be redirected: %d %s", status, body))
+	}
+
+	options.Proxy = true
+	eu.SetRegions(options)
+	if status, body := call("us"); status != 200 || !strings.Contains(body, `"id"`) {
+		t.Error(fmt.Sprintf("foreign tenant must be proxied: %d %s", status, body))
+	}
+	if us.Stats()[1].Calls != 1 {
+		t.Error(fmt.Sprintf("proxied call must reach the home region: %+v", us.Stats()[1]))
+	}
+
+	eu.SetMethodRegion("demo.Test", "us")
+	us.SetMethodRegion("demo.Test", "eu")
+	if status, _ := call(""); status != 421 {
+		t.Error(fmt.Sprintf("forwarded call must not be proxied again: %d", status))
+	}
+}
@@ -0,0 +1,152 @@
+package vapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ResourcePingTimeout limits every resource ping made by readiness checks
+var ResourcePingTimeout = time.Second
+
+// Resource is a connection used by services (database, cache, queue)
+type Resource interface {
+	// Ping checks that the resource is reachable
+	Ping(ctx context.Context) error
+	// Stats returns pool counters, e.g. open and idle connections
+	Stats() map[string]int64
+}
+
+// resource is a named Resource
+type resource struct {
+	name     string
+	resource Resource
+}
+
+// ResourceStatus is the health and pool counters of a resource
+type ResourceStatus struct {
+	Name    string           `json:"name"`
+	Healthy bool             `json:"healthy"`
+	Error   string           `json:"error,omitempty"`
+	Latency time.Duration    `json:"latency"`
+	Stats   map[string]int64 `json:"stats,omitempty"`
+}
+
+// RegisterResource adds resource checked by ReadinessHandler and reported by Resources
+func (as *VAPI) RegisterResource(name string, r Resource) error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	for _, registered := range as.resources {
+		if registered.name == name {
+			return fmt.Errorf("vapi: resource already defined: %q", name)
+		}
+	}
+	as.resources = append(as.resources, resource{name: name, resource: r})
+	return nil
+}
+
+// Resources pings every registered resource and returns statuses sorted by name
+func (as *VAPI) Resources() []ResourceStatus {
+	as.mutex.RLock()
+	resources := as.resources
+	as.mutex.RUnlock()
+
+	statuses := make([]ResourceStatus, len(resources))
+	done := make(chan struct{}, len(resources))
+	for i := range resources {
+		go func(i int) {
+			statuses[i] = checkResource(resources[i])
+			done <- struct{}{}
+		}(i)
+	}
+	for range resources {
+		<-done
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// ResourcesHandler serves Resources as json, responds 503 when any resource is unhealthy
+func (as *VAPI) ResourcesHandler(ctx *fasthttp.RequestCtx) {
+	statuses := as.Resources()
+	body, err := json.Marshal(statuses)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	for _, status := range statuses {
+		if !status.Healthy {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			break
+		}
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// pingResources returns error naming unhealthy resources
+func (as *VAPI) pingResources() error {
+	var failed []string
+	for _, status := range as.Resources() {
+		if !status.Healthy {
+			failed = append(failed, status.Name+": "+status.Error)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("vapi: resources are unhealthy: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// checkResource pings resource and collects its stats
+func checkResource(r resource) ResourceStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), ResourcePingTimeout)
+	defer cancel()
+
+	status := ResourceStatus{Name: r.name, Healthy: true}
+	started := time.Now()
+	if err := r.resource.Ping(ctx); err != nil {
+		status.Healthy, status.Error = false, err.Error()
+	}
+	status.Latency = time.Since(started)
+	status.Stats = r.resource.Stats()
+	return status
+}
+
+// sqlResource adapts *sql.DB to Resource
+type sqlResource struct {
+	db *sql.DB
+}
+
+// SQLResource returns Resource reporting *sql.DB pool statistics
+func SQLResource(db *sql.DB) Resource {
+	return sqlResource{db: db}
+}
+
+// Ping implements Resource
+func (r sqlResource) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Stats implements Resource
+func (r sqlResource) Stats() map[string]int64 {
+	stats := r.db.Stats()
+	return map[string]int64{
+		"max_open":       int64(stats.MaxOpenConnections),
+		"open":           int64(stats.OpenConnections),
+		"in_use":         int64(stats.InUse),
+		"idle":           int64(stats.Idle),
+		"wait_count":     stats.WaitCount,
+		"wait_ms":        stats.WaitDuration.Milliseconds(),
+		"max_idle_close": stats.MaxIdleClosed,
+		"max_life_close": stats.MaxLifetimeClosed,
+	}
+}
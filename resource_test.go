@@ -0,0 +1,53 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type fakeResource struct {
+	err error
+}
+
+func (r *fakeResource) Ping(ctx context.Context) error { return r.err }
+
+func (r *fakeResource) Stats() map[string]int64 { return map[string]int64{"open": 3} }
+
+func TestVAPI_Resources(t *testing.T) {
+	as := NewServer()
+	if err := as.WarmUp(); err != nil {
+		t.Fatal(err)
+	}
+	cache := &fakeResource{}
+	if err := as.RegisterResource("db", &fakeResource{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RegisterResource("cache", cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RegisterResource("db", &fakeResource{}); err == nil {
+		t.Error("duplicate resource must be rejected")
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	as.ReadinessHandler(ctx)
+	if ctx.Response.StatusCode() != 200 {
+		t.Error(fmt.Sprintf("healthy resources must be ready, got %d", ctx.Response.StatusCode()))
+	}
+
+	cache.err = errors.New("connection refused")
+	statuses := as.Resources()
+	if len(statuses) != 2 || statuses[0].Name != "cache" || statuses[0].Healthy || statuses[1].Stats["open"] != 3 {
+		t.Error(fmt.Sprintf("wrong statuses: %+v", statuses))
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	as.ReadinessHandler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Error(fmt.Sprintf("unhealthy resource must fail readiness, got %d", ctx.Response.StatusCode()))
+	}
+}
@@ -0,0 +1,161 @@
+package vapi
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultRetryHeaders are request headers recognized as client retry markers,
+// a positive value means the request is a retry
+var DefaultRetryHeaders = []string{"X-Retry-Attempt", "X-Retry-Count", "Grpc-Previous-Rpc-Attempts"}
+
+var errRetryBudgetExceeded = errors.New("vapi: retry budget exceeded, slow down")
+
+// RetryBudget limits the share of retried requests per principal.
+//
+// Within every Window a principal may send MinRetries retries plus Ratio
+// retries per original request; retries above the budget are rejected with
+// 429 until the window ends. Stats lists principals by retry volume, so
+// clients retrying in tight loops are easy to identify.
+type RetryBudget struct {
+	// Ratio is the number of retries allowed per original request, e.g. 0.1
+	Ratio float64
+	// MinRetries is the number of retries always allowed per window
+	MinRetries int
+	// Window is the budget accounting period
+	Window time.Duration
+	// Headers are the recognized retry headers
+	Headers []string
+
+	principal PrincipalFunc
+	now       func() time.Time
+
+	mutex     sync.Mutex
+	entries   map[string]*retryEntry
+	lastSweep time.Time
+}
+
+// retryEntry holds principal counters
+type retryEntry struct {
+	windowStart time.Time
+	requests    int
+	retries     int
+	stats       RetryStats
+}
+
+// RetryStats holds cumulative counters of a principal
+type RetryStats struct {
+	Principal string
+	Requests  uint64
+	Retries   uint64
+	Rejected  uint64
+}
+
+// NewRetryBudget returns budget allowing ratio retries per request in every window
+func NewRetryBudget(ratio float64, minRetries int, window time.Duration, principal PrincipalFunc) *RetryBudget {
+	return &RetryBudget{
+		Ratio:      ratio,
+		MinRetries: minRetries,
+		Window:     window,
+		Headers:    DefaultRetryHeaders,
+		principal:  principal,
+		now:        time.Now,
+		entries:    make(map[string]*retryEntry),
+	}
+}
+
+// Handler wraps next with retry budget enforcement
+func (rb *RetryBudget) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if retryAfter, ok := rb.allow(rb.principal(ctx), rb.isRetry(ctx)); !ok {
+			ctx.Response.Header.Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)+1))
+			writeHandlerError(ctx, fasthttp.StatusTooManyRequests, errRetryBudgetExceeded)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// Stats returns counters of known principals ordered by retries, most retrying first
+func (rb *RetryBudget) Stats() []RetryStats {
+	rb.mutex.Lock()
+	stats := make([]RetryStats, 0, len(rb.entries))
+	for _, entry := range rb.entries {
+		stats = append(stats, entry.stats)
+	}
+	rb.mutex.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Retries == stats[j].Retries {
+			return stats[i].Principal < stats[j].Principal
+		}
+		return stats[i].Retries > stats[j].Retries
+	})
+	return stats
+}
+
+// isRetry checks retry headers of the request
+func (rb *RetryBudget) isRetry(ctx *fasthttp.RequestCtx) bool {
+	for _, header := range rb.Headers {
+		value := ctx.Request.Header.Peek(header)
+		if len(value) == 0 {
+			continue
+		}
+		if attempt, err := strconv.Atoi(string(value)); err == nil && attempt > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allow accounts request of principal, returns time left in the window when request is rejected
+func (rb *RetryBudget) allow(principal string, retry bool) (time.Duration, bool) {
+	now := rb.now()
+
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	rb.sweep(now)
+
+	entry, ok := rb.entries[principal]
+	if !ok {
+		entry = &retryEntry{windowStart: now, stats: RetryStats{Principal: principal}}
+		rb.entries[principal] = entry
+	}
+	if now.Sub(entry.windowStart) >= rb.Window {
+		entry.windowStart, entry.requests, entry.retries = now, 0, 0
+	}
+
+	entry.stats.Requests++
+	if !retry {
+		entry.requests++
+		return 0, true
+	}
+
+	entry.stats.Retries++
+	budget := rb.MinRetries + int(float64(entry.requests)*rb.Ratio)
+	if entry.retries >= budget {
+		entry.stats.Rejected++
+		return rb.Window - now.Sub(entry.windowStart), false
+	}
+	entry.retries++
+	return 0, true
+}
+
+// sweep forgets principals idle for more than two windows. Called under mutex.
+func (rb *RetryBudget) sweep(now time.Time) {
+	if now.Sub(rb.lastSweep) < rb.Window {
+		return
+	}
+	rb.lastSweep = now
+	for principal, entry := range rb.entries {
+		if now.Sub(entry.windowStart) >= 2*rb.Window {
+			delete(rb.entries, principal)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	rb := NewRetryBudget(0.5, 1, time.Minute, nil)
+	rb.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, ok := rb.allow("bot", false); !ok {
+			t.Fatal("original requests must be allowed")
+		}
+	}
+
+	// budget is 1 + 2*0.5 = 2 retries
+	for i := 0; i < 2; i++ {
+		if _, ok := rb.allow("bot", true); !ok {
+			t.Error(fmt.Sprintf("retry %d must be allowed", i))
+		}
+	}
+	if _, ok := rb.allow("bot", true); ok {
+		t.Error("retry over budget must be rejected")
+	}
+	if _, ok := rb.allow("human", true); !ok {
+		t.Error("budget must be accounted per principal")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := rb.allow("bot", true); !ok {
+		t.Error("budget must be restored in the next window")
+	}
+
+	stats := rb.Stats()
+	if len(stats) != 2 || stats[0].Principal != "bot" || stats[0].Retries != 4 || stats[0].Rejected != 1 {
+		t.Error(fmt.Sprintf("wrong stats: %+v", stats))
+	}
+}
@@ -0,0 +1,175 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RoutedMethodHeader is the response header naming the method which served a rerouted call
+const RoutedMethodHeader = "X-Vapi-Routed-To"
+
+// Rollout sends calls of a method to an alternate implementation. A call is
+// rerouted when any of the conditions matches.
+type Rollout struct {
+	// Target is the alternate method, it must have the same args and reply types
+	Target string `json:"target"`
+	// Header and HeaderValue reroute calls carrying the header with the value,
+	// any non-empty value when HeaderValue is empty
+	Header      string `json:"header,omitempty"`
+	HeaderValue string `json:"header_value,omitempty"`
+	// Cohort reroutes calls of the listed principals
+	Cohort []string `json:"cohort,omitempty"`
+	// Percent reroutes the share (0 to 100) of principals, a principal stays in
+	// or out of the rollout while the percent grows; calls without principal
+	// are sampled randomly
+	Percent float64 `json:"percent,omitempty"`
+}
+
+// rolloutRule is the RolloutsHandler request body
+type rolloutRule struct {
+	Method  string   `json:"method"`
+	Rollout *Rollout `json:"rollout"`
+}
+
+// SetRolloutPrincipal sets the principal used by Rollout cohorts and percentages
+func (as *VAPI) SetRolloutPrincipal(principal PrincipalFunc) {
+	as.mutex.Lock()
+	as.rolloutPrincipal = principal
+	as.mutex.Unlock()
+}
+
+// SetMethodRollout reroutes calls of the method by rollout, nil removes it.
+// Rerouted calls are served, validated and accounted as calls of the target.
+func (as *VAPI) SetMethodRollout(method string, rollout *Rollout) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	if rollout != nil {
+		target, err := as.get(rollout.Target)
+		if err != nil {
+			return err
+		}
+		if target == methodSpec {
+			return fmt.Errorf("vapi: %s can't be rolled out to itself", method)
+		}
+		if target.argsType != methodSpec.argsType || target.replyType != methodSpec.replyType {
+			return fmt.Errorf("vapi: %s and %s have different args or reply types", method, rollout.Target)
+		}
+		if rollout.Percent < 0 || rollout.Percent > 100 {
+			return fmt.Errorf("vapi: rollout percent of %s must be from 0 to 100", method)
+		}
+	}
+
+	as.mutex.Lock()
+	methodSpec.rollout = rollout
+	as.mutex.Unlock()
+	return nil
+}
+
+// Rollouts returns rollouts by method name
+func (as *VAPI) Rollouts() map[string]Rollout {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	rollouts := map[string]Rollout{}
+	for name, methodSpec := range as.methods {
+		if methodSpec.rollout != nil {
+			rollouts[name] = *methodSpec.rollout
+		}
+	}
+	return rollouts
+}
+
+// RolloutsHandler is the admin api of rollouts, mount it behind admin authentication:
+// GET lists rollouts, POST sets {"method": "Service.Method", "rollout": {...}} and
+// DELETE with ?method=Service.Method removes the rollout.
+func (as *VAPI) RolloutsHandler(ctx *fasthttp.RequestCtx) {
+	switch {
+	case ctx.IsPost() || ctx.IsPut():
+		rule := rolloutRule{}
+		if err := json.Unmarshal(ctx.PostBody(), &rule); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusBadRequest, err)
+			return
+		}
+		if err := as.SetMethodRollout(rule.Method, rule.Rollout); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusBadRequest, err)
+			return
+		}
+	case ctx.IsDelete():
+		if err := as.SetMethodRollout(string(ctx.QueryArgs().Peek("method")), nil); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusNotFound, err)
+			return
+		}
+	}
+
+	rollouts := as.Rollouts()
+	rules := make([]rolloutRule, 0, len(rollouts))
+	for method := range rollouts {
+		rollout := rollouts[method]
+		rules = append(rules, rolloutRule{Method: method, Rollout: &rollout})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Method < rules[j].Method })
+
+	body, err := json.Marshal(rules)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// route returns the method serving the call, methodSpec itself unless a rollout matches
+func (as *VAPI) route(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod) *serviceMethod {
+	as.mutex.RLock()
+	rollout, principal := methodSpec.rollout, as.rolloutPrincipal
+	var target *serviceMethod
+	if rollout != nil {
+		target = as.methods[rollout.Target]
+	}
+	as.mutex.RUnlock()
+	if target == nil || !rollout.matches(ctx, principal) {
+		return methodSpec
+	}
+
+	ctx.Response.Header.Set(RoutedMethodHeader, target.name)
+	return target
+}
+
+// matches reports whether the call is rerouted
+func (rollout *Rollout) matches(ctx *fasthttp.RequestCtx, principal PrincipalFunc) bool {
+	if rollout.Header != "" {
+		value := string(ctx.Request.Header.Peek(rollout.Header))
+		if value != "" && (rollout.HeaderValue == "" || value == rollout.HeaderValue) {
+			return true
+		}
+	}
+
+	id := ""
+	if principal != nil {
+		id = principal(ctx)
+	}
+	if id != "" {
+		for _, member := range rollout.Cohort {
+			if member == id {
+				return true
+			}
+		}
+	}
+
+	if rollout.Percent <= 0 {
+		return false
+	}
+	if id == "" {
+		return rand.Float64()*100 < rollout.Percent
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return float64(hash.Sum32()%10000) < rollout.Percent*100
+}
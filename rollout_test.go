@@ -0,0 +1,74 @@
+package vapi
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_RolloutsHandler(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(CanaryAPI), "canary")
+	as.SetRolloutPrincipal(HeaderPrincipal("X-User"))
+
+	if err := as.SetMethodRollout("canary.Old", &Rollout{Target: "canary.Old"}); err == nil {
+		t.Error("rollout to itself must fail")
+	}
+	if err := as.SetMethodRollout("canary.Old", &Rollout{Target: "canary.New", Percent: 150}); err == nil {
+		t.Error("percent over 100 must fail")
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(`{"method":"canary.Old","rollout":{"target":"canary.New","header":"X-Beta","cohort":["alice"]}}`)
+	as.RolloutsHandler(ctx)
+	if ctx.Response.StatusCode() != 200 {
+		t.Fatal(fmt.Sprintf("can't set rollout: %s", ctx.Response.Body()))
+	}
+
+	call := func(header, value string) (string, string) {
+		var response *fasthttp.RequestCtx
+		_, body, _ := as.callLocalWith("canary.Old", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+			if header != "" {
+				ctx.Request.Header.Set(header, value)
+			}
+		})
+		return string(body), string(response.Response.Header.Peek(RoutedMethodHeader))
+	}
+	if body, routed := call("", ""); routed != "" || body != `{"response":{"id":"1"}}` {
+		t.Error(fmt.Sprintf("call must not be rerouted: %s %s", routed, body))
+	}
+	if body, routed := call("X-Beta", "1"); routed != "canary.New" || body != `{"response":{"id":"1","ttt":"new"}}` {
+		t.Error(fmt.Sprintf("beta header must reroute: %s %s", routed, body))
+	}
+	if _, routed := call("X-User", "alice"); routed != "canary.New" {
+		t.Error("cohort member must be rerouted")
+	}
+
+	as.SetMethodRollout("canary.Old", &Rollout{Target: "canary.New", Percent: 30})
+	rerouted := 0
+	for i := 0; i < 1000; i++ {
+		user := "user" + strconv.Itoa(i)
+		_, first := call("X-User", user)
+		if _, second := call("X-User", user); first != second {
+			t.Fatal("principal must stay in or out of the rollout")
+		}
+		if first != "" {
+			rerouted++
+		}
+	}
+	if rerouted < 200 || rerouted > 400 {
+		t.Error(fmt.Sprintf("30%% rollout rerouted %d of 1000", rerouted))
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("DELETE")
+	ctx.Request.SetRequestURI("/rollouts?method=canary.Old")
+	as.RolloutsHandler(ctx)
+	if string(ctx.Response.Body()) != `[]` {
+		t.Error(fmt.Sprintf("rollout is not removed: %s", ctx.Response.Body()))
+	}
+}
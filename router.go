@@ -0,0 +1,46 @@
+package vapi
+
+import (
+	"bytes"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Handler returns request handler calling the method named by the request path
+// after prefix, e.g. "/api/Users.Get" with prefix "/api/".
+//
+// Known methods are resolved from the path bytes without allocations, unknown
+// ones get the same 404 reply as from CallAPI.
+func (as *VAPI) Handler(prefix string) fasthttp.RequestHandler {
+	prefixBytes := []byte(prefix)
+	return func(ctx *fasthttp.RequestCtx) {
+		path := ctx.Path()
+		if !bytes.HasPrefix(path, prefixBytes) {
+			as.CallAPI(ctx, string(path))
+			return
+		}
+		name := path[len(prefixBytes):]
+
+		as.mutex.RLock()
+		methodSpec := as.methods[string(name)]
+		as.mutex.RUnlock()
+
+		if methodSpec == nil {
+			as.CallAPI(ctx, string(name))
+			return
+		}
+		as.serve(ctx, methodSpec, nil)
+	}
+}
+
+// Server returns fasthttp server serving methods under prefix with Handler.
+// Callers may tune its limits (Concurrency, ReadTimeout, MaxRequestBodySize)
+// before calling Serve or ListenAndServe. MaxRequestBodySize starts at the
+// per request limit of SetMemoryLimit when it is set.
+func (as *VAPI) Server(prefix string) *fasthttp.Server {
+	return &fasthttp.Server{
+		Name:               "vapi",
+		Handler:            as.Handler(prefix),
+		MaxRequestBodySize: as.maxBodySize(0),
+	}
+}
@@ -0,0 +1,83 @@
+package vapi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestVAPI_Handler(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	handler := as.Handler("/api/")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/demo.Test")
+	ctx.Request.SetBodyString(`{"id":"1"}`)
+	handler(ctx)
+	if ctx.Response.StatusCode() != 200 || string(ctx.Response.Body()) != `{"response":{"id":"1"}}` {
+		t.Error(fmt.Sprintf("wrong reply: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/demo.Missing")
+	handler(ctx)
+	if ctx.Response.StatusCode() != 404 {
+		t.Error(fmt.Sprintf("unknown method must be 404, got %d", ctx.Response.StatusCode()))
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { as.get("demo.Test") }); allocs != 0 {
+		t.Error(fmt.Sprintf("method lookup allocates %v times", allocs))
+	}
+}
+
+func TestVAPI_Server(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	server := as.Server("/api/")
+	if server.Name != "vapi" {
+		t.Error(fmt.Sprintf("wrong server name: %q", server.Name))
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go server.Serve(ln)
+	client := &fasthttp.Client{Dial: func(addr string) (net.Conn, error) { return ln.Dial() }}
+
+	call := func(path, body string) (int, string) {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.Header.SetMethod("POST")
+		req.SetRequestURI("http://vapi" + path)
+		req.SetBodyString(body)
+		if err := client.Do(req, resp); err != nil {
+			t.Fatal(err)
+		}
+		if server := string(resp.Header.Server()); server != "vapi" {
+			t.Error(fmt.Sprintf("wrong server header of %s: %q", path, server))
+		}
+		return resp.StatusCode(), string(resp.Body())
+	}
+
+	if status, body := call("/api/demo.Test", `{"id":"1"}`); status != 200 || body != `{"response":{"id":"1"}}` {
+		t.Error(fmt.Sprintf("mounted method must be served: %d %s", status, body))
+	}
+	if status, body := call("/api/demo.Missing", `{}`); status != 404 || !strings.Contains(body, `can't find method \"Missing\"`) {
+		t.Error(fmt.Sprintf("unknown method must fall through to CallAPI: %d %s", status, body))
+	}
+	if status, body := call("/api/other.Test", `{}`); status != 404 || !strings.Contains(body, `service not found: \"other\"`) {
+		t.Error(fmt.Sprintf("unknown service must fall through to CallAPI: %d %s", status, body))
+	}
+	if status, body := call("/demo.Test", `{"id":"1"}`); status != 404 || !strings.Contains(body, `service not found: \"/demo\"`) {
+		t.Error(fmt.Sprintf("paths outside of the prefix must not be served: %d %s", status, body))
+	}
+}
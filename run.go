@@ -0,0 +1,157 @@
+package vapi
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RunOptions configures Run
+type RunOptions struct {
+	// Addr is the tcp address to listen on, ":8080" when empty
+	Addr string
+	// Listener is used instead of Addr when set. Otherwise systemd activated
+	// and inherited (see Listen) sockets are preferred over Addr.
+	Listener net.Listener
+	// Prefix of method paths, "/" when empty
+	Prefix string
+	// Handler serves requests instead of Handler(Prefix), e.g. with middleware around it
+	Handler fasthttp.RequestHandler
+	// TLSCert and TLSKey are certificate and key files, plain http when empty
+	TLSCert string
+	TLSKey  string
+	// Configure is called with the server before it starts serving, to tune its limits
+	Configure func(server *fasthttp.Server)
+	// LameDuck is how long readiness reports not-ready before the listener is
+	// closed, so load balancers stop sending traffic first
+	LameDuck time.Duration
+	// DrainTimeout limits the wait for in-flight calls and worker pool jobs, 30 seconds when zero
+	DrainTimeout time.Duration
+	// Stop is an additional shutdown trigger, e.g. the stop request of a Windows
+	// service control handler
+	Stop <-chan struct{}
+}
+
+// shutdownSignals are the signals Run shuts down on; Ctrl+C and console close arrive
+// as os.Interrupt and SIGTERM on Windows too
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Run serves the api until SIGINT, SIGTERM or options.Stop and shuts down gracefully:
+// the server warms up, serves, enters lame duck state, waits options.LameDuck,
+// stops accepting connections and waits for in-flight calls and then for jobs of
+// the worker pool, all within options.DrainTimeout. It replaces the
+// usual main() boilerplate:
+//
+//	api := vapi.NewServer()
+//	api.RegisterService(new(Users), "")
+//	log.Fatal(api.Run(vapi.RunOptions{Addr: ":8080", LameDuck: 5 * time.Second}))
+func (as *VAPI) Run(options RunOptions) error {
+	ln, err := runListener(options)
+	if err != nil {
+		return err
+	}
+
+	prefix := options.Prefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	server := as.Server(prefix)
+	if options.Handler != nil {
+		server.Handler = options.Handler
+	}
+	handler := server.Handler
+	server.Handler = func(ctx *fasthttp.RequestCtx) {
+		handler(ctx)
+		if as.State() == StateLameDuck {
+			// release keep-alive connections, so shutdown isn't held by them
+			ctx.SetConnectionClose()
+		}
+	}
+	// fasthttp shutdown waits for idle keep-alive connections until they time out
+	server.ReadTimeout = 10 * time.Second
+	if options.Configure != nil {
+		options.Configure(server)
+	}
+
+	if err = as.WarmUp(); err != nil {
+		ln.Close()
+		return err
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if options.TLSCert != "" {
+			served <- server.ServeTLS(ln, options.TLSCert, options.TLSKey)
+			return
+		}
+		served <- server.Serve(ln)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+	defer signal.Stop(signals)
+
+	select {
+	case err = <-served:
+		return err
+	case <-signals:
+	case <-options.Stop:
+	}
+
+	as.EnterLameDuck()
+	time.Sleep(options.LameDuck)
+
+	drainTimeout := options.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(drainTimeout)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown()
+	}()
+	select {
+	case err = <-shutdown:
+		if err != nil {
+			return err
+		}
+	case <-time.After(drainTimeout):
+		return errors.New("vapi: drain timed out with open connections")
+	}
+
+	if !as.WaitIdle(time.Until(deadline)) {
+		return errors.New("vapi: drain timed out with calls in flight")
+	}
+	if !as.Workers().Drain(time.Until(deadline)) {
+		return errors.New("vapi: drain timed out with background jobs running")
+	}
+	return nil
+}
+
+// runListener returns the listener of options, systemd or inherited one or a new one on Addr
+func runListener(options RunOptions) (net.Listener, error) {
+	if options.Listener != nil {
+		return options.Listener, nil
+	}
+	listeners, err := SystemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		for _, extra := range listeners[1:] {
+			extra.Close()
+		}
+		return listeners[0], nil
+	}
+	addr := options.Addr
+	if addr == "" {
+		addr = ":8080"
+	}
+	return Listen("tcp", addr)
+}
@@ -0,0 +1,75 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_Run(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- as.Run(RunOptions{
+			Listener:  ln,
+			Stop:      stop,
+			LameDuck:  10 * time.Millisecond,
+			Configure: func(server *fasthttp.Server) { server.ReadTimeout = 100 * time.Millisecond },
+		})
+	}()
+
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://" + ln.Addr().String() + "/demo.Test")
+	req.Header.SetMethod("POST")
+	req.SetBodyString(`{"id":"1"}`)
+	for i := 0; ; i++ {
+		if err = fasthttp.DoTimeout(req, resp, time.Second); err == nil || i == 50 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || resp.StatusCode() != 200 || as.State() != StateReady {
+		t.Fatal(fmt.Sprintf("running server must serve: %v %d %s", err, resp.StatusCode(), as.State()))
+	}
+
+	jobDone := make(chan struct{})
+	as.Go(context.Background(), func(ctx context.Context) {
+		time.Sleep(50 * time.Millisecond)
+		close(jobDone)
+	})
+
+	close(stop)
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+		select {
+		case <-jobDone:
+		default:
+			t.Error("shutdown must wait for background jobs")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop must shut the server down")
+	}
+	if as.State() != StateLameDuck {
+		t.Error(fmt.Sprintf("stopped server must be in lame duck state: %s", as.State()))
+	}
+	if err = fasthttp.DoTimeout(req, resp, time.Second); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Error(fmt.Sprintf("stopped server must not accept connections: %v", err))
+	}
+}
@@ -0,0 +1,116 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SagaAction is a step or its compensation
+type SagaAction func(ctx context.Context) error
+
+// sagaStep is a named action with its compensation
+type sagaStep struct {
+	name       string
+	action     SagaAction
+	compensate SagaAction
+}
+
+// Saga runs steps in order and compensates completed steps in reverse order when one fails:
+//
+//	err := vapi.NewSaga().
+//		Step("reserve", reserveStock, releaseStock).
+//		Step("charge", chargeCard, refundCard).
+//		Step("ship", createShipment, nil).
+//		Run(ctx)
+type Saga struct {
+	steps []sagaStep
+}
+
+// SagaError reports the failed step and the outcome of compensations
+type SagaError struct {
+	// Step is the name of the failed step
+	Step string
+	// Err is the error of the failed step
+	Err error
+	// Compensated lists names of compensated steps in execution order
+	Compensated []string
+	// CompensationErrors maps names of steps whose compensation failed to the errors
+	CompensationErrors map[string]error
+}
+
+// NewSaga returns empty saga
+func NewSaga() *Saga {
+	return &Saga{}
+}
+
+// Step appends step, compensate may be nil for steps which need no undo
+func (s *Saga) Step(name string, action, compensate SagaAction) *Saga {
+	s.steps = append(s.steps, sagaStep{name: name, action: action, compensate: compensate})
+	return s
+}
+
+// Run executes the steps. On failure compensations of completed steps run
+// (all of them, even if some fail) and *SagaError is returned.
+func (s *Saga) Run(ctx context.Context) error {
+	for i, step := range s.steps {
+		err := step.action(ctx)
+		if err == nil {
+			continue
+		}
+
+		sagaErr := &SagaError{Step: step.name, Err: err}
+		for j := i - 1; j >= 0; j-- {
+			completed := s.steps[j]
+			if completed.compensate == nil {
+				continue
+			}
+			// compensations must run even when ctx is already cancelled
+			if compErr := completed.compensate(context.Background()); compErr != nil {
+				if sagaErr.CompensationErrors == nil {
+					sagaErr.CompensationErrors = make(map[string]error)
+				}
+				sagaErr.CompensationErrors[completed.name] = compErr
+				continue
+			}
+			sagaErr.Compensated = append([]string{completed.name}, sagaErr.Compensated...)
+		}
+		return sagaErr
+	}
+	return nil
+}
+
+func (e *SagaError) Error() string {
+	message := fmt.Sprintf("vapi: saga step %q failed: %s", e.Step, e.Err.Error())
+	if len(e.CompensationErrors) > 0 {
+		failed := make([]string, 0, len(e.CompensationErrors))
+		for name := range e.CompensationErrors {
+			failed = append(failed, name)
+		}
+		message += fmt.Sprintf(" (compensation of %s failed)", strings.Join(failed, ", "))
+	}
+	return message
+}
+
+// Unwrap returns the error of the failed step
+func (e *SagaError) Unwrap() error {
+	return e.Err
+}
+
+// APIError converts saga error into method error with the failure details in Data
+func (e *SagaError) APIError(httpCode, errorCode int) *Error {
+	compensationErrors := make(map[string]string, len(e.CompensationErrors))
+	for name, err := range e.CompensationErrors {
+		compensationErrors[name] = err.Error()
+	}
+	return &Error{
+		ErrorHTTPCode: httpCode,
+		ErrorCode:     errorCode,
+		ErrorMessage:  e.Error(),
+		Data: map[string]interface{}{
+			"failed_step":         e.Step,
+			"compensated":         e.Compensated,
+			"compensation_errors": compensationErrors,
+		},
+	}
+}
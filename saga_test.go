@@ -0,0 +1,46 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSaga(t *testing.T) {
+	var log []string
+	step := func(name string, err error) SagaAction {
+		return func(ctx context.Context) error {
+			log = append(log, name)
+			return err
+		}
+	}
+
+	err := NewSaga().
+		Step("reserve", step("reserve", nil), step("release", nil)).
+		Step("notify", step("notify", nil), nil).
+		Step("charge", step("charge", nil), step("refund", errors.New("bank is down"))).
+		Step("ship", step("ship", errors.New("no courier")), step("cancel", nil)).
+		Run(context.Background())
+
+	if strings.Join(log, ",") != "reserve,notify,charge,ship,refund,release" {
+		t.Error(fmt.Sprintf("wrong execution order: %v", log))
+	}
+
+	sagaErr, ok := err.(*SagaError)
+	if !ok || sagaErr.Step != "ship" || sagaErr.Err.Error() != "no courier" {
+		t.Fatal(fmt.Sprintf("wrong error: %v", err))
+	}
+	if fmt.Sprint(sagaErr.Compensated) != "[reserve]" || sagaErr.CompensationErrors["charge"] == nil {
+		t.Error(fmt.Sprintf("wrong compensations: %v %v", sagaErr.Compensated, sagaErr.CompensationErrors))
+	}
+	if apiErr := sagaErr.APIError(409, 7); apiErr.Data.(map[string]interface{})["failed_step"] != "ship" {
+		t.Error("api error must report failed step")
+	}
+
+	log = nil
+	if err = NewSaga().Step("a", step("a", nil), step("undo", nil)).Run(context.Background()); err != nil || len(log) != 1 {
+		t.Error(fmt.Sprintf("successful saga must not compensate: %v %v", err, log))
+	}
+}
@@ -0,0 +1,206 @@
+package vapi
+
+import (
+	"fmt"
+	"html"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Sanitizer cleans up a decoded string value
+type Sanitizer func(value string) string
+
+var (
+	sanitizersMutex sync.RWMutex
+	sanitizers      = map[string]Sanitizer{
+		"trim":      strings.TrimSpace,
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"collapse":  collapseSpaces,
+		"stripHTML": stripHTML,
+	}
+)
+
+// RegisterSanitizer adds a sanitizer usable in `sanitize` struct tags, it
+// replaces the built-in one of the same name. Sanitizers must be registered
+// before services using them: RegisterService rejects unknown names.
+func RegisterSanitizer(name string, sanitizer Sanitizer) error {
+	if name == "" || strings.ContainsAny(name, ", ") || sanitizer == nil {
+		return fmt.Errorf("vapi: invalid sanitizer %q", name)
+	}
+	sanitizersMutex.Lock()
+	sanitizers[name] = sanitizer
+	sanitizersMutex.Unlock()
+	return nil
+}
+
+// checkSanitizers returns an error when `sanitize` tags of args type t name unknown sanitizers
+func checkSanitizers(t reflect.Type, seen map[reflect.Type]bool) error {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return checkSanitizers(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return nil
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag, ok := field.Tag.Lookup("sanitize")
+			if !ok {
+				if err := checkSanitizers(field.Type, seen); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, name := range strings.Split(tag, ",") {
+				sanitizersMutex.RLock()
+				sanitizer := sanitizers[strings.TrimSpace(name)]
+				sanitizersMutex.RUnlock()
+				if sanitizer == nil {
+					return fmt.Errorf("vapi: unknown sanitizer %q of field %s.%s", name, t.Name(), field.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sanitizeArgs applies sanitizers named in `sanitize` tags of string fields
+// (and slices of them) in order, after decoding and before validation:
+//
+//	Email string `json:"email" sanitize:"trim,lower"`
+//	Bio   string `json:"bio" sanitize:"stripHTML,collapse"`
+//
+// Built-in sanitizers are trim, lower, upper, collapse (runs of spaces to one)
+// and stripHTML (entities decoded, then tags and script contents removed; the
+// result is plain text and must still be escaped when rendered).
+func sanitizeArgs(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return sanitizeArgs(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := sanitizeArgs(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if tag, ok := field.Tag.Lookup("sanitize"); ok {
+				if err := sanitizeField(v.Field(i), tag); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := sanitizeArgs(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sanitizeField applies sanitizers of tag to string value v
+func sanitizeField(v reflect.Value, tag string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return sanitizeField(v.Elem(), tag)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := sanitizeField(v.Index(i), tag); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		value := v.String()
+		for _, name := range strings.Split(tag, ",") {
+			sanitizersMutex.RLock()
+			sanitizer := sanitizers[strings.TrimSpace(name)]
+			sanitizersMutex.RUnlock()
+			if sanitizer == nil {
+				return fmt.Errorf("vapi: unknown sanitizer %q", name)
+			}
+			value = sanitizer(value)
+		}
+		if v.CanSet() {
+			v.SetString(value)
+		}
+	}
+	return nil
+}
+
+// collapseSpaces replaces runs of white space with a single space
+func collapseSpaces(value string) string {
+	return strings.Join(strings.FieldsFunc(value, unicode.IsSpace), " ")
+}
+
+// stripHTML decodes entities, then removes tags, comments and contents of script and style
+// elements, so escaped markup cannot survive as a tag. A '<' that cannot open a tag is kept as text
+func stripHTML(value string) string {
+	value = html.UnescapeString(value)
+	var text strings.Builder
+	for len(value) > 0 {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			text.WriteString(value)
+			break
+		}
+		text.WriteString(value[:start])
+		value = value[start:]
+		if !opensTag(value) {
+			text.WriteByte('<')
+			value = value[1:]
+			continue
+		}
+
+		end := strings.IndexByte(value, '>')
+		if strings.HasPrefix(value, "<!--") {
+			end = strings.Index(value, "-->")
+			if end >= 0 {
+				end += 2
+			}
+		}
+		if end < 0 {
+			break
+		}
+		tag := strings.ToLower(value[1:end])
+		value = value[end+1:]
+		for _, element := range []string{"script", "style"} {
+			if tag == element || strings.HasPrefix(tag, element+" ") {
+				closing := strings.Index(strings.ToLower(value), "</"+element)
+				if closing < 0 {
+					value = ""
+				} else if skip := strings.IndexByte(value[closing:], '>'); skip >= 0 {
+					value = value[closing+skip+1:]
+				} else {
+					value = ""
+				}
+			}
+		}
+	}
+	return text.String()
+}
+
+// opensTag reports whether value, starting with '<', begins a tag, end tag or comment
+func opensTag(value string) bool {
+	if len(value) < 2 {
+		return false
+	}
+	c := value[1]
+	return c == '/' || c == '!' || c == '?' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
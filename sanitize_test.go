@@ -0,0 +1,85 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SanitizedUser has sanitized fields
+type SanitizedUser struct {
+	Email string   `json:"email" sanitize:"trim,lower"`
+	Bio   *string  `json:"bio" sanitize:"stripHTML,collapse"`
+	Tags  []string `json:"tags" sanitize:"trim,upper"`
+	Code  string   `json:"code" sanitize:"digits"`
+}
+
+func (u *SanitizedUser) UnmarshalJSON(data []byte) error {
+	type plain SanitizedUser
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+func (u *SanitizedUser) MarshalJSON() ([]byte, error) {
+	type plain SanitizedUser
+	return json.Marshal((*plain)(u))
+}
+
+// SanitizeAPI echoes sanitized args
+type SanitizeAPI struct{}
+
+// Echo returns args
+func (h *SanitizeAPI) Echo(ctx *fasthttp.RequestCtx, Args *SanitizedUser, Reply *SanitizedUser) error {
+	*Reply = *Args
+	return nil
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(SanitizeAPI), "sanitize"); err == nil || !strings.Contains(err.Error(), `"digits"`) {
+		t.Error(fmt.Sprintf("unknown sanitizer must be rejected, got %v", err))
+	}
+
+	t.Cleanup(func() {
+		sanitizersMutex.Lock()
+		delete(sanitizers, "digits")
+		sanitizersMutex.Unlock()
+	})
+	RegisterSanitizer("digits", func(value string) string {
+		return strings.Map(func(r rune) rune {
+			if r < '0' || r > '9' {
+				return -1
+			}
+			return r
+		}, value)
+	})
+	if err := as.RegisterService(new(SanitizeAPI), "sanitize"); err != nil {
+		t.Fatal(err)
+	}
+
+	args := `{"email":"  Bob@Example.COM ","bio":"<b>Hi</b>\n\n<script>alert(1)</script>Tom &amp;   Jerry","code":"+1 (555) 01","tags":[" a ","b"]}`
+	status, body, _ := as.callLocal("sanitize.Echo", []byte(args))
+	expected := `{"response":{"email":"bob@example.com","bio":"Hi Tom \u0026 Jerry","tags":["A","B"],"code":"155501"}}`
+	if status != 200 || string(body) != expected {
+		t.Error(fmt.Sprintf("wrong sanitized args: %d %s", status, body))
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	tests := map[string]string{
+		"plain":                           "plain",
+		"<p class=\"x\">a</p><!-- c -->b": "ab",
+		"<style>p{}</style>x<br/>y":       "xy",
+		"1 &lt; 2":                        "1 < 2",
+		"broken <tag":                     "broken ",
+		"&lt;script&gt;alert(1)&lt;/script&gt;ok": "ok",
+		"&lt;b&gt;x&lt;/b&gt; &amp; a<b":          "x & a",
+	}
+	for value, expected := range tests {
+		if got := stripHTML(value); got != expected {
+			t.Error(fmt.Sprintf("%q: expected %q, got %q", value, expected, got))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package vapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// EventUploadInfected is emitted when an upload is rejected by the scanner with InfectedUpload payload
+const EventUploadInfected = "upload.infected"
+
+var errScanFailed = errors.New("vapi: upload can't be scanned")
+
+// ScanResult is the verdict of an UploadScanner
+type ScanResult struct {
+	Infected bool
+	// Signature names the detected threat
+	Signature string
+}
+
+// UploadScanner checks uploaded bodies of streaming methods, e.g. an antivirus daemon
+type UploadScanner interface {
+	Scan(ctx context.Context, body []byte) (ScanResult, error)
+}
+
+// UploadScannerFunc adapts a function to UploadScanner
+type UploadScannerFunc func(ctx context.Context, body []byte) (ScanResult, error)
+
+// Scan implements UploadScanner
+func (f UploadScannerFunc) Scan(ctx context.Context, body []byte) (ScanResult, error) {
+	return f(ctx, body)
+}
+
+// ScanOptions configures scanning of uploads
+type ScanOptions struct {
+	Scanner UploadScanner
+	// Quarantine, when set, receives infected uploads before they are rejected
+	Quarantine func(method string, body []byte, result ScanResult) error
+	// FailOpen lets uploads through when the scanner fails, they are rejected with 503 by default
+	FailOpen bool
+}
+
+// InfectedUpload is the payload of EventUploadInfected
+type InfectedUpload struct {
+	Method      string
+	Size        int
+	Signature   string
+	Quarantined bool
+}
+
+// ScanStats holds counters of upload scanning
+type ScanStats struct {
+	Scanned     uint64 `json:"scanned"`
+	Infected    uint64 `json:"infected"`
+	Failed      uint64 `json:"failed"`
+	Quarantined uint64 `json:"quarantined"`
+}
+
+// uploadScanner is the configured scanner with its counters
+type uploadScanner struct {
+	ScanOptions
+	stats ScanStats
+}
+
+// SetUploadScanner makes uploads of streaming methods (args with an io.Reader
+// field) scanned before the method sees them, nil disables it. Infected uploads
+// are quarantined (when configured) and rejected with 422.
+func (as *VAPI) SetUploadScanner(options *ScanOptions) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if options == nil || options.Scanner == nil {
+		as.scanner = nil
+		return
+	}
+	as.scanner = &uploadScanner{ScanOptions: *options}
+}
+
+// UploadScanStats returns counters of upload scanning
+func (as *VAPI) UploadScanStats() ScanStats {
+	as.mutex.RLock()
+	scanner := as.scanner
+	as.mutex.RUnlock()
+	if scanner == nil {
+		return ScanStats{}
+	}
+	return ScanStats{
+		Scanned:     atomic.LoadUint64(&scanner.stats.Scanned),
+		Infected:    atomic.LoadUint64(&scanner.stats.Infected),
+		Failed:      atomic.LoadUint64(&scanner.stats.Failed),
+		Quarantined: atomic.LoadUint64(&scanner.stats.Quarantined),
+	}
+}
+
+// ClamdScanner scans with the clamd INSTREAM command at addr, e.g.
+// "127.0.0.1:3310" or "unix:/run/clamav/clamd.ctl"
+func ClamdScanner(addr string, timeout time.Duration) UploadScanner {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	return UploadScannerFunc(func(ctx context.Context, body []byte) (ScanResult, error) {
+		result := ScanResult{}
+		conn, err := net.DialTimeout(network, addr, timeout)
+		if err != nil {
+			return result, err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(timeout))
+
+		writer := bufio.NewWriter(conn)
+		writer.WriteString("zINSTREAM\x00")
+		size := make([]byte, 4)
+		for chunk := body; len(chunk) > 0; {
+			n := len(chunk)
+			if n > 64<<10 {
+				n = 64 << 10
+			}
+			binary.BigEndian.PutUint32(size, uint32(n))
+			writer.Write(size)
+			writer.Write(chunk[:n])
+			chunk = chunk[n:]
+		}
+		binary.BigEndian.PutUint32(size, 0)
+		writer.Write(size)
+		if err = writer.Flush(); err != nil {
+			return result, err
+		}
+
+		reply, err := bufio.NewReader(conn).ReadString(0)
+		if err != nil {
+			return result, err
+		}
+		reply = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), "\x00")
+		switch {
+		case reply == "OK":
+			return result, nil
+		case strings.HasSuffix(reply, " FOUND"):
+			return ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+		}
+		return result, fmt.Errorf("vapi: clamd replied %q", reply)
+	})
+}
+
+// scanUpload scans the request body, writes the rejection and returns false when the call must not proceed
+func (as *VAPI) scanUpload(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	scanner := as.scanner
+	as.mutex.RUnlock()
+	if scanner == nil {
+		return true
+	}
+
+	body := ctx.Request.Body()
+	atomic.AddUint64(&scanner.stats.Scanned, 1)
+	result, err := scanner.Scanner.Scan(ctx, body)
+	if err != nil {
+		atomic.AddUint64(&scanner.stats.Failed, 1)
+		if scanner.FailOpen {
+			return true
+		}
+		as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errScanFailed)
+		return false
+	}
+	if !result.Infected {
+		return true
+	}
+
+	atomic.AddUint64(&scanner.stats.Infected, 1)
+	quarantined := false
+	if scanner.Quarantine != nil {
+		if err := scanner.Quarantine(methodSpec.name, append([]byte(nil), body...), result); err == nil {
+			quarantined = true
+			atomic.AddUint64(&scanner.stats.Quarantined, 1)
+		}
+	}
+	if as.Events().HasSubscribers(EventUploadInfected) {
+		as.Events().Publish(EventUploadInfected, InfectedUpload{
+			Method: methodSpec.name, Size: len(body), Signature: result.Signature, Quarantined: quarantined,
+		})
+	}
+	as.writeError(ctx, srvResponse, fasthttp.StatusUnprocessableEntity, fmt.Errorf("vapi: upload is infected with %s", result.Signature))
+	return false
+}
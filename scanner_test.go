@@ -0,0 +1,120 @@
+package vapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// UploadArgs is a streamed upload
+type UploadArgs struct {
+	Name string    `json:"name"`
+	Body io.Reader `json:"-"`
+}
+
+func (u *UploadArgs) UnmarshalJSON(data []byte) error {
+	type plain UploadArgs
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// UploadAPI stores uploads
+type UploadAPI struct{}
+
+// Put returns the size of the upload
+func (h *UploadAPI) Put(ctx *fasthttp.RequestCtx, Args *UploadArgs, Reply *TestReply) error {
+	body, err := ioutil.ReadAll(Args.Body)
+	Reply.ID = fmt.Sprint(len(body))
+	return err
+}
+
+func TestVAPI_SetUploadScanner(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(UploadAPI), "upload")
+
+	quarantine := map[string]string{}
+	as.SetUploadScanner(&ScanOptions{
+		Scanner: UploadScannerFunc(func(ctx context.Context, body []byte) (ScanResult, error) {
+			if strings.Contains(string(body), "EICAR") {
+				return ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+			}
+			if string(body) == "timeout" {
+				return ScanResult{}, errors.New("scanner timeout")
+			}
+			return ScanResult{}, nil
+		}),
+		Quarantine: func(method string, body []byte, result ScanResult) error {
+			quarantine[method] = string(body)
+			return nil
+		},
+	})
+
+	if status, body, _ := as.callLocal("upload.Put", []byte("clean file")); status != 200 || string(body) != `{"response":{"id":"10"}}` {
+		t.Error(fmt.Sprintf("clean upload must pass: %d %s", status, body))
+	}
+	if status, _, _ := as.callLocal("upload.Put", []byte("X5O EICAR")); status != 422 || quarantine["upload.Put"] != "X5O EICAR" {
+		t.Error(fmt.Sprintf("infected upload must be quarantined and rejected, got %d", status))
+	}
+	if status, _, _ := as.callLocal("upload.Put", []byte("timeout")); status != 503 {
+		t.Error(fmt.Sprintf("failed scan must fail closed, got %d", status))
+	}
+	if stats := as.UploadScanStats(); stats != (ScanStats{Scanned: 3, Infected: 1, Failed: 1, Quarantined: 1}) {
+		t.Error(fmt.Sprintf("wrong stats: %+v", stats))
+	}
+}
+
+func TestClamdScanner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			command, _ := reader.ReadString(0)
+			var body []byte
+			size := make([]byte, 4)
+			for command == "zINSTREAM\x00" {
+				if _, err := io.ReadFull(reader, size); err != nil {
+					break
+				}
+				n := binary.BigEndian.Uint32(size)
+				if n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(reader, chunk)
+				body = append(body, chunk...)
+			}
+			if strings.Contains(string(body), "EICAR") {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+
+	scanner := ClamdScanner(ln.Addr().String(), time.Second)
+	if result, err := scanner.Scan(context.Background(), []byte(strings.Repeat("a", 100<<10))); err != nil || result.Infected {
+		t.Error(fmt.Sprintf("clean body: %+v %v", result, err))
+	}
+	if result, err := scanner.Scan(context.Background(), []byte("EICAR")); err != nil || result.Signature != "Eicar-Test-Signature" {
+		t.Error(fmt.Sprintf("infected body: %+v %v", result, err))
+	}
+}
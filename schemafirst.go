@@ -0,0 +1,308 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LoadOpenAPI parses OpenAPI document in json
+func LoadOpenAPI(data []byte) (*OpenAPIDocument, error) {
+	doc := &OpenAPIDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("vapi: invalid openapi document: %s", err.Error())
+	}
+	if doc.Paths == nil {
+		return nil, fmt.Errorf("vapi: openapi document has no paths")
+	}
+	if _, err := compileSchemaPatterns(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// UseSchema switches the server to schema-first mode with the declared api doc,
+// paths of which are methods under basePath as in OpenAPI.
+//
+// It fails listing the drift when a declared method isn't registered, a
+// registered method isn't declared or the types of a method break the
+// declaration. Call it after registering services, so drift fails the startup.
+// Afterwards args are validated against the declared schemas before decoding
+// and OpenAPIHandler serves the declared document. Patterns are compiled once
+// here, an invalid pattern fails it.
+func (as *VAPI) UseSchema(doc *OpenAPIDocument, basePath string) error {
+	patterns, err := compileSchemaPatterns(doc)
+	if err != nil {
+		return err
+	}
+
+	implemented := as.OpenAPI(doc.Info.Title, doc.Info.Version, basePath)
+
+	var drift []string
+	for _, change := range DiffOpenAPI(doc, implemented) {
+		switch {
+		case change.Message == "method removed":
+			drift = append(drift, change.Path+": declared method is not registered")
+		case change.Message == "method added":
+			drift = append(drift, change.Path+": registered method is not declared")
+		case change.Breaking:
+			drift = append(drift, change.String())
+		}
+	}
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return fmt.Errorf("vapi: services drifted from the declared api:\n%s", strings.Join(drift, "\n"))
+	}
+
+	prefix := strings.TrimRight(basePath, "/") + "/"
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	for path, item := range doc.Paths {
+		if methodSpec, ok := as.methods[strings.TrimPrefix(path, prefix)]; ok {
+			methodSpec.declared = declaredArgsSchema(item)
+		}
+	}
+	as.schema = doc
+	as.schemaValidator = &schemaValidator{doc: doc, patterns: patterns}
+	return nil
+}
+
+// declaredArgsSchema returns args schema of the path: the json body or the args query parameter
+func declaredArgsSchema(item *OpenAPIPath) *Schema {
+	for _, op := range []*OpenAPIOperation{item.Post, item.Put, item.Patch, item.Get, item.Delete} {
+		if op == nil {
+			continue
+		}
+		if schema := bodySchema(op.RequestBody); schema != nil {
+			return schema
+		}
+		for _, param := range op.Parameters {
+			if param.Name == ArgsParam {
+				if schema := mediaSchema(param.Content); schema != nil {
+					return schema
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateDeclared validates raw args against the declared schema of the method
+func (as *VAPI) validateDeclared(methodSpec *serviceMethod, raw []byte) error {
+	if methodSpec.declared == nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return &ValidationError{Message: "args are not valid json: " + err.Error()}
+	}
+	as.mutex.RLock()
+	validator := as.schemaValidator
+	as.mutex.RUnlock()
+	return validator.validate(methodSpec.declared, value, "")
+}
+
+// schemaValidator validates values against schemas of the declared document
+type schemaValidator struct {
+	doc      *OpenAPIDocument
+	patterns map[string]*regexp.Regexp
+}
+
+// compileSchemaPatterns compiles patterns of every schema in doc, keyed by the pattern
+func compileSchemaPatterns(doc *OpenAPIDocument) (map[string]*regexp.Regexp, error) {
+	patterns := make(map[string]*regexp.Regexp)
+	visited := make(map[*Schema]bool)
+
+	var compile func(schema *Schema) error
+	compile = func(schema *Schema) error {
+		if schema == nil || visited[schema] {
+			return nil
+		}
+		visited[schema] = true
+		if schema.Pattern != "" && patterns[schema.Pattern] == nil {
+			pattern, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				return fmt.Errorf("vapi: invalid schema pattern %q: %s", schema.Pattern, err.Error())
+			}
+			patterns[schema.Pattern] = pattern
+		}
+		nested := []*Schema{schema.Items, schema.AdditionalProperties}
+		nested = append(nested, schema.AllOf...)
+		nested = append(nested, schema.OneOf...)
+		for _, property := range schema.Properties {
+			nested = append(nested, property)
+		}
+		for _, child := range nested {
+			if err := compile(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	compileMedia := func(content map[string]*OpenAPIMediaType) error {
+		for _, media := range content {
+			if media != nil {
+				if err := compile(media.Schema); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, schema := range doc.Components.Schemas {
+		if err := compile(schema); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+		for _, op := range item.operations() {
+			if op == nil {
+				continue
+			}
+			if op.RequestBody != nil {
+				if err := compileMedia(op.RequestBody.Content); err != nil {
+					return nil, err
+				}
+			}
+			for _, param := range op.Parameters {
+				if err := compileMedia(param.Content); err != nil {
+					return nil, err
+				}
+			}
+			for _, response := range op.Responses {
+				if response != nil {
+					if err := compileMedia(response.Content); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// validate checks value decoded from json against schema, nulls are treated as absent
+func (sv *schemaValidator) validate(schema *Schema, value interface{}, path string) error {
+	schema = resolveSchema(sv.doc, schema)
+	if value == nil {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Field: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	for _, part := range schema.AllOf {
+		if err := sv.validate(part, value, path); err != nil {
+			return err
+		}
+	}
+	if len(schema.OneOf) > 0 {
+		matched := 0
+		for _, variant := range schema.OneOf {
+			if sv.validate(variant, value, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fail("must match exactly one of %d schemas, matches %d", len(schema.OneOf), matched)
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %v", schema.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if schema.Type != "" && schema.Type != "object" {
+			return fail("must be %s", schema.Type)
+		}
+		for _, name := range schema.Required {
+			if v[name] == nil {
+				return &ValidationError{Field: joinPath(path, name), Message: "is required"}
+			}
+		}
+		for name, item := range v {
+			if property, ok := schema.Properties[name]; ok {
+				if err := sv.validate(property, item, joinPath(path, name)); err != nil {
+					return err
+				}
+			} else if schema.AdditionalProperties != nil {
+				if err := sv.validate(schema.AdditionalProperties, item, joinPath(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if schema.Type != "" && schema.Type != "array" {
+			return fail("must be %s", schema.Type)
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				if err := sv.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if schema.Type != "" && schema.Type != "string" {
+			return fail("must be %s", schema.Type)
+		}
+		length := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fail("must be at least %d characters", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fail("must be at most %d characters", *schema.MaxLength)
+		}
+		if schema.Pattern != "" {
+			// patterns added to the document after UseSchema are not compiled and never match
+			if pattern := sv.patterns[schema.Pattern]; pattern == nil || !pattern.MatchString(v) {
+				return fail("must match %s", schema.Pattern)
+			}
+		}
+	case float64:
+		if schema.Type != "" && schema.Type != "number" && schema.Type != "integer" {
+			return fail("must be %s", schema.Type)
+		}
+		if schema.Type == "integer" && v != math.Trunc(v) {
+			return fail("must be integer")
+		}
+		if schema.Minimum != nil && v < *schema.Minimum {
+			return fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			return fail("must be at most %v", *schema.Maximum)
+		}
+	case bool:
+		if schema.Type != "" && schema.Type != "boolean" {
+			return fail("must be %s", schema.Type)
+		}
+	}
+	return nil
+}
+
+// joinPath appends json field name to the dotted path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,85 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVAPI_UseSchema(t *testing.T) {
+	declaring := NewServer()
+	if err := declaring.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(declaring.OpenAPI("demo", "1", "/api"))
+	doc, err := LoadOpenAPI(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxLength := 3
+	args := resolveSchema(doc, declaredArgsSchema(doc.Paths["/api/demo.Test"]))
+	args.Properties["id"].MaxLength = &maxLength
+
+	as := NewServer()
+	if err = as.RegisterService(new(GatewayAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = as.UseSchema(doc, "/api"); err == nil || !strings.Contains(err.Error(), "/api/demo.Test: declared method is not registered") {
+		t.Error(fmt.Sprintf("drift must be reported: %v", err))
+	}
+
+	as = NewServer()
+	if err = as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err = as.UseSchema(doc, "/api"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{"id":"abc"}`)); status != 200 {
+		t.Error(fmt.Sprintf("valid args must pass, got %d", status))
+	}
+	if status, body, _ := as.callLocal("demo.Test", []byte(`{"id":"abcd"}`)); status != 400 || !strings.Contains(string(body), "invalid field id") {
+		t.Error(fmt.Sprintf("declared constraints must be validated: %d %s", status, body))
+	}
+}
+
+func TestSchemaValidator(t *testing.T) {
+	doc := &OpenAPIDocument{
+		Paths: map[string]*OpenAPIPath{},
+		Components: OpenAPIComponents{Schemas: map[string]*Schema{
+			"Card": {Type: "object", Required: []string{"number"}, Properties: map[string]*Schema{
+				"number": {Type: "string", Pattern: "^[0-9]{16}$"},
+			}},
+			"Wallet": {Type: "object", Required: []string{"wallet"}, Properties: map[string]*Schema{
+				"wallet": {Type: "string"},
+			}},
+		}},
+	}
+	patterns, err := compileSchemaPatterns(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := &schemaValidator{doc: doc, patterns: patterns}
+	payment := &Schema{OneOf: []*Schema{{Ref: "#/components/schemas/Card"}, {Ref: "#/components/schemas/Wallet"}}}
+
+	for body, valid := range map[string]bool{
+		`{"number":"4111111111111111"}`:               true,
+		`{"wallet":"w1"}`:                             true,
+		`{"number":"4111"}`:                           false,
+		`{"note":"none"}`:                             false,
+		`{"number":"4111111111111111","wallet":"w1"}`: false,
+	} {
+		var value interface{}
+		json.Unmarshal([]byte(body), &value)
+		if err := validator.validate(payment, value, ""); (err == nil) != valid {
+			t.Error(fmt.Sprintf("wrong oneOf result for %s: %v", body, err))
+		}
+	}
+
+	doc.Components.Schemas["Card"].Properties["number"].Pattern = "[0-9"
+	data, _ := json.Marshal(doc)
+	if _, err = LoadOpenAPI(data); err == nil || !strings.Contains(err.Error(), "invalid schema pattern") {
+		t.Error(fmt.Sprintf("invalid pattern must fail loading: %v", err))
+	}
+}
@@ -0,0 +1,171 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SecretProvider resolves secret references of one scheme, ref is given without the scheme prefix
+type SecretProvider func(ctx context.Context, ref string) (string, error)
+
+// Secrets resolves secret references like "env:DB_PASSWORD" or "vault:secret/data/db#password"
+// through providers registered per scheme, so credentials don't appear in config files.
+// The "env" and "file" schemes are registered by NewSecrets; register "vault" with
+// VaultSecrets and "awskms" or others with a SecretProvider of the cloud client.
+type Secrets struct {
+	mutex     sync.RWMutex
+	providers map[string]SecretProvider
+}
+
+// NewSecrets returns resolver with the "env" and "file" schemes
+func NewSecrets() *Secrets {
+	s := &Secrets{providers: make(map[string]SecretProvider)}
+	s.Register("env", envSecret)
+	s.Register("file", fileSecret)
+	return s
+}
+
+// Register sets provider of scheme, replacing the previous one
+func (s *Secrets) Register(scheme string, provider SecretProvider) {
+	s.mutex.Lock()
+	s.providers[scheme] = provider
+	s.mutex.Unlock()
+}
+
+// Resolve returns the secret value of ref. Values without a registered scheme
+// prefix are returned as is, so plain values keep working in development.
+func (s *Secrets) Resolve(ctx context.Context, ref string) (string, error) {
+	colon := strings.IndexByte(ref, ':')
+	if colon <= 0 {
+		return ref, nil
+	}
+	s.mutex.RLock()
+	provider, ok := s.providers[ref[:colon]]
+	s.mutex.RUnlock()
+	if !ok {
+		return ref, nil
+	}
+
+	value, err := provider(ctx, ref[colon+1:])
+	if err != nil {
+		// the reference names the secret, never its value, so it is safe to report
+		return "", fmt.Errorf("vapi: can't resolve secret %q: %s", ref, err.Error())
+	}
+	return value, nil
+}
+
+// ResolveStruct resolves string fields tagged with `vapi:"secret"` of the struct v
+// points to in place, descending into nested structs and pointers
+func (s *Secrets) ResolveStruct(ctx context.Context, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("vapi: ResolveStruct needs a pointer to struct, got %T", v)
+	}
+	return s.resolveFields(ctx, value.Elem())
+}
+
+// resolveFields resolves tagged fields of struct value v
+func (s *Secrets) resolveFields(ctx context.Context, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.String && hasTagOption(field, "secret"):
+			resolved, err := s.Resolve(ctx, fv.String())
+			if err != nil {
+				return err
+			}
+			fv.SetString(resolved)
+		case field.Type.Kind() == reflect.Struct:
+			if err := s.resolveFields(ctx, fv); err != nil {
+				return err
+			}
+		case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct && !fv.IsNil():
+			if err := s.resolveFields(ctx, fv.Elem()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// envSecret reads the environment variable
+func envSecret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileSecret reads the file, e.g. a mounted kubernetes or docker secret, trailing newlines are trimmed
+func fileSecret(ctx context.Context, path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultSecrets returns provider reading "path#key" refs from the HashiCorp Vault KV
+// engine at addr, e.g. "vault:secret/data/db#password". Both KV v1 and v2 layouts are read.
+func VaultSecrets(addr, token string, timeout time.Duration) SecretProvider {
+	return func(ctx context.Context, ref string) (string, error) {
+		hash := strings.LastIndexByte(ref, '#')
+		if hash < 0 {
+			return "", fmt.Errorf("reference must be path#key")
+		}
+		path, key := ref[:hash], ref[hash+1:]
+
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(path, "/"))
+		req.Header.Set("X-Vault-Token", token)
+
+		if err := fasthttp.DoTimeout(req, resp, timeout); err != nil {
+			return "", err
+		}
+		if resp.StatusCode() != fasthttp.StatusOK {
+			return "", fmt.Errorf("vault responded with status %d", resp.StatusCode())
+		}
+
+		secret := struct {
+			Data map[string]json.RawMessage `json:"data"`
+		}{}
+		if err := json.Unmarshal(resp.Body(), &secret); err != nil {
+			return "", err
+		}
+		data := secret.Data
+		if nested, ok := data["data"]; ok {
+			// kv v2 wraps values into data.data
+			data = nil
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return "", err
+			}
+		}
+		raw, ok := data[key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found", key)
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", fmt.Errorf("key %s is not a string", key)
+		}
+		return value, nil
+	}
+}
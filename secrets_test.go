@@ -0,0 +1,77 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type secretConfig struct {
+	Address  string
+	Password string `vapi:"secret"`
+	Store    *struct {
+		Token string `vapi:"secret"`
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	os.Setenv("VAPI_TEST_PASSWORD", "hunter2")
+	defer os.Unsetenv("VAPI_TEST_PASSWORD")
+
+	dir, _ := ioutil.TempDir("", "vapi-secrets")
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	ioutil.WriteFile(tokenFile, []byte("t0ken\n"), 0600)
+
+	secrets := NewSecrets()
+	config := secretConfig{Address: "env:NOT_A_SECRET", Password: "env:VAPI_TEST_PASSWORD"}
+	config.Store = &struct {
+		Token string `vapi:"secret"`
+	}{Token: "file:" + tokenFile}
+
+	if err := secrets.ResolveStruct(context.Background(), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Password != "hunter2" || config.Store.Token != "t0ken" || config.Address != "env:NOT_A_SECRET" {
+		t.Error(fmt.Sprintf("wrong resolved config: %+v %+v", config, config.Store))
+	}
+
+	if value, _ := secrets.Resolve(context.Background(), "plain"); value != "plain" {
+		t.Error(fmt.Sprintf("plain values must be kept: %q", value))
+	}
+	if _, err := secrets.Resolve(context.Background(), "env:VAPI_TEST_MISSING"); err == nil || !strings.Contains(err.Error(), "VAPI_TEST_MISSING") {
+		t.Error(fmt.Sprintf("missing secret must fail: %v", err))
+	}
+}
+
+func TestVaultSecrets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Request.Header.Peek("X-Vault-Token")) != "root" || string(ctx.Path()) != "/v1/secret/data/db" {
+			ctx.SetStatusCode(403)
+			return
+		}
+		ctx.SetBodyString(`{"data":{"data":{"password":"s3cret"},"metadata":{"version":1}}}`)
+	}}).Serve(ln)
+
+	secrets := NewSecrets()
+	secrets.Register("vault", VaultSecrets("http://"+ln.Addr().String(), "root", time.Second))
+	if value, err := secrets.Resolve(context.Background(), "vault:secret/data/db#password"); err != nil || value != "s3cret" {
+		t.Error(fmt.Sprintf("wrong vault secret: %q %v", value, err))
+	}
+	if _, err := secrets.Resolve(context.Background(), "vault:secret/data/db#user"); err == nil {
+		t.Error("missing key must fail")
+	}
+}
@@ -0,0 +1,180 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SelfTestFlag is the command line flag switching the process into self-test mode, see ExitOnSelfTest
+const SelfTestFlag = "--selftest"
+
+// exit terminates the process, replaced in tests
+var exit = os.Exit
+
+// Self-test check results
+const (
+	CheckPassed  = "passed"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+// SelfTestCheck is a single self-test check
+type SelfTestCheck struct {
+	Name     string        `json:"name"`
+	Result   string        `json:"result"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SelfTestReport is the result of SelfTest
+type SelfTestReport struct {
+	Passed bool            `json:"passed"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// SelfTest verifies the server is deployable: every method decodes zero-value args
+// and encodes zero-value reply without panics, methods supporting dry run are
+// dispatched in dry-run mode, encrypted fields have a keyring, the store answers
+// and all registered resources are healthy. Methods without dry run support are
+// not called, they may have side effects.
+func (as *VAPI) SelfTest(ctx context.Context) *SelfTestReport {
+	report := &SelfTestReport{Passed: true}
+	check := func(name string, fn func() (skipped bool, err error)) {
+		started := time.Now()
+		skipped, err := runCheck(fn)
+		c := SelfTestCheck{Name: name, Result: CheckPassed, Duration: time.Since(started)}
+		switch {
+		case err != nil:
+			c.Result, c.Error = CheckFailed, err.Error()
+			report.Passed = false
+		case skipped:
+			c.Result = CheckSkipped
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	as.mutex.RLock()
+	methods := make([]*serviceMethod, 0, len(as.methods))
+	for _, methodSpec := range as.methods {
+		methods = append(methods, methodSpec)
+	}
+	keyring := as.keyring
+	as.mutex.RUnlock()
+	sort.Slice(methods, func(i, j int) bool { return methods[i].name < methods[j].name })
+
+	for _, methodSpec := range methods {
+		methodSpec := methodSpec
+		check("codec "+methodSpec.name, func() (bool, error) {
+			return false, checkCodec(methodSpec, keyring)
+		})
+		check("dispatch "+methodSpec.name, func() (bool, error) {
+			if !methodSpec.dryRun {
+				return true, nil
+			}
+			return false, as.checkDispatch(methodSpec)
+		})
+	}
+
+	check("openapi", func() (bool, error) {
+		as.OpenAPI("selftest", "0", "/")
+		return false, nil
+	})
+	check("store", func() (bool, error) {
+		return false, checkStore(as.Store())
+	})
+	for _, status := range as.Resources() {
+		status := status
+		check("resource "+status.Name, func() (bool, error) {
+			if !status.Healthy {
+				return false, errors.New(status.Error)
+			}
+			return false, nil
+		})
+	}
+	return report
+}
+
+// ExitOnSelfTest runs SelfTest when args (usually os.Args[1:]) contain SelfTestFlag,
+// writes the report as json to w and exits with status 0 when it passed, 1 otherwise.
+// Without the flag it returns at once. Call it after services and resources are registered,
+// so deploy pipelines can gate on "app --selftest".
+func (as *VAPI) ExitOnSelfTest(args []string, w io.Writer) {
+	requested := false
+	for _, arg := range args {
+		requested = requested || arg == SelfTestFlag || arg == SelfTestFlag[1:]
+	}
+	if !requested {
+		return
+	}
+
+	report := as.SelfTest(context.Background())
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if report.Passed {
+		exit(0)
+	} else {
+		exit(1)
+	}
+}
+
+// runCheck calls fn turning panics into errors
+func runCheck(fn func() (bool, error)) (skipped bool, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			skipped, err = false, fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return fn()
+}
+
+// checkCodec decodes zero-value args and encodes zero-value reply of the method.
+// Decoding errors are fine (zero args may be invalid), panics are not.
+func checkCodec(methodSpec *serviceMethod, keyring Keyring) error {
+	if (methodSpec.argsPlan.encrypted || methodSpec.replyPlan.encrypted) && keyring == nil {
+		return errors.New("has encrypted fields but no keyring is set")
+	}
+	if args, ok := reflect.New(methodSpec.argsType).Interface().(Unmarshaler); ok {
+		args.UnmarshalJSON([]byte("{}"))
+	}
+	if _, err := reflect.New(methodSpec.replyType).Interface().(Marshaler).MarshalJSON(); err != nil {
+		return fmt.Errorf("zero reply doesn't encode: %s", err.Error())
+	}
+	return nil
+}
+
+// checkDispatch calls the method with empty args in dry-run mode, server errors fail the check
+func (as *VAPI) checkDispatch(methodSpec *serviceMethod) error {
+	status, body, _ := as.callLocalWith(methodSpec.name, []byte("{}"), nil, func(ctx *fasthttp.RequestCtx) {
+		ctx.Request.Header.Set(DryRunHeader, "true")
+	})
+	if status >= fasthttp.StatusInternalServerError {
+		return fmt.Errorf("status %d: %s", status, body)
+	}
+	return nil
+}
+
+// checkStore writes, reads and deletes a probe key
+func checkStore(store Store) error {
+	key := "vapi:selftest:" + time.Now().Format(time.RFC3339Nano)
+	if err := store.Set(key, []byte("ok"), time.Minute); err != nil {
+		return err
+	}
+	value, found, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	if !found || string(value) != "ok" {
+		return errors.New("written key can't be read back")
+	}
+	return store.Delete(key)
+}
@@ -0,0 +1,60 @@
+package vapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type FragileAPI struct{}
+
+func (f *FragileAPI) Safe(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	return nil
+}
+
+func (f *FragileAPI) Crash(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	var m map[string]int
+	m["boom"]++
+	return nil
+}
+
+func TestVAPI_SelfTest(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(FragileAPI), "fragile")
+	as.SetMethodDryRun("fragile.Safe", true)
+	as.SetMethodDryRun("fragile.Crash", true)
+
+	report := as.SelfTest(context.Background())
+	results := map[string]string{}
+	for _, check := range report.Checks {
+		results[check.Name] = check.Result
+	}
+	if report.Passed || results["dispatch fragile.Crash"] != CheckFailed || results["dispatch fragile.Safe"] != CheckPassed ||
+		results["codec fragile.Crash"] != CheckPassed || results["store"] != CheckPassed {
+		t.Error(fmt.Sprintf("wrong report: %+v", report))
+	}
+
+	as.SetMethodDryRun("fragile.Crash", false)
+	if report := as.SelfTest(context.Background()); !report.Passed {
+		t.Error(fmt.Sprintf("methods without dry run must be skipped: %+v", report))
+	}
+
+	code, previous := -1, exit
+	exit = func(c int) { code = c }
+	defer func() { exit = previous }()
+
+	as.ExitOnSelfTest([]string{"serve"}, &bytes.Buffer{})
+	if code != -1 {
+		t.Error("self test must run only with the flag")
+	}
+	buf := &bytes.Buffer{}
+	as.ExitOnSelfTest([]string{"--selftest"}, buf)
+	decoded := SelfTestReport{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || code != 0 || !decoded.Passed {
+		t.Error(fmt.Sprintf("wrong self test exit: %d %v %s", code, err, buf))
+	}
+}
@@ -2,9 +2,12 @@ package vapi
 
 import (
 	"fmt"
+	"html/template"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -19,18 +22,95 @@ var (
 
 // VAPI - main structure
 type VAPI struct {
-	mutex    sync.RWMutex
-	services map[string]bool
-	methods  map[string]*serviceMethod
+	inFlight int64 // accessed atomically, kept first for 64-bit alignment
+	polling  int64 // calls waiting in WaitEvent, accessed atomically
+	state    int32 // ServerState, accessed atomically
+	shedding int32 // 1 while the watchdog sheds load, accessed atomically
+
+	mutex     sync.RWMutex
+	services  map[string]bool
+	methods   map[string]*serviceMethod
+	keyring   Keyring
+	signer    Signer
+	limiter   ConcurrencyLimiter
+	warmers   []warmer
+	resources []resource
+	store     Store
+	workers   *WorkerPool
+	events    *EventBus
+	outbox    *Outbox
+
+	canonical        bool
+	localeResolver   LocaleResolver
+	methodOverride   bool
+	jsonp            bool
+	serverTiming     bool
+	debugTrace       func(ctx *fasthttp.RequestCtx) bool
+	bandwidth        *principalBandwidth
+	memory           *memoryAccount
+	schema           *OpenAPIDocument
+	schemaValidator  *schemaValidator
+	profile          Profile
+	longPollMax      time.Duration
+	pollDrain        chan struct{}
+	purgers          []Purger
+	sessions         *SessionOptions
+	roles            RoleResolver
+	classifiers      []RequestClassifier
+	tarpit           *Tarpit
+	geoip            GeoIPReader
+	decisions        *decisionPoint
+	regions          *RegionOptions
+	rolloutPrincipal PrincipalFunc
+	contentFilter    ContentFilter
+	scanner          *uploadScanner
+	spill            *SpillOptions
+	watchdog         *watchdogState
+	calls            callTable
+	journal          *journal
+	objectives       map[string]*objectiveTracker
+	analytics        *clientAnalytics
+	build            *BuildInfo
+	maintenance      *maintenanceMode
+	readOnly         string // message of rejected mutating calls, empty when writable
+	templates        *template.Template
+	callHeaders      []string
 }
 
 // serviceMethod - sub struct
 type serviceMethod struct {
-	rcvr      reflect.Value  // receiver of methods for the service
-	rcvrType  reflect.Type   // type of the receiver
-	method    reflect.Method // receiver method
-	argsType  reflect.Type   // type of the request argument
-	replyType reflect.Type   // type of the response argument
+	name          string             // "Service.Method"
+	rcvr          reflect.Value      // receiver of methods for the service
+	rcvrType      reflect.Type       // type of the receiver
+	method        reflect.Method     // receiver method
+	argsType      reflect.Type       // type of the request argument
+	replyType     reflect.Type       // type of the response argument
+	priority      Priority           // load shedding class of the method
+	examples      []methodExample    // sample calls for documentation
+	bodyField     []int              // index of io.Reader args field bound to the raw body
+	verbs         []string           // accepted http methods, any when empty
+	stats         *methodStats       // call counters
+	template      *template.Template // html template the reply is rendered with
+	bandwidth     int64              // bytes per second cap of every streamed response
+	argsPlan      typePlan           // reflection passes the args need
+	replyPlan     typePlan           // reflection passes the reply needs
+	quota         *methodQuota       // calls allowed per principal and window
+	fault         *Fault             // injected failures
+	dryRun        bool               // supports DryRunHeader
+	declared      *Schema            // args schema of the declared api (schema-first mode)
+	cache         *CachePolicy       // default cacheability of replies
+	surrogateKeys []string           // surrogate keys of every reply
+	policy        *Policy            // roles allowed to call the method
+	countries     *countryRule       // countries the method is available in
+	region        string             // region the method is pinned to
+	canary        *Canary            // candidate implementation compared with the method
+	rollout       *Rollout           // alternate implementation calls are rerouted to
+	transforms    []ArgsTransform    // rewrites of raw args applied before decoding
+	downgrades    []ReplyDowngrade   // reply conversions to older versions, newest first
+	sunsets       []Sunset           // scheduled removals of the method or its args fields
+	jsonp         bool               // whether JSONP responses are allowed, see SetJSONP
+	fieldStats    *fieldStats        // counts of args fields sent, nil when disabled
+	mutability    mutability         // whether the method changes state, checked in read-only mode
 }
 
 // RegisterService adds a new service to the api server.
@@ -50,11 +130,12 @@ type serviceMethod struct {
 //
 // All other methods are ignored.
 func (as *VAPI) RegisterService(receiver interface{}, name string) error {
-	return as.register(receiver, name)
+	return as.register(receiver, name, nil)
 }
 
 // register adds a new service using reflection to extract its methods.
-func (as *VAPI) register(rcvr interface{}, serviceName string) error {
+// Registered and skipped methods are recorded into report when it isn't nil.
+func (as *VAPI) register(rcvr interface{}, serviceName string, report *RegistrationReport) error {
 
 	rcvrValue := reflect.ValueOf(rcvr)
 	rcvrType := reflect.TypeOf(rcvr)
@@ -71,14 +152,34 @@ func (as *VAPI) register(rcvr interface{}, serviceName string) error {
 		return fmt.Errorf("vapi: no service name for type %q", rcvrType.String())
 	}
 
-	as.mutex.RLock()
-	defer as.mutex.RUnlock()
+	policies, err := receiverPolicies(rcvr, rcvrType)
+	if err != nil {
+		return err
+	}
+	mutations, err := receiverMutations(rcvr, rcvrType)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		method := rcvrType.Method(i)
+		if method.PkgPath == "" && methodShape(method.Type, 1) == "" {
+			if err = checkSanitizers(method.Type.In(2), map[reflect.Type]bool{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
 
 	if _, ok := as.services[serviceName]; ok {
 		return fmt.Errorf("vapi: service already defined: %q", serviceName)
 	}
 
 	as.services[serviceName] = true
+	if report != nil {
+		report.Service = serviceName
+	}
 
 	addedMethodCounter := 0
 
@@ -93,48 +194,38 @@ func (as *VAPI) register(rcvr interface{}, serviceName string) error {
 			continue
 		}
 
-		// Method needs four ins: receiver, *fasthttp.RequestCtx, *args, *reply.
-		if mtype.NumIn() != 4 {
-			continue
-		}
-
-		// First argument must be a pointer and must be fasthttp.RequestCtx.
-		reqType := mtype.In(1)
-		if reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest {
-			continue
-		}
-
-		// Second argument is Args must be a pointer, must be exported and must implement Unmarshaller interface.
-		args := mtype.In(2)
-		if args.Kind() != reflect.Ptr || !isExportedOrBuiltin(args) || !args.Implements(typeOfArgs) {
-			continue
-		}
-
-		// Third argument must be a pointer, must be exported and must implement Marshaller interface.
-		reply := mtype.In(3)
-		if reply.Kind() != reflect.Ptr || !isExportedOrBuiltin(reply) || !reply.Implements(typeOfReply) {
-			continue
-		}
-
-		// Method needs one out: error.
-		if mtype.NumOut() != 1 {
+		if reason := methodShape(mtype, 1); reason != "" {
+			if report != nil && !(method.Name == "Policies" && policies != nil) && !(method.Name == "Mutations" && mutations != nil) {
+				report.Skipped = append(report.Skipped, SkippedMethod{Method: method.Name, Reason: reason})
+			}
 			continue
 		}
 
-		// Method out should be an error type.
-		if returnType := mtype.Out(0); returnType != typeOfError {
-			continue
-		}
-
-		as.methods[fmt.Sprintf("%s.%s", serviceName, method.Name)] = &serviceMethod{
+		args, reply := mtype.In(2), mtype.In(3)
+		name := serviceName + "." + method.Name
+		as.methods[name] = &serviceMethod{
+			name:      name,
 			rcvr:      rcvrValue,
 			rcvrType:  rcvrType,
 			method:    method,
 			argsType:  args.Elem(),
 			replyType: reply.Elem(),
+			bodyField: bodyFieldIndex(args.Elem()),
+			argsPlan:  planOf(args.Elem()),
+			replyPlan: planOf(reply.Elem()),
+			stats:     &methodStats{},
+		}
+		if policy, ok := policies[method.Name]; ok {
+			as.methods[name].policy = &policy
+		}
+		if mutating, ok := mutations[method.Name]; ok {
+			as.methods[name].mutability = mutabilityOf(mutating)
 		}
 
 		addedMethodCounter++
+		if report != nil {
+			report.Registered = append(report.Registered, method.Name)
+		}
 	}
 
 	if addedMethodCounter == 0 {
@@ -144,29 +235,68 @@ func (as *VAPI) register(rcvr interface{}, serviceName string) error {
 	return nil
 }
 
+// methodShape returns why func type mtype can't be an api method, empty string when it can.
+// offset is 1 for method types with the receiver as the first argument and 0 for interface methods.
+func methodShape(mtype reflect.Type, offset int) string {
+
+	// Method needs three ins after the receiver: *fasthttp.RequestCtx, *args, *reply.
+	if mtype.NumIn() != offset+3 {
+		return fmt.Sprintf("has %d arguments, needs 3: *fasthttp.RequestCtx, *args, *reply", mtype.NumIn()-offset)
+	}
+
+	// First argument must be a pointer and must be fasthttp.RequestCtx.
+	reqType := mtype.In(offset)
+	if reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest {
+		return "first argument is not *fasthttp.RequestCtx"
+	}
+
+	// Second argument is Args must be a pointer, must be exported and must implement Unmarshaller interface.
+	args := mtype.In(offset + 1)
+	if args.Kind() != reflect.Ptr || !isExportedOrBuiltin(args) || !args.Implements(typeOfArgs) {
+		return fmt.Sprintf("args %s is not an exported pointer implementing UnmarshalJSON", args)
+	}
+
+	// Third argument must be a pointer, must be exported and must implement Marshaller interface.
+	reply := mtype.In(offset + 2)
+	if reply.Kind() != reflect.Ptr || !isExportedOrBuiltin(reply) || !reply.Implements(typeOfReply) {
+		return fmt.Sprintf("reply %s is not an exported pointer implementing MarshalJSON", reply)
+	}
+
+	// Method needs one out: error.
+	if mtype.NumOut() != 1 || mtype.Out(0) != typeOfError {
+		return "must return only error"
+	}
+
+	return ""
+}
+
 // get returns a registered service method by given name.
 //
 // The method name uses a dotted notation as in "Service.Method".
 func (as *VAPI) get(serviceWithMethod string) (*serviceMethod, error) {
 
-	parts := strings.Split(serviceWithMethod, ".")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("vapi: service/method request ill-formed: %q", serviceWithMethod)
+	as.mutex.RLock()
+	serviceMethod, okMethod := as.methods[serviceWithMethod]
+	as.mutex.RUnlock()
+
+	if okMethod {
+		return serviceMethod, nil
 	}
 
-	if _, ok := as.services[parts[0]]; !ok {
-		return nil, fmt.Errorf("vapi: service not found: %q", parts[0])
+	// the lookup above doesn't allocate, parsing is left for the error message
+	dot := strings.IndexByte(serviceWithMethod, '.')
+	if dot < 0 || strings.IndexByte(serviceWithMethod[dot+1:], '.') >= 0 {
+		return nil, fmt.Errorf("vapi: service/method request ill-formed: %q", serviceWithMethod)
 	}
 
-	as.mutex.Lock()
-	serviceMethod, okMethod := as.methods[serviceWithMethod]
-	as.mutex.Unlock()
+	as.mutex.RLock()
+	_, okService := as.services[serviceWithMethod[:dot]]
+	as.mutex.RUnlock()
 
-	if !okMethod {
-		return nil, fmt.Errorf("vapi: can't find method %q", parts[1])
+	if !okService {
+		return nil, fmt.Errorf("vapi: service not found: %q", serviceWithMethod[:dot])
 	}
-
-	return serviceMethod, nil
+	return nil, fmt.Errorf("vapi: can't find method %q", serviceWithMethod[dot+1:])
 }
 
 // GetServiceMap returns an json api schema
@@ -178,42 +308,227 @@ func (as *VAPI) GetServiceMap() (map[string]string, error) {
 // CallAPI call api method and process it.
 // Modifying body after this function not recommended
 func (as *VAPI) CallAPI(ctx *fasthttp.RequestCtx, method string) {
-
 	methodSpec, err := as.get(method)
+	as.serve(ctx, methodSpec, err)
+}
+
+// serve processes call of the resolved method, err is the method lookup error
+func (as *VAPI) serve(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, err error) {
+
+	atomic.AddInt64(&as.inFlight, 1)
+	defer atomic.AddInt64(&as.inFlight, -1)
+
+	// resources attached by middleware or the method and not committed are rolled back,
+	// including when the method panics
+	defer rollbackTx(ctx)
+	defer applyHeaders(ctx)
+	defer as.writeServerTiming(ctx)
 
 	srvResponse := acquireResponse()
 	defer releaseResponse(srvResponse)
 
+	// server-wide options are read once per call
+	as.mutex.RLock()
+	build, maintenance, readOnly := as.build, as.maintenance, as.readOnly
+	journal, analytics, objectives := as.journal, as.analytics, len(as.objectives) > 0
+	sessions, memory, limiter := as.sessions, as.memory, as.limiter
+	keyring, localeResolver, canonical := as.keyring, as.localeResolver, as.canonical
+	methodOverride, debugTrace := as.methodOverride, as.debugTrace
+	as.mutex.RUnlock()
+
+	writeBuildHeader(ctx, build)
+	startTrace(ctx, debugTrace)
+
 	if err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusNotFound, err)
+		return
+	}
+	methodSpec = as.route(ctx, methodSpec)
+
+	defer as.trackCall(ctx, methodSpec.name)()
+	defer journalCall(ctx, journal, methodSpec)()
+
+	var objective *objectiveTracker
+	if objectives {
+		objective = as.objectiveOf(methodSpec)
+	}
+
+	// calls rejected by the server itself (maintenance, shedding, limits) don't spend error budgets,
+	// otherwise shedding on an exhausted budget would keep it exhausted
+	admitted := false
+	started := time.Now()
+	defer func() {
+		duration := time.Since(started)
+		methodSpec.stats.record(duration, ctx.Response.StatusCode(), len(ctx.Request.Body()), responseSize(ctx))
+		if objective != nil && admitted {
+			objective.record(ctx.Response.StatusCode(), duration)
+		}
+		analytics.record(ctx, methodSpec.name)
+		as.emitCallEvent(methodSpec.name, ctx.Response.StatusCode(), duration)
+	}()
+	defer as.compareCanary(ctx, methodSpec)
+
+	chain := traceChain(ctx)
+	if chain.enter("maintenance"); !as.checkMaintenance(ctx, srvResponse, maintenance, methodSpec) {
+		return
+	}
 
-		errAPI := acquireError()
-		errAPI.ErrorHTTPCode = fasthttp.StatusNotFound
-		errAPI.ErrorCode = 0
-		errAPI.ErrorMessage = err.Error()
+	if chain.enter("region"); !as.routeRegion(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	if chain.enter("country"); !as.resolveCountry(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	// auth covers the gates from classification to the decision point
+	phase := time.Now()
+	if chain.enter("classify"); !as.classify(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	verb := requestVerb(ctx, methodOverride)
+	if chain.enter("verb"); !as.checkVerb(ctx, srvResponse, methodSpec, verb) {
+		return
+	}
+
+	if chain.enter("sunset"); !as.checkSunset(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	if chain.enter("jsonp"); !as.checkJSONP(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	if chain.enter("session_origin"); !as.checkSessionOrigin(ctx, srvResponse, sessions) {
+		return
+	}
+
+	if chain.enter("authorize"); !as.authorize(ctx, srvResponse, methodSpec) {
+		return
+	}
 
-		srvResponse.Error = errAPI
-		WriteResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
-		releaseError(errAPI)
+	if chain.enter("read_only"); !as.checkReadOnly(ctx, srvResponse, readOnly, methodSpec) {
 		return
 	}
 
+	if chain.enter("decision"); !as.decide(ctx, srvResponse, methodSpec, verb) {
+		return
+	}
+	as.markTiming(ctx, "auth", phase)
+
+	if IsDryRun(ctx) && !methodSpec.dryRun {
+		as.writeError(ctx, srvResponse, fasthttp.StatusBadRequest, fmt.Errorf("vapi: %s doesn't support dry run", methodSpec.name))
+		return
+	}
+
+	if chain.enter("quota"); !as.checkQuota(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	if chain.enter("shed"); !as.shedLoad(ctx, srvResponse) {
+		return
+	}
+	if chain.enter("budget"); !as.shedOnBudget(ctx, srvResponse, methodSpec, objective) {
+		return
+	}
+
+	reservation, status, err := memory.reserve(int64(len(ctx.Request.Body())))
+	if err != nil {
+		if status == fasthttp.StatusServiceUnavailable {
+			ctx.Response.Header.Set("Retry-After", "1")
+		}
+		as.writeError(ctx, srvResponse, status, err)
+		return
+	}
+	defer reservation.release()
+
+	if limiter != nil {
+		if !limiter.Acquire(methodSpec.priority) {
+			ctx.Response.Header.Set("Retry-After", "1")
+			as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errOverloaded)
+			return
+		}
+		acquired := time.Now()
+		defer func() {
+			limiter.Release(methodSpec.priority, time.Since(acquired))
+		}()
+	}
+
+	admitted = true
+	if chain.enter("fault"); !as.injectFault(ctx, srvResponse, methodSpec) {
+		return
+	}
+
+	ctx.SetUserValue(serverUserValue, as)
+
+	var locale *Locale
+	if localeResolver != nil {
+		locale = localeResolver(ctx)
+		ctx.SetUserValue(localeUserValue, locale)
+	}
+
 	// Decode the args.
+	phase = time.Now()
 	args := reflect.New(methodSpec.argsType)
-	err = args.Interface().(Unmarshaler).UnmarshalJSON(ctx.Request.Body())
+	switch {
+	case methodSpec.argsType == typeOfRawMessage:
+		passthroughArgs(ctx, args, verb)
+	case methodSpec.bodyField != nil:
+		if !as.scanUpload(ctx, srvResponse, methodSpec) {
+			return
+		}
+		err = decodeStreamArgs(ctx, args, methodSpec.bodyField)
+	default:
+		raw := requestArgs(ctx, verb)
+		as.recordFields(methodSpec, raw)
+		if !as.checkFieldSunsets(ctx, srvResponse, methodSpec, raw) {
+			return
+		}
+		raw, err = as.transformArgs(ctx, methodSpec, raw)
+		if err == nil {
+			err = as.validateDeclared(methodSpec, raw)
+		}
+		if err == nil {
+			err = args.Interface().(Unmarshaler).UnmarshalJSON(raw)
+		}
+	}
 	if err != nil {
+		status := fasthttp.StatusInternalServerError
+		if _, ok := err.(*ValidationError); ok {
+			status = fasthttp.StatusBadRequest
+		}
+		as.writeError(ctx, srvResponse, status, err)
+		return
+	}
+
+	if keyring != nil && methodSpec.argsPlan.encrypted {
+		if err = decryptFields(keyring, args); err != nil {
+			as.writeError(ctx, srvResponse, fasthttp.StatusBadRequest, err)
+			return
+		}
+	}
 
-		errAPI := acquireError()
-		errAPI.ErrorHTTPCode = fasthttp.StatusInternalServerError
-		errAPI.ErrorCode = 0
-		errAPI.ErrorMessage = err.Error()
+	if methodSpec.argsPlan.sanitized {
+		if err = sanitizeArgs(args); err != nil {
+			as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+			return
+		}
+	}
 
-		srvResponse.Error = errAPI
-		WriteResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
-		releaseError(errAPI)
+	if !as.moderateArgs(ctx, srvResponse, methodSpec, args) {
 		return
 	}
 
+	if err = validateArgs(args); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusBadRequest, err)
+		return
+	}
+
+	as.markTiming(ctx, "decode", phase)
+
 	// Call the service method.
+	phase = time.Now()
 	reply := reflect.New(methodSpec.replyType)
 	errValue := methodSpec.method.Func.Call([]reflect.Value{
 		methodSpec.rcvr,
@@ -222,37 +537,143 @@ func (as *VAPI) CallAPI(ctx *fasthttp.RequestCtx, method string) {
 		reply,
 	})
 
+	as.markTiming(ctx, "handler", phase)
+
 	errInter := errValue[0].Interface()
-	if errInter != nil {
+	directive, isDirective := errInter.(replyDirective)
+	if errInter != nil && !isDirective {
 		// TODO FIX THIS LOGIC!!!
 		srvResponse.Error = errInter.(*Error)
-		WriteResponse(ctx, srvResponse.Error.ErrorHTTPCode, *srvResponse)
+		as.writeResponse(ctx, srvResponse.Error.ErrorHTTPCode, *srvResponse)
 		return
 	}
 
-	repBytes, err := reply.Interface().(Marshaler).MarshalJSON()
+	if IsDryRun(ctx) {
+		discardDryRun(ctx)
+	} else if err = as.addEventsTx(ctx); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	} else if err = commitTx(ctx); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	if err = as.flushEvents(ctx); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	if err = as.saveSession(ctx); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	as.flushPurges(ctx)
+
+	if isDirective {
+		directive.writeReply(ctx)
+		as.finishResponse(ctx)
+		return
+	}
+
+	if err = hideReplyFields(ctx, reply); err != nil {
+		status := fasthttp.StatusInternalServerError
+		if err == errUnredactable {
+			status = fasthttp.StatusForbidden
+		}
+		as.writeError(ctx, srvResponse, status, err)
+		return
+	}
+
+	if file, ok := reply.Interface().(*FileReply); ok {
+		as.writeFile(ctx, srvResponse, methodSpec, file)
+		as.setHSTS(ctx)
+		return
+	}
+
+	// replies of in-process calls are decoded by the calling method, not sent to clients
+	inProcess := ctx.UserValue(callDepthUserValue) != nil
+	if !inProcess {
+		writeSurrogateKeys(ctx, methodSpec)
+		if writeCacheHeaders(ctx, methodSpec, reply, sessions != nil) {
+			return
+		}
+	}
+
+	if locale != nil && locale.Location != nil && methodSpec.replyPlan.localtime {
+		localizeTimes(reply, locale.Location)
+	}
+
+	if err = as.moderateReply(ctx, methodSpec, reply); err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, err)
+		return
+	}
+
+	if !inProcess && keyring != nil && methodSpec.replyPlan.encrypted {
+		if err = encryptFields(keyring, reply); err != nil {
+			as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if !inProcess && as.renderHTML(ctx, srvResponse, methodSpec, reply) {
+		return
+	}
+
+	phase = time.Now()
+	var repBytes []byte
+	if !inProcess {
+		var spilled bool
+		if repBytes, spilled, err = as.spillEncoded(ctx, srvResponse, reply); spilled || err != nil {
+			if err != nil {
+				as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+			}
+			return
+		}
+	}
+	if repBytes == nil {
+		repBytes, err = reply.Interface().(Marshaler).MarshalJSON()
+	}
+	if err == nil {
+		repBytes, err = applyObligations(ctx, repBytes)
+	}
+	if err == nil {
+		repBytes, err = as.downgradeReply(ctx, methodSpec, repBytes)
+	}
+	if err == nil && canonical {
+		repBytes, err = CanonicalJSON(repBytes)
+	}
+	var spill *SpillOptions
+	if err == nil && !inProcess {
+		spill = as.spillOf(srvResponse, len(repBytes))
+	}
+	if err == nil && spill == nil {
+		err = reservation.grow(int64(len(repBytes)))
+	}
 	if err != nil {
+		as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		return
+	}
 
-		errAPI := acquireError()
-		errAPI.ErrorHTTPCode = fasthttp.StatusInternalServerError
-		errAPI.ErrorCode = 0
-		errAPI.ErrorMessage = err.Error()
+	as.markTiming(ctx, "encode", phase)
 
-		srvResponse.Error = errAPI
-		WriteResponse(ctx, errAPI.ErrorHTTPCode, *srvResponse)
-		releaseError(errAPI)
+	if spill != nil {
+		if err = as.spillReply(ctx, srvResponse, spill, reply, dumpBytes(repBytes)); err != nil {
+			as.writeError(ctx, srvResponse, fasthttp.StatusInternalServerError, err)
+		}
 		return
 	}
 
 	srvResponse.Response = repBytes
-	WriteResponse(ctx, fasthttp.StatusOK, *srvResponse)
+	as.writeResponse(ctx, successStatus(ctx, reply), *srvResponse)
 	return
 }
 
 // NewServer returns a new RPC server.
 func NewServer() *VAPI {
 	return &VAPI{
-		services: make(map[string]bool),
-		methods:  make(map[string]*serviceMethod),
+		services:  make(map[string]bool),
+		methods:   make(map[string]*serviceMethod),
+		pollDrain: make(chan struct{}),
 	}
 }
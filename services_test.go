@@ -52,6 +52,24 @@ func TestVAPI_RegisterService(t *testing.T) {
 	}
 }
 
+func TestVAPI_RegisterService_Concurrent(t *testing.T) {
+	as := NewServer()
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			done <- as.RegisterService(new(DemoAPI), fmt.Sprintf("demo%d", i))
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+	if status, _, _ := as.callLocal("demo7.Test", []byte(`{"id":"1"}`)); status != 200 {
+		t.Error(fmt.Sprintf("concurrently registered method must be served, got %d", status))
+	}
+}
+
 func TestVAPI_GetServiceMap(t *testing.T) {
 	tt, err := apiService.GetServiceMap()
 	if err != nil {
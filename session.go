@@ -0,0 +1,337 @@
+package vapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// sessionUserValue is the RequestCtx user value key of the loaded Session
+const sessionUserValue = "vapi.session"
+
+// SessionOptions configures cookie sessions of browser clients
+type SessionOptions struct {
+	// Keyring encrypts and authenticates the cookie, required.
+	// AES-GCM from NewAESKeyring both hides and signs the value.
+	Keyring Keyring
+	// Store keeps session values server side and the cookie carries only the
+	// session id. When nil the values are kept in the cookie itself.
+	Store Store
+	// CookieName defaults to "vapi_session"
+	CookieName string
+	// MaxAge of the session, 24 hours when zero
+	MaxAge time.Duration
+	// Domain and Path of the cookie, Path defaults to "/"
+	Domain string
+	Path   string
+	// Insecure allows sending the cookie over plain http (local development)
+	Insecure bool
+	// SameSite mode of the cookie, strict when zero
+	SameSite fasthttp.CookieSameSite
+	// TrustedOrigins are origins besides the server itself, e.g. "https://app.example.com",
+	// allowed to make calls carrying the session cookie
+	TrustedOrigins []string
+}
+
+// Session holds values of a browser session
+type Session struct {
+	id       string
+	previous string // id replaced by Renew, removed from the store on save
+	values   map[string]string
+	changed  bool
+	destroy  bool
+}
+
+// sessionCookie is the sealed content of the session cookie
+type sessionCookie struct {
+	ID      string            `json:"id"`
+	Expires int64             `json:"exp"`
+	Values  map[string]string `json:"values,omitempty"`
+}
+
+// errForeignOrigin is returned to calls carrying the session cookie from untrusted origins
+var errForeignOrigin = errors.New("vapi: session calls from foreign origin are forbidden")
+
+// SetSessions enables cookie sessions, nil Keyring disables them.
+//
+// Calls carrying the session cookie are protected against cross-site request
+// forgery: their Origin (or Referer) must be the server itself or one of
+// TrustedOrigins, otherwise they get 403. Calls without both headers are
+// refused too unless SameSite is strict, which keeps browsers from sending
+// the cookie cross-site at all.
+func (as *VAPI) SetSessions(options SessionOptions) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if options.Keyring == nil {
+		as.sessions = nil
+		return
+	}
+	if options.CookieName == "" {
+		options.CookieName = "vapi_session"
+	}
+	if options.MaxAge <= 0 {
+		options.MaxAge = 24 * time.Hour
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.SameSite == 0 {
+		options.SameSite = fasthttp.CookieSameSiteStrictMode
+	}
+	options.TrustedOrigins = append([]string(nil), options.TrustedOrigins...)
+	as.sessions = &options
+}
+
+// GetSession returns the session of the current call, a new empty session when
+// the request has no valid session cookie. Changes are saved and the cookie is
+// set when the call succeeds. Returns nil when sessions are disabled.
+func GetSession(ctx *fasthttp.RequestCtx) *Session {
+	if session, ok := ctx.UserValue(sessionUserValue).(*Session); ok {
+		return session
+	}
+	as, ok := ctx.UserValue(serverUserValue).(*VAPI)
+	if !ok {
+		return nil
+	}
+	options := as.sessionOptions()
+	if options == nil {
+		return nil
+	}
+
+	session := loadSession(ctx, options)
+	ctx.SetUserValue(sessionUserValue, session)
+	return session
+}
+
+// SessionPrincipal returns PrincipalFunc taking the principal from session value key,
+// e.g. the user id stored at login
+func SessionPrincipal(key string) PrincipalFunc {
+	return func(ctx *fasthttp.RequestCtx) string {
+		session := GetSession(ctx)
+		if session == nil {
+			return ""
+		}
+		return session.Get(key)
+	}
+}
+
+// ID returns the session id
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Get returns session value of key, empty when not set
+func (s *Session) Get(key string) string {
+	return s.values[key]
+}
+
+// Set sets session value of key
+func (s *Session) Set(key, value string) {
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes session value of key
+func (s *Session) Delete(key string) {
+	delete(s.values, key)
+	s.changed = true
+}
+
+// Destroy ends the session, e.g. at logout: values are dropped and the cookie expires
+func (s *Session) Destroy() {
+	s.values = map[string]string{}
+	s.destroy = true
+}
+
+// Renew gives the session a new id keeping its values, call it after login
+// to prevent session fixation. Values kept in the Store under the old id are removed.
+func (s *Session) Renew() error {
+	id, err := NewUUID()
+	if err != nil {
+		return err
+	}
+	if s.previous == "" {
+		s.previous = s.id
+	}
+	s.id = id.String()
+	s.changed = true
+	return nil
+}
+
+// sessionOptions returns the session options, nil when sessions are disabled
+func (as *VAPI) sessionOptions() *SessionOptions {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	return as.sessions
+}
+
+// checkSessionOrigin rejects calls carrying the session cookie from foreign origins,
+// returns false when the call was answered
+func (as *VAPI) checkSessionOrigin(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, options *SessionOptions) bool {
+	if options == nil || len(ctx.Request.Header.Cookie(options.CookieName)) == 0 {
+		return true
+	}
+
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	if origin == "" {
+		if referer := ctx.Request.Header.Referer(); len(referer) > 0 {
+			uri := fasthttp.AcquireURI()
+			uri.Parse(nil, referer)
+			origin = string(uri.Scheme()) + "://" + string(uri.Host())
+			fasthttp.ReleaseURI(uri)
+		}
+	}
+	if origin == "" && options.SameSite == fasthttp.CookieSameSiteStrictMode {
+		return true
+	}
+	if origin != "" {
+		host := origin
+		if i := strings.Index(origin, "://"); i >= 0 {
+			host = origin[i+3:]
+		}
+		if len(ctx.Host()) > 0 && host == string(ctx.Host()) {
+			return true
+		}
+		for _, trusted := range options.TrustedOrigins {
+			if origin == trusted {
+				return true
+			}
+		}
+	}
+	as.writeError(ctx, srvResponse, fasthttp.StatusForbidden, errForeignOrigin)
+	return false
+}
+
+// loadSession opens the session cookie, invalid and expired cookies start a new session
+func loadSession(ctx *fasthttp.RequestCtx, options *SessionOptions) *Session {
+	session := &Session{values: map[string]string{}}
+
+	content, err := openSessionCookie(options, ctx.Request.Header.Cookie(options.CookieName))
+	if err == nil && options.Store != nil {
+		var stored []byte
+		var found bool
+		if stored, found, err = options.Store.Get(sessionKey(content.ID)); err == nil && !found {
+			err = errors.New("session not found")
+		}
+		if err == nil {
+			err = json.Unmarshal(stored, &content.Values)
+		}
+	}
+	if err != nil {
+		session.Renew()
+		session.changed = false
+		return session
+	}
+
+	session.id = content.ID
+	if content.Values != nil {
+		session.values = content.Values
+	}
+	return session
+}
+
+// saveSession stores changed session and sets the cookie
+func (as *VAPI) saveSession(ctx *fasthttp.RequestCtx) error {
+	session, ok := ctx.UserValue(sessionUserValue).(*Session)
+	if !ok || (!session.changed && !session.destroy) {
+		return nil
+	}
+	options := as.sessionOptions()
+	if options == nil {
+		return nil
+	}
+	if session.previous != "" && options.Store != nil {
+		if err := options.Store.Delete(sessionKey(session.previous)); err != nil {
+			return err
+		}
+		session.previous = ""
+	}
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey(options.CookieName)
+	cookie.SetDomain(options.Domain)
+	cookie.SetPath(options.Path)
+	cookie.SetHTTPOnly(true)
+	cookie.SetSecure(!options.Insecure)
+	cookie.SetSameSite(options.SameSite)
+
+	if session.destroy {
+		if options.Store != nil {
+			if err := options.Store.Delete(sessionKey(session.id)); err != nil {
+				return err
+			}
+		}
+		cookie.SetExpire(fasthttp.CookieExpireDelete)
+		SetCookie(ctx, cookie)
+		return nil
+	}
+
+	expires := time.Now().Add(options.MaxAge)
+	content := sessionCookie{ID: session.id, Expires: expires.Unix()}
+	if options.Store != nil {
+		values, err := json.Marshal(session.values)
+		if err != nil {
+			return err
+		}
+		if err = options.Store.Set(sessionKey(session.id), values, options.MaxAge); err != nil {
+			return err
+		}
+	} else {
+		content.Values = session.values
+	}
+
+	value, err := sealSessionCookie(options, content)
+	if err != nil {
+		return err
+	}
+	cookie.SetValue(value)
+	cookie.SetExpire(expires)
+	SetCookie(ctx, cookie)
+	return nil
+}
+
+// sealSessionCookie encrypts cookie content
+func sealSessionCookie(options *SessionOptions, content sessionCookie) (string, error) {
+	plaintext, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := options.Keyring.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openSessionCookie decrypts cookie value and checks its expiration
+func openSessionCookie(options *SessionOptions, value []byte) (sessionCookie, error) {
+	content := sessionCookie{}
+	if len(value) == 0 {
+		return content, errors.New("no session cookie")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(string(value))
+	if err != nil {
+		return content, err
+	}
+	plaintext, err := options.Keyring.Decrypt(sealed)
+	if err != nil {
+		return content, err
+	}
+	if err = json.Unmarshal(plaintext, &content); err != nil {
+		return content, err
+	}
+	if content.ID == "" || time.Now().Unix() > content.Expires {
+		return content, errors.New("session expired")
+	}
+	return content, nil
+}
+
+// sessionKey returns the store key of session id
+func sessionKey(id string) string {
+	return "vapi:session:" + id
+}
@@ -0,0 +1,133 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type SessionAPI struct{}
+
+func (s *SessionAPI) Login(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	session := GetSession(ctx)
+	session.Set("user", args.ID)
+	return session.Renew()
+}
+
+func (s *SessionAPI) Me(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	reply.ID = SessionPrincipal("user")(ctx)
+	return nil
+}
+
+func (s *SessionAPI) Logout(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	GetSession(ctx).Destroy()
+	return nil
+}
+
+func TestSessions(t *testing.T) {
+	keyring, _ := NewAESKeyring([]byte("0123456789abcdef"))
+	for _, store := range []Store{nil, NewMemoryStore()} {
+		as := NewServer()
+		as.RegisterService(new(SessionAPI), "session")
+		as.SetSessions(SessionOptions{Keyring: keyring, Store: store})
+
+		call := func(method, cookie string) (string, string) {
+			var response *fasthttp.RequestCtx
+			_, body, _ := as.callLocalWith(method, []byte(`{"id":"alice"}`), nil, func(ctx *fasthttp.RequestCtx) {
+				response = ctx
+				if cookie != "" {
+					ctx.Request.Header.SetCookie("vapi_session", cookie)
+				}
+			})
+			c := fasthttp.Cookie{}
+			c.SetKey("vapi_session")
+			response.Response.Header.Cookie(&c)
+			return string(body), string(c.Value())
+		}
+
+		_, cookie := call("session.Login", "")
+		if cookie == "" || strings.Contains(cookie, "alice") {
+			t.Fatal(fmt.Sprintf("login must set sealed cookie: %q", cookie))
+		}
+		if body, _ := call("session.Me", cookie); !strings.Contains(body, `"alice"`) {
+			t.Error(fmt.Sprintf("session must carry the user: %s", body))
+		}
+		if body, _ := call("session.Me", cookie[:len(cookie)-2]+"xx"); strings.Contains(body, "alice") {
+			t.Error(fmt.Sprintf("tampered cookie must be rejected: %s", body))
+		}
+		if _, deleted := call("session.Logout", cookie); deleted != "" {
+			t.Error(fmt.Sprintf("logout must clear the cookie: %q", deleted))
+		}
+		if store != nil {
+			if body, _ := call("session.Me", cookie); strings.Contains(body, "alice") {
+				t.Error(fmt.Sprintf("destroyed session must be gone from the store: %s", body))
+			}
+		}
+	}
+}
+
+func TestSessions_Renew(t *testing.T) {
+	keyring, _ := NewAESKeyring([]byte("0123456789abcdef"))
+	store := NewMemoryStore()
+	as := NewServer()
+	as.RegisterService(new(SessionAPI), "session")
+	as.SetSessions(SessionOptions{Keyring: keyring, Store: store})
+
+	session := &Session{id: "old", values: map[string]string{"user": "alice"}}
+	store.Set(sessionKey("old"), []byte(`{"user":"alice"}`), time.Minute)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue(sessionUserValue, session)
+	if err := session.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.saveSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Get(sessionKey("old")); found {
+		t.Error("renewed session must be removed from the store")
+	}
+	if _, found, _ := store.Get(sessionKey(session.ID())); !found {
+		t.Error("renewed session must be stored under the new id")
+	}
+}
+
+func TestSessions_Origin(t *testing.T) {
+	keyring, _ := NewAESKeyring([]byte("0123456789abcdef"))
+	as := NewServer()
+	as.RegisterService(new(SessionAPI), "session")
+	as.SetSessions(SessionOptions{Keyring: keyring, TrustedOrigins: []string{"https://app.example.com"}})
+
+	call := func(headers ...string) int {
+		status, _, _ := as.callLocalWith("session.Me", []byte(`{}`), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.SetHost("api.example.com")
+			ctx.Request.Header.SetCookie("vapi_session", "sealed")
+			for i := 0; i < len(headers); i += 2 {
+				ctx.Request.Header.Set(headers[i], headers[i+1])
+			}
+		})
+		return status
+	}
+
+	if status := call("Origin", "https://evil.example.net"); status != fasthttp.StatusForbidden {
+		t.Error(fmt.Sprintf("foreign origin must be rejected, got %d", status))
+	}
+	if status := call("Referer", "https://evil.example.net/page"); status != fasthttp.StatusForbidden {
+		t.Error(fmt.Sprintf("foreign referer must be rejected, got %d", status))
+	}
+	for _, origin := range []string{"https://api.example.com", "https://app.example.com"} {
+		if status := call("Origin", origin); status != 200 {
+			t.Error(fmt.Sprintf("%s must be served, got %d", origin, status))
+		}
+	}
+	if status := call(); status != 200 {
+		t.Error(fmt.Sprintf("strict cookie without origin must be served, got %d", status))
+	}
+
+	as.SetSessions(SessionOptions{Keyring: keyring, SameSite: fasthttp.CookieSameSiteLaxMode})
+	if status := call(); status != fasthttp.StatusForbidden {
+		t.Error(fmt.Sprintf("lax cookie without origin must be rejected, got %d", status))
+	}
+}
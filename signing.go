@@ -0,0 +1,190 @@
+package vapi
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SignatureHeader is the response header carrying the detached JWS of the response body
+const SignatureHeader = "X-JWS-Signature"
+
+// Signer is the interface implemented by keys able to produce JWS signatures.
+type Signer interface {
+	// Algorithm returns the JWS "alg" header value, e.g. "HS256"
+	Algorithm() string
+	// KeyID returns the JWS "kid" header value, may be empty
+	KeyID() string
+	// Sign returns the signature of the signing input
+	Sign(signingInput []byte) ([]byte, error)
+}
+
+// Verifier is the interface implemented by keys able to check JWS signatures.
+type Verifier interface {
+	Algorithm() string
+	Verify(signingInput, signature []byte) error
+}
+
+// jwsHeader is the protected header of produced signatures
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// hmacKey implements Signer and Verifier with HMAC SHA-256
+type hmacKey struct {
+	keyID  string
+	secret []byte
+}
+
+// NewHMACSigner returns HS256 Signer for the shared secret
+func NewHMACSigner(keyID string, secret []byte) Signer {
+	return &hmacKey{keyID: keyID, secret: secret}
+}
+
+// NewHMACVerifier returns HS256 Verifier for the shared secret
+func NewHMACVerifier(secret []byte) Verifier {
+	return &hmacKey{secret: secret}
+}
+
+func (k *hmacKey) Algorithm() string { return "HS256" }
+
+func (k *hmacKey) KeyID() string { return k.keyID }
+
+func (k *hmacKey) Sign(signingInput []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(signingInput)
+	return mac.Sum(nil), nil
+}
+
+func (k *hmacKey) Verify(signingInput, signature []byte) error {
+	expected, _ := k.Sign(signingInput)
+	if !hmac.Equal(expected, signature) {
+		return errors.New("vapi: signature mismatch")
+	}
+	return nil
+}
+
+// rsaSigner implements Signer with RSASSA-PKCS1-v1_5 SHA-256
+type rsaSigner struct {
+	keyID string
+	key   *rsa.PrivateKey
+}
+
+// NewRSASigner returns RS256 Signer for the private key
+func NewRSASigner(keyID string, key *rsa.PrivateKey) Signer {
+	return &rsaSigner{keyID: keyID, key: key}
+}
+
+func (k *rsaSigner) Algorithm() string { return "RS256" }
+
+func (k *rsaSigner) KeyID() string { return k.keyID }
+
+func (k *rsaSigner) Sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	return rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+}
+
+// rsaVerifier implements Verifier with RSASSA-PKCS1-v1_5 SHA-256
+type rsaVerifier struct {
+	key *rsa.PublicKey
+}
+
+// NewRSAVerifier returns RS256 Verifier for the public key
+func NewRSAVerifier(key *rsa.PublicKey) Verifier {
+	return &rsaVerifier{key: key}
+}
+
+func (k *rsaVerifier) Algorithm() string { return "RS256" }
+
+func (k *rsaVerifier) Verify(signingInput, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	return rsa.VerifyPKCS1v15(k.key, crypto.SHA256, digest[:], signature)
+}
+
+// SetResponseSigner enables signing of response bodies.
+// Every buffered response (json, html, redirects and other reply directives) gets
+// the detached JWS (RFC 7515, Appendix F) of its body in SignatureHeader.
+// File replies are streamed to the client and are never signed.
+// Passing nil disables signing.
+func (as *VAPI) SetResponseSigner(signer Signer) {
+	as.mutex.Lock()
+	as.signer = signer
+	as.mutex.Unlock()
+}
+
+// SignDetached returns the detached JWS ("header..signature") of payload
+func SignDetached(signer Signer, payload []byte) (string, error) {
+	header, err := json.Marshal(jwsHeader{Algorithm: signer.Algorithm(), KeyID: signer.KeyID()})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+
+	signature, err := signer.Sign(jwsSigningInput(encodedHeader, payload))
+	if err != nil {
+		return "", err
+	}
+
+	return encodedHeader + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyDetached checks the detached JWS produced by SignDetached against payload.
+// Clients use it to verify the SignatureHeader of received responses.
+func VerifyDetached(verifier Verifier, payload []byte, jws string) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return errors.New("vapi: malformed detached jws")
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("vapi: malformed jws header: %s", err.Error())
+	}
+	header := jwsHeader{}
+	if err = json.Unmarshal(rawHeader, &header); err != nil {
+		return fmt.Errorf("vapi: malformed jws header: %s", err.Error())
+	}
+	if header.Algorithm != verifier.Algorithm() {
+		return fmt.Errorf("vapi: unexpected jws algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("vapi: malformed jws signature: %s", err.Error())
+	}
+
+	return verifier.Verify(jwsSigningInput(parts[0], payload), signature)
+}
+
+// jwsSigningInput builds "header.base64url(payload)"
+func jwsSigningInput(encodedHeader string, payload []byte) []byte {
+	input := make([]byte, 0, len(encodedHeader)+1+base64.RawURLEncoding.EncodedLen(len(payload)))
+	input = append(input, encodedHeader...)
+	input = append(input, '.')
+	return append(input, base64.RawURLEncoding.EncodeToString(payload)...)
+}
+
+// signResponse sets SignatureHeader for the already written response body
+func signResponse(ctx *fasthttp.RequestCtx, signer Signer) {
+	jws, err := SignDetached(signer, ctx.Response.Body())
+	if err != nil {
+		// never send unsigned bodies when signing is required
+		ctx.Response.Header.Del(SignatureHeader)
+		ctx.Response.Header.Del("Location")
+		WriteResponse(ctx, fasthttp.StatusInternalServerError, ServerResponse{
+			Error: &Error{ErrorMessage: "can't sign response", Data: err.Error()},
+		})
+		return
+	}
+	ctx.Response.Header.Set(SignatureHeader, jws)
+}
@@ -0,0 +1,105 @@
+package vapi
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSignDetached_HMAC(t *testing.T) {
+	payload := []byte(`{"response":{"id":"onomnomnom"}}`)
+
+	jws, err := SignDetached(NewHMACSigner("k1", []byte("secret")), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = VerifyDetached(NewHMACVerifier([]byte("secret")), payload, jws); err != nil {
+		t.Error(err)
+	}
+
+	if err = VerifyDetached(NewHMACVerifier([]byte("secret")), []byte(`{"response":{}}`), jws); err == nil {
+		t.Error("tampered payload must not be verified")
+	}
+
+	if err = VerifyDetached(NewHMACVerifier([]byte("other")), payload, jws); err == nil {
+		t.Error("signature with other key must not be verified")
+	}
+}
+
+func TestSignDetached_RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"response":{"id":"onomnomnom"}}`)
+
+	jws, err := SignDetached(NewRSASigner("", key), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = VerifyDetached(NewRSAVerifier(&key.PublicKey), payload, jws); err != nil {
+		t.Error(err)
+	}
+
+	if err = VerifyDetached(NewHMACVerifier([]byte("secret")), payload, jws); err == nil {
+		t.Error("algorithm mismatch must not be verified")
+	}
+}
+
+type brokenSigner struct{}
+
+func (brokenSigner) Algorithm() string { return "HS256" }
+func (brokenSigner) KeyID() string     { return "" }
+func (brokenSigner) Sign(signingInput []byte) ([]byte, error) {
+	return nil, errors.New(`key "k1" is revoked`)
+}
+
+func TestSignResponse_Directive(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(NavigationAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	as.SetResponseSigner(NewHMACSigner("k1", []byte("secret")))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBody([]byte(`{"id":"home"}`))
+	as.CallAPI(ctx, "NavigationAPI.Login")
+
+	jws := string(ctx.Response.Header.Peek(SignatureHeader))
+	if err := VerifyDetached(NewHMACVerifier([]byte("secret")), ctx.Response.Body(), jws); err != nil {
+		t.Error(fmt.Sprintf("redirect must be signed: %s", err))
+	}
+}
+
+func TestSignResponse_Error(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(NavigationAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+	as.SetResponseSigner(brokenSigner{})
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBody([]byte(`{"id":"home"}`))
+	as.CallAPI(ctx, "NavigationAPI.Login")
+
+	if ctx.Response.StatusCode() != fasthttp.StatusInternalServerError || len(ctx.Response.Header.Peek("Location")) != 0 {
+		t.Error(fmt.Sprintf("wrong signing failure: %d %s", ctx.Response.StatusCode(), ctx.Response.Header.Peek("Location")))
+	}
+	reply := struct {
+		Error struct {
+			Data string `json:"data"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(ctx.Response.Body(), &reply); err != nil || reply.Error.Data != `key "k1" is revoked` {
+		t.Error(fmt.Sprintf("wrong signing error body: %s", ctx.Response.Body()))
+	}
+}
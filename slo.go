@@ -0,0 +1,206 @@
+package vapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// sloSlots is the number of slots rolling windows of objectives are split into
+const sloSlots = 60
+
+// DefaultObjectiveWindow is the default rolling window of objectives
+const DefaultObjectiveWindow = 24 * time.Hour
+
+var errBudgetExhausted = errors.New("vapi: error budget is exhausted, shedding load")
+
+// Objective is a service level objective of a service, e.g. 99.9% of calls
+// without server errors and 99% of them faster than 300ms
+type Objective struct {
+	// Service is the registered service name
+	Service string `json:"service"`
+	// Availability is the target share of calls without 5xx status, e.g. 0.999; 0 disables it
+	Availability float64 `json:"availability,omitempty"`
+	// Latency is the threshold of fast calls and LatencyTarget the target share of them; 0 disables it
+	Latency       Duration `json:"latency,omitempty"`
+	LatencyTarget float64  `json:"latency_target,omitempty"`
+	// Window is the rolling window budgets are computed over, DefaultObjectiveWindow when zero.
+	// It is split into 60 slots, in practice it should be a minute at least.
+	Window Duration `json:"window,omitempty"`
+	// Shed rejects calls of the service below PriorityHigh with 503 while a budget is exhausted
+	Shed bool `json:"shed,omitempty"`
+}
+
+// ErrorBudget is the state of an Objective over its window. Remaining
+// budgets are shares of the allowed bad calls not used yet, negative when
+// the objective is violated.
+type ErrorBudget struct {
+	Service          string   `json:"service"`
+	Window           Duration `json:"window"`
+	Calls            uint64   `json:"calls"`
+	Errors           uint64   `json:"errors"`
+	Slow             uint64   `json:"slow"`
+	Availability     float64  `json:"availability"`
+	AvailabilityLeft float64  `json:"availability_budget_left"`
+	LatencyLeft      float64  `json:"latency_budget_left"`
+	Exhausted        bool     `json:"exhausted"`
+}
+
+// sloSlot counts calls of a slot of the window
+type sloSlot struct {
+	epoch  int64
+	calls  uint64
+	errors uint64
+	slow   uint64
+}
+
+// objectiveTracker accounts calls of a service against its objective
+type objectiveTracker struct {
+	Objective
+	now func() time.Time
+
+	mutex sync.Mutex
+	slots [sloSlots]sloSlot
+}
+
+// LoadObjectives reads objectives from a json file holding an array of Objective
+func LoadObjectives(path string) ([]Objective, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var objectives []Objective
+	if err = json.Unmarshal(data, &objectives); err != nil {
+		return nil, fmt.Errorf("vapi: invalid objectives in %s: %s", path, err.Error())
+	}
+	return objectives, nil
+}
+
+// SetObjectives replaces service level objectives, budgets are counted from now
+func (as *VAPI) SetObjectives(objectives ...Objective) error {
+	trackers := make(map[string]*objectiveTracker, len(objectives))
+	for _, objective := range objectives {
+		if !as.hasService(objective.Service) {
+			return fmt.Errorf("vapi: objective of unknown service %q", objective.Service)
+		}
+		if objective.Availability < 0 || objective.Availability >= 1 || objective.LatencyTarget < 0 || objective.LatencyTarget >= 1 {
+			return fmt.Errorf("vapi: objective targets of %s must be from 0 to 1 exclusive", objective.Service)
+		}
+		if objective.Window == 0 {
+			objective.Window = Duration(DefaultObjectiveWindow)
+		}
+		if objective.Window < Duration(sloSlots) {
+			return fmt.Errorf("vapi: objective window of %s must be at least %s", objective.Service, time.Duration(sloSlots))
+		}
+		trackers[objective.Service] = &objectiveTracker{Objective: objective, now: time.Now}
+	}
+
+	as.mutex.Lock()
+	as.objectives = trackers
+	as.mutex.Unlock()
+	return nil
+}
+
+// ErrorBudgets returns budgets of all objectives sorted by service
+func (as *VAPI) ErrorBudgets() []ErrorBudget {
+	as.mutex.RLock()
+	budgets := make([]ErrorBudget, 0, len(as.objectives))
+	for _, tracker := range as.objectives {
+		budgets = append(budgets, tracker.budget())
+	}
+	as.mutex.RUnlock()
+
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Service < budgets[j].Service })
+	return budgets
+}
+
+// hasService reports whether the service is registered
+func (as *VAPI) hasService(name string) bool {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	return as.services[name]
+}
+
+// objectiveOf returns the tracker of the method service, nil when it has no objective
+func (as *VAPI) objectiveOf(methodSpec *serviceMethod) *objectiveTracker {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	if len(as.objectives) == 0 {
+		return nil
+	}
+	service := methodSpec.name
+	if dot := strings.LastIndexByte(service, '.'); dot >= 0 {
+		service = service[:dot]
+	}
+	return as.objectives[service]
+}
+
+// shedOnBudget rejects low priority calls of services with exhausted budgets, returns false when the call must not proceed
+func (as *VAPI) shedOnBudget(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, tracker *objectiveTracker) bool {
+	if tracker == nil || !tracker.Shed || methodSpec.priority >= PriorityHigh || !tracker.budget().Exhausted {
+		return true
+	}
+	ctx.Response.Header.Set("Retry-After", "1")
+	as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errBudgetExhausted)
+	return false
+}
+
+// record accounts a finished call
+func (ot *objectiveTracker) record(status int, duration time.Duration) {
+	ot.mutex.Lock()
+	slot := ot.slot()
+	slot.calls++
+	if status >= 500 {
+		slot.errors++
+	}
+	if ot.Latency > 0 && duration > time.Duration(ot.Latency) {
+		slot.slow++
+	}
+	ot.mutex.Unlock()
+}
+
+// slot returns the current slot, reset when it belongs to a past window; ot.mutex must be held
+func (ot *objectiveTracker) slot() *sloSlot {
+	epoch := ot.now().UnixNano() / int64(time.Duration(ot.Window)/sloSlots)
+	slot := &ot.slots[epoch%sloSlots]
+	if slot.epoch != epoch {
+		*slot = sloSlot{epoch: epoch}
+	}
+	return slot
+}
+
+// budget computes the error budget over the window
+func (ot *objectiveTracker) budget() ErrorBudget {
+	budget := ErrorBudget{Service: ot.Service, Window: ot.Window, Availability: 1, AvailabilityLeft: 1, LatencyLeft: 1}
+
+	ot.mutex.Lock()
+	current := ot.slot().epoch
+	for _, slot := range ot.slots {
+		if slot.epoch > current-sloSlots {
+			budget.Calls += slot.calls
+			budget.Errors += slot.errors
+			budget.Slow += slot.slow
+		}
+	}
+	ot.mutex.Unlock()
+
+	if budget.Calls == 0 {
+		return budget
+	}
+	budget.Availability = 1 - float64(budget.Errors)/float64(budget.Calls)
+	if ot.Objective.Availability > 0 {
+		budget.AvailabilityLeft = 1 - float64(budget.Errors)/((1-ot.Objective.Availability)*float64(budget.Calls))
+	}
+	if ot.Latency > 0 && ot.LatencyTarget > 0 {
+		budget.LatencyLeft = 1 - float64(budget.Slow)/((1-ot.LatencyTarget)*float64(budget.Calls))
+	}
+	budget.Exhausted = budget.AvailabilityLeft <= 0 || budget.LatencyLeft <= 0
+	return budget
+}
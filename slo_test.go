@@ -0,0 +1,83 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetObjectives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slo.json")
+	ioutil.WriteFile(path, []byte(`[{"service":"demo","availability":0.9,"latency":"1h","latency_target":0.5,"window":"1h","shed":true}]`), 0600)
+
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	if err := as.SetObjectives(Objective{Service: "missing"}); err == nil {
+		t.Error("objective of unknown service must fail")
+	}
+	if err := as.SetObjectives(Objective{Service: "demo", Window: Duration(time.Nanosecond)}); err == nil {
+		t.Error("objective window shorter than its slots must fail")
+	}
+	objectives, err := LoadObjectives(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetObjectives(objectives...); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	as.objectives["demo"].now = func() time.Time { return now }
+
+	for i := 0; i < 18; i++ {
+		as.callLocal("demo.Test", []byte(`{}`))
+	}
+	as.SetMethodFault("demo.Test", &Fault{ErrorRate: 1, ErrorStatus: 500})
+	as.callLocal("demo.Test", []byte(`{}`))
+	if budget := as.ErrorBudgets()[0]; budget.Calls != 19 || budget.Errors != 1 || budget.Exhausted || budget.AvailabilityLeft < 0.47 || budget.AvailabilityLeft > 0.48 {
+		t.Error(fmt.Sprintf("wrong budget: %+v", budget))
+	}
+	as.callLocal("demo.Test", []byte(`{}`))
+	as.SetMethodFault("demo.Test", nil)
+	if budget := as.ErrorBudgets()[0]; !budget.Exhausted {
+		t.Error(fmt.Sprintf("budget must be exhausted: %+v", budget))
+	}
+
+	if status, body, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 503 || !strings.Contains(string(body), "error budget") {
+		t.Error(fmt.Sprintf("calls must be shed: %d %s", status, body))
+	}
+	as.EnterMaintenance(Maintenance{Message: "upgrade"})
+	as.callLocal("demo.Test", []byte(`{}`))
+	as.ExitMaintenance()
+	if budget := as.ErrorBudgets()[0]; budget.Calls != 20 {
+		t.Error(fmt.Sprintf("calls rejected by the server must not be counted: %+v", budget))
+	}
+	as.SetMethodPriority("demo.Test", PriorityHigh)
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 200 {
+		t.Error(fmt.Sprintf("high priority calls must not be shed, got %d", status))
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/_stats?slo")
+	as.StatsHandler(ctx)
+	budgets := []ErrorBudget{}
+	if err := json.Unmarshal(ctx.Response.Body(), &budgets); err != nil || len(budgets) != 1 || budgets[0].Calls != 21 {
+		t.Error(fmt.Sprintf("stats must serve budgets: %s", ctx.Response.Body()))
+	}
+
+	now = now.Add(2 * time.Hour)
+	if budget := as.ErrorBudgets()[0]; budget.Calls != 0 || budget.Exhausted {
+		t.Error(fmt.Sprintf("budget must recover out of the window: %+v", budget))
+	}
+}
@@ -0,0 +1,227 @@
+package vapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/valyala/fasthttp"
+)
+
+// spillFilePrefix prefixes names of spilled reply files
+const spillFilePrefix = "vapi-reply-"
+
+// DefaultSpillTTL is the default time spilled replies stay downloadable
+const DefaultSpillTTL = time.Hour
+
+// SpillOptions configures spilling of large replies to disk
+type SpillOptions struct {
+	// Threshold is the encoded reply size in bytes above which replies are spilled
+	Threshold int
+	// Dir keeps spilled replies, os.TempDir() when empty
+	Dir string
+	// URL is the public path SpillHandler is mounted at, e.g. "/_replies/"
+	URL string
+	// TTL is the time spilled replies stay downloadable, DefaultSpillTTL when zero
+	TTL time.Duration
+}
+
+// SpilledReply is the reply of calls whose encoded reply was spilled to disk.
+// The full json response is downloaded from URL, Range requests resume
+// interrupted downloads.
+type SpilledReply struct {
+	URL     string    `json:"url"`
+	Size    int64     `json:"size"`
+	Expires time.Time `json:"expires"`
+}
+
+// SetSpill makes replies larger than options.Threshold written to disk and
+// served from SpillHandler instead of the response body, nil disables it. The
+// call gets SpilledReply with Location header set to the download url unless the
+// method set Location itself. Spilled replies are not counted by SetMemoryLimit.
+// The reply is encoded in memory before it is written, so spilling bounds the
+// response body, not the peak memory of the call; replies implementing
+// easyjson.Marshaler are at least dumped from pooled chunks without being joined
+// into one buffer. Files are removed after TTL, leftovers of previous runs in
+// Dir are removed now.
+func (as *VAPI) SetSpill(options *SpillOptions) error {
+	if options == nil {
+		as.mutex.Lock()
+		as.spill = nil
+		as.mutex.Unlock()
+		return nil
+	}
+	if options.Threshold <= 0 || options.URL == "" {
+		return fmt.Errorf("vapi: spill needs threshold and url")
+	}
+	spill := *options
+	if spill.Dir == "" {
+		spill.Dir = os.TempDir()
+	}
+	if spill.TTL <= 0 {
+		spill.TTL = DefaultSpillTTL
+	}
+	if !strings.HasSuffix(spill.URL, "/") {
+		spill.URL += "/"
+	}
+
+	files, err := filepath.Glob(filepath.Join(spill.Dir, spillFilePrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if stat, err := os.Stat(name); err == nil && time.Since(stat.ModTime()) > spill.TTL {
+			os.Remove(name)
+		}
+	}
+
+	as.mutex.Lock()
+	as.spill = &spill
+	as.mutex.Unlock()
+	return nil
+}
+
+// SpillHandler serves spilled replies by the id following the last slash of the path,
+// mount it at SpillOptions.URL
+func (as *VAPI) SpillHandler(ctx *fasthttp.RequestCtx) {
+	as.mutex.RLock()
+	spill := as.spill
+	as.mutex.RUnlock()
+
+	path := string(ctx.Path())
+	id := path[strings.LastIndexByte(path, '/')+1:]
+	if spill == nil || len(id) != 32 || strings.Trim(id, "0123456789abcdef") != "" {
+		writeHandlerError(ctx, fasthttp.StatusNotFound, fmt.Errorf("vapi: reply not found"))
+		return
+	}
+	file, err := os.Open(filepath.Join(spill.Dir, spillFilePrefix+id))
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusNotFound, fmt.Errorf("vapi: reply not found or expired"))
+		return
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+
+	srvResponse := acquireResponse()
+	defer releaseResponse(srvResponse)
+	ctx.Response.Header.Set("x-content-type-options", "nosniff")
+	as.writeFile(ctx, srvResponse, &serviceMethod{}, &FileReply{
+		ContentType: "application/json; charset=utf-8",
+		ModTime:     stat.ModTime(),
+		ETag:        `"` + id + `"`,
+		Content:     file,
+	})
+}
+
+// spillOf returns spill options when reply of size bytes must be spilled, nil otherwise
+func (as *VAPI) spillOf(srvResponse *ServerResponse, size int) *SpillOptions {
+	as.mutex.RLock()
+	spill := as.spill
+	as.mutex.RUnlock()
+	if spill == nil || size <= spill.Threshold || srvResponse.Error != nil {
+		return nil
+	}
+	return spill
+}
+
+// spillEncoded encodes reply implementing easyjson.Marshaler and dumps the chunks of
+// the encoder into the spill file when it is over the threshold and needs no
+// transforms of the encoded bytes.
+// Returns the encoded reply when it was not spilled, nil when the reply must be
+// encoded as usual. The reply is spilled when true is returned, an error means
+// the spilled reply could not be written.
+func (as *VAPI) spillEncoded(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, reply reflect.Value) ([]byte, bool, error) {
+	marshaler, ok := reply.Interface().(easyjson.Marshaler)
+	if !ok {
+		return nil, false, nil
+	}
+	as.mutex.RLock()
+	spill, canonical := as.spill, as.canonical
+	as.mutex.RUnlock()
+	if spill == nil || canonical || ctx.UserValue(decisionUserValue) != nil || len(ctx.Request.Header.Peek(APIVersionHeader)) > 0 {
+		return nil, false, nil
+	}
+
+	w := jwriter.Writer{}
+	marshaler.MarshalEasyJSON(&w)
+	if w.Error != nil {
+		return nil, false, w.Error
+	}
+	if w.Size() > spill.Threshold && srvResponse.Error == nil {
+		return nil, true, as.spillReply(ctx, srvResponse, spill, reply, w.DumpTo)
+	}
+	encoded, err := w.BuildBytes()
+	return encoded, false, err
+}
+
+// spillReply writes the reply dumped by dump to disk and responds with SpilledReply
+func (as *VAPI) spillReply(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, spill *SpillOptions, reply reflect.Value, dump func(w io.Writer) (int, error)) error {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return err
+	}
+	name := filepath.Join(spill.Dir, spillFilePrefix+hex.EncodeToString(id))
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	size, err := writeEnvelope(file, dump)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	time.AfterFunc(spill.TTL, func() { os.Remove(name) })
+
+	spilled := SpilledReply{URL: spill.URL + hex.EncodeToString(id), Size: size, Expires: time.Now().Add(spill.TTL)}
+	encoded, err := json.Marshal(spilled)
+	if err != nil {
+		return err
+	}
+	srvResponse.Response = encoded
+	status := successStatus(ctx, reply)
+	if len(ctx.Response.Header.Peek("Location")) == 0 {
+		ctx.Response.Header.Set("Location", spilled.URL)
+	}
+	as.writeResponse(ctx, status, *srvResponse)
+	return nil
+}
+
+// dumpBytes returns the dump func writing encoded
+func dumpBytes(encoded []byte) func(w io.Writer) (int, error) {
+	return func(w io.Writer) (int, error) {
+		return w.Write(encoded)
+	}
+}
+
+// writeEnvelope writes the reply dumped by dump wrapped in the ServerResponse envelope,
+// returns the written size
+func writeEnvelope(w io.Writer, dump func(w io.Writer) (int, error)) (int64, error) {
+	n, err := w.Write([]byte(`{"response":`))
+	size := int64(n)
+	if err != nil {
+		return size, err
+	}
+	n, err = dump(w)
+	size += int64(n)
+	if err != nil {
+		return size, err
+	}
+	n, err = w.Write([]byte(`}`))
+	return size + int64(n), err
+}
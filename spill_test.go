@@ -0,0 +1,139 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stale := dir + "/" + spillFilePrefix + "stale"
+	ioutil.WriteFile(stale, []byte("{}"), 0600)
+	os.Chtimes(stale, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	if err := as.SetSpill(&SpillOptions{Threshold: 40, Dir: dir, URL: "/_replies"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale spilled reply must be removed")
+	}
+
+	if status, body, _ := as.callLocal("demo.Test", []byte(`{"id":"1"}`)); status != 200 || string(body) != `{"response":{"id":"1"}}` {
+		t.Error(fmt.Sprintf("small reply must not be spilled: %d %s", status, body))
+	}
+
+	id := strings.Repeat("x", 50)
+	status, body, _ := as.callLocal("demo.Test", []byte(`{"id":"`+id+`"}`))
+	envelope := struct {
+		Response SpilledReply `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &envelope); status != 200 || err != nil || !strings.HasPrefix(envelope.Response.URL, "/_replies/") {
+		t.Fatal(fmt.Sprintf("large reply must be spilled: %d %s", status, body))
+	}
+	expected := `{"response":{"id":"` + id + `"}}`
+	if envelope.Response.Size != int64(len(expected)) {
+		t.Error(fmt.Sprintf("wrong spilled size: %+v", envelope.Response))
+	}
+
+	download := func(url string, headers ...string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(url)
+		for i := 0; i < len(headers); i += 2 {
+			ctx.Request.Header.Set(headers[i], headers[i+1])
+		}
+		as.SpillHandler(ctx)
+		return ctx
+	}
+	if ctx := download(envelope.Response.URL); ctx.Response.StatusCode() != 200 || string(ctx.Response.Body()) != expected {
+		t.Error(fmt.Sprintf("wrong download: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	if ctx := download(envelope.Response.URL, "Range", "bytes=0-11"); ctx.Response.StatusCode() != 206 || string(ctx.Response.Body()) != `{"response":` {
+		t.Error(fmt.Sprintf("download must resume: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	if ctx := download("/_replies/../../etc/passwd"); ctx.Response.StatusCode() != 404 {
+		t.Error("unknown reply must not be found")
+	}
+}
+
+// ExportReport is a large reply encoded with easyjson
+type ExportReport struct {
+	Rows []string
+}
+
+func (r *ExportReport) MarshalEasyJSON(w *jwriter.Writer) {
+	w.RawString(`{"rows":[`)
+	for i, row := range r.Rows {
+		if i > 0 {
+			w.RawByte(',')
+		}
+		w.String(row)
+	}
+	w.RawString(`]}`)
+}
+
+func (r *ExportReport) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	r.MarshalEasyJSON(&w)
+	return w.BuildBytes()
+}
+
+// ExportAPI creates large exports
+type ExportAPI struct{}
+
+// Create creates the export
+func (h *ExportAPI) Create(ctx *fasthttp.RequestCtx, Args *TestArgs, Reply *ExportReport) error {
+	for i := 0; i < 100; i++ {
+		Reply.Rows = append(Reply.Rows, Args.ID)
+	}
+	Created(ctx, "/exports/"+Args.ID)
+	return nil
+}
+
+func TestVAPI_SetSpill_Reply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	as := NewServer()
+	as.RegisterService(new(ExportAPI), "export")
+	as.SetMemoryLimit(1024, 0)
+	if err := as.SetSpill(&SpillOptions{Threshold: 512, Dir: dir, URL: "/_replies"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBody([]byte(`{"id":"` + strings.Repeat("x", 20) + `"}`))
+	as.CallAPI(ctx, "export.Create")
+
+	envelope := struct {
+		Response SpilledReply `json:"response"`
+	}{}
+	if err := json.Unmarshal(ctx.Response.Body(), &envelope); ctx.Response.StatusCode() != 201 || err != nil {
+		t.Fatal(fmt.Sprintf("reply over the memory limit must be spilled: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+	if location := string(ctx.Response.Header.Peek("Location")); location != "/exports/"+strings.Repeat("x", 20) {
+		t.Error(fmt.Sprintf("method location must be kept: %s", location))
+	}
+	spilled, err := ioutil.ReadFile(dir + "/" + spillFilePrefix + strings.TrimPrefix(envelope.Response.URL, "/_replies/"))
+	if err != nil || int64(len(spilled)) != envelope.Response.Size || !strings.HasPrefix(string(spilled), `{"response":{"rows":["xx`) {
+		t.Error(fmt.Sprintf("wrong spilled reply: %v %+v %.40s", err, envelope.Response, spilled))
+	}
+}
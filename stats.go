@@ -0,0 +1,204 @@
+package vapi
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// latencyWindow is the number of recent calls latency percentiles are computed from
+const latencyWindow = 1024
+
+// sizeBuckets are upper bounds of payload size histogram buckets, the last bucket is unbounded
+var sizeBuckets = [...]uint64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// SizeBucket is a payload size histogram bucket, calls with size up to LE bytes
+// which didn't fit into the smaller buckets. LE is 0 for the unbounded last bucket.
+type SizeBucket struct {
+	LE    uint64 `json:"le"`
+	Calls uint64 `json:"calls"`
+}
+
+// MethodStats holds counters of a method
+type MethodStats struct {
+	Method   string `json:"method"`
+	Calls    uint64 `json:"calls"`
+	Errors   uint64 `json:"errors"`
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+	// latency percentiles of the recent calls
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	// payload size histograms
+	BytesInHistogram  []SizeBucket `json:"bytes_in_histogram"`
+	BytesOutHistogram []SizeBucket `json:"bytes_out_histogram"`
+	// calls per caller country, when GeoIP is enabled ("" is unknown)
+	Countries map[string]uint64 `json:"countries,omitempty"`
+	// calls compared with the canary candidate and those which differed
+	CanaryCompared   uint64 `json:"canary_compared,omitempty"`
+	CanaryMismatches uint64 `json:"canary_mismatches,omitempty"`
+}
+
+// methodStats accumulates calls of a method
+type methodStats struct {
+	mutex     sync.Mutex
+	calls     uint64
+	errors    uint64
+	bytesIn   uint64
+	bytesOut  uint64
+	latencies [latencyWindow]time.Duration
+	next      int
+	sizesIn   [len(sizeBuckets) + 1]uint64
+	sizesOut  [len(sizeBuckets) + 1]uint64
+	countries map[string]uint64
+	compared  uint64
+	mismatch  uint64
+}
+
+// record accounts a finished call
+func (ms *methodStats) record(latency time.Duration, status, bytesIn, bytesOut int) {
+	ms.mutex.Lock()
+	ms.calls++
+	if status >= 400 {
+		ms.errors++
+	}
+	ms.bytesIn += uint64(bytesIn)
+	ms.bytesOut += uint64(bytesOut)
+	ms.sizesIn[sizeBucket(uint64(bytesIn))]++
+	ms.sizesOut[sizeBucket(uint64(bytesOut))]++
+	ms.latencies[ms.next%latencyWindow] = latency
+	ms.next++
+	ms.mutex.Unlock()
+}
+
+// recordCountry counts a call from country
+func (ms *methodStats) recordCountry(country string) {
+	ms.mutex.Lock()
+	if ms.countries == nil {
+		ms.countries = make(map[string]uint64)
+	}
+	ms.countries[country]++
+	ms.mutex.Unlock()
+}
+
+// recordCanary counts a call compared with the canary candidate
+func (ms *methodStats) recordCanary(mismatch bool) {
+	ms.mutex.Lock()
+	ms.compared++
+	if mismatch {
+		ms.mismatch++
+	}
+	ms.mutex.Unlock()
+}
+
+// snapshot returns current counters of the method
+func (ms *methodStats) snapshot(method string) MethodStats {
+	ms.mutex.Lock()
+	stats := MethodStats{Method: method, Calls: ms.calls, Errors: ms.errors, BytesIn: ms.bytesIn, BytesOut: ms.bytesOut}
+	stats.BytesInHistogram, stats.BytesOutHistogram = sizeHistogram(ms.sizesIn[:]), sizeHistogram(ms.sizesOut[:])
+	stats.CanaryCompared, stats.CanaryMismatches = ms.compared, ms.mismatch
+	if len(ms.countries) > 0 {
+		stats.Countries = make(map[string]uint64, len(ms.countries))
+		for country, calls := range ms.countries {
+			stats.Countries[country] = calls
+		}
+	}
+	n := ms.next
+	if n > latencyWindow {
+		n = latencyWindow
+	}
+	latencies := make([]time.Duration, n)
+	copy(latencies, ms.latencies[:n])
+	ms.mutex.Unlock()
+
+	if n == 0 {
+		return stats
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(n-1)+0.5)]
+	}
+	stats.P50, stats.P95, stats.P99 = percentile(0.50), percentile(0.95), percentile(0.99)
+	return stats
+}
+
+// Stats returns counters of every registered method sorted by name.
+// Latency percentiles cover the last 1024 calls of the method.
+func (as *VAPI) Stats() []MethodStats {
+	as.mutex.RLock()
+	stats := make([]MethodStats, 0, len(as.methods))
+	for name, methodSpec := range as.methods {
+		stats = append(stats, methodSpec.stats.snapshot(name))
+	}
+	as.mutex.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Method < stats[j].Method })
+	return stats
+}
+
+// TopProducers returns n methods which sent the most bytes, to find the ones responsible for bandwidth growth
+func (as *VAPI) TopProducers(n int) []MethodStats {
+	stats := as.Stats()
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].BytesOut > stats[j].BytesOut })
+	if n >= 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// StatsHandler serves Stats as json, e.g. mounted at "/_stats" behind admin authentication.
+// With "?top=N" it serves TopProducers, with "?slo" ErrorBudgets and with
+// "?fields" FieldUsages instead.
+func (as *VAPI) StatsHandler(ctx *fasthttp.RequestCtx) {
+	var stats interface{} = as.Stats()
+	if top, err := ctx.QueryArgs().GetUint("top"); err == nil {
+		stats = as.TopProducers(top)
+	}
+	if ctx.QueryArgs().Has("slo") {
+		stats = as.ErrorBudgets()
+	}
+	if ctx.QueryArgs().Has("fields") {
+		stats = as.FieldUsages()
+	}
+	body, err := json.Marshal(stats)
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// sizeBucket returns index of histogram bucket of size
+func sizeBucket(size uint64) int {
+	for i, bound := range sizeBuckets {
+		if size <= bound {
+			return i
+		}
+	}
+	return len(sizeBuckets)
+}
+
+// sizeHistogram converts bucket counters to SizeBucket list
+func sizeHistogram(counts []uint64) []SizeBucket {
+	histogram := make([]SizeBucket, len(counts))
+	for i, count := range counts {
+		histogram[i].Calls = count
+		if i < len(sizeBuckets) {
+			histogram[i].LE = sizeBuckets[i]
+		}
+	}
+	return histogram
+}
+
+// responseSize returns response body size without draining streamed bodies
+func responseSize(ctx *fasthttp.RequestCtx) int {
+	if ctx.Response.IsBodyStream() {
+		return ctx.Response.Header.ContentLength()
+	}
+	return len(ctx.Response.Body())
+}
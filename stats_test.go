@@ -0,0 +1,50 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVAPI_Stats(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		as.callLocal("demo.Test", []byte(`{"id":"1"}`))
+	}
+	as.callLocal("demo.ErrorTest", []byte(`{}`))
+
+	stats := as.Stats()
+	if len(stats) != 2 || stats[0].Method != "demo.ErrorTest" || stats[1].Method != "demo.Test" {
+		t.Fatal(fmt.Sprintf("wrong stats: %+v", stats))
+	}
+	if stats[0].Calls != 1 || stats[0].Errors != 1 {
+		t.Error(fmt.Sprintf("wrong error counters: %+v", stats[0]))
+	}
+	if stats[1].Calls != 3 || stats[1].Errors != 0 || stats[1].BytesIn != 30 || stats[1].BytesOut == 0 {
+		t.Error(fmt.Sprintf("wrong counters: %+v", stats[1]))
+	}
+	if stats[1].BytesInHistogram[0] != (SizeBucket{LE: 256, Calls: 3}) {
+		t.Error(fmt.Sprintf("wrong size histogram: %+v", stats[1].BytesInHistogram))
+	}
+
+	as.callLocal("demo.Test", []byte(`{"id":"`+strings.Repeat("x", 2000)+`"}`))
+	if top := as.TopProducers(1); len(top) != 1 || top[0].Method != "demo.Test" || top[0].BytesOutHistogram[2].Calls != 1 {
+		t.Error(fmt.Sprintf("wrong top producers: %+v", top))
+	}
+}
+
+func TestMethodStats_Percentiles(t *testing.T) {
+	ms := &methodStats{}
+	for i := 1; i <= 100; i++ {
+		ms.record(time.Duration(i)*time.Millisecond, 200, 0, 0)
+	}
+	stats := ms.snapshot("m")
+	if stats.P50 != 51*time.Millisecond || stats.P95 != 95*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Error(fmt.Sprintf("wrong percentiles: %s %s %s", stats.P50, stats.P95, stats.P99))
+	}
+}
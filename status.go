@@ -0,0 +1,65 @@
+package vapi
+
+import (
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// statusUserValue is the RequestCtx user value key of the success status set by the method
+const statusUserValue = "vapi.status"
+
+// StatusCoder is implemented by replies choosing their success status, e.g. 201 Created
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// SetStatus sets the status of successful reply of the current call, e.g. 202 Accepted.
+// It takes precedence over StatusCoder; codes outside 2xx are ignored.
+func SetStatus(ctx *fasthttp.RequestCtx, code int) {
+	ctx.SetUserValue(statusUserValue, code)
+}
+
+// ResourceLocator is implemented by replies of create methods, the reply is sent
+// with 201 Created and the Location header set to ResourceLocation
+type ResourceLocator interface {
+	ResourceLocation() string
+}
+
+// Created marks the current call as created the resource at location:
+// the reply is sent with 201 Created and the Location header
+func Created(ctx *fasthttp.RequestCtx, location string) {
+	ctx.Response.Header.Set("Location", location)
+	SetStatus(ctx, fasthttp.StatusCreated)
+}
+
+// successStatus returns status of successful reply, 200 unless the method or reply chose another 2xx.
+// The Location header of ResourceLocator replies is set here too.
+func successStatus(ctx *fasthttp.RequestCtx, reply reflect.Value) int {
+	locator, isLocator := reply.Interface().(ResourceLocator)
+	if isLocator && len(ctx.Response.Header.Peek("Location")) == 0 {
+		if location := locator.ResourceLocation(); location != "" {
+			ctx.Response.Header.Set("Location", location)
+		} else {
+			isLocator = false
+		}
+	}
+
+	if code, ok := ctx.UserValue(statusUserValue).(int); ok && isSuccessStatus(code) {
+		return code
+	}
+	if coder, ok := reply.Interface().(StatusCoder); ok {
+		if code := coder.StatusCode(); isSuccessStatus(code) {
+			return code
+		}
+	}
+	if isLocator {
+		return fasthttp.StatusCreated
+	}
+	return fasthttp.StatusOK
+}
+
+// isSuccessStatus reports whether code is 2xx
+func isSuccessStatus(code int) bool {
+	return code >= 200 && code <= 299
+}
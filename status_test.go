@@ -0,0 +1,72 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type CreatedReply struct {
+	TestReply
+}
+
+func (r *CreatedReply) StatusCode() int { return fasthttp.StatusCreated }
+
+type LocatedReply struct {
+	TestReply
+}
+
+func (r *LocatedReply) ResourceLocation() string { return "/items/" + r.ID }
+
+type StatusAPI struct{}
+
+func (h *StatusAPI) Add(ctx *fasthttp.RequestCtx, args *TestArgs, reply *LocatedReply) error {
+	reply.ID = args.ID
+	return nil
+}
+
+func (h *StatusAPI) Put(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	Created(ctx, "/items/"+args.ID)
+	return nil
+}
+
+func (h *StatusAPI) Create(ctx *fasthttp.RequestCtx, args *TestArgs, reply *CreatedReply) error {
+	return nil
+}
+
+func (h *StatusAPI) Enqueue(ctx *fasthttp.RequestCtx, args *TestArgs, reply *CreatedReply) error {
+	SetStatus(ctx, fasthttp.StatusAccepted)
+	return nil
+}
+
+func (h *StatusAPI) Invalid(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	SetStatus(ctx, fasthttp.StatusNotFound)
+	return nil
+}
+
+func TestSuccessStatus(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(StatusAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for method, expected := range map[string]int{
+		"StatusAPI.Create":  fasthttp.StatusCreated,
+		"StatusAPI.Enqueue": fasthttp.StatusAccepted,
+		"StatusAPI.Invalid": fasthttp.StatusOK,
+		"StatusAPI.Add":     fasthttp.StatusCreated,
+		"StatusAPI.Put":     fasthttp.StatusCreated,
+	} {
+		if status, body, _ := as.callLocal(method, []byte(`{}`)); status != expected {
+			t.Error(fmt.Sprintf("%s: expected %d, got %d %s", method, expected, status, body))
+		}
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{"id":"7"}`)
+	as.CallAPI(ctx, "StatusAPI.Add")
+	if location := string(ctx.Response.Header.Peek("Location")); location != "/items/7" {
+		t.Error(fmt.Sprintf("wrong location %q", location))
+	}
+}
@@ -0,0 +1,170 @@
+package vapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCInternalError  = -32603
+)
+
+// jsonRPCRequest is a JSON-RPC 2.0 request
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonRPCResponse is a JSON-RPC 2.0 response
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+// jsonRPCError is a JSON-RPC 2.0 error object
+type jsonRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ServeStdio serves registered methods with line-delimited JSON-RPC 2.0 over
+// stdin and stdout, so the binary can run as a subprocess of editors and
+// other tools. It returns when stdin is closed.
+func (as *VAPI) ServeStdio() error {
+	return as.ServeJSONRPC(os.Stdin, os.Stdout)
+}
+
+// ServeJSONRPC reads one JSON-RPC 2.0 request (or batch) per line from r and writes
+// responses to w, one per line. "method" is "Service.Method" and "params" the args
+// object. Notifications (requests without id) get no response.
+func (as *VAPI) ServeJSONRPC(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	var mutex sync.Mutex
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if response := as.handleJSONRPC(line); response != nil {
+				mutex.Lock()
+				_, writeErr := w.Write(append(response, '\n'))
+				mutex.Unlock()
+				if writeErr != nil {
+					return writeErr
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleJSONRPC processes request line, returns nil when nothing must be written
+func (as *VAPI) handleJSONRPC(line []byte) []byte {
+	if line[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(line, &batch); err != nil || len(batch) == 0 {
+			return marshalJSONRPC(jsonRPCErrorResponse(nil, jsonRPCInvalidRequest, "invalid batch"))
+		}
+		var responses []json.RawMessage
+		for _, item := range batch {
+			if response := as.handleJSONRPCRequest(item); response != nil {
+				responses = append(responses, marshalJSONRPC(*response))
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		body, _ := json.Marshal(responses)
+		return body
+	}
+
+	if response := as.handleJSONRPCRequest(line); response != nil {
+		return marshalJSONRPC(*response)
+	}
+	return nil
+}
+
+// handleJSONRPCRequest invokes single request, nil response for notifications
+func (as *VAPI) handleJSONRPCRequest(data []byte) *jsonRPCResponse {
+	request := jsonRPCRequest{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		response := jsonRPCErrorResponse(nil, jsonRPCParseError, "parse error")
+		return &response
+	}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response := jsonRPCErrorResponse(request.ID, jsonRPCInvalidRequest, "invalid request")
+		return &response
+	}
+
+	params := []byte(request.Params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		params = []byte("{}")
+	}
+	status, body, _ := as.callLocal(request.Method, params)
+	if len(request.ID) == 0 {
+		return nil
+	}
+
+	envelope := ServerResponse{}
+	if err := envelope.UnmarshalJSON(body); err != nil {
+		response := jsonRPCErrorResponse(request.ID, jsonRPCInternalError, "malformed reply")
+		return &response
+	}
+	if envelope.Error == nil && status < 400 {
+		return &jsonRPCResponse{JSONRPC: "2.0", ID: request.ID, Result: envelope.Response}
+	}
+
+	message := "call failed"
+	var errorData interface{}
+	code := jsonRPCInternalError
+	if envelope.Error != nil {
+		message, errorData = envelope.Error.ErrorMessage, envelope.Error.Data
+		code = envelope.Error.ErrorCode
+	}
+	switch {
+	case status == fasthttp.StatusNotFound:
+		code = jsonRPCMethodNotFound
+	case status == fasthttp.StatusBadRequest:
+		code = jsonRPCInvalidParams
+	case code == 0:
+		// application errors without code keep the http status
+		code = status
+	}
+	response := jsonRPCErrorResponse(request.ID, code, message)
+	response.Error.Data = errorData
+	return &response
+}
+
+// jsonRPCErrorResponse returns error response, null id when id is unknown
+func jsonRPCErrorResponse(id json.RawMessage, code int, message string) jsonRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return jsonRPCResponse{JSONRPC: "2.0", ID: id, Error: &jsonRPCError{Code: code, Message: message}}
+}
+
+// marshalJSONRPC encodes response
+func marshalJSONRPC(response jsonRPCResponse) []byte {
+	body, _ := json.Marshal(response)
+	return body
+}
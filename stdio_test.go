@@ -0,0 +1,46 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVAPI_ServeJSONRPC(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"demo.Test","params":{"id":"a"}}`,
+		`{"jsonrpc":"2.0","method":"demo.Test","params":{}}`,
+		`{"jsonrpc":"2.0","id":"x","method":"demo.Nope"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"demo.ErrorTest"}`,
+		`not json`,
+		`[{"jsonrpc":"2.0","id":3,"method":"demo.Test"},{"jsonrpc":"2.0","method":"demo.Test"}]`,
+	}, "\n")
+
+	out := &bytes.Buffer{}
+	if err := as.ServeJSONRPC(strings.NewReader(input), out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"id":"a"}}`,
+		`{"jsonrpc":"2.0","id":"x","error":{"code":-32601,"message":"vapi: can't find method \"Nope\""}}`,
+		`{"jsonrpc":"2.0","id":2,"error":{"code":606,"message":"Test Wrong answer"}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"parse error"}}`,
+		`[{"jsonrpc":"2.0","id":3,"result":{}}]`,
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatal(fmt.Sprintf("wrong output:\n%s", out))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Error(fmt.Sprintf("line %d: expected %s, got %s", i, expected[i], lines[i]))
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package vapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is the key-value storage shared by stateful features, so one backend
+// configured with SetStore serves all of them. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns value of key, false when key doesn't exist or expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores value for ttl, 0 ttl keeps it forever
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key, missing keys aren't an error
+	Delete(key string) error
+	// Incr atomically adds delta to the integer value of key and returns the result.
+	// Missing keys start from 0 and expire after ttl (0 keeps them forever).
+	Incr(key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// SetStore sets storage of stateful features, the in-memory store is used by default
+func (as *VAPI) SetStore(store Store) {
+	as.mutex.Lock()
+	as.store = store
+	as.mutex.Unlock()
+}
+
+// Store returns storage of stateful features
+func (as *VAPI) Store() Store {
+	as.mutex.RLock()
+	store := as.store
+	as.mutex.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.store == nil {
+		as.store = NewMemoryStore()
+	}
+	return as.store
+}
+
+// memoryEntry is a stored value
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore is the process local Store
+type MemoryStore struct {
+	mutex   sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+	now     func() time.Time
+}
+
+// memorySweepEvery is the number of writes between expired entries sweeps
+const memorySweepEvery = 1024
+
+// NewMemoryStore returns empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// Get implements Store
+func (ms *MemoryStore) Get(key string) ([]byte, bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	entry, ok := ms.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set implements Store
+func (ms *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.put(key, memoryEntry{value: append([]byte(nil), value...), expires: ms.expiry(ttl)})
+	return nil
+}
+
+// Delete implements Store
+func (ms *MemoryStore) Delete(key string) error {
+	ms.mutex.Lock()
+	delete(ms.entries, key)
+	ms.mutex.Unlock()
+	return nil
+}
+
+// Incr implements Store
+func (ms *MemoryStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	entry, ok := ms.lookup(key)
+	current := int64(0)
+	if ok {
+		parsed, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("vapi: value of %q is not an integer", key)
+		}
+		current = parsed
+	} else {
+		entry.expires = ms.expiry(ttl)
+	}
+
+	current += delta
+	entry.value = []byte(strconv.FormatInt(current, 10))
+	ms.put(key, entry)
+	return current, nil
+}
+
+// lookup returns live entry of key, expired entries are removed
+func (ms *MemoryStore) lookup(key string) (memoryEntry, bool) {
+	entry, ok := ms.entries[key]
+	if ok && !entry.expires.IsZero() && !ms.now().Before(entry.expires) {
+		delete(ms.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+// put stores entry and periodically sweeps expired entries
+func (ms *MemoryStore) put(key string, entry memoryEntry) {
+	ms.entries[key] = entry
+	ms.writes++
+	if ms.writes%memorySweepEvery != 0 {
+		return
+	}
+	now := ms.now()
+	for k, e := range ms.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(ms.entries, k)
+		}
+	}
+}
+
+// expiry returns expiration time of ttl, zero for no expiration
+func (ms *MemoryStore) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return ms.now().Add(ttl)
+}
+
+// SQLStore is the Store keeping values in a database table:
+//
+//	CREATE TABLE vapi_store (
+//		k          VARCHAR(255) PRIMARY KEY,
+//		v          BLOB NOT NULL,          -- BYTEA for PostgreSQL
+//		expires_at BIGINT NOT NULL         -- unix nanoseconds, 0 for never
+//	)
+//
+// Incr is implemented with compare-and-swap updates, so it needs no
+// dialect-specific upserts or row locks. Expired rows are ignored but not
+// removed; delete rows with 0 < expires_at < now periodically.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+	// placeholder returns n-th (1-based) query parameter placeholder
+	placeholder func(n int) string
+	now         func() time.Time
+}
+
+// maxIncrAttempts limits compare-and-swap retries of SQLStore.Incr under contention
+const maxIncrAttempts = 16
+
+// NewSQLStore returns store using table of db. Postgres should be true for
+// drivers using $1 placeholders instead of ?.
+func NewSQLStore(db *sql.DB, table string, postgres bool) *SQLStore {
+	placeholder := func(int) string { return "?" }
+	if postgres {
+		placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+	}
+	return &SQLStore{db: db, table: table, placeholder: placeholder, now: time.Now}
+}
+
+// Get implements Store
+func (ss *SQLStore) Get(key string) ([]byte, bool, error) {
+	value, _, ok, err := ss.get(key)
+	return value, ok, err
+}
+
+// Set implements Store
+func (ss *SQLStore) Set(key string, value []byte, ttl time.Duration) error {
+	expires := ss.expiry(ttl)
+	result, err := ss.db.Exec(
+		fmt.Sprintf("UPDATE %s SET v = %s, expires_at = %s WHERE k = %s", ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3)),
+		value, expires, key,
+	)
+	if err != nil {
+		return fmt.Errorf("vapi: can't store %q: %s", key, err.Error())
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated > 0 {
+		return nil
+	}
+	if err = ss.insert(key, value, expires); err != nil {
+		// a concurrent insert won, overwrite it
+		_, err = ss.db.Exec(
+			fmt.Sprintf("UPDATE %s SET v = %s, expires_at = %s WHERE k = %s", ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3)),
+			value, expires, key,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("vapi: can't store %q: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Delete implements Store
+func (ss *SQLStore) Delete(key string) error {
+	_, err := ss.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE k = %s", ss.table, ss.placeholder(1)), key)
+	if err != nil {
+		return fmt.Errorf("vapi: can't delete %q: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Incr implements Store
+func (ss *SQLStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	for attempt := 0; attempt < maxIncrAttempts; attempt++ {
+		value, expires, ok, err := ss.get(key)
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			next := []byte(strconv.FormatInt(delta, 10))
+			// expired rows are replaced, missing ones inserted
+			if err = ss.deleteExpired(key); err != nil {
+				return 0, err
+			}
+			if ss.insert(key, next, ss.expiry(ttl)) == nil {
+				return delta, nil
+			}
+			continue
+		}
+
+		current, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("vapi: value of %q is not an integer", key)
+		}
+		next := strconv.FormatInt(current+delta, 10)
+		result, err := ss.db.Exec(
+			fmt.Sprintf("UPDATE %s SET v = %s WHERE k = %s AND v = %s AND expires_at = %s",
+				ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3), ss.placeholder(4)),
+			[]byte(next), key, value, expires,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("vapi: can't increment %q: %s", key, err.Error())
+		}
+		if updated, err := result.RowsAffected(); err == nil && updated == 1 {
+			return current + delta, nil
+		}
+	}
+	return 0, fmt.Errorf("vapi: can't increment %q: too much contention", key)
+}
+
+// get returns live value and raw expiration of key
+func (ss *SQLStore) get(key string) ([]byte, int64, bool, error) {
+	var value []byte
+	var expires int64
+	err := ss.db.QueryRow(
+		fmt.Sprintf("SELECT v, expires_at FROM %s WHERE k = %s", ss.table, ss.placeholder(1)), key,
+	).Scan(&value, &expires)
+	if err == sql.ErrNoRows {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("vapi: can't load %q: %s", key, err.Error())
+	}
+	if expires != 0 && expires <= ss.now().UnixNano() {
+		return nil, 0, false, nil
+	}
+	return value, expires, true, nil
+}
+
+// insert adds a new row
+func (ss *SQLStore) insert(key string, value []byte, expires int64) error {
+	_, err := ss.db.Exec(
+		fmt.Sprintf("INSERT INTO %s (k, v, expires_at) VALUES (%s, %s, %s)", ss.table, ss.placeholder(1), ss.placeholder(2), ss.placeholder(3)),
+		key, value, expires,
+	)
+	return err
+}
+
+// deleteExpired removes expired row of key
+func (ss *SQLStore) deleteExpired(key string) error {
+	_, err := ss.db.Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE k = %s AND expires_at <> 0 AND expires_at <= %s", ss.table, ss.placeholder(1), ss.placeholder(2)),
+		key, ss.now().UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("vapi: can't expire %q: %s", key, err.Error())
+	}
+	return nil
+}
+
+// expiry returns unix nanoseconds expiration of ttl, 0 for no expiration
+func (ss *SQLStore) expiry(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return ss.now().Add(ttl).UnixNano()
+}
+
+// RedisClient is a thin adapter over Redis key commands, e.g. go-redis.
+// Get returns false for missing keys, Set with 0 ttl keeps the key forever
+// and Eval runs a Lua script returning its integer result as int64.
+type RedisClient interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// DefaultRedisTimeout limits RedisStore commands unless changed with RedisStore.Timeout
+const DefaultRedisTimeout = time.Second
+
+// redisIncrScript increments the key and sets ttl (milliseconds) only when it creates the key
+const redisIncrScript = `local existed = redis.call('EXISTS', KEYS[1])
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if existed == 0 and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value`
+
+// RedisStore is the Store keeping values in Redis, so counters and sessions
+// are shared by all instances. Incr runs as a script, so the increment and
+// the expiration of new keys are atomic.
+type RedisStore struct {
+	// Timeout limits every command, DefaultRedisTimeout when zero
+	Timeout time.Duration
+	// Prefix is prepended to keys, e.g. "myapp:"
+	Prefix string
+
+	client RedisClient
+}
+
+// NewRedisStore returns store using client
+func NewRedisStore(client RedisClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Get implements Store
+func (rs *RedisStore) Get(key string) ([]byte, bool, error) {
+	ctx, cancel := rs.context()
+	defer cancel()
+	value, ok, err := rs.client.Get(ctx, rs.Prefix+key)
+	if err != nil {
+		return nil, false, fmt.Errorf("vapi: can't get %q: %s", key, err.Error())
+	}
+	return value, ok, nil
+}
+
+// Set implements Store
+func (rs *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := rs.context()
+	defer cancel()
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := rs.client.Set(ctx, rs.Prefix+key, value, ttl); err != nil {
+		return fmt.Errorf("vapi: can't set %q: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Delete implements Store
+func (rs *RedisStore) Delete(key string) error {
+	ctx, cancel := rs.context()
+	defer cancel()
+	if err := rs.client.Del(ctx, rs.Prefix+key); err != nil {
+		return fmt.Errorf("vapi: can't delete %q: %s", key, err.Error())
+	}
+	return nil
+}
+
+// Incr implements Store
+func (rs *RedisStore) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx, cancel := rs.context()
+	defer cancel()
+	result, err := rs.client.Eval(ctx, redisIncrScript, []string{rs.Prefix + key}, delta, ttl.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("vapi: can't increment %q: %s", key, err.Error())
+	}
+	value, ok := result.(int64)
+	if !ok {
+		return 0, fmt.Errorf("vapi: can't increment %q: unexpected result %v", key, result)
+	}
+	return value, nil
+}
+
+// context returns context of a command
+func (rs *RedisStore) context() (context.Context, context.CancelFunc) {
+	timeout := rs.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRedisTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,161 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	ms := NewMemoryStore()
+	ms.now = func() time.Time { return now }
+
+	if err := ms.Set("a", []byte("1"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := ms.Get("a"); !ok || string(value) != "1" {
+		t.Error(fmt.Sprintf("wrong value: %s %v", value, ok))
+	}
+
+	now = now.Add(time.Second)
+	if _, ok, _ := ms.Get("a"); ok {
+		t.Error("value must expire")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ms.Incr("counter", 2, 0)
+		}()
+	}
+	wg.Wait()
+	if value, _ := ms.Incr("counter", -1, 0); value != 99 {
+		t.Error(fmt.Sprintf("wrong counter: %d", value))
+	}
+
+	ms.Set("text", []byte("x"), 0)
+	if _, err := ms.Incr("text", 1, 0); err == nil {
+		t.Error("non integer value can't be incremented")
+	}
+
+	ms.Delete("counter")
+	if _, ok, _ := ms.Get("counter"); ok {
+		t.Error("value must be deleted")
+	}
+}
+
+// fakeRedis emulates the commands of RedisClient used by RedisStore
+type fakeRedis struct {
+	mutex   sync.Mutex
+	now     time.Time
+	values  map[string][]byte
+	expires map[string]time.Time
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{now: time.Now(), values: map[string][]byte{}, expires: map[string]time.Time{}}
+}
+
+// live drops key when expired, called under mutex
+func (fr *fakeRedis) live(key string) bool {
+	if expires, ok := fr.expires[key]; ok && !fr.now.Before(expires) {
+		delete(fr.values, key)
+		delete(fr.expires, key)
+	}
+	_, ok := fr.values[key]
+	return ok
+}
+
+func (fr *fakeRedis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+	if !fr.live(key) {
+		return nil, false, nil
+	}
+	return fr.values[key], true, nil
+}
+
+func (fr *fakeRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+	fr.values[key] = value
+	delete(fr.expires, key)
+	if ttl > 0 {
+		fr.expires[key] = fr.now.Add(ttl)
+	}
+	return nil
+}
+
+func (fr *fakeRedis) Del(ctx context.Context, key string) error {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+	delete(fr.values, key)
+	delete(fr.expires, key)
+	return nil
+}
+
+func (fr *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if script != redisIncrScript {
+		return nil, errors.New("unknown script")
+	}
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+	key, delta, ttl := keys[0], args[0].(int64), args[1].(int64)
+	existed := fr.live(key)
+	value, err := strconv.ParseInt(string(fr.values[key]), 10, 64)
+	if existed && err != nil {
+		return nil, errors.New("ERR value is not an integer")
+	}
+	value += delta
+	fr.values[key] = []byte(strconv.FormatInt(value, 10))
+	if !existed && ttl > 0 {
+		fr.expires[key] = fr.now.Add(time.Duration(ttl) * time.Millisecond)
+	}
+	return value, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	fr := newFakeRedis()
+	rs := NewRedisStore(fr)
+	rs.Prefix = "app:"
+
+	if err := rs.Set("a", []byte("1"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := rs.Get("a"); !ok || string(value) != "1" || fr.values["app:a"] == nil {
+		t.Error(fmt.Sprintf("wrong value: %s %v", value, ok))
+	}
+
+	if value, _ := rs.Incr("counter", 2, time.Minute); value != 2 {
+		t.Error(fmt.Sprintf("wrong counter: %d", value))
+	}
+	fr.now = fr.now.Add(30 * time.Second)
+	if value, _ := rs.Incr("counter", 3, time.Minute); value != 5 {
+		t.Error(fmt.Sprintf("wrong counter: %d", value))
+	}
+	// the ttl is set by the increment creating the key only
+	fr.now = fr.now.Add(30 * time.Second)
+	if _, ok, _ := rs.Get("counter"); ok {
+		t.Error("counter must expire a ttl after creation")
+	}
+	if _, ok, _ := rs.Get("a"); ok {
+		t.Error("value must expire")
+	}
+
+	rs.Set("text", []byte("x"), 0)
+	if _, err := rs.Incr("text", 1, 0); err == nil {
+		t.Error("non integer value can't be incremented")
+	}
+
+	rs.Delete("text")
+	if _, ok, _ := rs.Get("text"); ok {
+		t.Error("value must be deleted")
+	}
+}
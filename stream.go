@@ -0,0 +1,74 @@
+package vapi
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ArgsParam is the query parameter carrying json encoded args of streaming methods and GET calls
+const ArgsParam = "args"
+
+// StreamArgsParam is the former name of ArgsParam.
+//
+// Deprecated: use ArgsParam.
+const StreamArgsParam = ArgsParam
+
+var typeOfReader = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// bodyFieldIndex returns index of the exported io.Reader field of args struct t, nil when there is none.
+//
+// Methods with such args receive the raw request body through that field
+// instead of decoding it as json, so uploads can be copied to storage as is:
+//
+//	type UploadArgs struct {
+//		Name string    `json:"name"`
+//		Body io.Reader `json:"-"`
+//	}
+//
+// The remaining fields are decoded from the ArgsParam query parameter.
+// The body is streamed from the connection when the server is built with a
+// fasthttp release supporting Server.StreamRequestBody and it is enabled,
+// otherwise fasthttp reads the whole body before calling handlers and its size
+// is bounded by Server.MaxRequestBodySize.
+func bodyFieldIndex(t reflect.Type) []int {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath == "" && field.Type == typeOfReader {
+			return field.Index
+		}
+	}
+	return nil
+}
+
+// decodeStreamArgs decodes args of streaming method from the query and binds body reader
+func decodeStreamArgs(ctx *fasthttp.RequestCtx, args reflect.Value, bodyField []int) error {
+	if encoded := ctx.QueryArgs().Peek(ArgsParam); len(encoded) > 0 {
+		if err := args.Interface().(Unmarshaler).UnmarshalJSON(encoded); err != nil {
+			return err
+		}
+	}
+	args.Elem().FieldByIndex(bodyField).Set(reflect.ValueOf(bodyReader(&ctx.Request)))
+	return nil
+}
+
+// bodyStreamer is implemented by requests of fasthttp releases supporting Server.StreamRequestBody
+type bodyStreamer interface {
+	BodyStream() io.Reader
+}
+
+// bodyReader returns the request body stream when the server streams bodies,
+// a reader of the buffered body otherwise
+func bodyReader(req interface{ Body() []byte }) io.Reader {
+	if streamer, ok := req.(bodyStreamer); ok {
+		if stream := streamer.BodyStream(); stream != nil {
+			return stream
+		}
+	}
+	return bytes.NewReader(req.Body())
+}
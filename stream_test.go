@@ -0,0 +1,67 @@
+package vapi
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// streamingRequest is a request whose body is streamed from the connection
+type streamingRequest struct {
+	stream io.Reader
+}
+
+func (r *streamingRequest) Body() []byte {
+	panic("streamed body must not be buffered")
+}
+
+func (r *streamingRequest) BodyStream() io.Reader {
+	return r.stream
+}
+
+func TestBodyFieldIndex(t *testing.T) {
+	if index := bodyFieldIndex(reflect.TypeOf(UploadArgs{})); !reflect.DeepEqual(index, []int{1}) {
+		t.Error(fmt.Sprintf("wrong body field: %v", index))
+	}
+	if index := bodyFieldIndex(reflect.TypeOf(TestArgs{})); index != nil {
+		t.Error(fmt.Sprintf("args without reader must have no body field: %v", index))
+	}
+}
+
+func TestBodyReader(t *testing.T) {
+	stream := strings.NewReader("streamed")
+	if reader := bodyReader(&streamingRequest{stream: stream}); reader != stream {
+		t.Error("streamed body must be passed as is")
+	}
+
+	req := &fasthttp.Request{}
+	req.SetBody([]byte("buffered"))
+	if body, _ := ioutil.ReadAll(bodyReader(req)); string(body) != "buffered" {
+		t.Error(fmt.Sprintf("wrong buffered body: %s", body))
+	}
+}
+
+func TestDecodeStreamArgs(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(UploadAPI), "upload")
+
+	call := func(args string) (int, string) {
+		status, body, _ := as.callLocalWith("upload.Put", []byte("0123456789"), nil, func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.SetRequestURI("/upload.Put?" + ArgsParam + "=" + url.QueryEscape(args))
+		})
+		return status, string(body)
+	}
+
+	if status, body := call(`{"name":"a.txt"}`); status != 200 || body != `{"response":{"id":"10"}}` {
+		t.Error(fmt.Sprintf("wrong upload: %d %s", status, body))
+	}
+	if status, body := call(`{"name":`); status == 200 {
+		t.Error(fmt.Sprintf("malformed args must fail: %d %s", status, body))
+	}
+}
@@ -0,0 +1,174 @@
+package vapi
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// Route describes a registered method
+type Route struct {
+	Method   string   `json:"method"`
+	Verbs    []string `json:"verbs"`
+	Priority string   `json:"priority"`
+	Args     string   `json:"args"`
+	Reply    string   `json:"reply"`
+	// Policy is the access policy, nil when the method is open to every caller
+	Policy *Policy `json:"policy,omitempty"`
+	// Features lists per-method behaviors, e.g. "quota", "template", "dry-run"
+	Features []string `json:"features,omitempty"`
+}
+
+// Routes returns registered methods sorted by name
+func (as *VAPI) Routes() []Route {
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+
+	routes := make([]Route, 0, len(as.methods))
+	for name, methodSpec := range as.methods {
+		verbs := methodSpec.verbs
+		if len(verbs) == 0 {
+			verbs = defaultVerbs
+		}
+		routes = append(routes, Route{
+			Method:   name,
+			Verbs:    append([]string(nil), verbs...),
+			Priority: methodSpec.priority.String(),
+			Args:     methodSpec.argsType.String(),
+			Reply:    methodSpec.replyType.String(),
+			Policy:   methodSpec.policy,
+			Features: methodFeatures(methodSpec),
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Method < routes[j].Method })
+	return routes
+}
+
+// PrintSummary writes enabled middleware, codecs and the table of registered
+// methods to w, for logging at startup or a --routes command line flag.
+func (as *VAPI) PrintSummary(w io.Writer) error {
+	routes := as.Routes()
+
+	as.mutex.RLock()
+	services := len(as.services)
+	middleware := as.middleware()
+	codecs := as.codecs()
+	as.mutex.RUnlock()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "vapi: %d services, %d methods\n", services, len(routes))
+	fmt.Fprintf(tw, "middleware:\t%s\n", listOrNone(middleware))
+	fmt.Fprintf(tw, "codecs:\t%s\n", strings.Join(codecs, ", "))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "METHOD\tVERBS\tPRIORITY\tARGS\tREPLY\tFEATURES")
+	for _, route := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", route.Method, strings.Join(route.Verbs, ","), route.Priority,
+			route.Args, route.Reply, listOrNone(route.Features))
+	}
+	return tw.Flush()
+}
+
+// middleware returns names of enabled server wide behaviors, as.mutex must be held
+func (as *VAPI) middleware() []string {
+	var names []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	add(as.limiter != nil, "concurrency limiter")
+	add(as.localeResolver != nil, "locale")
+	add(as.methodOverride, "method override")
+	add(as.keyring != nil, "field encryption")
+	add(as.signer != nil, "response signing")
+	add(as.canonical, "canonical json")
+	add(as.serverTiming, "server timing")
+	add(as.debugTrace != nil, "debug trace")
+	add(as.bandwidth != nil, "principal bandwidth")
+	add(as.memory != nil, "memory limit")
+	add(as.schema != nil, "schema-first")
+	add(as.store != nil, "store")
+	add(as.events != nil, "events")
+	add(as.outbox != nil, "outbox")
+	add(as.sessions != nil, "sessions")
+	add(len(as.classifiers) > 0, "request classifiers")
+	add(as.tarpit != nil, "tarpit")
+	add(as.geoip != nil, "geoip")
+	add(as.decisions != nil, "policy decision point")
+	add(as.regions != nil, "region pinning")
+	add(as.contentFilter != nil, "content filter")
+	add(as.scanner != nil, "upload scanner")
+	add(as.spill != nil, "reply spill")
+	add(as.watchdog != nil, "watchdog")
+	add(as.journal != nil, "request journal")
+	add(atomic.LoadInt32(&as.calls.enabled) == 1, "call tracking")
+	add(len(as.objectives) > 0, "objectives")
+	add(as.analytics != nil, "client analytics")
+	add(as.build != nil, "build header")
+	add(as.maintenance != nil, "maintenance")
+	add(as.readOnly != "", "read-only")
+	return names
+}
+
+// codecs returns names of enabled reply encodings, as.mutex must be held
+func (as *VAPI) codecs() []string {
+	codecs := []string{"json"}
+	if as.jsonp {
+		codecs = append(codecs, "jsonp")
+	}
+	html := as.templates != nil
+	for _, methodSpec := range as.methods {
+		html = html || methodSpec.template != nil
+	}
+	if html {
+		codecs = append(codecs, "html")
+	}
+	return codecs
+}
+
+// methodFeatures lists per-method behaviors of methodSpec, as.mutex must be held
+func methodFeatures(methodSpec *serviceMethod) []string {
+	var features []string
+	add := func(enabled bool, name string) {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	add(methodSpec.bodyField != nil, "streaming")
+	add(methodSpec.argsType == typeOfRawMessage, "passthrough")
+	add(methodSpec.argsPlan.encrypted || methodSpec.replyPlan.encrypted, "encrypted")
+	add(methodSpec.replyPlan.localtime, "localtime")
+	add(methodSpec.argsPlan.sanitized, "sanitized")
+	add(methodSpec.argsPlan.moderated || methodSpec.replyPlan.moderated, "moderated")
+	add(methodSpec.template != nil, "template")
+	add(methodSpec.bandwidth > 0, "bandwidth")
+	add(methodSpec.quota != nil, "quota")
+	add(methodSpec.fault != nil, "fault")
+	add(methodSpec.dryRun, "dry-run")
+	add(methodSpec.policy != nil, "policy")
+	add(methodSpec.countries != nil, "countries")
+	add(methodSpec.region != "", "region "+methodSpec.region)
+	add(methodSpec.cache != nil, "cache")
+	add(methodSpec.canary != nil, "canary")
+	add(methodSpec.rollout != nil, "rollout")
+	add(len(methodSpec.transforms) > 0, "args transforms")
+	add(len(methodSpec.downgrades) > 0, "reply versions")
+	add(len(methodSpec.sunsets) > 0, "sunset")
+	add(methodSpec.fieldStats != nil, "field stats")
+	add(methodSpec.mutability == mutabilityWrite, "mutating")
+	add(len(methodSpec.surrogateKeys) > 0, "surrogate keys")
+	add(methodSpec.declared != nil, "declared schema")
+	add(len(methodSpec.examples) > 0, "examples")
+	return features
+}
+
+// listOrNone joins names with commas, "-" when there are none
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
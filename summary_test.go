@@ -0,0 +1,36 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVAPI_Routes(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "")
+	as.SetMethodVerbs("DemoAPI.Test", "GET")
+	as.SetMethodQuota("DemoAPI.Test", 10, time.Minute, RemoteIPPrincipal)
+	as.SetCanonicalJSON(true)
+
+	routes := as.Routes()
+	if len(routes) != 2 || routes[0].Method != "DemoAPI.ErrorTest" || routes[1].Method != "DemoAPI.Test" {
+		t.Fatal(fmt.Sprintf("wrong routes: %+v", routes))
+	}
+	if fmt.Sprint(routes[1].Verbs) != "[GET]" || fmt.Sprint(routes[1].Features) != "[quota]" || routes[1].Priority != "normal" {
+		t.Error(fmt.Sprintf("wrong route: %+v", routes[1]))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := as.PrintSummary(buf); err != nil {
+		t.Fatal(err)
+	}
+	summary := buf.String()
+	for _, expected := range []string{"1 services, 2 methods", "canonical json", "codecs:      json", "DemoAPI.Test       GET"} {
+		if !strings.Contains(summary, expected) {
+			t.Error(fmt.Sprintf("summary misses %q:\n%s", expected, summary))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultSunsetWarning is how long before the sunset date warnings escalate
+const defaultSunsetWarning = 30 * 24 * time.Hour
+
+// Sunset schedules removal of a deprecated method or args field
+type Sunset struct {
+	// Field is the dotted args path, empty deprecates the whole method
+	Field string
+	// Since is when the deprecation was announced, zero for unknown
+	Since time.Time
+	// Date is when the method or field stops being served
+	Date time.Time
+	// Message tells clients how to migrate, e.g. "use users.List"
+	Message string
+	// Link is the url of the migration guide
+	Link string
+	// WarnBefore is how long before Date warnings escalate, 30 days by default
+	WarnBefore time.Duration
+	// Enforce fails calls after Date with 410 Gone instead of warning
+	Enforce bool
+}
+
+// SetMethodSunset deprecates the method or its args fields, none removes the
+// deprecations. Calls get Deprecation and Sunset headers and a warning in the
+// envelope, which grows urgent within WarnBefore of the date. Enforced
+// sunsets fail calls after the date with the migration message.
+func (as *VAPI) SetMethodSunset(method string, sunsets ...Sunset) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	// defaults are filled in, so the caller's slice is left alone
+	sunsets = append([]Sunset(nil), sunsets...)
+	seen := map[string]bool{}
+	for i, sunset := range sunsets {
+		if sunset.Date.IsZero() {
+			return fmt.Errorf("vapi: sunset of %s must have a date", method)
+		}
+		if seen[sunset.Field] {
+			return fmt.Errorf("vapi: sunsets of %s must have unique fields", method)
+		}
+		seen[sunset.Field] = true
+		if sunset.WarnBefore <= 0 {
+			sunsets[i].WarnBefore = defaultSunsetWarning
+		}
+	}
+
+	as.mutex.Lock()
+	methodSpec.sunsets = sunsets
+	as.mutex.Unlock()
+	return nil
+}
+
+// checkSunset applies the method sunset, returns false when the call was answered
+func (as *VAPI) checkSunset(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod) bool {
+	as.mutex.RLock()
+	sunsets := methodSpec.sunsets
+	as.mutex.RUnlock()
+	for i := range sunsets {
+		if sunsets[i].Field == "" {
+			return as.applySunset(ctx, srvResponse, &sunsets[i], methodSpec.name)
+		}
+	}
+	return true
+}
+
+// checkFieldSunsets applies sunsets of the fields present in raw args, returns
+// false when the call was answered
+func (as *VAPI) checkFieldSunsets(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, raw []byte) bool {
+	as.mutex.RLock()
+	sunsets := methodSpec.sunsets
+	as.mutex.RUnlock()
+	if len(sunsets) == 0 || (len(sunsets) == 1 && sunsets[0].Field == "") {
+		return true
+	}
+
+	args := map[string]interface{}{}
+	if json.Unmarshal(raw, &args) != nil {
+		// left to the args decoder to report
+		return true
+	}
+	for i := range sunsets {
+		if sunsets[i].Field == "" {
+			continue
+		}
+		if _, ok := lookupPath(args, sunsets[i].Field); !ok {
+			continue
+		}
+		if !as.applySunset(ctx, srvResponse, &sunsets[i], methodSpec.name+" args "+sunsets[i].Field) {
+			return false
+		}
+	}
+	return true
+}
+
+// applySunset sets deprecation headers and the warning about subject, or
+// rejects the call past an enforced sunset
+func (as *VAPI) applySunset(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, sunset *Sunset, subject string) bool {
+	MarkDeprecated(ctx, subject)
+
+	deprecation := "true"
+	if !sunset.Since.IsZero() {
+		deprecation = "@" + strconv.FormatInt(sunset.Since.Unix(), 10)
+	}
+	ctx.Response.Header.Set("Deprecation", deprecation)
+	if current, err := fasthttp.ParseHTTPDate(ctx.Response.Header.Peek("Sunset")); err != nil || sunset.Date.Before(current) {
+		// the earliest sunset of the call wins
+		ctx.Response.Header.Set("Sunset", string(fasthttp.AppendHTTPDate(nil, sunset.Date)))
+	}
+	if sunset.Link != "" {
+		ctx.Response.Header.Add("Link", "<"+sunset.Link+">; rel=\"deprecation\"")
+	}
+
+	date := sunset.Date.UTC().Format("2006-01-02")
+	remaining := time.Until(sunset.Date)
+	var warning string
+	switch {
+	case remaining <= 0 && sunset.Enforce:
+		as.writeError(ctx, srvResponse, fasthttp.StatusGone, fmt.Errorf("vapi: %s was removed on %s%s", subject, date, migration(sunset)))
+		return false
+	case remaining <= 0:
+		warning = fmt.Sprintf("%s is past its removal date %s and may stop working at any time%s", subject, date, migration(sunset))
+	case remaining <= sunset.WarnBefore:
+		days := int(remaining.Hours()/24) + 1
+		warning = fmt.Sprintf("%s will be removed in %d days on %s%s", subject, days, date, migration(sunset))
+	default:
+		warning = fmt.Sprintf("%s is deprecated and will be removed on %s%s", subject, date, migration(sunset))
+	}
+	srvResponse.Warnings = append(srvResponse.Warnings, warning)
+	return true
+}
+
+// migration returns the migration hint of sunset appended to warnings
+func migration(sunset *Sunset) string {
+	switch {
+	case sunset.Message != "" && sunset.Link != "":
+		return ": " + sunset.Message + " (" + sunset.Link + ")"
+	case sunset.Message != "":
+		return ": " + sunset.Message
+	case sunset.Link != "":
+		return ": see " + sunset.Link
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package vapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetMethodSunset(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+
+	if err := as.SetMethodSunset("demo.Test", Sunset{}); err == nil {
+		t.Error("sunset without date must fail")
+	}
+
+	sunsets := []Sunset{{Date: time.Now().Add(time.Hour)}}
+	if err := as.SetMethodSunset("demo.Test", sunsets...); err != nil {
+		t.Fatal(err)
+	}
+	if sunsets[0].WarnBefore != 0 {
+		t.Error("caller's sunsets must not be changed")
+	}
+
+	call := func() (*fasthttp.RequestCtx, string) {
+		var response *fasthttp.RequestCtx
+		_, body, _ := as.callLocalWith("demo.Test", []byte(`{"id":"1","ttt":"x"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+		})
+		return response, string(body)
+	}
+
+	date := time.Now().Add(90 * 24 * time.Hour)
+	if err := as.SetMethodSunset("demo.Test", Sunset{Date: date, Message: "use demo.New", Link: "https://example.com/migrate"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, body := call()
+	if string(ctx.Response.Header.Peek("Deprecation")) != "true" || string(ctx.Response.Header.Peek("Sunset")) != date.UTC().Format(http.TimeFormat) {
+		t.Error(fmt.Sprintf("unexpected headers: %s", ctx.Response.Header.String()))
+	}
+	if sunset := string(ctx.Response.Header.Peek("Sunset")); !strings.HasSuffix(sunset, " GMT") {
+		t.Error(fmt.Sprintf("sunset must be an http date: %s", sunset))
+	}
+	if !strings.Contains(string(ctx.Response.Header.Peek("Link")), `rel="deprecation"`) {
+		t.Error(fmt.Sprintf("missing link: %s", ctx.Response.Header.String()))
+	}
+	if !strings.Contains(body, `"warnings":["demo.Test is deprecated and will be removed on`) {
+		t.Error(fmt.Sprintf("missing warning: %s", body))
+	}
+
+	as.SetMethodSunset("demo.Test", Sunset{Date: time.Now().Add(48 * time.Hour)})
+	if _, body := call(); !strings.Contains(body, "demo.Test will be removed in 2 days") {
+		t.Error(fmt.Sprintf("warning must escalate: %s", body))
+	}
+
+	as.SetMethodSunset("demo.Test", Sunset{Date: time.Now().Add(-time.Hour), Message: "use demo.New", Enforce: true})
+	if ctx, body := call(); ctx.Response.StatusCode() != fasthttp.StatusGone || !strings.Contains(body, "use demo.New") {
+		t.Error(fmt.Sprintf("expected 410 with migration message, got %d: %s", ctx.Response.StatusCode(), body))
+	}
+
+	as.SetMethodSunset("demo.Test", Sunset{Field: "ttt", Date: time.Now().Add(-time.Hour), Enforce: true})
+	if ctx, body := call(); ctx.Response.StatusCode() != fasthttp.StatusGone || !strings.Contains(body, "demo.Test args ttt") {
+		t.Error(fmt.Sprintf("expected 410 for the field, got %d: %s", ctx.Response.StatusCode(), body))
+	}
+	if _, body, _ := as.callLocal("demo.Test", []byte(`{"id":"1"}`)); strings.Contains(string(body), "warnings") {
+		t.Error(fmt.Sprintf("calls without the field must not be warned: %s", body))
+	}
+
+	as.SetMethodSunset("demo.Test")
+	if ctx, body := call(); ctx.Response.StatusCode() != 200 || strings.Contains(body, "warnings") {
+		t.Error(fmt.Sprintf("sunset must be removed: %s", body))
+	}
+}
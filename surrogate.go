@@ -0,0 +1,144 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SurrogateKeyHeader is the response header listing surrogate keys of the reply,
+// CDNs use it to purge all replies tagged with a key at once
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// EventPurgeFailed is emitted when a purger fails with PurgeEvent payload
+const EventPurgeFailed = "purge.failed"
+
+const (
+	// surrogateUserValue is the RequestCtx user value key of surrogate keys added by the method
+	surrogateUserValue = "vapi.surrogate"
+	// purgeUserValue is the RequestCtx user value key of keys to purge after the call succeeds
+	purgeUserValue = "vapi.purge"
+)
+
+// Purger invalidates cached replies tagged with keys, e.g. at a CDN
+type Purger func(ctx context.Context, keys []string) error
+
+// PurgeEvent is the payload of EventPurgeFailed
+type PurgeEvent struct {
+	Keys []string
+	Err  error
+}
+
+// AddPurger registers purger invoked by Purge and after calls queueing keys with PurgeKeys
+func (as *VAPI) AddPurger(purger Purger) {
+	as.mutex.Lock()
+	as.purgers = append(as.purgers, purger)
+	as.mutex.Unlock()
+}
+
+// SetMethodSurrogateKeys sets surrogate keys sent with every successful reply of the method
+func (as *VAPI) SetMethodSurrogateKeys(method string, keys ...string) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.surrogateKeys = keys
+	as.mutex.Unlock()
+	return nil
+}
+
+// AddSurrogateKeys tags the reply of the current call with keys, e.g. "user-42"
+func AddSurrogateKeys(ctx *fasthttp.RequestCtx, keys ...string) {
+	current, _ := ctx.UserValue(surrogateUserValue).([]string)
+	ctx.SetUserValue(surrogateUserValue, append(current, keys...))
+}
+
+// PurgeKeys queues keys to purge when the current call succeeds. Mutating
+// methods call it for the resources they changed; purging runs in the worker
+// pool after the transaction scope commits, failed calls purge nothing.
+func PurgeKeys(ctx *fasthttp.RequestCtx, keys ...string) {
+	current, _ := ctx.UserValue(purgeUserValue).([]string)
+	ctx.SetUserValue(purgeUserValue, append(current, keys...))
+}
+
+// Purge invalidates keys with all registered purgers, returns the first error
+func (as *VAPI) Purge(ctx context.Context, keys ...string) error {
+	as.mutex.RLock()
+	purgers := as.purgers
+	as.mutex.RUnlock()
+
+	var firstErr error
+	for _, purger := range purgers {
+		if err := purger(ctx, keys); err != nil {
+			if as.Events().HasSubscribers(EventPurgeFailed) {
+				as.Events().Publish(EventPurgeFailed, PurgeEvent{Keys: keys, Err: err})
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// CDNPurger posts purge requests to url with keys in SurrogateKeyHeader
+// separated by spaces, any 2xx response acknowledges the purge
+func CDNPurger(url string, timeout time.Duration) Purger {
+	return func(ctx context.Context, keys []string) error {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI(url)
+		req.Header.SetMethod("POST")
+		req.Header.Set(SurrogateKeyHeader, strings.Join(keys, " "))
+
+		if err := fasthttp.DoTimeout(req, resp, timeout); err != nil {
+			return err
+		}
+		if status := resp.StatusCode(); status < 200 || status >= 300 {
+			return fmt.Errorf("vapi: purge responded with status %d", status)
+		}
+		return nil
+	}
+}
+
+// writeSurrogateKeys sets SurrogateKeyHeader of successful reply
+func writeSurrogateKeys(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod) {
+	keys, _ := ctx.UserValue(surrogateUserValue).([]string)
+	if len(methodSpec.surrogateKeys) > 0 {
+		keys = append(append([]string(nil), methodSpec.surrogateKeys...), keys...)
+	}
+	if len(keys) > 0 {
+		ctx.Response.Header.Set(SurrogateKeyHeader, strings.Join(keys, " "))
+	}
+}
+
+// flushPurges schedules purging of keys queued by the call
+func (as *VAPI) flushPurges(ctx *fasthttp.RequestCtx) {
+	keys, _ := ctx.UserValue(purgeUserValue).([]string)
+	if len(keys) == 0 {
+		return
+	}
+	ctx.SetUserValue(purgeUserValue, nil)
+
+	as.mutex.RLock()
+	enabled := len(as.purgers) > 0
+	as.mutex.RUnlock()
+	if !enabled {
+		return
+	}
+
+	err := as.Go(context.Background(), func(ctx context.Context) {
+		as.Purge(ctx, keys...)
+	})
+	if err != nil && as.Events().HasSubscribers(EventPurgeFailed) {
+		as.Events().Publish(EventPurgeFailed, PurgeEvent{Keys: keys, Err: err})
+	}
+}
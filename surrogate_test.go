@@ -0,0 +1,78 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type CatalogAPI struct{}
+
+func (c *CatalogAPI) Update(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	AddSurrogateKeys(ctx, "product-"+args.ID)
+	PurgeKeys(ctx, "product-"+args.ID, "catalog")
+	return nil
+}
+
+func TestSurrogateKeys(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(CatalogAPI), "catalog")
+	as.SetMethodSurrogateKeys("catalog.Update", "catalog")
+
+	purged := make(chan string, 1)
+	as.AddPurger(func(ctx context.Context, keys []string) error {
+		purged <- strings.Join(keys, " ")
+		return nil
+	})
+
+	var response *fasthttp.RequestCtx
+	as.callLocalWith("catalog.Update", []byte(`{"id":"7"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		response = ctx
+	})
+	if keys := string(response.Response.Header.Peek(SurrogateKeyHeader)); keys != "catalog product-7" {
+		t.Error(fmt.Sprintf("wrong surrogate keys: %q", keys))
+	}
+	select {
+	case keys := <-purged:
+		if keys != "product-7 catalog" {
+			t.Error(fmt.Sprintf("wrong purged keys: %q", keys))
+		}
+	case <-time.After(time.Second):
+		t.Error("keys must be purged after the call")
+	}
+
+	as.SetMethodDryRun("catalog.Update", true)
+	as.callLocalWith("catalog.Update", []byte(`{"id":"8"}`), nil, func(ctx *fasthttp.RequestCtx) {
+		ctx.Request.Header.Set(DryRunHeader, "true")
+	})
+	select {
+	case keys := <-purged:
+		t.Error(fmt.Sprintf("dry run must not purge: %q", keys))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCDNPurger(t *testing.T) {
+	var header string
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		header = string(ctx.Request.Header.Peek(SurrogateKeyHeader))
+	}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	if err := CDNPurger("http://"+ln.Addr().String()+"/purge", time.Second)(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if header != "a b" {
+		t.Error(fmt.Sprintf("wrong purge keys: %q", header))
+	}
+}
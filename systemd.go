@@ -0,0 +1,108 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemdFirstFD is the first descriptor passed by systemd socket activation (SD_LISTEN_FDS_START)
+const systemdFirstFD = 3
+
+// SystemdListeners returns listeners passed by systemd socket activation
+// (LISTEN_PID and LISTEN_FDS), none when the process wasn't socket activated.
+// The variables are unset, so children don't take the sockets again.
+func SystemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	for fd := systemdFirstFD; fd < systemdFirstFD+count; fd++ {
+		file := os.NewFile(uintptr(fd), "systemd-listener-"+strconv.Itoa(fd))
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("vapi: can't use systemd socket %d: %s", fd, err.Error())
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// SdNotify sends state (e.g. "READY=1") to the systemd notify socket. It returns
+// false without error when the process doesn't run under systemd with Type=notify.
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' {
+		// abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("vapi: can't connect to systemd: %s", err.Error())
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("vapi: can't notify systemd: %s", err.Error())
+	}
+	return true, nil
+}
+
+// SystemdWatchdogInterval returns the watchdog timeout systemd expects pings within,
+// zero when the watchdog isn't enabled for the process
+func SystemdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunSystemdWatchdog pings the systemd watchdog at half of its interval until ctx
+// is done. Pings are skipped while healthy returns an error (nil checks nothing),
+// so systemd restarts a server which stopped being healthy. It returns at once
+// when the watchdog isn't enabled.
+func RunSystemdWatchdog(ctx context.Context, healthy func() error) error {
+	interval := SystemdWatchdogInterval()
+	if interval == 0 {
+		return errors.New("vapi: systemd watchdog is not enabled")
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		if healthy == nil || healthy() == nil {
+			if _, err := SdNotify("WATCHDOG=1"); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	if ok, err := SdNotify("READY=1"); ok || err != nil {
+		t.Error(fmt.Sprintf("notify without systemd must be a no-op: %t %v", ok, err))
+	}
+
+	dir, _ := ioutil.TempDir("", "vapi-systemd")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skip("unixgram sockets are not supported: " + err.Error())
+	}
+	defer conn.Close()
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	read := func() string {
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _ := conn.Read(buf)
+		return string(buf[:n])
+	}
+
+	as := NewServer()
+	as.WarmUp()
+	if state := read(); state != "READY=1" {
+		t.Error(fmt.Sprintf("warm up must notify readiness: %q", state))
+	}
+	as.EnterLameDuck()
+	if state := read(); state != "STOPPING=1" {
+		t.Error(fmt.Sprintf("lame duck must notify stopping: %q", state))
+	}
+
+	os.Setenv("WATCHDOG_USEC", "20000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+	if interval := SystemdWatchdogInterval(); interval != 20*time.Millisecond {
+		t.Error(fmt.Sprintf("wrong watchdog interval: %s", interval))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+	defer cancel()
+	go RunSystemdWatchdog(ctx, nil)
+	if state := read(); state != "WATCHDOG=1" {
+		t.Error(fmt.Sprintf("watchdog must be pinged: %q", state))
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	if listeners, err := SystemdListeners(); listeners != nil || err != nil {
+		t.Error(fmt.Sprintf("process without socket activation must get no listeners: %v %v", listeners, err))
+	}
+}
@@ -0,0 +1,139 @@
+package vapi
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Tarpit escalates responses to clients hitting limits again and again: every
+// repeated offense doubles the Retry-After value and the delay before the 429
+// reply is sent, which calms retry loops of misbehaving scripts better than
+// instant rejections. Offenses are forgotten after Decay without new ones.
+type Tarpit struct {
+	// Delay is the delay of the first repeated offense, doubled by every next one up to MaxDelay
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// MaxRetryAfter caps the escalated Retry-After value
+	MaxRetryAfter time.Duration
+	// Decay is the quiet period after which offenses of the principal are forgotten
+	Decay time.Duration
+	// MaxDelayed limits responses being delayed at once, so the tarpit can't exhaust
+	// the server; above it replies are sent without delay
+	MaxDelayed int64
+
+	principal PrincipalFunc
+	delayed   int64 // accessed atomically
+
+	mutex     sync.Mutex
+	offenders map[string]*offender
+	lastSweep time.Time
+}
+
+// offender holds offenses of a principal
+type offender struct {
+	count int
+	last  time.Time
+}
+
+// NewTarpit returns tarpit accounting offenses to principal with default limits
+func NewTarpit(principal PrincipalFunc) *Tarpit {
+	return &Tarpit{
+		Delay:         500 * time.Millisecond,
+		MaxDelay:      10 * time.Second,
+		MaxRetryAfter: 10 * time.Minute,
+		Decay:         10 * time.Minute,
+		MaxDelayed:    100,
+		principal:     principal,
+		offenders:     make(map[string]*offender),
+	}
+}
+
+// SetTarpit escalates 429 replies of quotas and request classifiers with tarpit, nil disables it
+func (as *VAPI) SetTarpit(tarpit *Tarpit) {
+	as.mutex.Lock()
+	as.tarpit = tarpit
+	as.mutex.Unlock()
+}
+
+// Offenses returns the number of recent offenses of principal
+func (tp *Tarpit) Offenses(principal string) int {
+	tp.mutex.Lock()
+	defer tp.mutex.Unlock()
+	if o, ok := tp.offenders[principal]; ok && time.Since(o.last) <= tp.Decay {
+		return o.count
+	}
+	return 0
+}
+
+// penalize counts the offense of the request principal and returns escalated retryAfter and delay
+func (tp *Tarpit) penalize(ctx *fasthttp.RequestCtx, retryAfter time.Duration) (time.Duration, time.Duration) {
+	principal := tp.principal(ctx)
+	if principal == "" {
+		return retryAfter, 0
+	}
+
+	now := time.Now()
+	tp.mutex.Lock()
+	if now.Sub(tp.lastSweep) > tp.Decay {
+		for key, o := range tp.offenders {
+			if now.Sub(o.last) > tp.Decay {
+				delete(tp.offenders, key)
+			}
+		}
+		tp.lastSweep = now
+	}
+	o, ok := tp.offenders[principal]
+	if !ok || now.Sub(o.last) > tp.Decay {
+		o = &offender{}
+		tp.offenders[principal] = o
+	}
+	o.count++
+	o.last = now
+	repeated := o.count - 1
+	tp.mutex.Unlock()
+
+	if repeated == 0 {
+		return retryAfter, 0
+	}
+	return escalate(retryAfter, repeated, tp.MaxRetryAfter), escalate(tp.Delay, repeated-1, tp.MaxDelay)
+}
+
+// sleep delays the response unless too many responses are delayed already
+func (tp *Tarpit) sleep(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	if atomic.AddInt64(&tp.delayed, 1) <= tp.MaxDelayed {
+		time.Sleep(delay)
+	}
+	atomic.AddInt64(&tp.delayed, -1)
+}
+
+// escalate returns base doubled times times, capped at max
+func escalate(base time.Duration, times int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < times && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// writeTooManyRequests writes 429 with Retry-After, escalated by the tarpit when it is set
+func (as *VAPI) writeTooManyRequests(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, retryAfter time.Duration, err error) {
+	as.mutex.RLock()
+	tarpit := as.tarpit
+	as.mutex.RUnlock()
+	if tarpit != nil {
+		var delay time.Duration
+		retryAfter, delay = tarpit.penalize(ctx, retryAfter)
+		tarpit.sleep(delay)
+	}
+	ctx.Response.Header.Set("Retry-After", seconds(retryAfter))
+	as.writeError(ctx, srvResponse, fasthttp.StatusTooManyRequests, err)
+}
@@ -0,0 +1,45 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestTarpit(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+	as.AddClassifier(MissingUserAgent)
+	tarpit := NewTarpit(RemoteIPPrincipal)
+	tarpit.Delay = 10 * time.Millisecond
+	tarpit.MaxRetryAfter = 30 * time.Second
+	as.SetTarpit(tarpit)
+
+	call := func() (string, time.Duration) {
+		var response *fasthttp.RequestCtx
+		started := time.Now()
+		as.callLocalWith("demo.Test", []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+		})
+		return string(response.Response.Header.Peek("Retry-After")), time.Since(started)
+	}
+
+	for i, expected := range []string{"10", "20", "30", "30"} {
+		retryAfter, took := call()
+		if retryAfter != expected {
+			t.Error(fmt.Sprintf("offense %d: expected Retry-After %s, got %s", i+1, expected, retryAfter))
+		}
+		if (i == 0) != (took < 10*time.Millisecond) {
+			t.Error(fmt.Sprintf("offense %d: only repeated offenses must be delayed, took %s", i+1, took))
+		}
+	}
+	if offenses := tarpit.Offenses("0.0.0.0"); offenses != 4 {
+		t.Error(fmt.Sprintf("wrong offenses: %d", offenses))
+	}
+
+	if escalate(time.Second, 3, 5*time.Second) != 5*time.Second || escalate(time.Second, 2, time.Minute) != 4*time.Second {
+		t.Error("wrong escalation")
+	}
+}
@@ -0,0 +1,173 @@
+package vapi
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// bandwidthBucket is a token bucket of bytes refilled at rate per second with one second burst
+type bandwidthBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newBandwidthBucket returns full bucket
+func newBandwidthBucket(bytesPerSecond int64) *bandwidthBucket {
+	return &bandwidthBucket{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// take consumes n bytes and returns how long the caller must wait to stay within the rate
+func (b *bandwidthBucket) take(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// principalBandwidth caps total bandwidth of concurrent streamed responses of every principal
+type principalBandwidth struct {
+	mutex     sync.Mutex
+	principal PrincipalFunc
+	rate      int64
+	buckets   map[string]*sharedBucket
+}
+
+// sharedBucket is a principal bucket with the number of responses using it
+type sharedBucket struct {
+	*bandwidthBucket
+	refs int
+}
+
+// acquire returns bucket of the principal
+func (pb *principalBandwidth) acquire(principal string) *bandwidthBucket {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+
+	shared, ok := pb.buckets[principal]
+	if !ok {
+		shared = &sharedBucket{bandwidthBucket: newBandwidthBucket(pb.rate)}
+		pb.buckets[principal] = shared
+	}
+	shared.refs++
+	return shared.bandwidthBucket
+}
+
+// release forgets bucket of the principal when its last response is finished
+func (pb *principalBandwidth) release(principal string) {
+	pb.mutex.Lock()
+	defer pb.mutex.Unlock()
+
+	if shared, ok := pb.buckets[principal]; ok {
+		if shared.refs--; shared.refs <= 0 {
+			delete(pb.buckets, principal)
+		}
+	}
+}
+
+// SetMethodBandwidth caps every streamed (FileReply) response of the method
+// at bytesPerSecond, zero removes the cap
+func (as *VAPI) SetMethodBandwidth(method string, bytesPerSecond int64) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	as.mutex.Lock()
+	methodSpec.bandwidth = bytesPerSecond
+	as.mutex.Unlock()
+	return nil
+}
+
+// SetPrincipalBandwidth caps the total rate of concurrent streamed responses of
+// every principal at bytesPerSecond, so one client downloading exports can't
+// saturate the egress. Zero rate or nil principal removes the cap.
+func (as *VAPI) SetPrincipalBandwidth(principal PrincipalFunc, bytesPerSecond int64) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if principal == nil || bytesPerSecond <= 0 {
+		as.bandwidth = nil
+		return
+	}
+	as.bandwidth = &principalBandwidth{principal: principal, rate: bytesPerSecond, buckets: make(map[string]*sharedBucket)}
+}
+
+// throttle wraps streamed response body into reader keeping configured caps
+func (as *VAPI) throttle(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, body io.Reader) io.Reader {
+	as.mutex.RLock()
+	rate, bandwidth := methodSpec.bandwidth, as.bandwidth
+	as.mutex.RUnlock()
+
+	reader := &throttledReader{Reader: body}
+	if rate > 0 {
+		reader.buckets = append(reader.buckets, newBandwidthBucket(rate))
+	}
+	if bandwidth != nil {
+		if principal := bandwidth.principal(ctx); principal != "" {
+			reader.buckets = append(reader.buckets, bandwidth.acquire(principal))
+			reader.release = func() { bandwidth.release(principal) }
+		}
+	}
+	if len(reader.buckets) == 0 {
+		return body
+	}
+	return reader
+}
+
+// throttledReader delays reads to keep rates of its buckets
+type throttledReader struct {
+	io.Reader
+	buckets []*bandwidthBucket
+	release func()
+	once    sync.Once
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// small chunks keep the stream smooth instead of bursting a second worth of data
+	for _, bucket := range r.buckets {
+		if chunk := int(bucket.rate / 10); chunk > 0 && len(p) > chunk {
+			p = p[:chunk]
+		}
+	}
+
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		var delay time.Duration
+		for _, bucket := range r.buckets {
+			if wait := bucket.take(n); wait > delay {
+				delay = wait
+			}
+		}
+		time.Sleep(delay)
+	}
+	return n, err
+}
+
+// Close releases the principal bucket and closes the underlying reader, fasthttp calls it after streaming
+func (r *throttledReader) Close() error {
+	r.once.Do(func() {
+		if r.release != nil {
+			r.release()
+		}
+	})
+	if closer, ok := r.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
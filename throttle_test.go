@@ -0,0 +1,33 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestThrottledReader(t *testing.T) {
+	pb := &principalBandwidth{rate: 10000, buckets: make(map[string]*sharedBucket)}
+	reader := &throttledReader{
+		Reader:  bytes.NewReader(make([]byte, 12000)),
+		buckets: []*bandwidthBucket{pb.acquire("alice")},
+		release: func() { pb.release("alice") },
+	}
+
+	started := time.Now()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil || len(data) != 12000 {
+		t.Fatal(fmt.Sprintf("wrong read: %d %v", len(data), err))
+	}
+	// one second burst, the remaining 2000 bytes take 0.2s
+	if elapsed := time.Since(started); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Error(fmt.Sprintf("wrong throttling: %s", elapsed))
+	}
+
+	reader.Close()
+	if len(pb.buckets) != 0 {
+		t.Error("bucket must be released after the last response")
+	}
+}
@@ -0,0 +1,166 @@
+package vapi
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TimestampHeader is the request header carrying the client time of the request
+const TimestampHeader = "X-Timestamp"
+
+// DefaultTimestampSkew is the MaxSkew used when validator leaves it zero
+const DefaultTimestampSkew = 5 * time.Minute
+
+// skewBuckets are upper bounds of the absolute clock skew histogram
+var skewBuckets = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+}
+
+// TimestampValidator rejects requests whose TimestampHeader is missing or
+// differs from the server clock by more than MaxSkew.
+//
+// The header value is either unix time in seconds or an RFC 3339 time.
+// It is meant to be covered by the request signature, so a captured request
+// can't be replayed after the skew window passed.
+//
+// The zero value is ready to use and allows DefaultTimestampSkew.
+type TimestampValidator struct {
+	// MaxSkew is the maximum allowed difference between client and server clocks,
+	// zero means DefaultTimestampSkew
+	MaxSkew time.Duration
+
+	once sync.Once
+	now  func() time.Time
+
+	accepted uint64
+	rejected uint64
+	missing  uint64
+	buckets  []uint64 // one counter per skewBuckets entry plus the overflow bucket
+}
+
+// TimestampStats is a snapshot of the validator counters
+type TimestampStats struct {
+	Accepted uint64
+	Rejected uint64
+	Missing  uint64
+
+	// Skew holds observed absolute skew counts keyed by bucket upper bound,
+	// "+Inf" collects values above the largest bound
+	Skew map[string]uint64
+}
+
+// NewTimestampValidator returns validator allowing maxSkew clock difference in both directions
+func NewTimestampValidator(maxSkew time.Duration) *TimestampValidator {
+	return &TimestampValidator{
+		MaxSkew: maxSkew,
+		now:     time.Now,
+		buckets: make([]uint64, len(skewBuckets)+1),
+	}
+}
+
+// init fills fields a zero value validator leaves unset
+func (tv *TimestampValidator) init() {
+	tv.once.Do(func() {
+		if tv.now == nil {
+			tv.now = time.Now
+		}
+		if tv.buckets == nil {
+			tv.buckets = make([]uint64, len(skewBuckets)+1)
+		}
+	})
+}
+
+// maxSkew returns MaxSkew or its default
+func (tv *TimestampValidator) maxSkew() time.Duration {
+	if tv.MaxSkew <= 0 {
+		return DefaultTimestampSkew
+	}
+	return tv.MaxSkew
+}
+
+// Handler wraps next with timestamp validation
+func (tv *TimestampValidator) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if err := tv.validate(ctx.Request.Header.Peek(TimestampHeader)); err != nil {
+			writeHandlerError(ctx, fasthttp.StatusUnauthorized, err)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// Stats returns current validator counters
+func (tv *TimestampValidator) Stats() TimestampStats {
+	tv.init()
+	stats := TimestampStats{
+		Accepted: atomic.LoadUint64(&tv.accepted),
+		Rejected: atomic.LoadUint64(&tv.rejected),
+		Missing:  atomic.LoadUint64(&tv.missing),
+		Skew:     make(map[string]uint64, len(tv.buckets)),
+	}
+	for i := range tv.buckets {
+		label := "+Inf"
+		if i < len(skewBuckets) {
+			label = skewBuckets[i].String()
+		}
+		stats.Skew[label] = atomic.LoadUint64(&tv.buckets[i])
+	}
+	return stats
+}
+
+// validate checks raw header value and records its skew
+func (tv *TimestampValidator) validate(raw []byte) error {
+	tv.init()
+	if len(raw) == 0 {
+		atomic.AddUint64(&tv.missing, 1)
+		return errors.New("vapi: missing " + TimestampHeader + " header")
+	}
+
+	ts, err := parseTimestamp(string(raw))
+	if err != nil {
+		atomic.AddUint64(&tv.rejected, 1)
+		return errors.New("vapi: malformed " + TimestampHeader + " header")
+	}
+
+	skew := tv.now().Sub(ts)
+	if skew < 0 {
+		skew = -skew
+	}
+	tv.observe(skew)
+
+	if skew > tv.maxSkew() {
+		atomic.AddUint64(&tv.rejected, 1)
+		return errors.New("vapi: request timestamp is outside of allowed window")
+	}
+
+	atomic.AddUint64(&tv.accepted, 1)
+	return nil
+}
+
+// observe puts skew into histogram bucket
+func (tv *TimestampValidator) observe(skew time.Duration) {
+	for i, bound := range skewBuckets {
+		if skew <= bound {
+			atomic.AddUint64(&tv.buckets[i], 1)
+			return
+		}
+	}
+	atomic.AddUint64(&tv.buckets[len(skewBuckets)], 1)
+}
+
+// parseTimestamp accepts unix seconds or RFC 3339 time
+func parseTimestamp(value string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
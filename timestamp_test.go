@@ -0,0 +1,54 @@
+package vapi
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimestampValidator(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tv := NewTimestampValidator(30 * time.Second)
+	tv.now = func() time.Time { return now }
+
+	if err := tv.validate([]byte(strconv.FormatInt(now.Unix()-10, 10))); err != nil {
+		t.Error(err)
+	}
+	if err := tv.validate([]byte(now.Add(20 * time.Second).Format(time.RFC3339))); err != nil {
+		t.Error(err)
+	}
+	if err := tv.validate([]byte(strconv.FormatInt(now.Unix()-120, 10))); err == nil {
+		t.Error("stale timestamp must be rejected")
+	}
+	if err := tv.validate(nil); err == nil {
+		t.Error("missing timestamp must be rejected")
+	}
+	if err := tv.validate([]byte("yesterday")); err == nil {
+		t.Error("malformed timestamp must be rejected")
+	}
+
+	stats := tv.Stats()
+	if stats.Accepted != 2 || stats.Rejected != 2 || stats.Missing != 1 {
+		t.Error(fmt.Sprintf("wrong counters: %+v", stats))
+	}
+	if stats.Skew["30s"] != 2 || stats.Skew["5m0s"] != 1 {
+		t.Error(fmt.Sprintf("wrong skew histogram: %+v", stats.Skew))
+	}
+}
+
+func TestTimestampValidator_ZeroValue(t *testing.T) {
+	var tv TimestampValidator
+
+	if err := tv.validate([]byte(strconv.FormatInt(time.Now().Unix()-60, 10))); err != nil {
+		t.Error(err)
+	}
+	if err := tv.validate([]byte(strconv.FormatInt(time.Now().Unix()-3600, 10))); err == nil {
+		t.Error("timestamp outside of default skew must be rejected")
+	}
+
+	stats := tv.Stats()
+	if stats.Accepted != 1 || stats.Rejected != 1 || stats.Skew["5m0s"] != 1 {
+		t.Error(fmt.Sprintf("wrong counters: %+v", stats))
+	}
+}
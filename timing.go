@@ -0,0 +1,78 @@
+package vapi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// timingUserValue is the RequestCtx user value key of collected Server-Timing metrics
+const timingUserValue = "vapi.timing"
+
+// serverTimingMetric is a Server-Timing entry
+type serverTimingMetric struct {
+	name     string
+	duration time.Duration
+}
+
+// SetServerTiming enables Server-Timing response header with auth, decode, handler
+// and encode durations of every call, plus metrics added by methods with AddServerTiming.
+//
+// HTTP trailers are not supported: fasthttp writes responses with Content-Length,
+// so post-body metadata has to go to the reply or the headers.
+func (as *VAPI) SetServerTiming(enabled bool) {
+	as.mutex.Lock()
+	as.serverTiming = enabled
+	as.mutex.Unlock()
+}
+
+// AddServerTiming adds metric (e.g. "db" or "auth") to the Server-Timing header
+// and the debug trace of the current call. Middleware may call it before CallAPI.
+// It is ignored inside calls when both are disabled.
+func AddServerTiming(ctx *fasthttp.RequestCtx, name string, duration time.Duration) {
+	if as, ok := ctx.UserValue(serverUserValue).(*VAPI); ok && !as.timingEnabled(ctx) {
+		return
+	}
+	metrics, _ := ctx.UserValue(timingUserValue).([]serverTimingMetric)
+	ctx.SetUserValue(timingUserValue, append(metrics, serverTimingMetric{name: name, duration: duration}))
+}
+
+// markTiming records duration of the call phase started at started
+func (as *VAPI) markTiming(ctx *fasthttp.RequestCtx, name string, started time.Time) {
+	if !as.timingEnabled(ctx) {
+		return
+	}
+	metrics, _ := ctx.UserValue(timingUserValue).([]serverTimingMetric)
+	ctx.SetUserValue(timingUserValue, append(metrics, serverTimingMetric{name: name, duration: time.Since(started)}))
+}
+
+// timingEnabled reports whether call phases of the request are recorded
+func (as *VAPI) timingEnabled(ctx *fasthttp.RequestCtx) bool {
+	return as.serverTimingEnabled() || ctx.UserValue(traceUserValue) != nil
+}
+
+// serverTimingEnabled reports whether Server-Timing header is sent
+func (as *VAPI) serverTimingEnabled() bool {
+	as.mutex.RLock()
+	enabled := as.serverTiming
+	as.mutex.RUnlock()
+	return enabled
+}
+
+// writeServerTiming sets Server-Timing header from recorded metrics
+func (as *VAPI) writeServerTiming(ctx *fasthttp.RequestCtx) {
+	if !as.serverTimingEnabled() {
+		return
+	}
+	metrics, _ := ctx.UserValue(timingUserValue).([]serverTimingMetric)
+	if len(metrics) == 0 {
+		return
+	}
+	entries := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		entries = append(entries, metric.name+";dur="+strconv.FormatFloat(milliseconds(metric.duration), 'f', 3, 64))
+	}
+	ctx.Response.Header.Set("Server-Timing", strings.Join(entries, ", "))
+}
@@ -0,0 +1,40 @@
+package vapi
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type TimingAPI struct{}
+
+func (h *TimingAPI) Query(ctx *fasthttp.RequestCtx, args *TestArgs, reply *TestReply) error {
+	AddServerTiming(ctx, "db", 1500*time.Microsecond)
+	return nil
+}
+
+func TestVAPI_SetServerTiming(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(TimingAPI), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{}`)
+	as.CallAPI(ctx, "TimingAPI.Query")
+	if header := ctx.Response.Header.Peek("Server-Timing"); len(header) != 0 {
+		t.Error(fmt.Sprintf("disabled timing must not be sent: %s", header))
+	}
+
+	as.SetServerTiming(true)
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetBodyString(`{}`)
+	as.CallAPI(ctx, "TimingAPI.Query")
+	pattern := regexp.MustCompile(`^auth;dur=[0-9.]+, decode;dur=[0-9.]+, db;dur=1\.500, handler;dur=[0-9.]+, encode;dur=[0-9.]+$`)
+	if header := ctx.Response.Header.Peek("Server-Timing"); !pattern.Match(header) {
+		t.Error(fmt.Sprintf("wrong Server-Timing: %s", header))
+	}
+}
@@ -0,0 +1,110 @@
+package vapi
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// DebugParam is the query parameter enabling debug modes, "?debug=trace" returns the timing breakdown
+const DebugParam = "debug"
+
+// traceUserValue is the RequestCtx user value key of the debug trace start
+const traceUserValue = "vapi.trace"
+
+// traceChainUserValue is the RequestCtx user value key of the gates recorded for the trace
+const traceChainUserValue = "vapi.trace.chain"
+
+// TraceStep is a recorded phase of the call
+type TraceStep struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Trace is the debug field of the response envelope in trace mode
+type Trace struct {
+	// Steps are phases in the order they finished: routing (time spent before
+	// CallAPI, including middleware), metrics added with AddServerTiming, auth
+	// (classification, verb, session, role policy and decision point checks),
+	// decode, handler and encode. Phases which didn't run are missing.
+	Steps []TraceStep `json:"steps"`
+	// Chain lists the admission gates the call went through in order, e.g.
+	// "maintenance", "classify", "authorize", "decision", "quota"; when the call
+	// was rejected the last one rejected it. Middleware around CallAPI isn't
+	// visible to the server, its time is part of routing.
+	Chain   []string `json:"chain"`
+	TotalMs float64  `json:"total_ms"`
+}
+
+// callChain records the gates a traced call went through
+type callChain struct {
+	gates []string
+}
+
+// traceChain returns the gate recorder of traced request, nil otherwise
+func traceChain(ctx *fasthttp.RequestCtx) *callChain {
+	if _, ok := ctx.UserValue(traceUserValue).(time.Time); !ok {
+		return nil
+	}
+	chain := &callChain{}
+	ctx.SetUserValue(traceChainUserValue, chain)
+	return chain
+}
+
+// enter records gate before it runs, gates write the rejection themselves; nil chain does nothing
+func (c *callChain) enter(gate string) {
+	if c != nil {
+		c.gates = append(c.gates, gate)
+	}
+}
+
+// SetDebugTrace enables "?debug=trace" for requests allow returns true for,
+// e.g. authenticated operators. The envelope of traced calls gets the "debug"
+// member with Trace, so latency can be investigated without a profiler.
+// Nil disables trace mode.
+func (as *VAPI) SetDebugTrace(allow func(ctx *fasthttp.RequestCtx) bool) {
+	as.mutex.Lock()
+	as.debugTrace = allow
+	as.mutex.Unlock()
+}
+
+// startTrace marks the request traced when trace mode is requested and allowed
+func startTrace(ctx *fasthttp.RequestCtx, allow func(ctx *fasthttp.RequestCtx) bool) {
+	if allow == nil || string(ctx.QueryArgs().Peek(DebugParam)) != "trace" || !allow(ctx) {
+		return
+	}
+	now := time.Now()
+	ctx.SetUserValue(traceUserValue, now)
+
+	// routing covers everything since the request was read
+	metrics, _ := ctx.UserValue(timingUserValue).([]serverTimingMetric)
+	routing := serverTimingMetric{name: "routing", duration: now.Sub(ctx.Time())}
+	ctx.SetUserValue(timingUserValue, append([]serverTimingMetric{routing}, metrics...))
+}
+
+// traceDebug returns encoded Trace of traced request, nil otherwise
+func traceDebug(ctx *fasthttp.RequestCtx) json.RawMessage {
+	if _, ok := ctx.UserValue(traceUserValue).(time.Time); !ok {
+		return nil
+	}
+
+	metrics, _ := ctx.UserValue(timingUserValue).([]serverTimingMetric)
+	trace := Trace{Steps: make([]TraceStep, 0, len(metrics)), Chain: []string{}, TotalMs: milliseconds(time.Since(ctx.Time()))}
+	if chain, ok := ctx.UserValue(traceChainUserValue).(*callChain); ok {
+		trace.Chain = chain.gates
+	}
+	for _, metric := range metrics {
+		trace.Steps = append(trace.Steps, TraceStep{Name: metric.name, DurationMs: milliseconds(metric.duration)})
+	}
+	body, err := json.Marshal(trace)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// milliseconds returns d in fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
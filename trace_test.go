@@ -0,0 +1,65 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetDebugTrace(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	as.SetDebugTrace(func(ctx *fasthttp.RequestCtx) bool {
+		return string(ctx.Request.Header.Peek("X-Operator")) == "yes"
+	})
+
+	call := func(operator string) ServerResponse {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/demo.Test?debug=trace")
+		ctx.Request.Header.Set("X-Operator", operator)
+		ctx.Request.SetBodyString(`{"id":"1"}`)
+		AddServerTiming(ctx, "middleware", time.Millisecond)
+		as.CallAPI(ctx, "demo.Test")
+
+		envelope := ServerResponse{}
+		if err := envelope.UnmarshalJSON(ctx.Response.Body()); err != nil {
+			t.Fatal(err)
+		}
+		return envelope
+	}
+
+	if envelope := call("no"); envelope.Debug != nil {
+		t.Error(fmt.Sprintf("trace must be authorized: %s", envelope.Debug))
+	}
+
+	trace := Trace{}
+	envelope := call("yes")
+	if err := json.Unmarshal(envelope.Debug, &trace); err != nil {
+		t.Fatal(fmt.Sprintf("%s: %s", err, envelope.Debug))
+	}
+	names := []string{}
+	for _, step := range trace.Steps {
+		names = append(names, step.Name)
+	}
+	if fmt.Sprint(names) != "[routing middleware auth decode handler encode]" || string(envelope.Response) != `{"id":"1"}` {
+		t.Error(fmt.Sprintf("wrong trace: %s", envelope.Debug))
+	}
+	if chain := fmt.Sprint(trace.Chain); chain != "[maintenance region country classify verb sunset jsonp session_origin authorize read_only decision quota shed budget fault]" {
+		t.Error(fmt.Sprintf("wrong chain: %s", chain))
+	}
+
+	// rejected call ends the chain with the gate which rejected it
+	as.SetMethodPolicy("demo.Test", Policy{AllowRoles: []string{"admin"}})
+	trace = Trace{}
+	if err := json.Unmarshal(call("yes").Debug, &trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.Chain) == 0 || trace.Chain[len(trace.Chain)-1] != "authorize" {
+		t.Error(fmt.Sprintf("wrong chain of rejected call: %v", trace.Chain))
+	}
+}
@@ -0,0 +1,161 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ArgsTransform rewrites raw args of a method before they are decoded, so
+// clients sending an older args shape keep working across breaking changes.
+// Paths are dotted field names, e.g. "filter.user_id". Steps are applied in
+// field order.
+type ArgsTransform struct {
+	// Rename moves fields from old to new paths, unless the new path is already set
+	Rename map[string]string `json:"rename,omitempty"`
+	// Wrap replaces a scalar at path with an object holding it under the key,
+	// e.g. {"user": "42"} becomes {"user": {"id": "42"}} with "user": "id"
+	Wrap map[string]string `json:"wrap,omitempty"`
+	// Defaults sets values of missing or null fields
+	Defaults map[string]json.RawMessage `json:"defaults,omitempty"`
+	// Remove drops fields unknown to the current args
+	Remove []string `json:"remove,omitempty"`
+	// Rewrite, when set, runs last with the decoded args object
+	Rewrite func(args map[string]interface{}) error `json:"-"`
+}
+
+// SetMethodArgsTransforms sets transforms applied in order to args of the
+// method before decoding and validation, none removes them. Args which are
+// not a json object are passed as is.
+func (as *VAPI) SetMethodArgsTransforms(method string, transforms ...ArgsTransform) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	for _, transform := range transforms {
+		for path, value := range transform.Defaults {
+			if !json.Valid(value) {
+				return fmt.Errorf("vapi: default of %s in %s is not valid json", path, method)
+			}
+		}
+	}
+
+	as.mutex.Lock()
+	methodSpec.transforms = transforms
+	as.mutex.Unlock()
+	return nil
+}
+
+// transformArgs applies args transforms of the method to raw, marking renamed
+// and wrapped fields sent in their old shape as deprecated
+func (as *VAPI) transformArgs(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, raw []byte) ([]byte, error) {
+	as.mutex.RLock()
+	transforms := methodSpec.transforms
+	as.mutex.RUnlock()
+	if len(transforms) == 0 || !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return raw, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	args := map[string]interface{}{}
+	if err := decoder.Decode(&args); err != nil {
+		// left to the args decoder to report
+		return raw, nil
+	}
+
+	for _, transform := range transforms {
+		for _, path := range transform.legacy(args) {
+			MarkDeprecated(ctx, methodSpec.name+" args "+path)
+		}
+		if err := transform.apply(args); err != nil {
+			return nil, &ValidationError{Message: err.Error()}
+		}
+	}
+	return json.Marshal(args)
+}
+
+// legacy returns paths of args the transform renames or wraps
+func (transform *ArgsTransform) legacy(args map[string]interface{}) []string {
+	var paths []string
+	for from := range transform.Rename {
+		if _, ok := lookupPath(args, from); ok {
+			paths = append(paths, from)
+		}
+	}
+	for path := range transform.Wrap {
+		value, ok := lookupPath(args, path)
+		if _, isObject := value.(map[string]interface{}); ok && value != nil && !isObject {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// apply runs the transform steps on args
+func (transform *ArgsTransform) apply(args map[string]interface{}) error {
+	for from, to := range transform.Rename {
+		if value, ok := lookupPath(args, from); ok {
+			if _, exists := lookupPath(args, to); !exists {
+				setPath(args, to, value)
+			}
+			deletePath(args, from)
+		}
+	}
+	for path, key := range transform.Wrap {
+		value, ok := lookupPath(args, path)
+		if _, isObject := value.(map[string]interface{}); ok && value != nil && !isObject {
+			setPath(args, path, map[string]interface{}{key: value})
+		}
+	}
+	for path, raw := range transform.Defaults {
+		if value, ok := lookupPath(args, path); !ok || value == nil {
+			var value interface{}
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			decoder.Decode(&value)
+			setPath(args, path, value)
+		}
+	}
+	for _, path := range transform.Remove {
+		deletePath(args, path)
+	}
+	if transform.Rewrite != nil {
+		return transform.Rewrite(args)
+	}
+	return nil
+}
+
+// lookupPath returns the value at dotted path of decoded json
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath sets the value at dotted path of args, creating missing objects
+func setPath(args map[string]interface{}, path string, value interface{}) {
+	names := strings.Split(path, ".")
+	object := args
+	for _, name := range names[:len(names)-1] {
+		next, ok := object[name].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			object[name] = next
+		}
+		object = next
+	}
+	object[names[len(names)-1]] = value
+}
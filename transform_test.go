@@ -0,0 +1,54 @@
+package vapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVAPI_SetMethodArgsTransforms(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+
+	if err := as.SetMethodArgsTransforms("demo.Test", ArgsTransform{Defaults: map[string]json.RawMessage{"id": json.RawMessage("{")}}); err == nil {
+		t.Error("invalid default must fail")
+	}
+
+	err := as.SetMethodArgsTransforms("demo.Test", ArgsTransform{
+		Rename:   map[string]string{"identifier": "id", "meta.note": "ttt"},
+		Defaults: map[string]json.RawMessage{"ttt": json.RawMessage(`"none"`)},
+		Remove:   []string{"legacy"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		`{"identifier":"1","legacy":true}`:   `{"response":{"id":"1","ttt":"none"}}`,
+		`{"id":"2","meta":{"note":"moved"}}`: `{"response":{"id":"2","ttt":"moved"}}`,
+		`{"identifier":"3","id":"4"}`:        `{"response":{"id":"4","ttt":"none"}}`,
+	}
+	for args, expected := range tests {
+		if status, body, _ := as.callLocal("demo.Test", []byte(args)); status != 200 || string(body) != expected {
+			t.Error(fmt.Sprintf("%s: %d %s", args, status, body))
+		}
+	}
+
+	as.SetMethodArgsTransforms("demo.Test", ArgsTransform{Rewrite: func(args map[string]interface{}) error {
+		return errors.New("args version 1 is no longer supported")
+	}})
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{"id":"1"}`)); status != 400 {
+		t.Error(fmt.Sprintf("rewrite error must be 400, got %d", status))
+	}
+}
+
+func TestArgsTransform_Wrap(t *testing.T) {
+	args := map[string]interface{}{"user": "42", "group": map[string]interface{}{"id": "7"}}
+	transform := ArgsTransform{Wrap: map[string]string{"user": "id", "group": "id"}}
+	transform.apply(args)
+	body, _ := json.Marshal(args)
+	if string(body) != `{"group":{"id":"7"},"user":{"id":"42"}}` {
+		t.Error(fmt.Sprintf("wrong wrap: %s", body))
+	}
+}
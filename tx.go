@@ -0,0 +1,94 @@
+package vapi
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// txUserValue is the RequestCtx user value key of resources in the request transaction scope
+const txUserValue = "vapi.tx"
+
+// TxResource is a transactional resource finished by the framework, *sql.Tx satisfies it
+type TxResource interface {
+	Commit() error
+	Rollback() error
+}
+
+// TxHooks adapts functions to TxResource, nil hooks are skipped
+type TxHooks struct {
+	OnCommit   func() error
+	OnRollback func() error
+}
+
+// Commit implements TxResource
+func (h TxHooks) Commit() error {
+	if h.OnCommit == nil {
+		return nil
+	}
+	return h.OnCommit()
+}
+
+// Rollback implements TxResource
+func (h TxHooks) Rollback() error {
+	if h.OnRollback == nil {
+		return nil
+	}
+	return h.OnRollback()
+}
+
+// txEntry is a named resource of the scope
+type txEntry struct {
+	name     string
+	resource TxResource
+}
+
+// AttachTx adds resource to the transaction scope of the request.
+//
+// Resources are committed in the order of attaching when the method returns nil
+// and rolled back in reverse order when it returns an error, panics or the call
+// fails before reaching the method. A middleware may attach resources before
+// CallAPI as well.
+func AttachTx(ctx *fasthttp.RequestCtx, name string, resource TxResource) {
+	entries, _ := ctx.UserValue(txUserValue).([]txEntry)
+	ctx.SetUserValue(txUserValue, append(entries, txEntry{name: name, resource: resource}))
+}
+
+// GetTx returns resource attached to the request by name, nil when absent
+func GetTx(ctx *fasthttp.RequestCtx, name string) TxResource {
+	entries, _ := ctx.UserValue(txUserValue).([]txEntry)
+	for _, entry := range entries {
+		if entry.name == name {
+			return entry.resource
+		}
+	}
+	return nil
+}
+
+// commitTx commits attached resources. When a commit fails the remaining
+// resources are rolled back and the error is returned; resources committed
+// before it stay committed.
+func commitTx(ctx *fasthttp.RequestCtx) error {
+	entries, _ := ctx.UserValue(txUserValue).([]txEntry)
+	for i, entry := range entries {
+		if err := entry.resource.Commit(); err != nil {
+			ctx.SetUserValue(txUserValue, entries[i+1:])
+			return fmt.Errorf("vapi: commit of %q failed: %s", entry.name, err.Error())
+		}
+	}
+	ctx.SetUserValue(txUserValue, nil)
+	return nil
+}
+
+// rollbackTx rolls back resources left in the scope. Errors are ignored:
+// the response is already decided at this point.
+func rollbackTx(ctx *fasthttp.RequestCtx) {
+	entries, _ := ctx.UserValue(txUserValue).([]txEntry)
+	if len(entries) == 0 {
+		return
+	}
+	ctx.SetUserValue(txUserValue, nil)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].resource.Rollback()
+	}
+}
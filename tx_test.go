@@ -0,0 +1,47 @@
+package vapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestTxScope(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+	hooks := func(name string, commitErr error) TxHooks {
+		return TxHooks{
+			OnCommit:   func() error { log = append(log, "commit "+name); return commitErr },
+			OnRollback: func() error { log = append(log, "rollback "+name); return nil },
+		}
+	}
+	call := func(method string, resources ...TxHooks) int {
+		log = nil
+		status, _, _ := as.callLocalWith(method, []byte(`{"id":"1"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			for i, resource := range resources {
+				AttachTx(ctx, fmt.Sprint(i), resource)
+			}
+		})
+		return status
+	}
+
+	if status := call("demo.Test", hooks("a", nil), hooks("b", nil)); status != 200 || strings.Join(log, ",") != "commit a,commit b" {
+		t.Error(fmt.Sprintf("success must commit: %d %v", status, log))
+	}
+	if status := call("demo.ErrorTest", hooks("a", nil), hooks("b", nil)); status == 200 || strings.Join(log, ",") != "rollback b,rollback a" {
+		t.Error(fmt.Sprintf("error must roll back: %d %v", status, log))
+	}
+	if status := call("demo.Missing", hooks("a", nil)); status != 404 || strings.Join(log, ",") != "rollback a" {
+		t.Error(fmt.Sprintf("failed call must roll back: %d %v", status, log))
+	}
+	if status := call("demo.Test", hooks("a", errors.New("conflict")), hooks("b", nil)); status != 500 || strings.Join(log, ",") != "commit a,rollback b" {
+		t.Error(fmt.Sprintf("failed commit must roll back the rest: %d %v", status, log))
+	}
+}
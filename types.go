@@ -0,0 +1,300 @@
+package vapi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	typeOfUUID     = reflect.TypeOf(UUID{})
+	typeOfDuration = reflect.TypeOf(Duration(0))
+	typeOfIP       = reflect.TypeOf(IP{})
+	typeOfCIDR     = reflect.TypeOf(CIDR{})
+	typeOfURL      = reflect.TypeOf(URL{})
+)
+
+// UUID is an RFC 4122 identifier encoded as "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+type UUID [16]byte
+
+// NewUUID returns random (version 4) UUID
+func NewUUID() (UUID, error) {
+	u := UUID{}
+	if _, err := io.ReadFull(rand.Reader, u[:]); err != nil {
+		return u, err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+// ParseUUID parses canonical (optionally braced or urn:uuid: prefixed) UUID text
+func ParseUUID(s string) (UUID, error) {
+	u := UUID{}
+	text := s
+	switch {
+	case len(text) == 45 && text[:9] == "urn:uuid:":
+		text = text[9:]
+	case len(text) == 38 && text[0] == '{' && text[37] == '}':
+		text = text[1:37]
+	}
+	if len(text) != 36 || text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+		return u, &ValidationError{Message: fmt.Sprintf("invalid uuid %q", s)}
+	}
+	compact := text[:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+	if _, err := hex.Decode(u[:], []byte(compact)); err != nil {
+		return u, &ValidationError{Message: fmt.Sprintf("invalid uuid %q", s)}
+	}
+	return u, nil
+}
+
+// IsZero reports whether u is the nil UUID
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// String returns canonical UUID text
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (u UUID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + u.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (u *UUID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	text, ok := jsonString(data)
+	if !ok {
+		return &ValidationError{Message: fmt.Sprintf("uuid must be a string, got %s", data)}
+	}
+	if text == "" {
+		*u = UUID{}
+		return nil
+	}
+	return u.UnmarshalText([]byte(text))
+}
+
+// Duration is a time.Duration encoded as Go duration string ("1h30m", "30s").
+// Json numbers are accepted too and taken as seconds.
+type Duration time.Duration
+
+// String returns duration text
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return &ValidationError{Message: fmt.Sprintf("invalid duration %q", text)}
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if text, ok := jsonString(data); ok {
+		return d.UnmarshalText([]byte(text))
+	}
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return &ValidationError{Message: fmt.Sprintf("invalid duration %s", data)}
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// IP is a net.IP encoded as text
+type IP struct {
+	net.IP
+}
+
+// MarshalJSON implements json.Marshaler
+func (ip IP) MarshalJSON() ([]byte, error) {
+	if ip.IP == nil {
+		return []byte(`""`), nil
+	}
+	return []byte(`"` + ip.IP.String() + `"`), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (ip *IP) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		ip.IP = nil
+		return nil
+	}
+	parsed := net.ParseIP(string(text))
+	if parsed == nil {
+		return &ValidationError{Message: fmt.Sprintf("invalid ip address %q", text)}
+	}
+	ip.IP = parsed
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (ip *IP) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	text, ok := jsonString(data)
+	if !ok {
+		return &ValidationError{Message: fmt.Sprintf("ip address must be a string, got %s", data)}
+	}
+	return ip.UnmarshalText([]byte(text))
+}
+
+// CIDR is a network in CIDR notation ("192.168.0.0/16")
+type CIDR struct {
+	*net.IPNet
+}
+
+// String returns CIDR text
+func (c CIDR) String() string {
+	if c.IPNet == nil {
+		return ""
+	}
+	return c.IPNet.String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (c CIDR) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (c *CIDR) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		c.IPNet = nil
+		return nil
+	}
+	_, network, err := net.ParseCIDR(string(text))
+	if err != nil {
+		return &ValidationError{Message: fmt.Sprintf("invalid cidr %q", text)}
+	}
+	c.IPNet = network
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (c CIDR) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(c.String())), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *CIDR) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	text, ok := jsonString(data)
+	if !ok {
+		return &ValidationError{Message: fmt.Sprintf("cidr must be a string, got %s", data)}
+	}
+	return c.UnmarshalText([]byte(text))
+}
+
+// URL is an absolute url encoded as text
+type URL struct {
+	url.URL
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.URL.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (u *URL) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		u.URL = url.URL{}
+		return nil
+	}
+	parsed, err := url.Parse(string(text))
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return &ValidationError{Message: fmt.Sprintf("invalid absolute url %q", text)}
+	}
+	u.URL = *parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (u URL) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(u.URL.String())), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	text, ok := jsonString(data)
+	if !ok {
+		return &ValidationError{Message: fmt.Sprintf("url must be a string, got %s", data)}
+	}
+	return u.UnmarshalText([]byte(text))
+}
+
+// jsonString decodes json string literal, false when data is not a string
+func jsonString(data []byte) (string, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '"' {
+		return "", false
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return "", false
+	}
+	return text, true
+}
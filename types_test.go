@@ -0,0 +1,79 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTypes_JSON(t *testing.T) {
+	var args struct {
+		ID      UUID     `json:"id"`
+		Timeout Duration `json:"timeout"`
+		Retry   Duration `json:"retry"`
+		Client  IP       `json:"client"`
+		Network CIDR     `json:"network"`
+		Hook    URL      `json:"hook"`
+	}
+
+	err := json.Unmarshal([]byte(`{
+		"id": "{6BA7B810-9DAD-11D1-80B4-00C04FD430C8}",
+		"timeout": "1m30s",
+		"retry": 2.5,
+		"client": "2001:db8::1",
+		"network": "10.0.0.1/8",
+		"hook": "https://example.com/hook?x=1"
+	}`), &args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Duration(args.Timeout) != 90*time.Second || time.Duration(args.Retry) != 2500*time.Millisecond {
+		t.Error(fmt.Sprintf("wrong durations: %s %s", args.Timeout, args.Retry))
+	}
+
+	body, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":"6ba7b810-9dad-11d1-80b4-00c04fd430c8","timeout":"1m30s","retry":"2.5s","client":"2001:db8::1","network":"10.0.0.0/8","hook":"https://example.com/hook?x=1"}`
+	if string(body) != expected {
+		t.Error(fmt.Sprintf("wrong encoded value: %s", body))
+	}
+
+	// null leaves values as they are, like encoding/json does for its own types
+	err = json.Unmarshal([]byte(`{"id":null,"timeout":null,"client":null,"network":null,"hook":null}`), &args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = json.Marshal(args); string(body) != expected {
+		t.Error(fmt.Sprintf("null must keep values: %s", body))
+	}
+
+	invalid := []string{
+		`{"id": "6ba7b810-9dad-11d1-80b4"}`,
+		`{"id": 42}`,
+		`{"timeout": "soon"}`,
+		`{"client": "300.1.1.1"}`,
+		`{"network": "10.0.0.1"}`,
+		`{"hook": "/relative"}`,
+	}
+	for _, input := range invalid {
+		err = json.Unmarshal([]byte(input), &args)
+		if _, ok := err.(*ValidationError); !ok {
+			t.Error(fmt.Sprintf("%s must be rejected with validation error, got %v", input, err))
+		}
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	u, err := NewUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseUUID(u.String())
+	if err != nil || parsed != u || u.String()[14] != '4' {
+		t.Error(fmt.Sprintf("wrong uuid: %s", u))
+	}
+}
@@ -0,0 +1,202 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// unionType is the discriminator mapping of a registered interface
+type unionType struct {
+	discriminator string
+	variants      map[string]reflect.Type
+}
+
+// unionVariant is the discriminator value of a concrete type
+type unionVariant struct {
+	discriminator string
+	name          string
+}
+
+var (
+	unionsMutex sync.RWMutex
+	unions      = map[reflect.Type]*unionType{}
+	variants    = map[reflect.Type]unionVariant{}
+)
+
+// RegisterUnion declares the concrete types of interface payloads. iface is a nil pointer
+// to the interface, variants maps discriminator values to zero values of implementations:
+//
+//	vapi.RegisterUnion((*Payment)(nil), "type", map[string]interface{}{
+//		"card": &CardPayment{},
+//		"bank": &BankPayment{},
+//	})
+//
+// Registered interface fields are documented in OpenAPI with oneOf and discriminator.
+// Json codecs of types holding them decode and encode the field with UnmarshalUnion
+// and MarshalUnion.
+func RegisterUnion(iface interface{}, discriminator string, implementations map[string]interface{}) error {
+	ifaceType := reflect.TypeOf(iface)
+	if ifaceType == nil || ifaceType.Kind() != reflect.Ptr || ifaceType.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("vapi: union must be declared with nil pointer to interface, got %T", iface)
+	}
+	ifaceType = ifaceType.Elem()
+	if discriminator == "" {
+		return fmt.Errorf("vapi: union %s has no discriminator property", ifaceType)
+	}
+
+	union := &unionType{discriminator: discriminator, variants: make(map[string]reflect.Type, len(implementations))}
+	for name, implementation := range implementations {
+		t := reflect.TypeOf(implementation)
+		if t == nil || !t.Implements(ifaceType) {
+			return fmt.Errorf("vapi: %T of union %s variant %q doesn't implement it", implementation, ifaceType, name)
+		}
+		union.variants[name] = t
+	}
+
+	unionsMutex.Lock()
+	defer unionsMutex.Unlock()
+	unions[ifaceType] = union
+	for name, t := range union.variants {
+		variants[t] = unionVariant{discriminator: discriminator, name: name}
+	}
+	return nil
+}
+
+// UnmarshalUnion decodes json object into target, a pointer to registered interface,
+// choosing the concrete type by the discriminator property
+func UnmarshalUnion(data []byte, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("vapi: union target must be pointer to interface, got %T", target)
+	}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		return nil
+	}
+
+	union := lookupUnion(v.Elem().Type())
+	if union == nil {
+		return fmt.Errorf("vapi: union %s is not registered", v.Elem().Type())
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("%s must be an object", union.discriminator)}
+	}
+	name := ""
+	if raw, ok := fields[union.discriminator]; ok {
+		name, _ = jsonString(raw)
+	}
+	t, ok := union.variants[name]
+	if !ok {
+		return &ValidationError{
+			Field:   union.discriminator,
+			Message: fmt.Sprintf("%q is not one of allowed values: %s", name, strings.Join(union.names(), ", ")),
+		}
+	}
+
+	value := reflect.New(indirectType(t))
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return err
+	}
+	if t.Kind() != reflect.Ptr {
+		value = value.Elem()
+	}
+	v.Elem().Set(value)
+	return nil
+}
+
+// MarshalUnion encodes registered union variant adding the discriminator property
+func MarshalUnion(value interface{}) ([]byte, error) {
+	if value == nil {
+		return []byte("null"), nil
+	}
+
+	unionsMutex.RLock()
+	variant, ok := variants[reflect.TypeOf(value)]
+	unionsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("vapi: %T is not a registered union variant", value)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("vapi: union variant %T must encode to json object", value)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields[variant.discriminator]; ok {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	buf.WriteString(strconv.Quote(variant.discriminator))
+	buf.WriteByte(':')
+	buf.WriteString(strconv.Quote(variant.name))
+	if len(bytes.TrimSpace(data[1:len(data)-1])) > 0 {
+		buf.WriteByte(',')
+	}
+	buf.Write(data[1:])
+	return buf.Bytes(), nil
+}
+
+// lookupUnion returns registered union of interface type t
+func lookupUnion(t reflect.Type) *unionType {
+	unionsMutex.RLock()
+	defer unionsMutex.RUnlock()
+	return unions[t]
+}
+
+// names returns sorted discriminator values
+func (u *unionType) names() []string {
+	names := make([]string, 0, len(u.variants))
+	for name := range u.variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unionSchema documents union as oneOf of variants with discriminator mapping
+func unionSchema(doc *OpenAPIDocument, union *unionType) *Schema {
+	schema := &Schema{Discriminator: &OpenAPIDiscriminator{PropertyName: union.discriminator, Mapping: map[string]string{}}}
+
+	for _, name := range union.names() {
+		variant := schemaOf(doc, union.variants[name])
+		schema.OneOf = append(schema.OneOf, variant)
+		if variant.Ref == "" {
+			continue
+		}
+		schema.Discriminator.Mapping[name] = variant.Ref
+
+		// variants must declare the discriminator property themselves
+		component := doc.Components.Schemas[strings.TrimPrefix(variant.Ref, "#/components/schemas/")]
+		if component == nil || component.Properties == nil {
+			continue
+		}
+		property, ok := component.Properties[union.discriminator]
+		if !ok {
+			property = &Schema{Type: "string"}
+			component.Properties[union.discriminator] = property
+			component.Required = append(component.Required, union.discriminator)
+		}
+		if len(property.Enum) == 0 {
+			property.Enum = []interface{}{name}
+		}
+	}
+	return schema
+}
@@ -0,0 +1,80 @@
+package vapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testPayment interface {
+	Amount() int
+}
+
+type testCardPayment struct {
+	Number string `json:"number"`
+	Sum    int    `json:"sum"`
+}
+
+func (p *testCardPayment) Amount() int { return p.Sum }
+
+type testBankPayment struct {
+	IBAN string `json:"iban"`
+	Sum  int    `json:"sum"`
+}
+
+func (p testBankPayment) Amount() int { return p.Sum }
+
+func init() {
+	err := RegisterUnion((*testPayment)(nil), "type", map[string]interface{}{
+		"card": &testCardPayment{},
+		"bank": testBankPayment{},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestUnion(t *testing.T) {
+	var payment testPayment
+	if err := UnmarshalUnion([]byte(`{"type":"card","number":"4111","sum":10}`), &payment); err != nil {
+		t.Fatal(err)
+	}
+	if card, ok := payment.(*testCardPayment); !ok || card.Number != "4111" {
+		t.Error(fmt.Sprintf("wrong variant: %#v", payment))
+	}
+
+	if err := UnmarshalUnion([]byte(`{"type":"bank","iban":"DE1","sum":5}`), &payment); err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount() != 5 {
+		t.Error(fmt.Sprintf("wrong variant: %#v", payment))
+	}
+
+	err := UnmarshalUnion([]byte(`{"type":"cash"}`), &payment)
+	if _, ok := err.(*ValidationError); !ok {
+		t.Error(fmt.Sprintf("unknown variant must be rejected, got %v", err))
+	}
+
+	body, err := MarshalUnion(&testCardPayment{Number: "4111", Sum: 10})
+	if err != nil || string(body) != `{"type":"card","number":"4111","sum":10}` {
+		t.Error(fmt.Sprintf("wrong encoded variant: %s %v", body, err))
+	}
+}
+
+func TestOpenAPI_Union(t *testing.T) {
+	type payArgs struct {
+		Payment testPayment `json:"payment"`
+	}
+	doc := &OpenAPIDocument{Components: OpenAPIComponents{Schemas: make(map[string]*Schema)}}
+	schema := structSchema(doc, reflect.TypeOf(payArgs{})).Properties["payment"]
+
+	body, _ := json.Marshal(schema)
+	expected := `{"oneOf":[{"$ref":"#/components/schemas/testBankPayment"},{"$ref":"#/components/schemas/testCardPayment"}],"discriminator":{"propertyName":"type","mapping":{"bank":"#/components/schemas/testBankPayment","card":"#/components/schemas/testCardPayment"}}}`
+	if string(body) != expected {
+		t.Error(fmt.Sprintf("wrong union schema: %s", body))
+	}
+	if card := doc.Components.Schemas["testCardPayment"]; card.Properties["type"] == nil || card.Required[0] != "type" {
+		t.Error("variant schema must declare discriminator property")
+	}
+}
@@ -0,0 +1,198 @@
+package vapi
+
+import (
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Build information of the binary, set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/riftbit/go-vapi.BuildVersion=1.4.2 -X github.com/riftbit/go-vapi.BuildCommit=$(git rev-parse HEAD) -X github.com/riftbit/go-vapi.BuildTime=$(date -u +%FT%TZ)"
+var (
+	BuildVersion = ""
+	BuildCommit  = ""
+	BuildTime    = ""
+)
+
+// BuildInfo identifies the build of the server
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Time      string `json:"time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// BuildHeader is the response header naming the build which served the call,
+// see SetBuildInfo. APIVersionHeader is the reply shape version, not the build.
+const BuildHeader = "X-Server-Version"
+
+// ReadBuildInfo returns the build information set with ldflags. Without
+// BuildVersion it falls back to the main module version, "dev" for builds
+// outside of a module release.
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Version: BuildVersion, Commit: BuildCommit, Time: BuildTime, GoVersion: runtime.Version()}
+	if info.Version == "" {
+		info.Version = "dev"
+		if module, ok := debug.ReadBuildInfo(); ok && module.Main.Version != "" && module.Main.Version != "(devel)" {
+			info.Version = module.Main.Version
+		}
+	}
+	return info
+}
+
+// SetBuildInfo sets the build of the server reported by BuildInfo, VersionHandler
+// and UtilService.Version, and sends it in BuildHeader of every call, e.g.
+// "1.4.2 3f2a9c1", so support can tell which build a client talked to. Pass
+// ReadBuildInfo() to report the ldflags build information.
+func (as *VAPI) SetBuildInfo(info BuildInfo) {
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	as.mutex.Lock()
+	as.build = &info
+	as.mutex.Unlock()
+}
+
+// BuildInfo returns the build set with SetBuildInfo, ReadBuildInfo without it
+func (as *VAPI) BuildInfo() BuildInfo {
+	as.mutex.RLock()
+	build := as.build
+	as.mutex.RUnlock()
+	if build == nil {
+		return ReadBuildInfo()
+	}
+	return *build
+}
+
+// VersionHandler serves BuildInfo as json, e.g. mounted at "/_version"
+func (as *VAPI) VersionHandler(ctx *fasthttp.RequestCtx) {
+	body, err := json.Marshal(as.BuildInfo())
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// writeBuildHeader sends BuildHeader when the build is set
+func writeBuildHeader(ctx *fasthttp.RequestCtx, build *BuildInfo) {
+	if build == nil {
+		return
+	}
+	value := build.Version
+	if build.Commit != "" {
+		value += " " + build.Commit
+	}
+	ctx.Response.Header.Set(BuildHeader, value)
+}
+
+// UtilService is an optional service for smoke tests, client SDK validation
+// and latency measurement, register it with
+//
+//	as.RegisterService(new(vapi.UtilService), "util")
+type UtilService struct{}
+
+// UtilArgs are args of UtilService methods taking none
+type UtilArgs struct{}
+
+// PingReply is the reply of UtilService.Ping
+type PingReply struct {
+	Message string `json:"message"`
+	// Received is when the server got the call, in unix nanoseconds
+	Received int64 `json:"received"`
+}
+
+// TimeReply is the reply of UtilService.Time
+type TimeReply struct {
+	Unix     int64  `json:"unix"`
+	UnixNano int64  `json:"unix_nano"`
+	RFC3339  string `json:"rfc3339"`
+	Zone     string `json:"zone"`
+}
+
+// Ping answers "pong", the cheapest call to measure round trips
+func (s *UtilService) Ping(ctx *fasthttp.RequestCtx, args *UtilArgs, reply *PingReply) error {
+	reply.Message = "pong"
+	reply.Received = ctx.Time().UnixNano()
+	return nil
+}
+
+// Echo replies with the args as sent, to validate client encoding
+func (s *UtilService) Echo(ctx *fasthttp.RequestCtx, args *json.RawMessage, reply *json.RawMessage) error {
+	if len(*args) == 0 {
+		*reply = json.RawMessage("null")
+		return nil
+	}
+	// args point into the request body
+	*reply = append(json.RawMessage(nil), *args...)
+	return nil
+}
+
+// Time replies with the server clock, to detect client clock skew
+func (s *UtilService) Time(ctx *fasthttp.RequestCtx, args *UtilArgs, reply *TimeReply) error {
+	now := time.Now()
+	zone, _ := now.Zone()
+	*reply = TimeReply{Unix: now.Unix(), UnixNano: now.UnixNano(), RFC3339: now.Format(time.RFC3339Nano), Zone: zone}
+	return nil
+}
+
+// Version replies with the build of the server, see SetBuildInfo
+func (s *UtilService) Version(ctx *fasthttp.RequestCtx, args *UtilArgs, reply *BuildInfo) error {
+	if as, ok := ctx.UserValue(serverUserValue).(*VAPI); ok {
+		*reply = as.BuildInfo()
+		return nil
+	}
+	*reply = ReadBuildInfo()
+	return nil
+}
+
+// UnmarshalJSON implements Unmarshaler, any args are accepted
+func (args *UtilArgs) UnmarshalJSON(data []byte) error {
+	return nil
+}
+
+// MarshalJSON implements Marshaler
+func (args UtilArgs) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+// MarshalJSON implements Marshaler
+func (reply PingReply) MarshalJSON() ([]byte, error) {
+	type plain PingReply
+	return json.Marshal(plain(reply))
+}
+
+// UnmarshalJSON implements Unmarshaler
+func (reply *PingReply) UnmarshalJSON(data []byte) error {
+	type plain PingReply
+	return json.Unmarshal(data, (*plain)(reply))
+}
+
+// MarshalJSON implements Marshaler
+func (reply TimeReply) MarshalJSON() ([]byte, error) {
+	type plain TimeReply
+	return json.Marshal(plain(reply))
+}
+
+// UnmarshalJSON implements Unmarshaler
+func (reply *TimeReply) UnmarshalJSON(data []byte) error {
+	type plain TimeReply
+	return json.Unmarshal(data, (*plain)(reply))
+}
+
+// MarshalJSON implements Marshaler
+func (info BuildInfo) MarshalJSON() ([]byte, error) {
+	type plain BuildInfo
+	return json.Marshal(plain(info))
+}
+
+// UnmarshalJSON implements Unmarshaler
+func (info *BuildInfo) UnmarshalJSON(data []byte) error {
+	type plain BuildInfo
+	return json.Unmarshal(data, (*plain)(info))
+}
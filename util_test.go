@@ -0,0 +1,64 @@
+package vapi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestUtilService(t *testing.T) {
+	as := NewServer()
+	report, err := as.RegisterServiceReport(new(UtilService), "util")
+	if err != nil || len(report.Registered) != 4 {
+		t.Fatal(fmt.Sprintf("util service must register all methods: %v %s", err, report))
+	}
+
+	if _, body, _ := as.callLocal("util.Ping", nil); !strings.Contains(string(body), `"message":"pong"`) {
+		t.Error(fmt.Sprintf("unexpected ping reply: %s", body))
+	}
+	if _, body, _ := as.callLocal("util.Echo", []byte(`{"a":[1,"x"]}`)); string(body) != `{"response":{"a":[1,"x"]}}` {
+		t.Error(fmt.Sprintf("unexpected echo reply: %s", body))
+	}
+	if _, body, _ := as.callLocal("util.Time", []byte(`{}`)); !strings.Contains(string(body), `"unix_nano":`) {
+		t.Error(fmt.Sprintf("unexpected time reply: %s", body))
+	}
+
+	BuildVersion, BuildCommit = "1.2.3", "abc"
+	defer func() { BuildVersion, BuildCommit = "", "" }()
+	if _, body, _ := as.callLocal("util.Version", nil); !strings.Contains(string(body), `"version":"1.2.3","commit":"abc"`) {
+		t.Error(fmt.Sprintf("unexpected version reply: %s", body))
+	}
+}
+
+func TestVAPI_SetBuildInfo(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(UtilService), "util")
+
+	var response *fasthttp.RequestCtx
+	capture := func(ctx *fasthttp.RequestCtx) { response = ctx }
+	as.callLocalWith("util.Ping", nil, nil, capture)
+	if header := response.Response.Header.Peek(BuildHeader); len(header) != 0 {
+		t.Error(fmt.Sprintf("build header must be opt-in: %s", header))
+	}
+
+	as.SetBuildInfo(BuildInfo{Version: "2.0.1", Commit: "3f2a9c1", Time: "2026-10-01T10:00:00Z"})
+	_, body, _ := as.callLocalWith("util.Version", nil, nil, capture)
+	if header := string(response.Response.Header.Peek(BuildHeader)); header != "2.0.1 3f2a9c1" {
+		t.Error(fmt.Sprintf("unexpected build header: %s", header))
+	}
+	if !strings.Contains(string(body), `"version":"2.0.1","commit":"3f2a9c1","time":"2026-10-01T10:00:00Z"`) {
+		t.Error(fmt.Sprintf("unexpected version reply: %s", body))
+	}
+	as.callLocalWith("util.Missing", nil, nil, capture)
+	if len(response.Response.Header.Peek(BuildHeader)) == 0 {
+		t.Error("unknown methods must get the build header too")
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	as.VersionHandler(ctx)
+	if !strings.Contains(string(ctx.Response.Body()), `"version":"2.0.1"`) {
+		t.Error(fmt.Sprintf("unexpected version endpoint reply: %s", ctx.Response.Body()))
+	}
+}
@@ -0,0 +1,167 @@
+package vapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var typeOfDecimal = reflect.TypeOf(Decimal{})
+
+// ValidationError describes decoded args which don't satisfy declared constraints
+type ValidationError struct {
+	// Field is the path of the invalid field, e.g. "Items[2].Price"
+	Field string
+	// Message describes the violated constraint
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return "vapi: " + e.Message
+	}
+	return fmt.Sprintf("vapi: invalid field %s: %s", e.Field, e.Message)
+}
+
+// validateArgs checks constraints declared with struct tags on decoded args:
+//
+//	Price vapi.Decimal `json:"price" decimal:"precision=12,scale=2"`
+//	Status string `json:"status" enum:"active|blocked"`
+func validateArgs(v reflect.Value) error {
+	if !needsValidation(v.Type()) {
+		return nil
+	}
+	return validateValue(v, "")
+}
+
+// validatedTypes caches whether a type declares any constraints
+var validatedTypes sync.Map
+
+// validationTags are the struct tags declaring constraints
+var validationTags = []string{"decimal", "enum"}
+
+// needsValidation reports whether t or any type reachable from it has constrained fields
+func needsValidation(t reflect.Type) bool {
+	if cached, ok := validatedTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	result := hasConstraints(t, map[reflect.Type]bool{})
+	validatedTypes.Store(t, result)
+	return result
+}
+
+// hasConstraints looks for constraint tags in t, visited stops recursive types
+func hasConstraints(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t.Kind() != reflect.Interface && t.Implements(typeOfEnum) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasConstraints(t.Elem(), visited)
+	case reflect.Struct:
+		if visited[t] {
+			return false
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			for _, tag := range validationTags {
+				if _, ok := field.Tag.Lookup(tag); ok {
+					return true
+				}
+			}
+			if hasConstraints(field.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateValue walks v checking field constraints, path is the field path of v
+func validateValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateValue(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		if t == typeOfDecimal || t == typeOfTime || t == typeOfURL {
+			return nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			fv := v.Field(i)
+			if err := validateField(field, fv, fieldPath); err != nil {
+				return err
+			}
+			if err := validateValue(fv, fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField checks tag constraints of a single field
+func validateField(field reflect.StructField, v reflect.Value, path string) error {
+	if allowed := enumValues(field); allowed != nil && (!v.IsZero() || isRequiredField(field)) {
+		if err := validateEnum(v, allowed, path); err != nil {
+			return err
+		}
+	}
+	if tag, ok := field.Tag.Lookup("decimal"); ok && indirectType(field.Type) == typeOfDecimal {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		return validateDecimal(v.Interface().(Decimal), tag, path)
+	}
+	return nil
+}
+
+// isRequiredField reports whether field is tagged `openapi:"required"`
+func isRequiredField(field reflect.StructField) bool {
+	_, ok := parseTagOptions(field.Tag.Get("openapi"))["required"]
+	return ok
+}
+
+// validateDecimal checks precision and scale limits of decimal like SQL numeric(precision, scale) does
+func validateDecimal(d Decimal, tag, path string) error {
+	options := parseTagOptions(tag)
+
+	scale, scaleErr := strconv.Atoi(options["scale"])
+	if scaleErr == nil && int(d.Scale()) > scale {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("at most %d digits after the decimal point allowed", scale)}
+	}
+
+	precision, err := strconv.Atoi(options["precision"])
+	if err != nil {
+		return nil
+	}
+	if scaleErr != nil {
+		scale = int(d.Scale())
+	}
+	if integerDigits := d.Precision() - int(d.Scale()); integerDigits > precision-scale {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("at most %d digits before the decimal point allowed", precision-scale)}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package vapi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultVerbs are reported in Allow header of methods without configured verbs
+var defaultVerbs = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// SetMethodVerbs restricts http methods the method may be called with, e.g. "GET", "POST".
+// Methods accept any verb by default.
+//
+// GET methods also answer HEAD with headers only and every method answers
+// OPTIONS with the Allow header. Other verbs get 405 Method Not Allowed.
+// Args of GET, HEAD and DELETE calls without body are read from the ArgsParam query parameter.
+func (as *VAPI) SetMethodVerbs(method string, verbs ...string) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+
+	normalized := make([]string, 0, len(verbs))
+	for _, verb := range verbs {
+		verb = strings.ToUpper(strings.TrimSpace(verb))
+		if verb == "" || verb == "HEAD" || verb == "OPTIONS" {
+			return fmt.Errorf("vapi: verb %q can't be configured for %q", verb, method)
+		}
+		normalized = append(normalized, verb)
+	}
+
+	as.mutex.Lock()
+	methodSpec.verbs = normalized
+	as.mutex.Unlock()
+	return nil
+}
+
+// MethodOverrideHeader is the request header carrying the verb a POST request is sent for
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// SetMethodOverride lets clients behind proxies which pass only GET and POST call methods
+// restricted to other verbs: POST requests with MethodOverrideHeader or "_method" query or
+// form field are handled as sent with that verb. Disabled by default.
+func (as *VAPI) SetMethodOverride(enabled bool) {
+	as.mutex.Lock()
+	as.methodOverride = enabled
+	as.mutex.Unlock()
+}
+
+// requestVerb returns http method of the call taking overrides into account when they are enabled
+func requestVerb(ctx *fasthttp.RequestCtx, methodOverride bool) string {
+	if !methodOverride || !ctx.IsPost() {
+		return string(ctx.Method())
+	}
+
+	override := ctx.Request.Header.Peek(MethodOverrideHeader)
+	if len(override) == 0 {
+		override = ctx.QueryArgs().Peek("_method")
+	}
+	if len(override) == 0 && bytes.HasPrefix(ctx.Request.Header.ContentType(), []byte("application/x-www-form-urlencoded")) {
+		override = ctx.PostArgs().Peek("_method")
+	}
+	if len(override) == 0 {
+		return "POST"
+	}
+	return strings.ToUpper(string(override))
+}
+
+// allowHeader returns Allow header value of method accepting verbs
+func allowHeader(verbs []string) string {
+	if len(verbs) == 0 {
+		verbs = defaultVerbs
+	}
+	allowed := make([]string, 0, len(verbs)+2)
+	for _, verb := range verbs {
+		allowed = append(allowed, verb)
+		if verb == "GET" {
+			allowed = append(allowed, "HEAD")
+		}
+	}
+	return strings.Join(append(allowed, "OPTIONS"), ", ")
+}
+
+// checkVerb answers OPTIONS and rejects disallowed verbs, returns false when the call must not proceed
+func (as *VAPI) checkVerb(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse, methodSpec *serviceMethod, verb string) bool {
+	as.mutex.RLock()
+	verbs := methodSpec.verbs
+	as.mutex.RUnlock()
+
+	if verb == "OPTIONS" {
+		ctx.Response.Header.Set("Allow", allowHeader(verbs))
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+		return false
+	}
+	if len(verbs) == 0 {
+		return true
+	}
+	if verb == "HEAD" {
+		verb = "GET"
+	}
+	for _, allowed := range verbs {
+		if allowed == verb {
+			return true
+		}
+	}
+
+	ctx.Response.Header.Set("Allow", allowHeader(verbs))
+	as.writeError(ctx, srvResponse, fasthttp.StatusMethodNotAllowed, fmt.Errorf("vapi: method %s is not allowed", verb))
+	return false
+}
+
+// requestArgs returns json encoded args of the call: the body or, for bodiless verbs, the ArgsParam query parameter
+func requestArgs(ctx *fasthttp.RequestCtx, verb string) []byte {
+	body := ctx.Request.Body()
+	if len(body) > 0 || (verb != "GET" && verb != "HEAD" && verb != "DELETE") {
+		return body
+	}
+	if encoded := ctx.QueryArgs().Peek(ArgsParam); len(encoded) > 0 {
+		return encoded
+	}
+	return []byte("{}")
+}
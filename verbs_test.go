@@ -0,0 +1,86 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetMethodVerbs(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodVerbs("demo.Test", "get", "POST"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodVerbs("demo.Test", "HEAD"); err == nil {
+		t.Error("HEAD must not be configurable")
+	}
+
+	call := func(verb, uri string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(verb)
+		ctx.Request.SetRequestURI(uri)
+		as.CallAPI(ctx, "demo.Test")
+		return ctx
+	}
+
+	ctx := call("OPTIONS", "/demo.Test")
+	if ctx.Response.StatusCode() != fasthttp.StatusNoContent || string(ctx.Response.Header.Peek("Allow")) != "GET, HEAD, POST, OPTIONS" {
+		t.Error(fmt.Sprintf("wrong OPTIONS response: %d %s", ctx.Response.StatusCode(), ctx.Response.Header.Peek("Allow")))
+	}
+
+	ctx = call("DELETE", "/demo.Test")
+	if ctx.Response.StatusCode() != fasthttp.StatusMethodNotAllowed || len(ctx.Response.Header.Peek("Allow")) == 0 {
+		t.Error(fmt.Sprintf("wrong DELETE response: %d", ctx.Response.StatusCode()))
+	}
+
+	ctx = call("GET", `/demo.Test?args={"id":"7"}`)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != `{"response":{"id":"7"}}` {
+		t.Error(fmt.Sprintf("wrong GET response: %d %s", ctx.Response.StatusCode(), ctx.Response.Body()))
+	}
+
+	ctx = call("HEAD", "/demo.Test")
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Error(fmt.Sprintf("wrong HEAD response: %d", ctx.Response.StatusCode()))
+	}
+}
+
+func TestVAPI_SetMethodOverride(t *testing.T) {
+	as := NewServer()
+	if err := as.RegisterService(new(DemoAPI), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.SetMethodVerbs("demo.Test", "DELETE"); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(uri string, headers ...string) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetRequestURI(uri)
+		for i := 0; i < len(headers); i += 2 {
+			ctx.Request.Header.Set(headers[i], headers[i+1])
+		}
+		ctx.Request.SetBody([]byte(`{"id":"1"}`))
+		as.CallAPI(ctx, "demo.Test")
+		return ctx.Response.StatusCode()
+	}
+
+	if status := call("/demo.Test", MethodOverrideHeader, "DELETE"); status != fasthttp.StatusMethodNotAllowed {
+		t.Error(fmt.Sprintf("override must be disabled by default, got %d", status))
+	}
+
+	as.SetMethodOverride(true)
+	if status := call("/demo.Test", MethodOverrideHeader, "delete"); status != fasthttp.StatusOK {
+		t.Error(fmt.Sprintf("wrong status with override header: %d", status))
+	}
+	if status := call("/demo.Test?_method=DELETE"); status != fasthttp.StatusOK {
+		t.Error(fmt.Sprintf("wrong status with override field: %d", status))
+	}
+	if status := call("/demo.Test"); status != fasthttp.StatusMethodNotAllowed {
+		t.Error(fmt.Sprintf("wrong status without override: %d", status))
+	}
+}
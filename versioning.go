@@ -0,0 +1,88 @@
+package vapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// APIVersionHeader is the request header pinning the client to an older reply
+// shape, the served version is echoed in the response header of the same name
+const APIVersionHeader = "X-Api-Version"
+
+// ReplyDowngrade converts a reply from the shape of the next newer version to
+// the shape of Version. Paths are dotted field names as in ArgsTransform.
+type ReplyDowngrade struct {
+	// Version is the version the downgrade produces
+	Version string
+	// Rename moves fields from new to old paths
+	Rename map[string]string
+	// Remove drops fields unknown to Version
+	Remove []string
+	// Rewrite, when set, runs last with the decoded reply object
+	Rewrite func(reply map[string]interface{}) error
+}
+
+// SetMethodReplyVersions sets downgrades of the method reply, ordered from the
+// newest version to the oldest, none removes them. The method always produces
+// the latest shape, calls with APIVersionHeader naming one of the versions get
+// the reply passed through every downgrade down to that version. Calls with
+// unknown or no version get the latest shape.
+func (as *VAPI) SetMethodReplyVersions(method string, downgrades ...ReplyDowngrade) error {
+	methodSpec, err := as.get(method)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, downgrade := range downgrades {
+		if downgrade.Version == "" || seen[downgrade.Version] {
+			return fmt.Errorf("vapi: reply versions of %s must be unique and not empty", method)
+		}
+		seen[downgrade.Version] = true
+	}
+
+	as.mutex.Lock()
+	methodSpec.downgrades = downgrades
+	as.mutex.Unlock()
+	return nil
+}
+
+// downgradeReply converts encoded reply to the version requested by the caller
+func (as *VAPI) downgradeReply(ctx *fasthttp.RequestCtx, methodSpec *serviceMethod, reply []byte) ([]byte, error) {
+	as.mutex.RLock()
+	downgrades := methodSpec.downgrades
+	as.mutex.RUnlock()
+	version := string(ctx.Request.Header.Peek(APIVersionHeader))
+	if len(downgrades) == 0 || version == "" {
+		return reply, nil
+	}
+
+	last := -1
+	for i, downgrade := range downgrades {
+		if downgrade.Version == version {
+			last = i
+			break
+		}
+	}
+	if last < 0 || !bytes.HasPrefix(bytes.TrimSpace(reply), []byte("{")) {
+		return reply, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(reply))
+	decoder.UseNumber()
+	object := map[string]interface{}{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	for _, downgrade := range downgrades[:last+1] {
+		transform := ArgsTransform{Rename: downgrade.Rename, Remove: downgrade.Remove, Rewrite: downgrade.Rewrite}
+		if err := transform.apply(object); err != nil {
+			return nil, fmt.Errorf("vapi: can't downgrade reply of %s to %s: %s", methodSpec.name, downgrade.Version, err.Error())
+		}
+	}
+	ctx.Response.Header.Set(APIVersionHeader, version)
+	MarkDeprecated(ctx, methodSpec.name+" reply version "+version)
+	return json.Marshal(object)
+}
@@ -0,0 +1,48 @@
+package vapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestVAPI_SetMethodReplyVersions(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+
+	if err := as.SetMethodReplyVersions("demo.Test", ReplyDowngrade{Version: "1"}, ReplyDowngrade{Version: "1"}); err == nil {
+		t.Error("duplicate versions must fail")
+	}
+
+	err := as.SetMethodReplyVersions("demo.Test",
+		ReplyDowngrade{Version: "2", Rename: map[string]string{"id": "identifier"}},
+		ReplyDowngrade{Version: "1", Remove: []string{"ttt"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(version string) (string, string) {
+		var response *fasthttp.RequestCtx
+		_, body, _ := as.callLocalWith("demo.Test", []byte(`{"id":"5","ttt":"x"}`), nil, func(ctx *fasthttp.RequestCtx) {
+			response = ctx
+			if version != "" {
+				ctx.Request.Header.Set(APIVersionHeader, version)
+			}
+		})
+		return string(body), string(response.Response.Header.Peek(APIVersionHeader))
+	}
+
+	tests := []struct{ version, body, served string }{
+		{"", `{"response":{"id":"5","ttt":"x"}}`, ""},
+		{"3", `{"response":{"id":"5","ttt":"x"}}`, ""},
+		{"2", `{"response":{"identifier":"5","ttt":"x"}}`, "2"},
+		{"1", `{"response":{"identifier":"5"}}`, "1"},
+	}
+	for _, test := range tests {
+		if body, served := call(test.version); body != test.body || served != test.served {
+			t.Error(fmt.Sprintf("version %q: %s %q", test.version, body, served))
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package vapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// EventWatchdogTripped is emitted when a watchdog threshold is crossed with WatchdogObservation payload
+const EventWatchdogTripped = "watchdog.tripped"
+
+var errShedding = errors.New("vapi: server is overloaded, shedding load")
+
+// WatchdogObservation is a sample of process health taken by the watchdog
+type WatchdogObservation struct {
+	Time       time.Time `json:"time"`
+	HeapBytes  uint64    `json:"heap_bytes"`
+	Goroutines int       `json:"goroutines"`
+	InFlight   int64     `json:"in_flight"`
+	// Tripped lists crossed thresholds: "heap", "goroutines" or "in_flight"
+	Tripped []string `json:"tripped,omitempty"`
+	// Trips counts transitions into the tripped state since start
+	Trips uint64 `json:"trips"`
+	// Shedding reports whether calls are rejected by ShedLoad
+	Shedding bool `json:"shedding"`
+}
+
+// WatchdogAction runs when the watchdog trips, with the observation that tripped it
+type WatchdogAction func(as *VAPI, observation WatchdogObservation)
+
+// Watchdog monitors process health and runs actions when thresholds are crossed.
+// Zero thresholds aren't checked.
+type Watchdog struct {
+	// Interval between observations, 1s when zero
+	Interval time.Duration
+	// MaxHeap is the limit of allocated heap bytes
+	MaxHeap uint64
+	// MaxGoroutines is the limit of running goroutines
+	MaxGoroutines int
+	// MaxInFlight is the limit of calls being served
+	MaxInFlight int64
+	// Actions run once when the watchdog trips, they run again only after it recovered
+	Actions []WatchdogAction
+}
+
+// watchdogState is the last observation of the running watchdog
+type watchdogState struct {
+	mutex       sync.Mutex
+	observation WatchdogObservation
+}
+
+// RunWatchdog observes the process every interval until ctx is done. Actions
+// run on the transition into the tripped state; load shedding set by ShedLoad
+// stops when all thresholds are met again.
+func (as *VAPI) RunWatchdog(ctx context.Context, watchdog Watchdog) error {
+	interval := watchdog.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	state := &watchdogState{}
+	as.mutex.Lock()
+	as.watchdog = state
+	as.mutex.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		observation := as.observe(watchdog)
+		state.mutex.Lock()
+		tripped := len(state.observation.Tripped) == 0 && len(observation.Tripped) > 0
+		observation.Trips = state.observation.Trips
+		if tripped {
+			observation.Trips++
+		}
+		state.observation = observation
+		state.mutex.Unlock()
+
+		if len(observation.Tripped) == 0 {
+			atomic.StoreInt32(&as.shedding, 0)
+		}
+		if tripped {
+			for _, action := range watchdog.Actions {
+				action(as, observation)
+			}
+			if as.Events().HasSubscribers(EventWatchdogTripped) {
+				as.Events().Publish(EventWatchdogTripped, observation)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe samples process health and checks thresholds
+func (as *VAPI) observe(watchdog Watchdog) WatchdogObservation {
+	memStats := runtime.MemStats{}
+	runtime.ReadMemStats(&memStats)
+	observation := WatchdogObservation{
+		Time:       time.Now(),
+		HeapBytes:  memStats.HeapAlloc,
+		Goroutines: runtime.NumGoroutine(),
+		InFlight:   as.InFlight(),
+	}
+	if watchdog.MaxHeap > 0 && observation.HeapBytes > watchdog.MaxHeap {
+		observation.Tripped = append(observation.Tripped, "heap")
+	}
+	if watchdog.MaxGoroutines > 0 && observation.Goroutines > watchdog.MaxGoroutines {
+		observation.Tripped = append(observation.Tripped, "goroutines")
+	}
+	if watchdog.MaxInFlight > 0 && observation.InFlight > watchdog.MaxInFlight {
+		observation.Tripped = append(observation.Tripped, "in_flight")
+	}
+	return observation
+}
+
+// WatchdogObservation returns the last observation of the running watchdog
+func (as *VAPI) WatchdogObservation() WatchdogObservation {
+	as.mutex.RLock()
+	state := as.watchdog
+	as.mutex.RUnlock()
+	if state == nil {
+		return WatchdogObservation{}
+	}
+	state.mutex.Lock()
+	observation := state.observation
+	state.mutex.Unlock()
+	observation.Shedding = atomic.LoadInt32(&as.shedding) == 1
+	return observation
+}
+
+// WatchdogHealthy returns an error while the watchdog is tripped, e.g. to pass to RunSystemdWatchdog
+func (as *VAPI) WatchdogHealthy() error {
+	if tripped := as.WatchdogObservation().Tripped; len(tripped) > 0 {
+		return fmt.Errorf("vapi: watchdog tripped on %s", strings.Join(tripped, ", "))
+	}
+	return nil
+}
+
+// WatchdogHandler serves the last watchdog observation as json, e.g. mounted at "/_watchdog"
+func (as *VAPI) WatchdogHandler(ctx *fasthttp.RequestCtx) {
+	body, err := json.Marshal(as.WatchdogObservation())
+	if err != nil {
+		writeHandlerError(ctx, fasthttp.StatusInternalServerError, err)
+		return
+	}
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// LogObservation is a WatchdogAction logging the observation with the standard logger
+func LogObservation(as *VAPI, observation WatchdogObservation) {
+	log.Printf("vapi: watchdog tripped on %s: heap=%d goroutines=%d in_flight=%d",
+		strings.Join(observation.Tripped, ", "), observation.HeapBytes, observation.Goroutines, observation.InFlight)
+}
+
+// DumpGoroutines returns a WatchdogAction writing stacks of all goroutines to w
+func DumpGoroutines(w io.Writer) WatchdogAction {
+	return func(as *VAPI, observation WatchdogObservation) {
+		pprof.Lookup("goroutine").WriteTo(w, 1)
+	}
+}
+
+// ShedLoad is a WatchdogAction rejecting new calls with 503 until the watchdog recovers
+func ShedLoad(as *VAPI, observation WatchdogObservation) {
+	atomic.StoreInt32(&as.shedding, 1)
+}
+
+// RequestRestart is a WatchdogAction interrupting the process, so Run shuts down
+// gracefully and the supervisor (systemd, kubernetes) starts it again
+func RequestRestart(as *VAPI, observation WatchdogObservation) {
+	if process, err := os.FindProcess(os.Getpid()); err == nil {
+		process.Signal(os.Interrupt)
+	}
+}
+
+// shedLoad rejects the call while the watchdog sheds load, returns false when the call must not proceed
+func (as *VAPI) shedLoad(ctx *fasthttp.RequestCtx, srvResponse *ServerResponse) bool {
+	if atomic.LoadInt32(&as.shedding) == 0 {
+		return true
+	}
+	ctx.Response.Header.Set("Retry-After", "1")
+	as.writeError(ctx, srvResponse, fasthttp.StatusServiceUnavailable, errShedding)
+	return false
+}
@@ -0,0 +1,54 @@
+package vapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVAPI_RunWatchdog(t *testing.T) {
+	as := NewServer()
+	as.RegisterService(new(DemoAPI), "demo")
+
+	dump := &bytes.Buffer{}
+	trips := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := as.RunWatchdog(ctx, Watchdog{
+		Interval:      5 * time.Millisecond,
+		MaxGoroutines: 1,
+		Actions: []WatchdogAction{ShedLoad, DumpGoroutines(dump), func(as *VAPI, observation WatchdogObservation) {
+			trips++
+		}},
+	})
+	if err != context.DeadlineExceeded {
+		t.Error(err)
+	}
+
+	observation := as.WatchdogObservation()
+	if trips != 1 || observation.Trips != 1 || !observation.Shedding || observation.Tripped[0] != "goroutines" {
+		t.Error(fmt.Sprintf("watchdog must trip once: %d %+v", trips, observation))
+	}
+	if !strings.Contains(dump.String(), "goroutine profile") {
+		t.Error("goroutines must be dumped")
+	}
+	if as.WatchdogHealthy() == nil {
+		t.Error("tripped watchdog must be unhealthy")
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 503 {
+		t.Error(fmt.Sprintf("calls must be shed, got %d", status))
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	as.RunWatchdog(ctx, Watchdog{Interval: 5 * time.Millisecond, MaxGoroutines: 1 << 20})
+	if as.WatchdogHealthy() != nil || as.WatchdogObservation().Shedding {
+		t.Error("recovered watchdog must stop shedding")
+	}
+	if status, _, _ := as.callLocal("demo.Test", []byte(`{}`)); status != 200 {
+		t.Error(fmt.Sprintf("calls must pass after recovery, got %d", status))
+	}
+}
@@ -0,0 +1,169 @@
+package vapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWorkerLimit is the number of concurrent background tasks of a new server
+const DefaultWorkerLimit = 64
+
+// ErrWorkersDraining is returned by Go after draining has started
+var ErrWorkersDraining = errors.New("vapi: workers are draining, task rejected")
+
+// WorkerPool runs background tasks with bounded concurrency, panic capture and draining.
+type WorkerPool struct {
+	// OnPanic receives values recovered from panicking tasks, by default they are logged
+	OnPanic func(recovered interface{}, stack []byte)
+
+	slots   chan struct{}
+	running int64 // accessed atomically
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// a WaitGroup can't be used here: Go may add tasks while Drain waits
+	mutex    sync.Mutex
+	active   int           // started tasks not finished yet
+	draining bool          // Drain was called, new tasks are rejected
+	idle     chan struct{} // closed when draining and no task is active
+}
+
+// NewWorkerPool returns pool running up to limit tasks at once
+func NewWorkerPool(limit int) *WorkerPool {
+	if limit <= 0 {
+		limit = DefaultWorkerLimit
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WorkerPool{
+		OnPanic: func(recovered interface{}, stack []byte) {
+			log.Printf("vapi: background task panic: %v\n%s", recovered, stack)
+		},
+		slots:  make(chan struct{}, limit),
+		ctx:    ctx,
+		cancel: cancel,
+		idle:   make(chan struct{}),
+	}
+}
+
+// Go starts task once a slot is free, waiting no longer than ctx allows.
+//
+// The task gets the pool context, which is cancelled when Drain times out, never
+// the context of the request it was started from: fasthttp.RequestCtx is reused
+// after the handler returns.
+func (wp *WorkerPool) Go(ctx context.Context, task func(ctx context.Context)) error {
+	if wp.isDraining() {
+		return ErrWorkersDraining
+	}
+
+	select {
+	case wp.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// Drain may have started while waiting for the slot
+	wp.mutex.Lock()
+	if wp.draining {
+		wp.mutex.Unlock()
+		<-wp.slots
+		return ErrWorkersDraining
+	}
+	wp.active++
+	wp.mutex.Unlock()
+
+	atomic.AddInt64(&wp.running, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil && wp.OnPanic != nil {
+				wp.OnPanic(recovered, debug.Stack())
+			}
+			atomic.AddInt64(&wp.running, -1)
+			<-wp.slots
+			wp.finish()
+		}()
+		task(wp.ctx)
+	}()
+	return nil
+}
+
+// isDraining reports whether Drain was called
+func (wp *WorkerPool) isDraining() bool {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+	return wp.draining
+}
+
+// finish accounts the end of a task and signals Drain when it was the last one
+func (wp *WorkerPool) finish() {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+	wp.active--
+	if wp.draining && wp.active == 0 {
+		close(wp.idle)
+	}
+}
+
+// Running returns the number of running tasks
+func (wp *WorkerPool) Running() int64 {
+	return atomic.LoadInt64(&wp.running)
+}
+
+// Drain rejects new tasks and waits for running ones. Contexts of tasks still running
+// after timeout are cancelled and false is returned. It is safe to call concurrently
+// with Go, tasks started before draining are waited for and later ones rejected.
+func (wp *WorkerPool) Drain(timeout time.Duration) bool {
+	wp.mutex.Lock()
+	if !wp.draining {
+		wp.draining = true
+		if wp.active == 0 {
+			close(wp.idle)
+		}
+	}
+	wp.mutex.Unlock()
+
+	select {
+	case <-wp.idle:
+		return true
+	case <-time.After(timeout):
+		wp.cancel()
+		return false
+	}
+}
+
+// SetWorkerLimit replaces the background worker pool with one running up to limit tasks.
+// Call it before the server starts.
+func (as *VAPI) SetWorkerLimit(limit int) {
+	as.mutex.Lock()
+	as.workers = NewWorkerPool(limit)
+	as.mutex.Unlock()
+}
+
+// Workers returns the background worker pool of the server
+func (as *VAPI) Workers() *WorkerPool {
+	as.mutex.RLock()
+	workers := as.workers
+	as.mutex.RUnlock()
+	if workers != nil {
+		return workers
+	}
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.workers == nil {
+		as.workers = NewWorkerPool(DefaultWorkerLimit)
+	}
+	return as.workers
+}
+
+// Go runs task in the background worker pool of the server, see WorkerPool.Go.
+// Use it instead of bare go statements in methods, so tasks are bounded and drained on shutdown:
+//
+//	as.Go(ctx, func(ctx context.Context) { notify(ctx, order) })
+func (as *VAPI) Go(ctx context.Context, task func(ctx context.Context)) error {
+	return as.Workers().Go(ctx, task)
+}
@@ -0,0 +1,97 @@
+package vapi
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	wp := NewWorkerPool(2)
+	var panics int32
+	wp.OnPanic = func(recovered interface{}, stack []byte) {
+		atomic.AddInt32(&panics, 1)
+	}
+
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		if err := wp.Go(context.Background(), func(ctx context.Context) { <-release }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := wp.Go(ctx, func(ctx context.Context) {}); err != context.DeadlineExceeded {
+		t.Error(fmt.Sprintf("full pool must wait for a slot, got %v", err))
+	}
+
+	close(release)
+	if err := wp.Go(context.Background(), func(ctx context.Context) { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+
+	if !wp.Drain(time.Second) || wp.Running() != 0 || atomic.LoadInt32(&panics) != 1 {
+		t.Error(fmt.Sprintf("wrong drain: running %d, panics %d", wp.Running(), panics))
+	}
+	if err := wp.Go(context.Background(), func(ctx context.Context) {}); err != ErrWorkersDraining {
+		t.Error(fmt.Sprintf("drained pool must reject tasks, got %v", err))
+	}
+}
+
+func TestWorkerPool_DrainTimeout(t *testing.T) {
+	wp := NewWorkerPool(1)
+	cancelled := make(chan struct{})
+	wp.Go(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	if wp.Drain(10 * time.Millisecond) {
+		t.Error("drain must time out")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("task context must be cancelled after drain timeout")
+	}
+}
+
+func TestWorkerPool_GoDuringDrain(t *testing.T) {
+	wp := NewWorkerPool(4)
+	var started, finished int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := wp.Go(context.Background(), func(ctx context.Context) {
+					atomic.AddInt64(&finished, 1)
+				})
+				if err == ErrWorkersDraining {
+					return
+				}
+				atomic.AddInt64(&started, 1)
+			}
+		}()
+	}
+
+	for atomic.LoadInt64(&started) < 100 {
+		runtime.Gosched()
+	}
+	if !wp.Drain(time.Minute) {
+		t.Fatal("drain must finish")
+	}
+	// tasks accepted before Drain returned have finished
+	if done := atomic.LoadInt64(&finished); done < atomic.LoadInt64(&started) {
+		wg.Wait()
+		t.Error(fmt.Sprintf("drain returned with %d of %d tasks finished", done, atomic.LoadInt64(&started)))
+	}
+	wg.Wait()
+}